@@ -17,15 +17,21 @@ package spannerdriver
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 func TestCommitAborted(t *testing.T) {
@@ -79,6 +85,269 @@ func TestCommitAbortedWithInternalRetriesDisabled(t *testing.T) {
 	}
 }
 
+func TestUpdateAbortedWithInternalRetriesDisabled(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = FALSE"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteSql, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted")},
+	})
+	_, err = tx.ExecContext(ctx, testutil.UpdateBarSetFoo)
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("update error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// All following statements and the commit fail without being sent to
+	// Spanner, as the transaction must be rolled back.
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); spanner.ErrCode(err) != codes.Aborted {
+		t.Fatalf("second update error code mismatch\nGot: %v\nWant: %v", spanner.ErrCode(err), codes.Aborted)
+	}
+	rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		_ = rows.Close()
+	}
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("query error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if err := tx.Commit(); spanner.ErrCode(err) != codes.Aborted {
+		t.Fatalf("commit error code mismatch\nGot: %v\nWant: %v", spanner.ErrCode(err), codes.Aborted)
+	}
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(reqs, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))), 1; g != w {
+		t.Fatalf("execute request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requestsOfType(reqs, reflect.TypeOf(&sppb.CommitRequest{}))), 0; g != w {
+		t.Fatalf("commit request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requestsOfType(reqs, reflect.TypeOf(&sppb.RollbackRequest{}))), 1; g != w {
+		t.Fatalf("rollback request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// The connection can be used for a new transaction.
+	tx, err = conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryAbortedWithInternalRetriesDisabled(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnectionWithParams(t, "retryAbortsInternally=false")
+	defer teardown()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteStreamingSql, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted")},
+	})
+	rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		_ = rows.Close()
+	}
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("query error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); spanner.ErrCode(err) != codes.Aborted {
+		t.Fatalf("update error code mismatch\nGot: %v\nWant: %v", spanner.ErrCode(err), codes.Aborted)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+}
+
+func TestCommitAbortedMaxCommitRetries(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnectionWithParams(t, "maxCommitRetries=2")
+	defer teardown()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{
+			status.Error(codes.Aborted, "Aborted"),
+			status.Error(codes.Aborted, "Aborted"),
+			status.Error(codes.Aborted, "Aborted"),
+		},
+	})
+	err = tx.Commit()
+	// The commit is attempted once and retried twice.
+	var budgetErr *RetryBudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("commit error mismatch\nGot: %v\nWant: %T", err, budgetErr)
+	}
+	if g, w := budgetErr.Attempts, 3; g != w {
+		t.Fatalf("attempts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("commit error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	commitReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitReqs), 3; g != w {
+		t.Fatalf("commit request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestCommitAbortedSetMaxCommitRetries(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET MAX_COMMIT_RETRIES = 1"); err != nil {
+		t.Fatal(err)
+	}
+	var retries int64
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE MAX_COMMIT_RETRIES").Scan(&retries); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := retries, int64(1); g != w {
+		t.Fatalf("max commit retries mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if _, err := conn.ExecContext(ctx, "SET MAX_COMMIT_RETRIES = -1"); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{
+			status.Error(codes.Aborted, "Aborted"),
+			status.Error(codes.Aborted, "Aborted"),
+		},
+	})
+	var budgetErr *RetryBudgetExceededError
+	if err := tx.Commit(); !errors.As(err, &budgetErr) || budgetErr.Attempts != 2 {
+		t.Fatalf("commit error mismatch\nGot: %v\nWant: transaction was aborted 2 times", err)
+	}
+}
+
+func TestCommitAbortedWithRetryBackoff(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	connector, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+		ConnectorConfig{
+			AbortedRetryBackoff: &gax.Backoff{Initial: 50 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 1},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	// The retry delay that is returned by Spanner takes precedence over the
+	// backoff. The backoff uses a random pause between zero and the current
+	// delay, and can therefore not be verified.
+	aborted, err := status.New(codes.Aborted, "Aborted").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(200 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{aborted.Err()},
+	})
+	start := time.Now()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("commit was retried without waiting for the retry delay: %v", elapsed)
+	}
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	commitReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitReqs), 2; g != w {
+		t.Fatalf("commit request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestCommitAbortedWithRetryDelayWithoutBackoff(t *testing.T) {
+	t.Parallel()
+
+	// The connector has no AbortedRetryBackoff, which means that aborted
+	// transactions are retried without delay, unless Spanner returns a retry
+	// delay.
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	aborted, err := status.New(codes.Aborted, "Aborted").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(200 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{aborted.Err()},
+	})
+	start := time.Now()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("commit was retried without waiting for the retry delay: %v", elapsed)
+	}
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	commitReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitReqs), 2; g != w {
+		t.Fatalf("commit request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
 func TestUpdateAborted(t *testing.T) {
 	t.Parallel()
 
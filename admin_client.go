@@ -0,0 +1,184 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AdminClient executes administrative operations for the database of a
+// connection string, such as creating the database and creating backups. The
+// client uses the same endpoint and credentials as a connector for the same
+// connection string. AdminClient is independent of the database/sql driver,
+// and does not open any connections to the database.
+//
+// Example:
+//
+//	admin, err := spannerdriver.NewAdminClient(ctx,
+//		"projects/my-project/instances/my-instance/databases/my-db")
+//	if err != nil {
+//		return err
+//	}
+//	defer admin.Close()
+//	if err := admin.CreateDatabase(ctx, nil); err != nil {
+//		return err
+//	}
+type AdminClient struct {
+	client     *adminapi.DatabaseAdminClient
+	instance   string
+	database   string
+	databaseID string
+	dialect    Dialect
+}
+
+// NewAdminClient creates a new AdminClient for the database in the given
+// connection string. The given client options are added to the options that
+// are derived from the connection string. Connection properties that only
+// apply to the database/sql driver, such as prewarmSessions, are ignored. The
+// client must be closed when it is no longer needed.
+func NewAdminClient(ctx context.Context, dsn string, opts ...option.ClientOption) (*AdminClient, error) {
+	dsnConfig, err := extractConnectorConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connConfig := ConnectorConfig{CredentialsFile: dsnConfig.params["credentials"]}
+	clientOpts, err := clientOptions(dsnConfig.host, dsnConfig.params, &connConfig)
+	if err != nil {
+		return nil, err
+	}
+	dialect, err := parseDialectParam(dsnConfig.params)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts = append(append(clientOpts, option.WithUserAgent(userAgent)), opts...)
+	client, err := adminapi.NewDatabaseAdminClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	instance := fmt.Sprintf("projects/%s/instances/%s", dsnConfig.project, dsnConfig.instance)
+	return &AdminClient{
+		client:     client,
+		instance:   instance,
+		database:   fmt.Sprintf("%s/databases/%s", instance, dsnConfig.database),
+		databaseID: dsnConfig.database,
+		dialect:    dialect,
+	}, nil
+}
+
+// DatabaseAdminClient returns the underlying database admin client. This can
+// be used for operations that are not supported by AdminClient.
+func (a *AdminClient) DatabaseAdminClient() *adminapi.DatabaseAdminClient {
+	return a.client
+}
+
+// Database returns the fully qualified name of the database of the client.
+func (a *AdminClient) Database() string {
+	return a.database
+}
+
+// Close closes the client.
+func (a *AdminClient) Close() error {
+	return a.client.Close()
+}
+
+// CreateDatabase creates the database of the client with the given additional
+// DDL statements, and waits until the database has been created. The database
+// is created with the dialect of the connection string, or with the GoogleSQL
+// dialect if the connection string does not contain a dialect.
+func (a *AdminClient) CreateDatabase(ctx context.Context, extraStatements []string) error {
+	req := createDatabaseRequest(a.instance, a.databaseID, a.dialect)
+	req.ExtraStatements = extraStatements
+	op, err := a.client.CreateDatabase(ctx, req)
+	if err != nil {
+		return err
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
+
+// createDatabaseRequest returns a request for creating a database with the
+// given id and dialect on the given instance. The database id is quoted with
+// the quote character of the dialect.
+func createDatabaseRequest(instance, databaseID string, dialect Dialect) *adminpb.CreateDatabaseRequest {
+	if dialect == PostgreSQL {
+		return &adminpb.CreateDatabaseRequest{
+			Parent:          instance,
+			CreateStatement: fmt.Sprintf(`CREATE DATABASE "%s"`, databaseID),
+			DatabaseDialect: adminpb.DatabaseDialect_POSTGRESQL,
+		}
+	}
+	return &adminpb.CreateDatabaseRequest{
+		Parent:          instance,
+		CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", databaseID),
+	}
+}
+
+// UpdateDatabaseDdl executes the given DDL statements on the database of the
+// client as one batch, and waits until all statements have been executed.
+func (a *AdminClient) UpdateDatabaseDdl(ctx context.Context, statements []string) error {
+	op, err := a.client.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   a.database,
+		Statements: statements,
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+// CreateBackup creates a backup with the given id of the database of the
+// client, and waits until the backup has been created. The backup is deleted
+// by Spanner at the given expire time.
+func (a *AdminClient) CreateBackup(ctx context.Context, backupID string, expireTime time.Time) (*adminpb.Backup, error) {
+	op, err := a.client.CreateBackup(ctx, &adminpb.CreateBackupRequest{
+		Parent:   a.instance,
+		BackupId: backupID,
+		Backup: &adminpb.Backup{
+			Database:   a.database,
+			ExpireTime: timestamppb.New(expireTime),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return op.Wait(ctx)
+}
+
+// ListBackups returns all backups of the database of the client.
+func (a *AdminClient) ListBackups(ctx context.Context) ([]*adminpb.Backup, error) {
+	it := a.client.ListBackups(ctx, &adminpb.ListBackupsRequest{
+		Parent: a.instance,
+		Filter: fmt.Sprintf("database:%s", a.database),
+	})
+	var backups []*adminpb.Backup
+	for {
+		backup, err := it.Next()
+		if err == iterator.Done {
+			return backups, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+}
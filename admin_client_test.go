@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func doneOperation(t *testing.T, response proto.Message) *longrunningpb.Operation {
+	any, err := anypb.New(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &longrunningpb.Operation{
+		Done:   true,
+		Result: &longrunningpb.Operation_Response{Response: any},
+		Name:   "test-operation",
+	}
+}
+
+func TestAdminClient(t *testing.T) {
+	t.Parallel()
+
+	server, _, teardown := setupMockedTestServer(t)
+	defer teardown()
+	ctx := context.Background()
+
+	admin, err := NewAdminClient(ctx, fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	if g, w := admin.Database(), "projects/p/instances/i/databases/d"; g != w {
+		t.Fatalf("database mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	server.TestDatabaseAdmin.SetResps([]proto.Message{doneOperation(t, &databasepb.Database{Name: admin.Database()})})
+	if err := admin.CreateDatabase(ctx, []string{"CREATE TABLE Singers (SingerId INT64) PRIMARY KEY (SingerId)"}); err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{doneOperation(t, &emptypb.Empty{})})
+	if err := admin.UpdateDatabaseDdl(ctx, []string{"DROP TABLE Singers"}); err != nil {
+		t.Fatal(err)
+	}
+	expireTime := time.Now().Add(24 * time.Hour)
+	server.TestDatabaseAdmin.SetResps([]proto.Message{doneOperation(t, &databasepb.Backup{Name: "projects/p/instances/i/backups/b"})})
+	backup, err := admin.CreateBackup(ctx, "b", expireTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := backup.Name, "projects/p/instances/i/backups/b"; g != w {
+		t.Fatalf("backup name mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{&databasepb.ListBackupsResponse{
+		Backups: []*databasepb.Backup{{Name: "projects/p/instances/i/backups/b"}},
+	}})
+	backups, err := admin.ListBackups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(backups), 1; g != w {
+		t.Fatalf("backups count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 4; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	createDatabase := requests[0].(*databasepb.CreateDatabaseRequest)
+	if g, w := createDatabase.Parent, "projects/p/instances/i"; g != w {
+		t.Fatalf("parent mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := createDatabase.CreateStatement, "CREATE DATABASE `d`"; g != w {
+		t.Fatalf("create statement mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(createDatabase.ExtraStatements), 1; g != w {
+		t.Fatalf("extra statements count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := requests[1].(*databasepb.UpdateDatabaseDdlRequest).Database, admin.Database(); g != w {
+		t.Fatalf("database mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	createBackup := requests[2].(*databasepb.CreateBackupRequest)
+	if g, w := createBackup.BackupId, "b"; g != w {
+		t.Fatalf("backup id mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := createBackup.Backup.Database, admin.Database(); g != w {
+		t.Fatalf("backup database mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !createBackup.Backup.ExpireTime.AsTime().Equal(expireTime) {
+		t.Fatalf("expire time mismatch\n Got: %v\nWant: %v", createBackup.Backup.ExpireTime.AsTime(), expireTime)
+	}
+	if g, w := requests[3].(*databasepb.ListBackupsRequest).Filter, "database:projects/p/instances/i/databases/d"; g != w {
+		t.Fatalf("filter mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAdminClient_PostgreSQL(t *testing.T) {
+	t.Parallel()
+
+	server, _, teardown := setupMockedTestServer(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// Connection properties for the data client, such as prewarmSessions, are
+	// ignored by the admin client.
+	admin, err := NewAdminClient(ctx, fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;dialect=postgresql;prewarmSessions=true;minSessions=10", server.Address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{doneOperation(t, &databasepb.Database{Name: admin.Database()})})
+	if err := admin.CreateDatabase(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	createDatabase := requests[0].(*databasepb.CreateDatabaseRequest)
+	if g, w := createDatabase.CreateStatement, `CREATE DATABASE "d"`; g != w {
+		t.Fatalf("create statement mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := createDatabase.DatabaseDialect, databasepb.DatabaseDialect_POSTGRESQL; g != w {
+		t.Fatalf("dialect mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := server.TestSpanner.TotalSessionsCreated(), uint(0); g != w {
+		t.Fatalf("sessions created mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if _, err := NewAdminClient(ctx, fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;dialect=mysql", server.Address)); err == nil {
+		t.Fatal("missing error for invalid dialect")
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FixedSizeArray returns a sql.Scanner that scans an ARRAY column into the
+// given pointer to a fixed-size Go array, for example a *[3]float64. The
+// number of elements in the column value must be equal to the length of the
+// Go array. Scanning a NULL array, or a NULL element into an element type
+// that cannot hold NULL, returns an error.
+//
+// Fixed-size arrays can also be used as query parameters. These are sent to
+// Spanner in the same way as a slice with the same element type.
+//
+// Example:
+//
+//	var embedding [3]float64
+//	err := db.QueryRowContext(ctx, "SELECT Embedding FROM Documents WHERE Id=1").
+//		Scan(spannerdriver.FixedSizeArray(&embedding))
+func FixedSizeArray(dest interface{}) sql.Scanner {
+	return &fixedSizeArrayScanner{dest: dest}
+}
+
+type fixedSizeArrayScanner struct {
+	dest interface{}
+}
+
+func (s *fixedSizeArrayScanner) Scan(src interface{}) error {
+	dest := reflect.ValueOf(s.dest)
+	if dest.Kind() != reflect.Pointer || dest.IsNil() || dest.Elem().Kind() != reflect.Array {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "destination must be a non-nil pointer to an array, got %T", s.dest))
+	}
+	array := dest.Elem()
+	if src == nil {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan NULL into %T", s.dest))
+	}
+	values := reflect.ValueOf(src)
+	if values.Kind() != reflect.Slice {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan %T into %T", src, s.dest))
+	}
+	if values.Len() != array.Len() {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "array length mismatch: the column value has %d elements and %T has length %d", values.Len(), s.dest, array.Len()))
+	}
+	elemType := array.Type().Elem()
+	for i := 0; i < values.Len(); i++ {
+		elem, err := convertArrayElement(values.Index(i), elemType)
+		if err != nil {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "element %d: %v", i, err))
+		}
+		array.Index(i).Set(elem)
+	}
+	return nil
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// convertArrayElement converts an element of an array that was returned by
+// the driver to the given type.
+func convertArrayElement(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	// Element types that implement sql.Scanner, such as NullBytes, scan the
+	// element themselves.
+	if reflect.PointerTo(t).Implements(scannerType) {
+		res := reflect.New(t)
+		if err := res.Interface().(sql.Scanner).Scan(v.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return res.Elem(), nil
+	}
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if value == nil {
+			return reflect.Value{}, status.Errorf(codes.InvalidArgument, "cannot scan NULL into %v", t)
+		}
+		v = reflect.ValueOf(value)
+	}
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	// Numeric values may be converted to other numeric types, for example
+	// FLOAT64 values to float32. Conversions between numbers and strings are
+	// not allowed.
+	if v.Type().ConvertibleTo(t) && (v.Kind() == reflect.String) == (t.Kind() == reflect.String) {
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, status.Errorf(codes.InvalidArgument, "cannot convert %v to %v", v.Type(), t)
+}
+
+// arrayToSlice returns a slice with the elements of the given value if the
+// value is a fixed-size array. The returned ok value is false if the value is
+// not an array.
+func arrayToSlice(value interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Array {
+		return nil, false
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), v.Len(), v.Len())
+	reflect.Copy(slice, v)
+	return slice.Interface(), true
+}
+
+// NativeArray returns a sql.Scanner that scans an ARRAY column into the given
+// pointer to a slice of a native Go type, for example a *[]int64, a
+// *[]civil.Date or a *[]time.Time, or to a slice of a type that implements
+// sql.Scanner, such as NullBytes. The elements are converted to the element
+// type of the slice in the same way as for FixedSizeArray, so an ARRAY<INT64>
+// can also be scanned into a *[]int32. A NULL array is scanned into a nil
+// slice, and an empty array into a non-nil empty slice. Scanning an array
+// that contains a NULL element into an element type that cannot hold NULL
+// returns an error. Use NullArray for arrays that can contain NULL elements.
+//
+// This is an alternative to setting ExecOptions.DecodeToNativeArrays for
+// a single column. The same slices can be used as query parameters.
+//
+// Example:
+//
+//	var ids []int64
+//	err := db.QueryRowContext(ctx, "SELECT Ids FROM Batches WHERE Id=1").
+//		Scan(spannerdriver.NativeArray(&ids))
+func NativeArray(dest interface{}) sql.Scanner {
+	return &nativeArrayScanner{dest: dest}
+}
+
+type nativeArrayScanner struct {
+	dest interface{}
+}
+
+func (s *nativeArrayScanner) Scan(src interface{}) error {
+	dest := reflect.ValueOf(s.dest)
+	if dest.Kind() != reflect.Pointer || dest.IsNil() || dest.Elem().Kind() != reflect.Slice {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "destination must be a non-nil pointer to a slice, got %T", s.dest))
+	}
+	slice := dest.Elem()
+	if src == nil {
+		slice.Set(reflect.Zero(slice.Type()))
+		return nil
+	}
+	values := reflect.ValueOf(src)
+	if values.Kind() != reflect.Slice {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan %T into %T", src, s.dest))
+	}
+	if values.IsNil() {
+		slice.Set(reflect.Zero(slice.Type()))
+		return nil
+	}
+	elemType := slice.Type().Elem()
+	res := reflect.MakeSlice(slice.Type(), values.Len(), values.Len())
+	for i := 0; i < values.Len(); i++ {
+		elem, err := convertArrayElement(values.Index(i), elemType)
+		if err != nil {
+			if isNullElement(values.Index(i)) {
+				return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "element %d is NULL and cannot be scanned into %T, use NullArray for arrays with NULL elements", i, s.dest))
+			}
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "element %d: %v", i, err))
+		}
+		res.Index(i).Set(elem)
+	}
+	slice.Set(res)
+	return nil
+}
+
+// NullArray returns a sql.Scanner that scans an ARRAY column into the given
+// pointer to a slice of sql.Null[T], for example a *[]sql.Null[int64]. NULL
+// elements are scanned into an element with Valid set to false. A NULL array
+// is scanned into a nil slice, and an empty array into a non-nil empty slice.
+// This is a standard library alternative to scanning into slices of Spanner
+// null types, such as []spanner.NullInt64.
+//
+// Slices of sql.Null[T] can also be used as query parameters. NULL elements
+// are sent to Spanner as NULL.
+//
+// Example:
+//
+//	var scores []sql.Null[int64]
+//	err := db.QueryRowContext(ctx, "SELECT Scores FROM Players WHERE Id=1").
+//		Scan(spannerdriver.NullArray(&scores))
+func NullArray(dest interface{}) sql.Scanner {
+	return &nullArrayScanner{dest: dest}
+}
+
+type nullArrayScanner struct {
+	dest interface{}
+}
+
+func (s *nullArrayScanner) Scan(src interface{}) error {
+	dest := reflect.ValueOf(s.dest)
+	if dest.Kind() != reflect.Pointer || dest.IsNil() || dest.Elem().Kind() != reflect.Slice || !isSQLNullType(dest.Elem().Type().Elem()) {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "destination must be a non-nil pointer to a slice of sql.Null[T], got %T", s.dest))
+	}
+	slice := dest.Elem()
+	if src == nil {
+		slice.Set(reflect.Zero(slice.Type()))
+		return nil
+	}
+	values := reflect.ValueOf(src)
+	if values.Kind() != reflect.Slice {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan %T into %T", src, s.dest))
+	}
+	if values.IsNil() {
+		slice.Set(reflect.Zero(slice.Type()))
+		return nil
+	}
+	elemType := slice.Type().Elem()
+	valueType := elemType.Field(0).Type
+	res := reflect.MakeSlice(slice.Type(), values.Len(), values.Len())
+	for i := 0; i < values.Len(); i++ {
+		v := values.Index(i)
+		if isNullElement(v) {
+			continue
+		}
+		elem, err := convertArrayElement(v, valueType)
+		if err != nil {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "element %d: %v", i, err))
+		}
+		res.Index(i).Field(0).Set(elem)
+		res.Index(i).Field(1).SetBool(true)
+	}
+	slice.Set(res)
+	return nil
+}
+
+// isNullElement returns true if the given element of an array that was
+// returned by the driver is NULL.
+func isNullElement(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		return err == nil && value == nil
+	}
+	return false
+}
+
+// isSQLNullType returns true if the given type is an instance of the generic
+// sql.Null[T] type.
+func isSQLNullType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct &&
+		t.PkgPath() == "database/sql" &&
+		strings.HasPrefix(t.Name(), "Null[") &&
+		t.NumField() == 2 &&
+		t.Field(0).Name == "V" &&
+		t.Field(1).Name == "Valid" &&
+		t.Field(1).Type.Kind() == reflect.Bool
+}
+
+// nullArrayToSlice returns a slice of pointers to the values of the given
+// slice of sql.Null[T], with a nil pointer for each invalid element. The
+// returned ok value is false if the value is not a slice of sql.Null[T].
+func nullArrayToSlice(value interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice || !isSQLNullType(v.Type().Elem()) {
+		return nil, false
+	}
+	pointerType := reflect.PointerTo(v.Type().Elem().Field(0).Type)
+	if v.IsNil() {
+		return reflect.Zero(reflect.SliceOf(pointerType)).Interface(), true
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(pointerType), v.Len(), v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if !v.Index(i).Field(1).Bool() {
+			continue
+		}
+		p := reflect.New(pointerType.Elem())
+		p.Elem().Set(v.Index(i).Field(0))
+		slice.Index(i).Set(p)
+	}
+	return slice.Interface(), true
+}
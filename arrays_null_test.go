@@ -0,0 +1,183 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.22
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNullArray_Scan(t *testing.T) {
+	t.Parallel()
+
+	var ints []sql.Null[int64]
+	if err := NullArray(&ints).Scan([]spanner.NullInt64{{Int64: 1, Valid: true}, {}}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := ints, []sql.Null[int64]{{V: 1, Valid: true}, {}}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// Native arrays can also be scanned.
+	var floats []sql.Null[float32]
+	if err := NullArray(&floats).Scan([]float64{0.5, 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := floats, []sql.Null[float32]{{V: 0.5, Valid: true}, {V: 1.5, Valid: true}}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var bytes []sql.Null[[]byte]
+	if err := NullArray(&bytes).Scan([][]byte{[]byte("a"), nil}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := bytes, []sql.Null[[]byte]{{V: []byte("a"), Valid: true}, {}}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// A NULL array is scanned into a nil slice.
+	if err := NullArray(&ints).Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if ints != nil {
+		t.Fatalf("array mismatch\n Got: %v\nWant: nil", ints)
+	}
+	// An empty array is scanned into a non-nil empty slice.
+	if err := NullArray(&ints).Scan([]spanner.NullInt64{}); err != nil {
+		t.Fatal(err)
+	}
+	if ints == nil || len(ints) != 0 {
+		t.Fatalf("array mismatch\n Got: %#v\nWant: []sql.Null[int64]{}", ints)
+	}
+
+	for _, test := range []struct {
+		name string
+		dest interface{}
+		src  interface{}
+	}{
+		{"not an array", &ints, int64(1)},
+		{"invalid destination", ints, []spanner.NullInt64{}},
+		{"not a slice of sql.Null", &[]spanner.NullInt64{}, []spanner.NullInt64{}},
+		{"invalid element type", &[]sql.Null[string]{}, []spanner.NullInt64{{Int64: 1, Valid: true}}},
+	} {
+		err := NullArray(test.dest).Scan(test.src)
+		if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+			t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
+
+func TestNullArray(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM AllTypes WHERE ColInt64Array=@p1"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateResultSetWithAllTypes(false),
+	})
+	rows, err := db.QueryContext(ctx, "SELECT * FROM AllTypes WHERE ColInt64Array=?", []sql.Null[int64]{{V: 1, Valid: true}, {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("missing row: %v", rows.Err())
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var (
+		bools      []sql.Null[bool]
+		strings    []sql.Null[string]
+		bytes      []sql.Null[[]byte]
+		ints       []sql.Null[int64]
+		float32s   []sql.Null[float32]
+		float64s   []sql.Null[float64]
+		numerics   []sql.Null[big.Rat]
+		dates      []sql.Null[civil.Date]
+		timestamps []sql.Null[time.Time]
+	)
+	dest[10] = NullArray(&bools)
+	dest[11] = NullArray(&strings)
+	dest[12] = NullArray(&bytes)
+	dest[13] = NullArray(&ints)
+	dest[14] = NullArray(&float32s)
+	dest[15] = NullArray(&float64s)
+	dest[16] = NullArray(&numerics)
+	dest[17] = NullArray(&dates)
+	dest[18] = NullArray(&timestamps)
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"bools", bools, []sql.Null[bool]{{V: true, Valid: true}, {}, {V: false, Valid: true}}},
+		{"strings", strings, []sql.Null[string]{{V: "test1", Valid: true}, {}, {V: "test2", Valid: true}}},
+		{"bytes", bytes, []sql.Null[[]byte]{{V: []byte("testbytes1"), Valid: true}, {}, {V: []byte("testbytes2"), Valid: true}}},
+		{"ints", ints, []sql.Null[int64]{{V: 1, Valid: true}, {}, {V: 2, Valid: true}}},
+		{"float32s", float32s, []sql.Null[float32]{{V: 3.14, Valid: true}, {}, {V: -99.99, Valid: true}}},
+		{"float64s", float64s, []sql.Null[float64]{{V: 6.626, Valid: true}, {}, {V: 10.01, Valid: true}}},
+		{"numerics", numerics, []sql.Null[big.Rat]{{V: *big.NewRat(314, 100), Valid: true}, {}, {V: *big.NewRat(1001, 100), Valid: true}}},
+		{"dates", dates, []sql.Null[civil.Date]{{V: civil.Date{Year: 2000, Month: 2, Day: 29}, Valid: true}, {}, {V: civil.Date{Year: 2021, Month: 7, Day: 27}, Valid: true}}},
+		{"timestamps", timestamps, []sql.Null[time.Time]{{V: time.Date(2021, 7, 21, 21, 7, 59, 339911800, time.UTC), Valid: true}, {}, {V: time.Date(2021, 7, 27, 21, 7, 59, 339911800, time.UTC), Valid: true}}},
+	} {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s mismatch\n Got: %v\nWant: %v", test.name, test.got, test.want)
+		}
+	}
+
+	// The parameter is sent as an ARRAY<INT64> with a NULL element.
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := req.ParamTypes["p1"].GetArrayElementType().GetCode(), sppb.TypeCode_INT64; g != w {
+		t.Fatalf("param type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	params := req.Params.Fields["p1"].GetListValue().GetValues()
+	if g, w := len(params), 2; g != w {
+		t.Fatalf("param length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := params[0].GetStringValue(), "1"; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if _, ok := params[1].GetKind().(*structpb.Value_NullValue); !ok {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: NULL", params[1])
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestFixedSizeArray_Scan(t *testing.T) {
+	t.Parallel()
+
+	var ints [3]int64
+	if err := FixedSizeArray(&ints).Scan([]spanner.NullInt64{{Int64: 1, Valid: true}, {Int64: 2, Valid: true}, {Int64: 3, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := ints, [3]int64{1, 2, 3}; g != w {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var floats [2]float32
+	if err := FixedSizeArray(&floats).Scan([]spanner.NullFloat64{{Float64: 0.5, Valid: true}, {Float64: 1.5, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := floats, [2]float32{0.5, 1.5}; g != w {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// NULL elements can be scanned into nullable element types.
+	var nullableInts [2]spanner.NullInt64
+	if err := FixedSizeArray(&nullableInts).Scan([]spanner.NullInt64{{Int64: 1, Valid: true}, {}}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := nullableInts, [2]spanner.NullInt64{{Int64: 1, Valid: true}, {}}; g != w {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	for _, test := range []struct {
+		name string
+		dest interface{}
+		src  interface{}
+	}{
+		{"length mismatch", &ints, []spanner.NullInt64{{Int64: 1, Valid: true}}},
+		{"null array", &ints, nil},
+		{"null element", &ints, []spanner.NullInt64{{Int64: 1, Valid: true}, {}, {Int64: 3, Valid: true}}},
+		{"not an array", &ints, int64(1)},
+		{"invalid destination", ints, []spanner.NullInt64{}},
+		{"invalid element type", &[1]string{}, []spanner.NullInt64{{Int64: 1, Valid: true}}},
+	} {
+		err := FixedSizeArray(test.dest).Scan(test.src)
+		if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+			t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
+
+func TestFixedSizeArray(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM AllTypes WHERE ColInt64Array=@p1"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateResultSetWithAllTypes(false),
+	})
+	rows, err := db.QueryContext(ctx, "SELECT * FROM AllTypes WHERE ColInt64Array=?", [3]int64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("missing row: %v", rows.Err())
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	// ColStringArray contains a NULL element, and can only be scanned into an
+	// array with a nullable element type.
+	var stringArray [3]spanner.NullString
+	dest[11] = FixedSizeArray(&stringArray)
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := stringArray, [3]spanner.NullString{{StringVal: "test1", Valid: true}, {}, {StringVal: "test2", Valid: true}}; g != w {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var tooShort [2]spanner.NullString
+	dest[11] = FixedSizeArray(&tooShort)
+	if err := rows.Scan(dest...); err == nil {
+		t.Fatal("missing error for array length mismatch")
+	}
+
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := req.ParamTypes["p1"].GetCode(), sppb.TypeCode_ARRAY; g != w {
+		t.Fatalf("param type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(req.Params.Fields["p1"].GetListValue().GetValues()), 3; g != w {
+		t.Fatalf("param length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestNativeArray_Scan(t *testing.T) {
+	t.Parallel()
+
+	var ints []int32
+	if err := NativeArray(&ints).Scan([]spanner.NullInt64{{Int64: 1, Valid: true}, {Int64: 2, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := ints, []int32{1, 2}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var dates []civil.Date
+	if err := NativeArray(&dates).Scan([]spanner.NullDate{{Date: civil.Date{Year: 2024, Month: 2, Day: 29}, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := dates, []civil.Date{{Year: 2024, Month: 2, Day: 29}}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// A NULL array is scanned into a nil slice.
+	if err := NativeArray(&ints).Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if ints != nil {
+		t.Fatalf("array mismatch\n Got: %v\nWant: nil", ints)
+	}
+	// An empty array is scanned into a non-nil empty slice.
+	if err := NativeArray(&ints).Scan([]spanner.NullInt64{}); err != nil {
+		t.Fatal(err)
+	}
+	if ints == nil || len(ints) != 0 {
+		t.Fatalf("array mismatch\n Got: %#v\nWant: []int32{}", ints)
+	}
+
+	for _, test := range []struct {
+		name string
+		dest interface{}
+		src  interface{}
+	}{
+		{"null element", &ints, []spanner.NullInt64{{Int64: 1, Valid: true}, {}}},
+		{"not an array", &ints, int64(1)},
+		{"invalid destination", ints, []spanner.NullInt64{}},
+		{"invalid element type", &[]string{}, []spanner.NullInt64{{Int64: 1, Valid: true}}},
+	} {
+		err := NativeArray(test.dest).Scan(test.src)
+		if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+			t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
+
+func TestNativeArrays(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name  string
+		value interface{}
+		code  sppb.TypeCode
+		// decoded indicates that DecodeToNativeArrays returns a slice of the
+		// same type as value.
+		decoded bool
+	}{
+		{"bool", []bool{true, false}, sppb.TypeCode_BOOL, true},
+		{"int64", []int64{1, 2}, sppb.TypeCode_INT64, true},
+		{"int", []int{1, 2}, sppb.TypeCode_INT64, false},
+		{"int32", []int32{1, 2}, sppb.TypeCode_INT64, false},
+		{"uint64", []uint64{1, 2}, sppb.TypeCode_INT64, false},
+		{"float32", []float32{0.5, 1.5}, sppb.TypeCode_FLOAT32, true},
+		{"float64", []float64{0.5, 1.5}, sppb.TypeCode_FLOAT64, true},
+		{"numeric", []big.Rat{*big.NewRat(1, 2), *big.NewRat(3, 4)}, sppb.TypeCode_NUMERIC, true},
+		{"string", []string{"a", "b"}, sppb.TypeCode_STRING, true},
+		{"bytes", [][]byte{[]byte("a"), []byte("b")}, sppb.TypeCode_BYTES, true},
+		{"date", []civil.Date{{Year: 2024, Month: 2, Day: 29}, {Year: 2024, Month: 3, Day: 1}}, sppb.TypeCode_DATE, true},
+		{"timestamp", []time.Time{time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}, sppb.TypeCode_TIMESTAMP, true},
+	} {
+		// The query returns the value of the parameter.
+		query := "SELECT @p1 AS " + test.name
+		row, err := spanner.NewRow([]string{test.name}, []interface{}{convertParam(test.value)})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var col spanner.GenericColumnValue
+		if err := row.Column(0, &col); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+			Type: testutil.StatementResultResultSet,
+			ResultSet: &sppb.ResultSet{
+				Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{Fields: []*sppb.StructType_Field{{Name: test.name, Type: col.Type}}}},
+				Rows:     []*structpb.ListValue{{Values: []*structpb.Value{col.Value}}},
+			},
+		})
+
+		dest := reflect.New(reflect.TypeOf(test.value))
+		if err := db.QueryRowContext(ctx, query, test.value).Scan(NativeArray(dest.Interface())); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if g, w := dest.Elem().Interface(), test.value; !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: NativeArray mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		if test.decoded {
+			dest = reflect.New(reflect.TypeOf(test.value))
+			if err := db.QueryRowContext(ctx, query, ExecOptions{DecodeToNativeArrays: true}, test.value).Scan(dest.Interface()); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if g, w := dest.Elem().Interface(), test.value; !reflect.DeepEqual(g, w) {
+				t.Errorf("%s: DecodeToNativeArrays mismatch\n Got: %v\nWant: %v", test.name, g, w)
+			}
+		}
+
+		requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		for _, r := range requests {
+			req := r.(*sppb.ExecuteSqlRequest)
+			if g, w := req.ParamTypes["p1"].GetArrayElementType().GetCode(), test.code; g != w {
+				t.Errorf("%s: param type mismatch\n Got: %v\nWant: %v", test.name, g, w)
+			}
+			if g, w := req.Params.Fields["p1"], col.Value; !reflect.DeepEqual(g.AsInterface(), w.AsInterface()) {
+				t.Errorf("%s: param value mismatch\n Got: %v\nWant: %v", test.name, g, w)
+			}
+		}
+	}
+}
+
+func TestNativeArrays_Float32SpecialValues(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	value := []float32{float32(math.NaN()), float32(math.Inf(1)), float32(math.Inf(-1))}
+	query := "SELECT @p1 AS float32s"
+	float32Type := &sppb.Type{Code: sppb.TypeCode_FLOAT32}
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{Fields: []*sppb.StructType_Field{
+				{Name: "float32s", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: float32Type}},
+			}}},
+			Rows: []*structpb.ListValue{{Values: []*structpb.Value{structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+				structpb.NewStringValue("NaN"),
+				structpb.NewStringValue("Infinity"),
+				structpb.NewStringValue("-Infinity"),
+			}})}}},
+		},
+	})
+
+	var got []float32
+	if err := db.QueryRowContext(ctx, query, ExecOptions{DecodeToNativeArrays: true}, value).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(got), len(value); g != w {
+		t.Fatalf("length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !math.IsNaN(float64(got[0])) || !math.IsInf(float64(got[1]), 1) || !math.IsInf(float64(got[2]), -1) {
+		t.Fatalf("value mismatch\n Got: %v\nWant: %v", got, value)
+	}
+
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := req.ParamTypes["p1"].GetArrayElementType().GetCode(), sppb.TypeCode_FLOAT32; g != w {
+		t.Fatalf("param type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	params := req.Params.Fields["p1"].GetListValue().GetValues()
+	if g, w := len(params), len(value); g != w {
+		t.Fatalf("param length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !math.IsNaN(params[0].GetNumberValue()) || !math.IsInf(params[1].GetNumberValue(), 1) || !math.IsInf(params[2].GetNumberValue(), -1) {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", params, value)
+	}
+}
+
+func TestUnsignedIntegers_OutOfRange(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "UPDATE Singers SET Ratings=@p1 WHERE TRUE"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	large := uint(math.MaxUint64)
+	for _, value := range []interface{}{
+		[]uint64{1, math.MaxUint64},
+		[]uint64{math.MaxInt64 + 1},
+		[]uint{1, math.MaxUint64},
+		uint(math.MaxUint64),
+		&large,
+		[]*uint{&large},
+	} {
+		if _, err := db.ExecContext(ctx, query, value); spanner.ErrCode(err) != codes.InvalidArgument {
+			t.Errorf("%T: error code mismatch\n Got: %v\nWant: %v", value, err, codes.InvalidArgument)
+		}
+	}
+	drainRequestsFromServer(server.TestSpanner)
+
+	// The largest value that fits in an INT64 is accepted.
+	if _, err := db.ExecContext(ctx, query, []uint64{math.MaxInt64}); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	values := requests[0].(*sppb.ExecuteSqlRequest).Params.Fields["p1"].GetListValue().GetValues()
+	if g, w := values[0].GetStringValue(), "9223372036854775807"; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TransactionCheckpoint is a position in a read/write transaction that the
+// transaction can be rolled back to with SpannerConn.RollbackToCheckpoint.
+//
+// Spanner does not support savepoints. A checkpoint is therefore emulated
+// with the same mechanism that is used to internally retry aborted
+// transactions: Rolling back to a checkpoint rolls back the Spanner
+// transaction, starts a new Spanner transaction, and replays all statements
+// and mutations before the checkpoint. This has the following constraints:
+//   - Checkpoints can only be used in read/write transactions that are
+//     retried internally if they are aborted. Internal retries are enabled by
+//     default, but are disabled for transactions that are started by
+//     RunTransaction.
+//   - Rolling back to a checkpoint re-executes all statements before the
+//     checkpoint. This can be expensive for large transactions.
+//   - Rolling back fails with ErrAbortedDueToConcurrentModification if the
+//     replayed statements return different results than the first time they
+//     were executed, for example because the data was modified by another
+//     transaction in the meantime. The transaction must then be rolled back.
+//   - Locks that were acquired by the transaction are released, and the
+//     replayed statements acquire new locks.
+//   - Rows of queries that were executed after the checkpoint must be closed
+//     before rolling back to the checkpoint.
+//   - Rolling back to a checkpoint is not possible while a DML batch is
+//     active.
+//   - Rolling back to a checkpoint invalidates all checkpoints that were
+//     created after that checkpoint.
+type TransactionCheckpoint struct {
+	tx         *readWriteTransaction
+	statements int
+	mutations  int
+}
+
+func (c *conn) Checkpoint() (*TransactionCheckpoint, error) {
+	tx, err := c.checkpointTransaction("checkpoints")
+	if err != nil {
+		return nil, err
+	}
+	return tx.checkpoint(), nil
+}
+
+func (c *conn) RollbackToCheckpoint(ctx context.Context, checkpoint *TransactionCheckpoint) error {
+	if err := c.enter(); err != nil {
+		return err
+	}
+	defer c.leave()
+	tx, err := c.checkpointTransaction("checkpoints")
+	if err != nil {
+		return err
+	}
+	if tx.batch != nil {
+		return spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "cannot roll back to a checkpoint while a DML batch is active"))
+	}
+	return tx.rollbackToCheckpoint(ctx, checkpoint)
+}
+
+// checkpointTransaction returns the read/write transaction of the connection
+// if it supports checkpoints. The given feature is used in error messages.
+func (c *conn) checkpointTransaction(feature string) (*readWriteTransaction, error) {
+	tx, ok := c.tx.(*readWriteTransaction)
+	if !ok {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.FailedPrecondition, "%s are only supported in read/write transactions", feature))
+	}
+	if !tx.retryAborts {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.Unimplemented, "%s are not supported for transactions that are not retried internally", feature))
+	}
+	return tx, nil
+}
+
+// namedCheckpoint is a checkpoint that was created by a SAVEPOINT statement.
+type namedCheckpoint struct {
+	name       string
+	checkpoint *TransactionCheckpoint
+}
+
+// savepoint executes a SAVEPOINT statement. Savepoints are emulated with
+// checkpoints, and have the same constraints. A savepoint with the same name
+// as an existing savepoint hides the existing savepoint until the new
+// savepoint is released.
+func (c *conn) savepoint(name string) (driver.Result, error) {
+	tx, err := c.checkpointTransaction("savepoints")
+	if err != nil {
+		return nil, err
+	}
+	tx.savepoints = append(tx.savepoints, namedCheckpoint{name: name, checkpoint: tx.checkpoint()})
+	return driver.ResultNoRows, nil
+}
+
+// releaseSavepoint executes a RELEASE SAVEPOINT statement. The savepoint and
+// all savepoints that were created after it are removed. The statements
+// after the savepoint are not rolled back.
+func (c *conn) releaseSavepoint(name string) (driver.Result, error) {
+	tx, err := c.checkpointTransaction("savepoints")
+	if err != nil {
+		return nil, err
+	}
+	index, err := tx.findSavepoint(name)
+	if err != nil {
+		return nil, err
+	}
+	tx.savepoints = tx.savepoints[:index]
+	return driver.ResultNoRows, nil
+}
+
+// rollbackToSavepoint executes a ROLLBACK TO SAVEPOINT statement. All
+// statements and mutations after the savepoint are rolled back, and all
+// savepoints that were created after it are removed. The savepoint itself
+// remains valid. The transaction must be rolled back if this returns
+// ErrAbortedDueToConcurrentModification.
+func (c *conn) rollbackToSavepoint(ctx context.Context, name string) (driver.Result, error) {
+	tx, err := c.checkpointTransaction("savepoints")
+	if err != nil {
+		return nil, err
+	}
+	if tx.batch != nil {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "cannot roll back to a savepoint while a DML batch is active"))
+	}
+	index, err := tx.findSavepoint(name)
+	if err != nil {
+		return nil, err
+	}
+	tx.savepoints = tx.savepoints[:index+1]
+	if err := tx.rollbackToCheckpoint(ctx, tx.savepoints[index].checkpoint); err != nil {
+		return nil, err
+	}
+	return driver.ResultNoRows, nil
+}
+
+// findSavepoint returns the index of the last savepoint with the given name.
+// Savepoint names are case-insensitive.
+func (tx *readWriteTransaction) findSavepoint(name string) (int, error) {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if strings.EqualFold(tx.savepoints[i].name, name) {
+			return i, nil
+		}
+	}
+	return -1, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "savepoint %s does not exist", name))
+}
+
+// checkpoint creates a checkpoint at the current position in the transaction.
+func (tx *readWriteTransaction) checkpoint() *TransactionCheckpoint {
+	checkpoint := &TransactionCheckpoint{
+		tx:         tx,
+		statements: len(tx.statements),
+		mutations:  len(tx.mutations),
+	}
+	tx.checkpoints = append(tx.checkpoints, checkpoint)
+	return checkpoint
+}
+
+// rollbackToCheckpoint removes all statements and mutations after the given
+// checkpoint from the transaction, and replays the remaining statements and
+// mutations on a new Spanner transaction.
+func (tx *readWriteTransaction) rollbackToCheckpoint(ctx context.Context, checkpoint *TransactionCheckpoint) error {
+	index := -1
+	for i, cp := range tx.checkpoints {
+		if cp == checkpoint {
+			index = i
+			break
+		}
+	}
+	if index == -1 || checkpoint.tx != tx {
+		return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "the checkpoint is not valid for the current transaction"))
+	}
+	tx.checkpoints = tx.checkpoints[:index+1]
+	for _, stmt := range tx.statements[checkpoint.statements:] {
+		if it, ok := stmt.(*checksumRowIterator); ok {
+			it.Stop()
+		}
+	}
+	tx.statements = tx.statements[:checkpoint.statements]
+	tx.mutations = tx.mutations[:checkpoint.mutations]
+	tx.rwTx.Rollback(ctx)
+	err := tx.retry(ctx)
+	for err != ErrAbortedDueToConcurrentModification && spanner.ErrCode(err) == codes.Aborted {
+		// Aborted replays use the same retry budget and backoff as other
+		// retries of the transaction.
+		if err = tx.waitBeforeRetry(ctx, err); err != nil {
+			return err
+		}
+		err = tx.retry(ctx)
+	}
+	return err
+}
@@ -0,0 +1,301 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRollbackToCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	withSpannerConn := func(f func(c SpannerConn) error) error {
+		return conn.Raw(func(driverConn interface{}) error {
+			return f(driverConn.(SpannerConn))
+		})
+	}
+	bufferWrite := func(m *spanner.Mutation) {
+		if err := withSpannerConn(func(c SpannerConn) error {
+			return c.BufferWrite([]*spanner.Mutation{m})
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkpoint := func() (cp *TransactionCheckpoint) {
+		if err := withSpannerConn(func(c SpannerConn) (err error) {
+			cp, err = c.Checkpoint()
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return cp
+	}
+	rollbackToCheckpoint := func(cp *TransactionCheckpoint) error {
+		return withSpannerConn(func(c SpannerConn) error {
+			return c.RollbackToCheckpoint(ctx, cp)
+		})
+	}
+
+	// Checkpoints are only supported in read/write transactions.
+	if err := withSpannerConn(func(c SpannerConn) error {
+		_, err := c.Checkpoint()
+		return err
+	}); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	m1 := spanner.Insert("Singers", []string{"SingerId"}, []interface{}{int64(1)})
+	bufferWrite(m1)
+	cp1 := checkpoint()
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	bufferWrite(spanner.Delete("Singers", spanner.Key{int64(2)}))
+	cp2 := checkpoint()
+	drainRequestsFromServer(server.TestSpanner)
+
+	if err := rollbackToCheckpoint(cp1); err != nil {
+		t.Fatal(err)
+	}
+	// Rolling back to cp1 invalidates cp2.
+	if err := rollbackToCheckpoint(cp2); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.RollbackRequest{}))), 1; g != w {
+		t.Fatalf("rollback requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))), 1; g != w {
+		t.Fatalf("begin requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// Only the statement before the checkpoint is replayed.
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))), 1; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	mutations := commitRequests[0].(*sppb.CommitRequest).Mutations
+	if g, w := len(mutations), 1; g != w {
+		t.Fatalf("mutations count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if mutations[0].GetInsert() == nil {
+		t.Fatalf("mutation mismatch\n Got: %v\nWant: insert", mutations[0])
+	}
+}
+
+func TestRollbackToCheckpoint_ConcurrentModification(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	var cp *TransactionCheckpoint
+	if err := conn.Raw(func(driverConn interface{}) (err error) {
+		cp, err = driverConn.(SpannerConn).Checkpoint()
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The statement returns a different update count during the replay.
+	_ = server.TestSpanner.PutStatementResult(testutil.UpdateBarSetFoo, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: testutil.UpdateBarSetFooRowCount + 1,
+	})
+	if err := conn.Raw(func(driverConn interface{}) error {
+		return driverConn.(SpannerConn).RollbackToCheckpoint(ctx, cp)
+	}); err != ErrAbortedDueToConcurrentModification {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, ErrAbortedDueToConcurrentModification)
+	}
+	_ = tx.Rollback()
+}
+
+func TestRollbackToCheckpoint_MaxCommitRetries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnectionWithParams(t, "maxCommitRetries=2")
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	var cp *TransactionCheckpoint
+	if err := conn.Raw(func(driverConn interface{}) (err error) {
+		cp, err = driverConn.(SpannerConn).Checkpoint()
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+	// Every replay of the statement is aborted.
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteSql, testutil.SimulatedExecutionTime{
+		Errors: []error{
+			status.Error(codes.Aborted, "Aborted"),
+			status.Error(codes.Aborted, "Aborted"),
+			status.Error(codes.Aborted, "Aborted"),
+		},
+	})
+	err = conn.Raw(func(driverConn interface{}) error {
+		return driverConn.(SpannerConn).RollbackToCheckpoint(ctx, cp)
+	})
+	// The replay is attempted once and retried twice.
+	var budgetErr *RetryBudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %T", err, budgetErr)
+	}
+	if g, w := budgetErr.Attempts, 3; g != w {
+		t.Fatalf("attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))), 3; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestCheckpoint_InternalRetriesDisabled(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnectionWithParams(t, "retryAbortsInternally=false")
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).Checkpoint()
+		return err
+	}); spanner.ErrCode(err) != codes.Unimplemented {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.Unimplemented)
+	}
+}
+
+func TestSavepoints(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// Savepoints are only supported in read/write transactions.
+	if _, err := db.ExecContext(ctx, "SAVEPOINT s1"); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{testutil.UpdateBarSetFoo, "SAVEPOINT s1", testutil.UpdateBarSetFoo, "SAVEPOINT s2", testutil.UpdateBarSetFoo} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT unknown"); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+	// Rolling back to s2 keeps s2, and replays the statements before it.
+	if _, err := tx.ExecContext(ctx, "rollback to savepoint S2"); err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))), 2; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if _, err := tx.ExecContext(ctx, "ROLLBACK TO s2"); err != nil {
+		t.Fatal(err)
+	}
+	// Releasing s1 also releases s2.
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT s1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT s2"); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))), 0; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -48,6 +49,65 @@ import (
 type statementExecutor struct {
 }
 
+// connectionVariable is an entry in the table of variables that can be read
+// with SHOW VARIABLE <name> and, unless set is nil, changed with
+// SET <name> = <value>.
+type connectionVariable struct {
+	show func(s *statementExecutor, ctx context.Context, c *conn, params string, args []driver.NamedValue) (driver.Rows, error)
+	set  func(s *statementExecutor, ctx context.Context, c *conn, params string, args []driver.NamedValue) (driver.Result, error)
+}
+
+// connectionVariables contains all variables that are supported by the generic
+// SHOW VARIABLE and SET statements. The keys are the lower-case names of the
+// variables. New variables are added by registering their show and set methods
+// in this table.
+var connectionVariables = map[string]connectionVariable{
+	"commit_timestamp":                {show: (*statementExecutor).ShowCommitTimestamp},
+	"read_timestamp":                  {show: (*statementExecutor).ShowReadTimestamp},
+	"commit_timestamp_location":       {show: (*statementExecutor).ShowCommitTimestampLocation, set: (*statementExecutor).SetCommitTimestampLocation},
+	"retry_aborts_internally":         {show: (*statementExecutor).ShowRetryAbortsInternally, set: (*statementExecutor).SetRetryAbortsInternally},
+	"max_commit_retries":              {show: (*statementExecutor).ShowMaxCommitRetries, set: (*statementExecutor).SetMaxCommitRetries},
+	"autocommit":                      {show: (*statementExecutor).ShowAutocommit, set: (*statementExecutor).SetAutocommit},
+	"readonly":                        {show: (*statementExecutor).ShowReadOnly, set: (*statementExecutor).SetReadOnly},
+	"optimizer_version":               {show: (*statementExecutor).ShowOptimizerVersion, set: (*statementExecutor).SetOptimizerVersion},
+	"optimizer_statistics_package":    {show: (*statementExecutor).ShowOptimizerStatisticsPackage, set: (*statementExecutor).SetOptimizerStatisticsPackage},
+	"autocommit_dml_mode":             {show: (*statementExecutor).ShowAutocommitDmlMode, set: (*statementExecutor).SetAutocommitDmlMode},
+	"read_only_staleness":             {show: (*statementExecutor).ShowReadOnlyStaleness, set: (*statementExecutor).SetReadOnlyStaleness},
+	"exclude_txn_from_change_streams": {show: (*statementExecutor).ShowExcludeTxnFromChangeStreams, set: (*statementExecutor).SetExcludeTxnFromChangeStreams},
+	"rpc_priority":                    {show: (*statementExecutor).ShowRpcPriority, set: (*statementExecutor).SetRpcPriority},
+	"directed_read":                   {show: (*statementExecutor).ShowDirectedRead, set: (*statementExecutor).SetDirectedRead},
+	"max_commit_delay":                {show: (*statementExecutor).ShowMaxCommitDelay, set: (*statementExecutor).SetMaxCommitDelay},
+	"statement_timeout":               {show: (*statementExecutor).ShowStatementTimeout, set: (*statementExecutor).SetStatementTimeout},
+}
+
+// ShowVariable looks up the variable with the given name in the
+// connectionVariables table and returns its current value.
+func (s *statementExecutor) ShowVariable(ctx context.Context, c *conn, name string, args []driver.NamedValue) (driver.Rows, error) {
+	v, ok := connectionVariables[strings.ToLower(name)]
+	if !ok {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unknown variable: %s", name))
+	}
+	return v.show(s, ctx, c, "", args)
+}
+
+// SetVariable parses a '<name> = <value>' string, looks up the variable in the
+// connectionVariables table and assigns the value to it.
+func (s *statementExecutor) SetVariable(ctx context.Context, c *conn, params string, args []driver.NamedValue) (driver.Result, error) {
+	p := strings.SplitN(params, "=", 2)
+	if len(p) != 2 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid SET statement: %s", params))
+	}
+	name := strings.TrimSpace(p[0])
+	v, ok := connectionVariables[strings.ToLower(name)]
+	if !ok {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unknown variable: %s", name))
+	}
+	if v.set == nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "variable %s is read-only", name))
+	}
+	return v.set(s, ctx, c, strings.TrimSpace(p[1]), args)
+}
+
 func (s *statementExecutor) ShowCommitTimestamp(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
 	ts, err := c.CommitTimestamp()
 	var commitTs *time.Time
@@ -61,6 +121,27 @@ func (s *statementExecutor) ShowCommitTimestamp(_ context.Context, c *conn, _ st
 	return &rows{it: it}, nil
 }
 
+func (s *statementExecutor) ShowReadTimestamp(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	ts, err := c.ReadTimestamp()
+	var readTs *time.Time
+	if err == nil {
+		readTs = &ts
+	}
+	it, err := createTimestampIterator("ReadTimestamp", readTs)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowCommitTimestampLocation(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	it, err := createStringIterator("CommitTimestampLocation", c.CommitTimestampLocation().String())
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
 func (s *statementExecutor) ShowRetryAbortsInternally(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
 	it, err := createBooleanIterator("RetryAbortsInternally", c.RetryAbortsInternally())
 	if err != nil {
@@ -69,6 +150,46 @@ func (s *statementExecutor) ShowRetryAbortsInternally(_ context.Context, c *conn
 	return &rows{it: it}, nil
 }
 
+func (s *statementExecutor) ShowMaxCommitRetries(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	it, err := createInt64Iterator("MaxCommitRetries", int64(c.MaxCommitRetries()))
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowAutocommit(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	it, err := createBooleanIterator("Autocommit", c.Autocommit())
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowReadOnly(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	it, err := createBooleanIterator("ReadOnly", c.ReadOnly())
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowOptimizerVersion(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	it, err := createStringIterator("OptimizerVersion", c.OptimizerVersion())
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowOptimizerStatisticsPackage(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	it, err := createStringIterator("OptimizerStatisticsPackage", c.OptimizerStatisticsPackage())
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
 func (s *statementExecutor) ShowAutocommitDmlMode(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
 	it, err := createStringIterator("AutocommitDMLMode", c.AutocommitDMLMode().String())
 	if err != nil {
@@ -93,6 +214,50 @@ func (s *statementExecutor) ShowExcludeTxnFromChangeStreams(_ context.Context, c
 	return &rows{it: it}, nil
 }
 
+func (s *statementExecutor) ShowRpcPriority(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	priority := "NULL"
+	if c.RPCPriority() != sppb.RequestOptions_PRIORITY_UNSPECIFIED {
+		priority = strings.TrimPrefix(c.RPCPriority().String(), "PRIORITY_")
+	}
+	it, err := createStringIterator("RpcPriority", priority)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowDirectedRead(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	it, err := createStringIterator("DirectedRead", formatDirectedReadOptions(c.DirectedReadOptions()))
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowMaxCommitDelay(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	delay := "NULL"
+	if c.MaxCommitDelay() != nil {
+		delay = c.MaxCommitDelay().String()
+	}
+	it, err := createStringIterator("MaxCommitDelay", delay)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
+func (s *statementExecutor) ShowStatementTimeout(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	timeout := "NULL"
+	if c.StatementTimeout() > 0 {
+		timeout = c.StatementTimeout().String()
+	}
+	it, err := createStringIterator("StatementTimeout", timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: it}, nil
+}
+
 func (s *statementExecutor) StartBatchDdl(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Result, error) {
 	return c.startBatchDDL()
 }
@@ -109,6 +274,70 @@ func (s *statementExecutor) AbortBatch(_ context.Context, c *conn, _ string, _ [
 	return c.abortBatch()
 }
 
+func (s *statementExecutor) Commit(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	return c.commit()
+}
+
+func (s *statementExecutor) Rollback(_ context.Context, c *conn, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	return c.rollback()
+}
+
+func (s *statementExecutor) Savepoint(_ context.Context, c *conn, name string, _ []driver.NamedValue) (driver.Result, error) {
+	return c.savepoint(name)
+}
+
+func (s *statementExecutor) ReleaseSavepoint(_ context.Context, c *conn, name string, _ []driver.NamedValue) (driver.Result, error) {
+	return c.releaseSavepoint(name)
+}
+
+func (s *statementExecutor) RollbackToSavepoint(ctx context.Context, c *conn, name string, _ []driver.NamedValue) (driver.Result, error) {
+	return c.rollbackToSavepoint(ctx, name)
+}
+
+func (s *statementExecutor) SetAutocommit(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for Autocommit"))
+	}
+	autocommit, err := strconv.ParseBool(params)
+	if err != nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid boolean value: %s", params))
+	}
+	return c.setAutocommit(autocommit)
+}
+
+func (s *statementExecutor) SetReadOnly(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for ReadOnly"))
+	}
+	readOnly, err := strconv.ParseBool(params)
+	if err != nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid boolean value: %s", params))
+	}
+	return c.setReadOnly(readOnly)
+}
+
+var optimizerOptionRegexp = regexp.MustCompile(`\A'(?P<value>[^']*)'\z`)
+
+func (s *statementExecutor) SetOptimizerVersion(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for OptimizerVersion"))
+	}
+	if !optimizerOptionRegexp.MatchString(params) {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid OptimizerVersion value: %s", params))
+	}
+	return c.setOptimizerVersion(matchesToMap(optimizerOptionRegexp, params)["value"])
+}
+
+func (s *statementExecutor) SetOptimizerStatisticsPackage(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for OptimizerStatisticsPackage"))
+	}
+	if !optimizerOptionRegexp.MatchString(params) {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid OptimizerStatisticsPackage value: %s", params))
+	}
+	return c.setOptimizerStatisticsPackage(matchesToMap(optimizerOptionRegexp, params)["value"])
+}
+
 func (s *statementExecutor) SetRetryAbortsInternally(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
 	if params == "" {
 		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for RetryAbortsInternally"))
@@ -120,6 +349,17 @@ func (s *statementExecutor) SetRetryAbortsInternally(_ context.Context, c *conn,
 	return c.setRetryAbortsInternally(retry)
 }
 
+func (s *statementExecutor) SetMaxCommitRetries(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for MaxCommitRetries"))
+	}
+	retries, err := strconv.ParseUint(params, 10, 31)
+	if err != nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid MaxCommitRetries value: %s", params))
+	}
+	return c.setMaxCommitRetries(int(retries))
+}
+
 func (s *statementExecutor) SetAutocommitDmlMode(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
 	if params == "" {
 		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for AutocommitDMLMode"))
@@ -147,6 +387,161 @@ func (s *statementExecutor) SetExcludeTxnFromChangeStreams(_ context.Context, c
 	return c.setExcludeTxnFromChangeStreams(exclude)
 }
 
+func (s *statementExecutor) SetRpcPriority(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for RpcPriority"))
+	}
+	var priority sppb.RequestOptions_Priority
+	switch strings.ToUpper(params) {
+	case "'HIGH'":
+		priority = sppb.RequestOptions_PRIORITY_HIGH
+	case "'MEDIUM'":
+		priority = sppb.RequestOptions_PRIORITY_MEDIUM
+	case "'LOW'":
+		priority = sppb.RequestOptions_PRIORITY_LOW
+	case "'NULL'":
+		priority = sppb.RequestOptions_PRIORITY_UNSPECIFIED
+	default:
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid RpcPriority value: %s", params))
+	}
+	return c.setRPCPriority(priority)
+}
+
+var maxCommitDelayRegexp = regexp.MustCompile(`(?i)\A'(?P<duration>[^']*)'\z`)
+
+func (s *statementExecutor) SetMaxCommitDelay(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for MaxCommitDelay"))
+	}
+	if strings.EqualFold(params, "'NULL'") || strings.EqualFold(params, "NULL") {
+		return c.setMaxCommitDelay(nil)
+	}
+	if !maxCommitDelayRegexp.MatchString(params) {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid MaxCommitDelay value: %s", params))
+	}
+	delay, err := parseDuration(maxCommitDelayRegexp, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.setMaxCommitDelay(&delay)
+}
+
+var statementTimeoutRegexp = regexp.MustCompile(`(?i)\A'(?P<duration>[^']*)'\z`)
+
+func (s *statementExecutor) SetStatementTimeout(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for StatementTimeout"))
+	}
+	if strings.EqualFold(params, "'NULL'") || strings.EqualFold(params, "NULL") {
+		return c.setStatementTimeout(0)
+	}
+	if !statementTimeoutRegexp.MatchString(params) {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid StatementTimeout value: %s", params))
+	}
+	timeout, err := parseDuration(statementTimeoutRegexp, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.setStatementTimeout(timeout)
+}
+
+var directedReadRegexp = regexp.MustCompile(`\A'(?P<options>[^']*)'\z`)
+
+func (s *statementExecutor) SetDirectedRead(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for DirectedRead"))
+	}
+	if !directedReadRegexp.MatchString(params) {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid DirectedRead value: %s", params))
+	}
+	options, err := parseDirectedReadOptions(matchesToMap(directedReadRegexp, params)["options"])
+	if err != nil {
+		return nil, err
+	}
+	return c.setDirectedReadOptions(options)
+}
+
+// parseDirectedReadOptions parses directed read options in the format
+// `[EXCLUDE ]location[/type][,location[/type]...]`, for example
+// `us-east1/READ_ONLY,us-west1`. The type is one of READ_ONLY and READ_WRITE,
+// and any type is selected if it is omitted. An empty string and NULL remove
+// the directed read options.
+func parseDirectedReadOptions(value string) (*spannerpb.DirectedReadOptions, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "NULL") {
+		return nil, nil
+	}
+	exclude := false
+	if prefix, rest, ok := strings.Cut(value, " "); ok && strings.EqualFold(prefix, "EXCLUDE") {
+		exclude = true
+		value = rest
+	}
+	var selections []*spannerpb.DirectedReadOptions_ReplicaSelection
+	for _, replica := range strings.Split(value, ",") {
+		location, typeName, hasType := strings.Cut(strings.TrimSpace(replica), "/")
+		selection := &spannerpb.DirectedReadOptions_ReplicaSelection{Location: location}
+		if hasType {
+			tp, ok := spannerpb.DirectedReadOptions_ReplicaSelection_Type_value[strings.ToUpper(typeName)]
+			if !ok || tp == int32(spannerpb.DirectedReadOptions_ReplicaSelection_TYPE_UNSPECIFIED) {
+				return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid replica type %q, expected READ_ONLY or READ_WRITE", typeName))
+			}
+			selection.Type = spannerpb.DirectedReadOptions_ReplicaSelection_Type(tp)
+		}
+		if selection.Location == "" && !hasType {
+			return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid replica selection %q", replica))
+		}
+		selections = append(selections, selection)
+	}
+	if exclude {
+		return &spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_ExcludeReplicas_{
+			ExcludeReplicas: &spannerpb.DirectedReadOptions_ExcludeReplicas{ReplicaSelections: selections},
+		}}, nil
+	}
+	return &spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_IncludeReplicas_{
+		IncludeReplicas: &spannerpb.DirectedReadOptions_IncludeReplicas{ReplicaSelections: selections},
+	}}, nil
+}
+
+// formatDirectedReadOptions formats the given directed read options in the
+// format that is accepted by parseDirectedReadOptions.
+func formatDirectedReadOptions(options *spannerpb.DirectedReadOptions) string {
+	if options == nil {
+		return "NULL"
+	}
+	prefix := ""
+	selections := options.GetIncludeReplicas().GetReplicaSelections()
+	if options.GetExcludeReplicas() != nil {
+		prefix = "EXCLUDE "
+		selections = options.GetExcludeReplicas().GetReplicaSelections()
+	}
+	replicas := make([]string, 0, len(selections))
+	for _, selection := range selections {
+		replica := selection.Location
+		if selection.Type != spannerpb.DirectedReadOptions_ReplicaSelection_TYPE_UNSPECIFIED {
+			replica += "/" + selection.Type.String()
+		}
+		replicas = append(replicas, replica)
+	}
+	return prefix + strings.Join(replicas, ",")
+}
+
+var commitTimestampLocationRegexp = regexp.MustCompile(`\A'(?P<location>[^']*)'\z`)
+
+func (s *statementExecutor) SetCommitTimestampLocation(_ context.Context, c *conn, params string, _ []driver.NamedValue) (driver.Result, error) {
+	if params == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "no value given for CommitTimestampLocation"))
+	}
+	if !commitTimestampLocationRegexp.MatchString(params) {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid CommitTimestampLocation value: %s", params))
+	}
+	name := matchesToMap(commitTimestampLocationRegexp, params)["location"]
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid time zone for CommitTimestampLocation: %s", params))
+	}
+	return c.setCommitTimestampLocation(loc)
+}
+
 var strongRegexp = regexp.MustCompile("(?i)'STRONG'")
 var exactStalenessRegexp = regexp.MustCompile(`(?i)'(?P<type>EXACT_STALENESS)[\t ]+(?P<duration>(\d{1,19})(s|ms|us|ns))'`)
 var maxStalenessRegexp = regexp.MustCompile(`(?i)'(?P<type>MAX_STALENESS)[\t ]+(?P<duration>(\d{1,19})(s|ms|us|ns))'`)
@@ -235,6 +630,13 @@ func createBooleanIterator(column string, value bool) (*clientSideIterator, erro
 	return createSingleValueIterator(column, value, sppb.TypeCode_BOOL)
 }
 
+// createInt64Iterator creates a row iterator with a single INT64 column with
+// one row. This is used for client side statements that return a result set
+// containing an INT64 value.
+func createInt64Iterator(column string, value int64) (*clientSideIterator, error) {
+	return createSingleValueIterator(column, value, sppb.TypeCode_INT64)
+}
+
 // createStringIterator creates a row iterator with a single STRING column with
 // one row. This is used for client side statements that return a result set
 // containing a STRING value.
@@ -150,6 +150,147 @@ func TestStatementExecutor_RetryAbortsInternally(t *testing.T) {
 	}
 }
 
+func TestStatementExecutor_Autocommit(t *testing.T) {
+	c := &conn{}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		wantValue  bool
+		setValue   string
+		wantSetErr bool
+	}{
+		{true, "false", false},
+		{false, "TRUE", false},
+		{true, "False", false},
+		{false, "true", false},
+		{true, "", true},
+		{true, "off", true},
+	} {
+		it, err := s.ShowAutocommit(ctx, c, "", nil)
+		if err != nil {
+			t.Fatalf("%d: could not get current autocommit value from connection: %v", i, err)
+		}
+		cols := it.Columns()
+		wantCols := []string{"Autocommit"}
+		if !cmp.Equal(cols, wantCols) {
+			t.Fatalf("%d: column names mismatch\nGot: %v\nWant: %v", i, cols, wantCols)
+		}
+		values := make([]driver.Value, len(cols))
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row: %v", i, err)
+		}
+		wantValues := []driver.Value{test.wantValue}
+		if !cmp.Equal(values, wantValues) {
+			t.Fatalf("%d: autocommit values mismatch\nGot: %v\nWant: %v", i, values, wantValues)
+		}
+		if err := it.Next(values); err != io.EOF {
+			t.Fatalf("%d: error mismatch\nGot: %v\nWant: %v", i, err, io.EOF)
+		}
+
+		// Set the next value.
+		res, err := s.SetAutocommit(ctx, c, test.setValue, nil)
+		if test.wantSetErr {
+			if err == nil {
+				t.Fatalf("%d: missing expected error for value %q", i, test.setValue)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%d: could not set new value %q for autocommit: %v", i, test.setValue, err)
+			}
+			if res != driver.ResultNoRows {
+				t.Fatalf("%d: result mismatch\nGot: %v\nWant: %v", i, res, driver.ResultNoRows)
+			}
+		}
+	}
+	// COMMIT and ROLLBACK are no-ops when there is no transaction.
+	if _, err := s.Commit(ctx, c, "", nil); err != nil {
+		t.Fatalf("unexpected error for COMMIT without a transaction: %v", err)
+	}
+	if _, err := s.Rollback(ctx, c, "", nil); err != nil {
+		t.Fatalf("unexpected error for ROLLBACK without a transaction: %v", err)
+	}
+}
+
+func TestStatementExecutor_OptimizerOptions(t *testing.T) {
+	c := &conn{}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		show       func(ctx context.Context, c *conn, query string, args []driver.NamedValue) (driver.Rows, error)
+		set        func(ctx context.Context, c *conn, params string, args []driver.NamedValue) (driver.Result, error)
+		setValue   string
+		wantValue  string
+		wantSetErr bool
+	}{
+		{s.ShowOptimizerVersion, s.SetOptimizerVersion, "'1'", "1", false},
+		{s.ShowOptimizerVersion, s.SetOptimizerVersion, "'LATEST'", "LATEST", false},
+		{s.ShowOptimizerVersion, s.SetOptimizerVersion, "''", "", false},
+		{s.ShowOptimizerVersion, s.SetOptimizerVersion, "1", "", true},
+		{s.ShowOptimizerVersion, s.SetOptimizerVersion, "", "", true},
+		{s.ShowOptimizerStatisticsPackage, s.SetOptimizerStatisticsPackage, "'auto_20191128_14_47_22UTC'", "auto_20191128_14_47_22UTC", false},
+		{s.ShowOptimizerStatisticsPackage, s.SetOptimizerStatisticsPackage, "''", "", false},
+		{s.ShowOptimizerStatisticsPackage, s.SetOptimizerStatisticsPackage, "'package", "", true},
+	} {
+		_, err := test.set(ctx, c, test.setValue, nil)
+		if test.wantSetErr {
+			if err == nil {
+				t.Fatalf("%d: missing expected error for value %q", i, test.setValue)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: could not set new value %q: %v", i, test.setValue, err)
+		}
+		it, err := test.show(ctx, c, "", nil)
+		if err != nil {
+			t.Fatalf("%d: could not get current value from connection: %v", i, err)
+		}
+		values := make([]driver.Value, len(it.Columns()))
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row: %v", i, err)
+		}
+		if g, w := values, []driver.Value{test.wantValue}; !cmp.Equal(g, w) {
+			t.Fatalf("%d: values mismatch\nGot: %v\nWant: %v", i, g, w)
+		}
+	}
+}
+
+func TestStatementExecutor_ReadOnly(t *testing.T) {
+	c := &conn{}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		wantValue  bool
+		setValue   string
+		wantSetErr bool
+	}{
+		{false, "true", false},
+		{true, "FALSE", false},
+		{false, "True", false},
+		{true, "", true},
+		{true, "yes", true},
+	} {
+		it, err := s.ShowReadOnly(ctx, c, "", nil)
+		if err != nil {
+			t.Fatalf("%d: could not get current read-only value from connection: %v", i, err)
+		}
+		if g, w := it.Columns(), []string{"ReadOnly"}; !cmp.Equal(g, w) {
+			t.Fatalf("%d: column names mismatch\nGot: %v\nWant: %v", i, g, w)
+		}
+		values := make([]driver.Value, 1)
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row: %v", i, err)
+		}
+		if g, w := values, []driver.Value{test.wantValue}; !cmp.Equal(g, w) {
+			t.Fatalf("%d: read-only values mismatch\nGot: %v\nWant: %v", i, g, w)
+		}
+		_, err = s.SetReadOnly(ctx, c, test.setValue, nil)
+		if test.wantSetErr != (err != nil) {
+			t.Fatalf("%d: error mismatch for value %q\nGot: %v\nWant error: %v", i, test.setValue, err, test.wantSetErr)
+		}
+	}
+}
+
 func TestStatementExecutor_AutocommitDmlMode(t *testing.T) {
 	c := &conn{}
 	s := &statementExecutor{}
@@ -319,6 +460,69 @@ func TestShowCommitTimestamp(t *testing.T) {
 	}
 }
 
+func TestStatementExecutor_CommitTimestampLocation(t *testing.T) {
+	c := &conn{retryAborts: true}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		wantValue  string
+		setValue   string
+		wantSetErr bool
+	}{
+		{"UTC", "'America/New_York'", false},
+		{"America/New_York", "'Europe/Amsterdam'", false},
+		{"Europe/Amsterdam", "'UTC'", false},
+		{"UTC", "'Mars/Olympus_Mons'", true},
+		{"UTC", "''", true},
+		{"UTC", "America/New_York", true},
+	} {
+		it, err := s.ShowCommitTimestampLocation(ctx, c, "", nil)
+		if err != nil {
+			t.Fatalf("%d: could not get current location from connection: %v", i, err)
+		}
+		cols := it.Columns()
+		wantCols := []string{"CommitTimestampLocation"}
+		if !cmp.Equal(cols, wantCols) {
+			t.Fatalf("%d: column names mismatch\nGot: %v\nWant: %v", i, cols, wantCols)
+		}
+		values := make([]driver.Value, len(cols))
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row: %v", i, err)
+		}
+		wantValues := []driver.Value{test.wantValue}
+		if !cmp.Equal(values, wantValues) {
+			t.Fatalf("%d: location values mismatch\nGot: %v\nWant: %v", i, values, wantValues)
+		}
+
+		// Set the next value.
+		_, err = s.SetCommitTimestampLocation(ctx, c, test.setValue, nil)
+		if test.wantSetErr {
+			if spanner.ErrCode(err) != codes.InvalidArgument {
+				t.Fatalf("%d: error mismatch for value %q\nGot: %v\nWant: %v", i, test.setValue, err, codes.InvalidArgument)
+			}
+		} else if err != nil {
+			t.Fatalf("%d: could not set new value %q for location: %v", i, test.setValue, err)
+		}
+	}
+
+	// The commit timestamp is returned in the location of the connection.
+	if _, err := s.SetCommitTimestampLocation(ctx, c, "'America/New_York'", nil); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c.commitTs = &ts
+	commitTs, err := c.CommitTimestamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := commitTs.Location().String(), "America/New_York"; g != w {
+		t.Fatalf("location mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if !commitTs.Equal(ts) {
+		t.Fatalf("commit timestamp mismatch\nGot: %v\nWant: %v", commitTs, ts)
+	}
+}
+
 func TestStatementExecutor_ExcludeTxnFromChangeStreams(t *testing.T) {
 	c := &conn{retryAborts: true}
 	s := &statementExecutor{}
@@ -374,3 +578,249 @@ func TestStatementExecutor_ExcludeTxnFromChangeStreams(t *testing.T) {
 		}
 	}
 }
+
+func TestStatementExecutor_RpcPriority(t *testing.T) {
+	c := &conn{retryAborts: true}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		wantValue  string
+		setValue   string
+		wantSetErr bool
+	}{
+		{"NULL", "'LOW'", false},
+		{"LOW", "'medium'", false},
+		{"MEDIUM", "'High'", false},
+		{"HIGH", "'NULL'", false},
+		{"NULL", "'UNSPECIFIED'", true},
+		{"NULL", "LOW", true},
+		{"NULL", "'lowest'", true},
+	} {
+		it, err := s.ShowRpcPriority(ctx, c, "", nil)
+		if err != nil {
+			t.Fatalf("%d: could not get current rpc priority from connection: %v", i, err)
+		}
+		cols := it.Columns()
+		wantCols := []string{"RpcPriority"}
+		if !cmp.Equal(cols, wantCols) {
+			t.Fatalf("%d: column names mismatch\nGot: %v\nWant: %v", i, cols, wantCols)
+		}
+		values := make([]driver.Value, len(cols))
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row: %v", i, err)
+		}
+		wantValues := []driver.Value{test.wantValue}
+		if !cmp.Equal(values, wantValues) {
+			t.Fatalf("%d: rpc priority values mismatch\nGot: %v\nWant: %v", i, values, wantValues)
+		}
+
+		// Set the next value.
+		res, err := s.SetRpcPriority(ctx, c, test.setValue, nil)
+		if test.wantSetErr {
+			if err == nil {
+				t.Fatalf("%d: missing expected error for value %q", i, test.setValue)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%d: could not set new value %q for rpc priority: %v", i, test.setValue, err)
+			}
+			if res != driver.ResultNoRows {
+				t.Fatalf("%d: result mismatch\nGot: %v\nWant: %v", i, res, driver.ResultNoRows)
+			}
+		}
+	}
+}
+
+func TestParseDirectedReadOptions(t *testing.T) {
+	for _, test := range []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "us-east1/READ_ONLY", want: "us-east1/READ_ONLY"},
+		{value: "us-east1, us-west1/read_write", want: "us-east1,us-west1/READ_WRITE"},
+		{value: "exclude us-central1", want: "EXCLUDE us-central1"},
+		{value: "/READ_ONLY", want: "/READ_ONLY"},
+		{value: "", want: "NULL"},
+		{value: "null", want: "NULL"},
+		{value: "us-east1/", wantErr: true},
+		{value: "us-east1/TYPE_UNSPECIFIED", wantErr: true},
+		{value: "us-east1,,us-west1", wantErr: true},
+	} {
+		options, err := parseDirectedReadOptions(test.value)
+		if test.wantErr {
+			if spanner.ErrCode(err) != codes.InvalidArgument {
+				t.Errorf("%q: error code mismatch\nGot: %v\nWant: %v", test.value, spanner.ErrCode(err), codes.InvalidArgument)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.value, err)
+			continue
+		}
+		if g, w := formatDirectedReadOptions(options), test.want; g != w {
+			t.Errorf("%q: directed read mismatch\nGot: %v\nWant: %v", test.value, g, w)
+		}
+	}
+}
+
+func TestStatementExecutor_StatementTimeout(t *testing.T) {
+	c := &conn{retryAborts: true}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		wantValue  string
+		setValue   string
+		wantSetErr bool
+	}{
+		{"NULL", "'10s'", false},
+		{"10s", "'100ms'", false},
+		{"100ms", "'0'", false},
+		{"NULL", "'1m30s'", false},
+		{"1m30s", "NULL", false},
+		{"NULL", "'500us'", false},
+		{"500µs", "'NULL'", false},
+		{"NULL", "'-1s'", true},
+		{"NULL", "10s", true},
+		{"NULL", "'forever'", true},
+		{"NULL", "", true},
+	} {
+		it, err := s.ShowStatementTimeout(ctx, c, "", nil)
+		if err != nil {
+			t.Fatalf("%d: could not get current statement timeout from connection: %v", i, err)
+		}
+		cols := it.Columns()
+		wantCols := []string{"StatementTimeout"}
+		if !cmp.Equal(cols, wantCols) {
+			t.Fatalf("%d: column names mismatch\nGot: %v\nWant: %v", i, cols, wantCols)
+		}
+		values := make([]driver.Value, len(cols))
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row: %v", i, err)
+		}
+		wantValues := []driver.Value{test.wantValue}
+		if !cmp.Equal(values, wantValues) {
+			t.Fatalf("%d: statement timeout values mismatch\nGot: %v\nWant: %v", i, values, wantValues)
+		}
+
+		// Set the next value.
+		res, err := s.SetStatementTimeout(ctx, c, test.setValue, nil)
+		if test.wantSetErr {
+			if spanner.ErrCode(err) != codes.InvalidArgument {
+				t.Fatalf("%d: error code mismatch for value %q\nGot: %v\nWant: %v", i, test.setValue, spanner.ErrCode(err), codes.InvalidArgument)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%d: could not set new value %q for statement timeout: %v", i, test.setValue, err)
+			}
+			if res != driver.ResultNoRows {
+				t.Fatalf("%d: result mismatch\nGot: %v\nWant: %v", i, res, driver.ResultNoRows)
+			}
+		}
+	}
+}
+
+func TestStatementExecutor_MaxCommitDelay(t *testing.T) {
+	c := &conn{retryAborts: true}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		wantValue  string
+		setValue   string
+		wantSetErr bool
+	}{
+		{"NULL", "'100ms'", false},
+		{"100ms", "'0s'", false},
+		{"0s", "'500ms'", false},
+		{"500ms", "NULL", false},
+		{"NULL", "'500000us'", false},
+		{"500ms", "'NULL'", false},
+		{"NULL", "'501ms'", true},
+		{"NULL", "'1s'", true},
+		{"NULL", "'-1ms'", true},
+		{"NULL", "100ms", true},
+		{"NULL", "'fast'", true},
+	} {
+		it, err := s.ShowMaxCommitDelay(ctx, c, "", nil)
+		if err != nil {
+			t.Fatalf("%d: could not get current max commit delay from connection: %v", i, err)
+		}
+		cols := it.Columns()
+		wantCols := []string{"MaxCommitDelay"}
+		if !cmp.Equal(cols, wantCols) {
+			t.Fatalf("%d: column names mismatch\nGot: %v\nWant: %v", i, cols, wantCols)
+		}
+		values := make([]driver.Value, len(cols))
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row: %v", i, err)
+		}
+		wantValues := []driver.Value{test.wantValue}
+		if !cmp.Equal(values, wantValues) {
+			t.Fatalf("%d: max commit delay values mismatch\nGot: %v\nWant: %v", i, values, wantValues)
+		}
+
+		// Set the next value.
+		res, err := s.SetMaxCommitDelay(ctx, c, test.setValue, nil)
+		if test.wantSetErr {
+			if spanner.ErrCode(err) != codes.InvalidArgument {
+				t.Fatalf("%d: error code mismatch for value %q\nGot: %v\nWant: %v", i, test.setValue, spanner.ErrCode(err), codes.InvalidArgument)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%d: could not set new value %q for max commit delay: %v", i, test.setValue, err)
+			}
+			if res != driver.ResultNoRows {
+				t.Fatalf("%d: result mismatch\nGot: %v\nWant: %v", i, res, driver.ResultNoRows)
+			}
+		}
+	}
+}
+
+func TestStatementExecutor_Variables(t *testing.T) {
+	c := &conn{retryAborts: true}
+	s := &statementExecutor{}
+	ctx := context.Background()
+	for i, test := range []struct {
+		set       string
+		show      string
+		wantValue driver.Value
+	}{
+		{"retry_aborts_internally = false", "retry_aborts_internally", false},
+		{"Retry_Aborts_Internally=true", "RETRY_ABORTS_INTERNALLY", true},
+		{"autocommit_dml_mode = 'Partitioned_Non_Atomic'", "autocommit_dml_mode", "Partitioned_Non_Atomic"},
+		{"read_only_staleness = 'EXACT_STALENESS 10s'", "read_only_staleness", "(exactStaleness: 10s)"},
+	} {
+		if _, err := s.SetVariable(ctx, c, test.set, nil); err != nil {
+			t.Fatalf("%d: failed to set variable %q: %v", i, test.set, err)
+		}
+		it, err := s.ShowVariable(ctx, c, test.show, nil)
+		if err != nil {
+			t.Fatalf("%d: failed to show variable %q: %v", i, test.show, err)
+		}
+		values := make([]driver.Value, len(it.Columns()))
+		if err := it.Next(values); err != nil {
+			t.Fatalf("%d: failed to get first row for %q: %v", i, test.show, err)
+		}
+		if g, w := values[0], test.wantValue; g != w {
+			t.Fatalf("%d: value mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+	}
+
+	for _, test := range []struct {
+		set  string
+		show string
+	}{
+		{"unknown_variable = true", "unknown_variable"},
+		{"commit_timestamp = '2026-01-01T00:00:00Z'", ""},
+	} {
+		if _, err := s.SetVariable(ctx, c, test.set, nil); spanner.ErrCode(err) != codes.InvalidArgument {
+			t.Fatalf("error code mismatch for %q\n Got: %v\nWant: %v", test.set, spanner.ErrCode(err), codes.InvalidArgument)
+		}
+		if test.show == "" {
+			continue
+		}
+		if _, err := s.ShowVariable(ctx, c, test.show, nil); spanner.ErrCode(err) != codes.InvalidArgument {
+			t.Fatalf("error code mismatch for %q\n Got: %v\nWant: %v", test.show, spanner.ErrCode(err), codes.InvalidArgument)
+		}
+	}
+}
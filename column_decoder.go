@@ -0,0 +1,96 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ColumnDecoder decodes a column value that is returned by Spanner to the
+// value that is returned by the driver. The returned value is passed to
+// database/sql, which converts it to the type of the destination of Scan.
+type ColumnDecoder func(value spanner.GenericColumnValue) (driver.Value, error)
+
+// RegisterColumnTypeDecoder registers a decoder for all columns of the given
+// Spanner type for all connections of the given connector. The connector must
+// have been created with CreateConnector. A decoder for TypeCode_ARRAY is
+// used for all arrays, regardless of the type of the elements. Registering a
+// decoder for a type replaces any decoder that was registered earlier for the
+// same type, and registering a nil decoder removes it. The decoder is used for
+// queries that are started after it has been registered.
+//
+// A registered decoder takes precedence over the decoding of the driver,
+// including the decoding options in ExecOptions, such as NullAsZeroValue and
+// DecodeToNativeArrays. The value that is returned by the decoder is converted
+// to the destination of Scan by database/sql in the same way as the values
+// that are returned by the driver, so a destination that implements
+// sql.Scanner receives the value that was returned by the decoder.
+//
+// Example:
+//
+//	connector, err := spannerdriver.CreateConnector(dsn, spannerdriver.ConnectorConfig{})
+//	if err != nil {
+//		return err
+//	}
+//	err = spannerdriver.RegisterColumnTypeDecoder(connector, sppb.TypeCode_BYTES,
+//		func(value spanner.GenericColumnValue) (driver.Value, error) {
+//			var b []byte
+//			if err := value.Decode(&b); err != nil {
+//				return nil, err
+//			}
+//			return decrypt(b)
+//		})
+func RegisterColumnTypeDecoder(c driver.Connector, typeCode sppb.TypeCode, decoder ColumnDecoder) error {
+	spannerConnector, ok := c.(*connector)
+	if !ok {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "not a Spanner connector: %T", c))
+	}
+	spannerConnector.registerColumnTypeDecoder(typeCode, decoder)
+	return nil
+}
+
+// registerColumnTypeDecoder registers the given decoder for the given type.
+// The map with decoders is replaced instead of modified, so rows can use the
+// map that they were created with without locking.
+func (c *connector) registerColumnTypeDecoder(typeCode sppb.TypeCode, decoder ColumnDecoder) {
+	c.decodersMu.Lock()
+	defer c.decodersMu.Unlock()
+	decoders := make(map[sppb.TypeCode]ColumnDecoder, len(c.decoders)+1)
+	for k, v := range c.decoders {
+		decoders[k] = v
+	}
+	if decoder == nil {
+		delete(decoders, typeCode)
+	} else {
+		decoders[typeCode] = decoder
+	}
+	c.decoders = decoders
+}
+
+// columnDecoders returns the decoders that have been registered for the
+// connector of the connection. The returned map must not be modified.
+func (c *conn) columnDecoders() map[sppb.TypeCode]ColumnDecoder {
+	if c.connector == nil {
+		return nil
+	}
+	c.connector.decodersMu.RLock()
+	defer c.connector.decodersMu.RUnlock()
+	return c.connector.decoders
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRegisterColumnTypeDecoder(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address)
+	connector, err := CreateConnector(dsn, ConnectorConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := RegisterColumnTypeDecoder(connector, sppb.TypeCode_INT64, func(value spanner.GenericColumnValue) (driver.Value, error) {
+		var v int64
+		if err := value.Decode(&v); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("decoded-%d", v), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	query := func() []string {
+		// The decoder takes precedence over the decoding options of the driver.
+		rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar, ExecOptions{NullAsZeroValue: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var values []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, v)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+	if g, w := query(), []string{"decoded-1", "decoded-2"}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("values mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Removing the decoder restores the default decoding.
+	if err := RegisterColumnTypeDecoder(connector, sppb.TypeCode_INT64, nil); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := query(), []string{"1", "2"}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("values mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if err := RegisterColumnTypeDecoder(nil, sppb.TypeCode_INT64, nil); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
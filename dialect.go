@@ -0,0 +1,145 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Dialect is the SQL dialect of a Spanner database.
+type Dialect int
+
+const (
+	// DialectUnspecified indicates that the dialect is not known.
+	DialectUnspecified Dialect = iota
+	// GoogleSQL is the GoogleSQL dialect.
+	GoogleSQL
+	// PostgreSQL is the PostgreSQL dialect.
+	PostgreSQL
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DialectUnspecified:
+		return "DIALECT_UNSPECIFIED"
+	case GoogleSQL:
+		return "GOOGLE_STANDARD_SQL"
+	case PostgreSQL:
+		return "POSTGRESQL"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// dialectQuery returns the dialect of the database. The query is valid in
+// both GoogleSQL and PostgreSQL.
+const dialectQuery = "SELECT option_value FROM information_schema.database_options WHERE option_name='database_dialect'"
+
+// DatabaseDialect returns the SQL dialect of the database of the given
+// sql.DB. The dialect is read from the database the first time that it is
+// requested, and is cached for all subsequent calls for the same connector.
+//
+// Example:
+//
+//	dialect, err := spannerdriver.DatabaseDialect(ctx, db)
+//	if err != nil {
+//		return err
+//	}
+//	if dialect == spannerdriver.PostgreSQL {
+//		// Use PostgreSQL syntax.
+//	}
+func DatabaseDialect(ctx context.Context, db *sql.DB) (Dialect, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return DialectUnspecified, err
+	}
+	defer conn.Close()
+	var dialect Dialect
+	if err := conn.Raw(func(driverConn interface{}) error {
+		spannerConn, ok := driverConn.(SpannerConn)
+		if !ok {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "not a Spanner connection: %T", driverConn))
+		}
+		dialect, err = spannerConn.DatabaseDialect(ctx)
+		return err
+	}); err != nil {
+		return DialectUnspecified, err
+	}
+	return dialect, nil
+}
+
+func (c *conn) DatabaseDialect(ctx context.Context) (Dialect, error) {
+	return c.connector.databaseDialect(ctx, c.client)
+}
+
+// statementDialect returns the dialect that is used to parse the parameters
+// of the given statement. The dialect is only read from the database if it is
+// not yet known and the statement contains a $ sign, which could be a
+// PostgreSQL parameter. GoogleSQL is used for all other statements, as
+// GoogleSQL statements never use a $ sign outside literals.
+func (c *conn) statementDialect(ctx context.Context, query string) (Dialect, error) {
+	if c.connector == nil {
+		return GoogleSQL, nil
+	}
+	c.connector.dialectMu.Lock()
+	dialect := c.connector.dialect
+	c.connector.dialectMu.Unlock()
+	if dialect != DialectUnspecified {
+		return dialect, nil
+	}
+	if !strings.Contains(query, "$") {
+		return GoogleSQL, nil
+	}
+	return c.DatabaseDialect(ctx)
+}
+
+// databaseDialect returns the cached dialect of the database of this
+// connector, or reads it from the database if it has not yet been read.
+func (c *connector) databaseDialect(ctx context.Context, client *spanner.Client) (Dialect, error) {
+	c.dialectMu.Lock()
+	defer c.dialectMu.Unlock()
+	if c.dialect != DialectUnspecified {
+		return c.dialect, nil
+	}
+	iter := client.Single().Query(ctx, spanner.Statement{SQL: dialectQuery})
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err == iterator.Done {
+		return DialectUnspecified, spanner.ToSpannerError(status.Errorf(codes.NotFound, "the database did not return a dialect"))
+	}
+	if err != nil {
+		return DialectUnspecified, err
+	}
+	var value string
+	if err := row.Columns(&value); err != nil {
+		return DialectUnspecified, err
+	}
+	switch value {
+	case GoogleSQL.String():
+		c.dialect = GoogleSQL
+	case PostgreSQL.String():
+		c.dialect = PostgreSQL
+	default:
+		return DialectUnspecified, spanner.ToSpannerError(status.Errorf(codes.Unknown, "unknown database dialect: %q", value))
+	}
+	return c.dialect, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func createDialectResultSet(dialect string) *sppb.ResultSet {
+	return &sppb.ResultSet{
+		Metadata: &sppb.ResultSetMetadata{
+			RowType: &sppb.StructType{
+				Fields: []*sppb.StructType_Field{
+					{Name: "option_value", Type: &sppb.Type{Code: sppb.TypeCode_STRING}},
+				},
+			},
+		},
+		Rows: []*structpb.ListValue{
+			{Values: []*structpb.Value{structpb.NewStringValue(dialect)}},
+		},
+	}
+}
+
+func TestDatabaseDialect(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_ = server.TestSpanner.PutStatementResult(dialectQuery, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: createDialectResultSet("POSTGRESQL"),
+	})
+	for i := 0; i < 2; i++ {
+		dialect, err := DatabaseDialect(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, w := dialect, PostgreSQL; g != w {
+			t.Fatalf("dialect mismatch\n Got: %v\nWant: %v", g, w)
+		}
+	}
+	// The dialect is only read once.
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestPostgreSQLParameters(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name        string
+		params      string
+		wantDialect bool
+	}{
+		{name: "connection string", params: "dialect=postgresql"},
+		{name: "detected", wantDialect: true},
+	} {
+		db, server, teardown := setupTestDBConnectionWithParams(t, test.params)
+		ctx := context.Background()
+		_ = server.TestSpanner.PutStatementResult(dialectQuery, &testutil.StatementResult{
+			Type:      testutil.StatementResultResultSet,
+			ResultSet: createDialectResultSet("POSTGRESQL"),
+		})
+		query := "SELECT * FROM Singers WHERE SingerId=$2 AND Active=$1"
+		_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+			Type:      testutil.StatementResultResultSet,
+			ResultSet: testutil.CreateSingleColumnResultSet([]int64{1}, "SingerId"),
+		})
+		var id int64
+		if err := db.QueryRowContext(ctx, query+";", true, int64(1)).Scan(&id); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		wantRequests := 1
+		if test.wantDialect {
+			wantRequests++
+		}
+		if g, w := len(requests), wantRequests; g != w {
+			t.Fatalf("%s: request count mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		req := requests[len(requests)-1].(*sppb.ExecuteSqlRequest)
+		if g, w := req.Sql, query; g != w {
+			t.Fatalf("%s: sql mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		if g, w := req.Params.Fields["p1"].GetBoolValue(), true; g != w {
+			t.Fatalf("%s: p1 mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		if g, w := req.Params.Fields["p2"].GetStringValue(), "1"; g != w {
+			t.Fatalf("%s: p2 mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		// A missing argument is rejected before the statement is sent.
+		if err := db.QueryRowContext(ctx, query, true).Scan(&id); spanner.ErrCode(err) != codes.InvalidArgument {
+			t.Fatalf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, spanner.ErrCode(err), codes.InvalidArgument)
+		}
+		teardown()
+	}
+}
+
+func TestDatabaseDialect_Unknown(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_ = server.TestSpanner.PutStatementResult(dialectQuery, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: createDialectResultSet("UNKNOWN_DIALECT"),
+	})
+	if _, err := DatabaseDialect(ctx, db); err == nil {
+		t.Fatal("missing error for unknown dialect")
+	}
+	// The dialect is not cached if it could not be determined.
+	_ = server.TestSpanner.PutStatementResult(dialectQuery, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: createDialectResultSet("GOOGLE_STANDARD_SQL"),
+	})
+	dialect, err := DatabaseDialect(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := dialect, GoogleSQL; g != w {
+		t.Fatalf("dialect mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
@@ -18,8 +18,12 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
+	"net/url"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -31,11 +35,17 @@ import (
 	"cloud.google.com/go/spanner"
 	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
 	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	vkit "cloud.google.com/go/spanner/apiv1"
 	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/gax-go/v2"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 const userAgent = "go-sql-spanner/1.0.2"
@@ -44,31 +54,325 @@ const userAgent = "go-sql-spanner/1.0.2"
 // Google Cloud Spanner. The string consists of the following parts:
 //  1. (Optional) Host: The host name and port number to connect to.
 //  2. Database name: The database name to connect to in the format `projects/my-project/instances/my-instance/databases/my-database`
-//  3. (Optional) Parameters: One or more parameters in the format `name=value`. Multiple entries are separated by `;`.
+//  3. (Optional) Parameters: One or more parameters in the format `name=value`. The parameters start after the first
+//     `;` or `?` after the database name. Multiple entries are separated by `;`. Entries may also be separated by `&`
+//     if the parameters start with `?`, as in a URL query string. Names and values may be percent-encoded, and values
+//     that contain `;`, `&`, `%` or other reserved characters must be percent-encoded, for example `%3B` for `;`. A `+`
+//     is not decoded as a space.
 //     The supported parameters are:
 //     - credentials: File name for the credentials to use. The connection will use the default credentials of the
 //     environment if no credentials file is specified in the connection string.
 //     - usePlainText: Boolean that indicates whether the connection should use plain text communication or not. Set this
 //     to true to connect to local mock servers that do not use SSL.
+//     - autoConfigEmulator: Boolean that indicates whether the connection should connect to the Spanner emulator, and
+//     create the instance and the database on the emulator if they do not exist. The emulator host is the host in the
+//     connection string, the SPANNER_EMULATOR_HOST environment variable or localhost:9010. The default is false.
 //     - retryAbortsInternally: Boolean that indicates whether the connection should automatically retry aborted errors.
 //     The default is true.
+//     - maxCommitRetries: The maximum number of times that an aborted read/write transaction is retried internally. The
+//     default is 0, which means that the number of retries is not limited.
 //     - disableRouteToLeader: Boolean that indicates if all the requests of type read-write and PDML
 //     need to be routed to the leader region.
 //     The default is false
 //     - minSessions: The minimum number of sessions in the backing session pool. The default is 100.
+//     - prewarmSessions: Boolean that indicates whether the session pool should be created and filled with the minimum
+//     number of sessions when the connector is created, instead of when the first connection is opened. The default is
+//     false.
+//     - waitForMinSessions: The maximum time that opening the first connection waits until the session pool contains
+//     the minimum number of sessions, for example 10s. The default is 0, which means that it does not wait.
 //     - maxSessions: The maximum number of sessions in the backing session pool. The default is 400.
 //     - numChannels: The number of gRPC channels to use to communicate with Cloud Spanner. The default is 4.
 //     - optimizerVersion: Sets the default query optimizer version to use for this connection.
 //     - optimizerStatisticsPackage: Sets the default query optimizer statistic package to use for this connection.
 //     - rpcPriority: Sets the priority for all RPC invocations from this connection (HIGH/MEDIUM/LOW). The default is HIGH.
+//     - autoMarshalJson: Boolean that indicates whether query parameters of types that are not supported by the driver,
+//     such as maps, structs and slices of structs, should be marshalled to JSON and sent to Spanner as JSON values.
+//     A nil map, slice or pointer is sent as a JSON null value. The default is false.
+//     - dialect: The SQL dialect of the database (GoogleSQL or PostgreSQL). PostgreSQL statements use positional
+//     parameters in the form $1, $2, .... The dialect is read from the database the first time that a statement
+//     contains a $ sign if no dialect is specified.
+//
+// Boolean properties accept the values true, false, 1 and 0. Duration properties accept values like 10s or 500ms.
+// An invalid value for a property causes the connector to fail with an InvalidArgument error.
 //
 // Example: `localhost:9010/projects/test-project/instances/test-instance/databases/test-database;usePlainText=true;disableRouteToLeader=true`
-var dsnRegExp = regexp.MustCompile(`((?P<HOSTGROUP>[\w.-]+(?:\.[\w\.-]+)*[\w\-\._~:/?#\[\]@!\$&'\(\)\*\+,;=.]+)/)?projects/(?P<PROJECTGROUP>(([a-z]|[-.:]|[0-9])+|(DEFAULT_PROJECT_ID)))(/instances/(?P<INSTANCEGROUP>([a-z]|[-]|[0-9])+)(/databases/(?P<DATABASEGROUP>([a-z]|[-]|[_]|[0-9])+))?)?(([\?|;])(?P<PARAMSGROUP>.*))?`)
+// Example: `projects/test-project/instances/test-instance/databases/test-database?minSessions=10&credentials=/path/my%20credentials.json`
+var dsnRegExp = regexp.MustCompile(`((?P<HOSTGROUP>[\w.-]+(?:\.[\w\.-]+)*[\w\-\._~:/?#\[\]@!\$&'\(\)\*\+,;=.]+)/)?projects/(?P<PROJECTGROUP>(([a-z]|[-.:]|[0-9])+|(DEFAULT_PROJECT_ID)))(/instances/(?P<INSTANCEGROUP>([a-z]|[-]|[0-9])+)(/databases/(?P<DATABASEGROUP>([a-z]|[-]|[_]|[0-9])+))?)?((?P<PARAMSSEPARATOR>[\?|;])(?P<PARAMSGROUP>.*))?`)
 
 var _ driver.DriverContext = &Driver{}
 
+var spannerDriver *Driver
+
 func init() {
-	sql.Register("spanner", &Driver{connectors: make(map[string]*connector)})
+	spannerDriver = &Driver{connectors: make(map[string]*connector)}
+	sql.Register("spanner", spannerDriver)
+}
+
+// ConnectorConfig contains the configuration of a connector. A connector can
+// be created from a ConnectorConfig alone with NewConnector, or from a
+// connection string and additional configuration with CreateConnector.
+type ConnectorConfig struct {
+	// Project, Instance and Database identify the database that the
+	// connector connects to. These are required for NewConnector. The values
+	// in the connection string are used for CreateConnector.
+	Project  string
+	Instance string
+	Database string
+
+	// CredentialsFile is the name of a file with the credentials that are
+	// used to connect to Spanner. The default credentials of the environment
+	// are used if neither CredentialsFile nor CredentialsJSON is set. This is
+	// the same as the credentials connection property.
+	CredentialsFile string
+	// CredentialsJSON are the credentials in JSON format that are used to
+	// connect to Spanner.
+	CredentialsJSON []byte
+	// EmulatorHost is the host and port of the Spanner emulator, for example
+	// localhost:9010. The connector connects to the emulator using plain text
+	// and without authentication if this is set. The value of the
+	// SPANNER_EMULATOR_HOST environment variable is used if EmulatorHost is
+	// empty and the connection string does not contain a host.
+	EmulatorHost string
+	// AutoConfigEmulator configures the connector for the Spanner emulator.
+	// The connector then connects to the emulator using plain text and
+	// without authentication, and creates the instance and the database of
+	// the connector on the emulator if they do not exist when the first
+	// connection is opened. The emulator host is EmulatorHost, the host in
+	// the connection string, the SPANNER_EMULATOR_HOST environment variable
+	// or localhost:9010, in that order. This is the same as the
+	// autoConfigEmulator connection property.
+	//
+	// AutoConfigEmulator must only be used with the emulator, and is never
+	// enabled automatically, so connections to Spanner never create
+	// instances or databases.
+	AutoConfigEmulator bool
+	// SessionPoolConfig is the configuration of the session pool of the
+	// Spanner client. spanner.DefaultSessionPoolConfig is used if this is nil.
+	// The minSessions and maxSessions connection properties override the
+	// values in this configuration.
+	SessionPoolConfig *spanner.SessionPoolConfig
+	// MinSessions is the minimum number of sessions in the session pool. This
+	// overrides SessionPoolConfig.MinOpened if it is not zero, and is
+	// overridden by the minSessions connection property.
+	MinSessions uint64
+	// PrewarmSessions creates the Spanner client when the connector is
+	// created, instead of when the first connection is opened. The session
+	// pool of the client then starts to create the minimum number of sessions
+	// in the background straight away, so these are available for the first
+	// statements of the application. This is the same as the prewarmSessions
+	// connection property.
+	PrewarmSessions bool
+	// WaitForMinSessions is the maximum time that opening the first
+	// connection waits until the session pool has created the minimum number
+	// of sessions. The first connection, and the first Ping or query that
+	// opened it, fails with a DeadlineExceeded error if the sessions have not
+	// been created within this time. Connections that are opened after the
+	// first connection do not wait. The default is zero, which means that
+	// connections never wait for sessions to be created. This is the same as
+	// the waitForMinSessions connection property.
+	//
+	// Use this in combination with PrewarmSessions and a Ping at startup to
+	// prevent the first statements of an application from waiting for
+	// sessions, at the cost of a slower startup.
+	WaitForMinSessions time.Duration
+
+	// OnStatementComplete is called each time that a statement has finished
+	// on a connection of the connector. Client-side statements, such as
+	// `SHOW VARIABLE` and `START BATCH DDL`, are not reported. The callback
+	// is called on the goroutine that executed the statement, and should not
+	// block.
+	OnStatementComplete func(info StatementInfo)
+
+	// QueryCacheSize is the maximum number of query results that are cached
+	// by the connector. The query cache is disabled if this is zero. Only
+	// queries that are executed with ExecOptions{Cacheable: true} outside a
+	// transaction are cached. The results are cached per SQL string,
+	// parameter values and read-only staleness, and are shared by all
+	// connections of the connector.
+	//
+	// Cached results are only invalidated when they are older than
+	// QueryCacheTTL. Writes to the database do not invalidate the cache, and
+	// the query cache should therefore only be used for small tables that
+	// rarely change, such as lookup tables.
+	QueryCacheSize int
+	// QueryCacheTTL is the time that a query result is kept in the query
+	// cache.
+	QueryCacheTTL time.Duration
+
+	// Retryer returns the retryer that is used to retry failed RPCs that
+	// read or write data, such as UNAVAILABLE errors for queries and DML
+	// statements. A new retryer is created for each RPC. The retryer is used
+	// for the ExecuteSql, ExecuteStreamingSql, ExecuteBatchDml, Read,
+	// StreamingRead, BeginTransaction, Commit, PartitionQuery and
+	// PartitionRead RPCs. Session management RPCs use the default retry
+	// policy of the Spanner client. The default retry policy of the Spanner
+	// client is also used if Retryer is nil.
+	//
+	// The retryer only retries individual RPCs. Aborted transactions are
+	// still retried by the driver as a whole, independently of this retryer.
+	// Errors of streaming RPCs that occur after the stream has returned data
+	// are resumed by the Spanner client and are not passed to the retryer.
+	//
+	// Example:
+	//
+	//	Retryer: func() gax.Retryer {
+	//		return gax.OnCodes([]codes.Code{codes.Unavailable}, gax.Backoff{
+	//			Initial: 20 * time.Millisecond,
+	//			Max:     time.Second,
+	//		})
+	//	},
+	Retryer func() gax.Retryer
+
+	// MaxCommitRetries is the maximum number of times that a read/write
+	// transaction is retried internally if it is aborted by Spanner. The
+	// statement or commit that was aborted then returns a
+	// *RetryBudgetExceededError that wraps the last Aborted error. The number
+	// of retries is not limited if this is zero. This is the default for the
+	// MAX_COMMIT_RETRIES variable of the connections of the connector, and
+	// the same as the maxCommitRetries connection property.
+	MaxCommitRetries int
+	// AbortedRetryBackoff is the backoff that is used between the internal
+	// retries of an aborted read/write transaction, if Spanner does not
+	// return a retry delay with the Aborted error. Aborted transactions are
+	// retried without delay if this is nil, unless Spanner returns a retry
+	// delay.
+	//
+	// Example:
+	//
+	//	AbortedRetryBackoff: &gax.Backoff{
+	//		Initial:    20 * time.Millisecond,
+	//		Max:        time.Second,
+	//		Multiplier: 1.5,
+	//	},
+	AbortedRetryBackoff *gax.Backoff
+
+	// MaxConcurrentReads is the maximum number of queries and reads that may
+	// stream results at the same time on all connections of the connector.
+	// This limits the memory that is used by large result sets that are read
+	// by many goroutines at the same time, independently of the size of the
+	// session pool. The number of concurrent reads is not limited if this is
+	// zero.
+	//
+	// A query that would exceed the limit waits until one of the other
+	// queries has finished. A query has finished when all rows have been
+	// consumed, when an error occurs, or when the rows are closed. A query
+	// that is waiting returns the error of the context of the query if the
+	// context is cancelled or the deadline is exceeded before the query can
+	// start. Queries that are answered from the query cache do not count
+	// towards the limit. Note that a goroutine that keeps more rows open than
+	// the limit at the same time waits for itself, and will only continue
+	// when the context of the query is done.
+	MaxConcurrentReads int
+
+	// AutoSplitMutationBatches splits mutations that are applied outside a
+	// transaction into multiple commits if Spanner rejects them because they
+	// exceed the limit of 80,000 mutations per commit. This applies to
+	// mutations that are written with Apply, or with BufferWrite on a
+	// connection in autocommit mode. The mutations are then no longer
+	// written atomically, and a failed commit can leave the mutations of
+	// earlier commits written. Mutations in a transaction are never split, as
+	// a transaction must be committed atomically.
+	AutoSplitMutationBatches bool
+
+	// ConnectTimeout is the maximum time that the connector may use to create
+	// the Spanner client and to verify that the database can be reached when
+	// the first connection is opened. The first connection executes a
+	// `SELECT 1` query to verify the connection, which also creates the
+	// first session. Opening the connection, and the first Ping or query that
+	// opened it, fails with a DeadlineExceeded error if this takes longer than
+	// ConnectTimeout, for example because the network or the credentials are
+	// misconfigured. Connections that are opened after the first connection
+	// has been verified are not checked again.
+	//
+	// The default is zero, which means that no timeout is applied and that
+	// the first connection is not verified. Creating the client and sessions
+	// is then only bounded by the context of the first Ping or query.
+	ConnectTimeout time.Duration
+
+	// KeepAliveInterval is the interval at which the connector executes a
+	// `SELECT 1` query in the background to keep the connection with
+	// Spanner warm, and to detect problems with the connection before they
+	// affect the application. This can be useful for deployments with little
+	// traffic. The keep-alive queries are started when the first connection
+	// is opened, and stopped when the sql.DB is closed. The default is zero,
+	// which disables the keep-alive queries.
+	//
+	// Each keep-alive query uses one session of the session pool for the
+	// duration of the query, and is counted as a normal query by Spanner. A
+	// short interval therefore costs more than it gains.
+	KeepAliveInterval time.Duration
+	// OnKeepAliveFailed is called each time that a keep-alive query fails. It
+	// is called on the goroutine that executes the keep-alive queries, and
+	// should not block.
+	OnKeepAliveFailed func(err error)
+
+	// TracerProvider is the OpenTelemetry tracer provider that is used to
+	// create spans for the statements, commits and rollbacks that are
+	// executed on the connections of the connector, and for the internal
+	// retries of aborted transactions. The global tracer provider is used if
+	// this is nil. Use noop.NewTracerProvider() from
+	// go.opentelemetry.io/otel/trace/noop to disable tracing.
+	TracerProvider trace.TracerProvider
+	// MeterProvider is the OpenTelemetry meter provider that is used to
+	// record the metrics of the connector, such as the latency of statements,
+	// the number of sessions that are created, and the number of read/write
+	// transactions that are committed, rolled back and retried. All metrics
+	// include the name of the database as the 'database' attribute. The
+	// global meter provider is used if this is nil.
+	//
+	// The MeterProvider is also passed to the Spanner client for its session
+	// pool metrics, such as the number of sessions in use. The Spanner client
+	// only records these metrics if they have been enabled for the entire
+	// process, so the connector does not enable them. Call
+	// spanner.EnableOpenTelemetryMetrics before creating the connector to
+	// record them.
+	MeterProvider metric.MeterProvider
+}
+
+// CreateConnector creates a new connector for the given connection string and
+// additional configuration. The connector can be used with sql.OpenDB.
+//
+// Example:
+//
+//	connector, err := spannerdriver.CreateConnector(
+//		"projects/my-project/instances/my-instance/databases/my-db",
+//		spannerdriver.ConnectorConfig{
+//			OnStatementComplete: func(info spannerdriver.StatementInfo) {
+//				log.Printf("%s took %v (%d RPC attempts)", info.SQL, info.Duration, info.RPCAttempts)
+//			},
+//		})
+//	if err != nil {
+//		return err
+//	}
+//	db := sql.OpenDB(connector)
+func CreateConnector(dsn string, config ConnectorConfig) (driver.Connector, error) {
+	return createConnector(spannerDriver, dsn, config)
+}
+
+// NewConnector creates a new connector for the database in the given
+// configuration. The connector can be used with sql.OpenDB. Project, Instance
+// and Database must be set. NewConnector creates the same connector as
+// CreateConnector with a connection string that only contains the database
+// name, and can be used to avoid encoding options in a connection string.
+//
+// Example:
+//
+//	connector, err := spannerdriver.NewConnector(spannerdriver.ConnectorConfig{
+//		Project:         "my-project",
+//		Instance:        "my-instance",
+//		Database:        "my-db",
+//		CredentialsFile: "/path/to/credentials.json",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	db := sql.OpenDB(connector)
+func NewConnector(config ConnectorConfig) (driver.Connector, error) {
+	if config.Project == "" || config.Instance == "" || config.Database == "" {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "ConnectorConfig.Project, Instance and Database must be set"))
+	}
+	dsn := fmt.Sprintf("projects/%s/instances/%s/databases/%s", config.Project, config.Instance, config.Database)
+	return newConnectorWithConfig(spannerDriver, dsn, "", map[string]string{}, config)
 }
 
 // Driver represents a Google Cloud Spanner database/sql driver.
@@ -113,7 +417,7 @@ func extractConnectorConfig(dsn string) (connectorConfig, error) {
 		}
 	}
 	paramsString := matches["PARAMSGROUP"]
-	params, err := extractConnectorParams(paramsString)
+	params, err := extractConnectorParams(paramsString, matches["PARAMSSEPARATOR"] == "?")
 	if err != nil {
 		return connectorConfig{}, err
 	}
@@ -127,23 +431,35 @@ func extractConnectorConfig(dsn string) (connectorConfig, error) {
 	}, nil
 }
 
-func extractConnectorParams(paramsString string) (map[string]string, error) {
+// extractConnectorParams extracts the parameters from the parameters part of
+// a connection string. Parameters are separated by `;`, and also by `&` if
+// queryStyle is true. The names and values of the parameters are
+// percent-decoded.
+func extractConnectorParams(paramsString string, queryStyle bool) (map[string]string, error) {
 	params := make(map[string]string)
 	if paramsString == "" {
 		return params, nil
 	}
-	keyValuePairs := strings.Split(paramsString, ";")
+	keyValuePairs := strings.FieldsFunc(paramsString, func(r rune) bool {
+		return r == ';' || (queryStyle && r == '&')
+	})
 	for _, keyValueString := range keyValuePairs {
-		if keyValueString == "" {
-			// Ignore empty parameter entries in the string, for example if
-			// the connection string contains a trailing ';'.
-			continue
-		}
-		keyValue := strings.SplitN(keyValueString, "=", 2)
-		if keyValue == nil || len(keyValue) != 2 {
+		// Empty parameter entries in the string, for example if the
+		// connection string contains a trailing ';', are skipped by
+		// FieldsFunc.
+		key, value, ok := strings.Cut(keyValueString, "=")
+		if !ok {
 			return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid connection property: %s", keyValueString))
 		}
-		params[strings.ToLower(keyValue[0])] = keyValue[1]
+		key, err := url.PathUnescape(key)
+		if err != nil {
+			return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid connection property name %s: %v", keyValueString, err))
+		}
+		value, err = url.PathUnescape(value)
+		if err != nil {
+			return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid value for connection property %s: %v", key, err))
+		}
+		params[strings.ToLower(key)] = value
 	}
 	return params, nil
 }
@@ -165,6 +481,37 @@ type connector struct {
 	// retried internally (when possible), or whether all aborted errors will be
 	// propagated to the caller. This option is enabled by default.
 	retryAbortsInternally bool
+	// autoMarshalJSON determines whether query parameters of unsupported
+	// types are marshalled to JSON.
+	autoMarshalJSON bool
+
+	// config is the additional configuration that was used to create the
+	// connector. It is empty for connectors that are created for a connection
+	// string by sql.Open.
+	config ConnectorConfig
+	// cached indicates whether the connector is registered in the connectors
+	// map of the driver.
+	cached bool
+	// queryCache contains the results of cacheable queries. It is nil if the
+	// query cache is disabled.
+	queryCache *queryCache
+	// readSemaphore limits the number of concurrent streaming reads of the
+	// connector. It is nil if the number of reads is not limited.
+	readSemaphore chan struct{}
+	// tracer creates the OpenTelemetry spans of the connector.
+	tracer trace.Tracer
+	// metrics records the OpenTelemetry metrics of the connector.
+	metrics *driverMetrics
+	// decoders are the column decoders that have been registered with
+	// RegisterColumnTypeDecoder.
+	decodersMu sync.RWMutex
+	decoders   map[spannerpb.TypeCode]ColumnDecoder
+
+	// dialect is the cached dialect of the database. It is
+	// DialectUnspecified until the dialect has been read from the database,
+	// unless the dialect was set in the connection string.
+	dialectMu sync.Mutex
+	dialect   Dialect
 
 	initClient     sync.Once
 	client         *spanner.Client
@@ -172,6 +519,98 @@ type connector struct {
 	adminClient    *adminapi.DatabaseAdminClient
 	adminClientErr error
 	connCount      int32
+	// connected is set to 1 when a connection has verified that the database
+	// can be reached within ConnectTimeout.
+	connected int32
+	// sessions counts the sessions that have been created by the session pool.
+	sessions *sessionCounter
+	// warmedUp is set to 1 when a connection has waited until the session
+	// pool contained the minimum number of sessions.
+	warmedUp int32
+
+	// keepAliveStop is closed to stop the keep-alive goroutine, which
+	// closes keepAliveDone when it has stopped. Both are nil if no keep-alive
+	// goroutine has been started.
+	keepAliveMu   sync.Mutex
+	keepAliveStop chan struct{}
+	keepAliveDone chan struct{}
+
+	// prewarmDone is closed when the clients that are created in the
+	// background for PrewarmSessions have been created. It is nil if
+	// PrewarmSessions is not set.
+	prewarmDone chan struct{}
+	// closed is set to 1 when Close has been called on the connector.
+	closed int32
+	// clientsClosed indicates whether the Spanner clients of the connector
+	// have been closed.
+	clientsMu     sync.Mutex
+	clientsClosed bool
+}
+
+// parseDialectParam parses the dialect connection property. It returns
+// DialectUnspecified if the property is not set.
+func parseDialectParam(params map[string]string) (Dialect, error) {
+	strval, ok := params["dialect"]
+	if !ok {
+		return DialectUnspecified, nil
+	}
+	switch strings.ToUpper(strval) {
+	case "GOOGLESQL", GoogleSQL.String():
+		return GoogleSQL, nil
+	case "POSTGRESQL", "POSTGRES":
+		return PostgreSQL, nil
+	}
+	return DialectUnspecified, invalidParamError("dialect", "one of GoogleSQL or PostgreSQL", strval)
+}
+
+// parseBoolParam parses the connection property with the given name as a
+// boolean. The name is case-insensitive. The returned ok value indicates
+// whether the property was set in the connection string.
+func parseBoolParam(params map[string]string, name string) (value bool, ok bool, err error) {
+	strval, ok := params[strings.ToLower(name)]
+	if !ok {
+		return false, false, nil
+	}
+	value, err = strconv.ParseBool(strval)
+	if err != nil {
+		return false, true, invalidParamError(name, "boolean", strval)
+	}
+	return value, true, nil
+}
+
+// parseUintParam parses the connection property with the given name as an
+// unsigned integer. The name is case-insensitive. The returned ok value
+// indicates whether the property was set in the connection string.
+func parseUintParam(params map[string]string, name string) (value uint64, ok bool, err error) {
+	strval, ok := params[strings.ToLower(name)]
+	if !ok {
+		return 0, false, nil
+	}
+	value, err = strconv.ParseUint(strval, 10, 64)
+	if err != nil {
+		return 0, true, invalidParamError(name, "non-negative integer", strval)
+	}
+	return value, true, nil
+}
+
+// parseDurationParam parses the connection property with the given name as a
+// duration, for example 10s or 500ms. The name is case-insensitive. The
+// returned ok value indicates whether the property was set in the connection
+// string.
+func parseDurationParam(params map[string]string, name string) (value time.Duration, ok bool, err error) {
+	strval, ok := params[strings.ToLower(name)]
+	if !ok {
+		return 0, false, nil
+	}
+	value, err = time.ParseDuration(strval)
+	if err != nil || value < 0 {
+		return 0, true, invalidParamError(name, "non-negative duration", strval)
+	}
+	return value, true, nil
+}
+
+func invalidParamError(name, expected, value string) error {
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid value for %s: expected %s, got %q", name, expected, value))
 }
 
 func newConnector(d *Driver, dsn string) (*connector, error) {
@@ -183,48 +622,152 @@ func newConnector(d *Driver, dsn string) (*connector, error) {
 	if c, ok := d.connectors[dsn]; ok {
 		return c, nil
 	}
+	c, err := createConnector(d, dsn, ConnectorConfig{})
+	if err != nil {
+		return nil, err
+	}
+	c.cached = true
+	d.connectors[dsn] = c
+	return c, nil
+}
 
-	connectorConfig, err := extractConnectorConfig(dsn)
+// createConnector creates a connector for the given connection string. The
+// database and the connection properties in the connection string are added
+// to the given configuration.
+func createConnector(d *Driver, dsn string, connConfig ConnectorConfig) (*connector, error) {
+	dsnConfig, err := extractConnectorConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
-	opts := make([]option.ClientOption, 0)
-	if connectorConfig.host != "" {
-		opts = append(opts, option.WithEndpoint(connectorConfig.host))
+	connConfig.Project = dsnConfig.project
+	connConfig.Instance = dsnConfig.instance
+	connConfig.Database = dsnConfig.database
+	if strval, ok := dsnConfig.params["credentials"]; ok {
+		connConfig.CredentialsFile = strval
+	}
+	return newConnectorWithConfig(d, dsn, dsnConfig.host, dsnConfig.params, connConfig)
+}
+
+// clientOptions returns the options for the Spanner clients for the given
+// host, connection properties and configuration. The emulator host and the
+// AutoConfigEmulator option of the given configuration are updated with the
+// values from the connection properties and the environment.
+func clientOptions(host string, params map[string]string, connConfig *ConnectorConfig) ([]option.ClientOption, error) {
+	if val, ok, err := parseBoolParam(params, "autoConfigEmulator"); err != nil {
+		return nil, err
+	} else if ok {
+		connConfig.AutoConfigEmulator = val
 	}
-	if strval, ok := connectorConfig.params["credentials"]; ok {
-		opts = append(opts, option.WithCredentialsFile(strval))
+	if connConfig.EmulatorHost == "" && host == "" {
+		connConfig.EmulatorHost = os.Getenv("SPANNER_EMULATOR_HOST")
 	}
-	if strval, ok := connectorConfig.params["useplaintext"]; ok {
-		if val, err := strconv.ParseBool(strval); err == nil && val {
-			opts = append(opts, option.WithGRPCDialOption(grpc.WithInsecure()), option.WithoutAuthentication())
+	if connConfig.AutoConfigEmulator && connConfig.EmulatorHost == "" {
+		connConfig.EmulatorHost = host
+		if connConfig.EmulatorHost == "" {
+			connConfig.EmulatorHost = defaultEmulatorHost
 		}
 	}
+	opts := make([]option.ClientOption, 0)
+	if host != "" {
+		opts = append(opts, option.WithEndpoint(host))
+	}
+	if connConfig.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(connConfig.CredentialsFile))
+	}
+	if connConfig.CredentialsJSON != nil {
+		opts = append(opts, option.WithCredentialsJSON(connConfig.CredentialsJSON))
+	}
+	if connConfig.EmulatorHost != "" {
+		opts = append(opts, option.WithEndpoint(connConfig.EmulatorHost), option.WithGRPCDialOption(grpc.WithInsecure()), option.WithoutAuthentication())
+	}
+	usePlainText, _, err := parseBoolParam(params, "usePlainText")
+	if err != nil {
+		return nil, err
+	}
+	if usePlainText {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithInsecure()), option.WithoutAuthentication())
+	}
+	return opts, nil
+}
+
+// newConnectorWithConfig creates a connector for the given configuration. The
+// host and the params are the host and the connection properties of the
+// connection string, if any.
+func newConnectorWithConfig(d *Driver, dsn, host string, params map[string]string, connConfig ConnectorConfig) (*connector, error) {
+	connectorConfig := connectorConfig{
+		host:     host,
+		project:  connConfig.Project,
+		instance: connConfig.Instance,
+		database: connConfig.Database,
+		params:   params,
+	}
+	opts, err := clientOptions(host, params, &connConfig)
+	if err != nil {
+		return nil, err
+	}
 	retryAbortsInternally := true
-	if strval, ok := connectorConfig.params["retryabortsinternally"]; ok {
-		if val, err := strconv.ParseBool(strval); err == nil && !val {
-			retryAbortsInternally = false
-		}
+	if val, ok, err := parseBoolParam(params, "retryAbortsInternally"); err != nil {
+		return nil, err
+	} else if ok {
+		retryAbortsInternally = val
+	}
+	autoMarshalJSON, _, err := parseBoolParam(params, "autoMarshalJson")
+	if err != nil {
+		return nil, err
+	}
+	dialect, err := parseDialectParam(params)
+	if err != nil {
+		return nil, err
 	}
 	config := spanner.ClientConfig{
 		SessionPoolConfig: spanner.DefaultSessionPoolConfig,
 	}
-	if strval, ok := connectorConfig.params["minsessions"]; ok {
-		if val, err := strconv.ParseUint(strval, 10, 64); err == nil {
-			config.MinOpened = val
-		}
+	if connConfig.SessionPoolConfig != nil {
+		config.SessionPoolConfig = *connConfig.SessionPoolConfig
 	}
-	if strval, ok := connectorConfig.params["maxsessions"]; ok {
-		if val, err := strconv.ParseUint(strval, 10, 64); err == nil {
-			config.MaxOpened = val
-		}
+	if connConfig.MinSessions > 0 {
+		config.MinOpened = connConfig.MinSessions
+	}
+	if val, ok, err := parseUintParam(params, "minSessions"); err != nil {
+		return nil, err
+	} else if ok {
+		config.MinOpened = val
+	}
+	if val, ok, err := parseBoolParam(params, "prewarmSessions"); err != nil {
+		return nil, err
+	} else if ok {
+		connConfig.PrewarmSessions = val
+	}
+	if val, ok, err := parseDurationParam(params, "waitForMinSessions"); err != nil {
+		return nil, err
+	} else if ok {
+		connConfig.WaitForMinSessions = val
+	}
+	if val, ok, err := parseUintParam(params, "maxCommitRetries"); err != nil {
+		return nil, err
+	} else if ok {
+		connConfig.MaxCommitRetries = int(val)
+	}
+	if connConfig.MaxCommitRetries < 0 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "ConnectorConfig.MaxCommitRetries must not be negative, got %d", connConfig.MaxCommitRetries))
 	}
-	if strval, ok := connectorConfig.params["numchannels"]; ok {
-		if val, err := strconv.Atoi(strval); err == nil && val > 0 {
-			config.NumChannels = val
+	if connConfig.WaitForMinSessions < 0 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "ConnectorConfig.WaitForMinSessions must not be negative, got %v", connConfig.WaitForMinSessions))
+	}
+	if val, ok, err := parseUintParam(params, "maxSessions"); err != nil {
+		return nil, err
+	} else if ok {
+		config.MaxOpened = val
+	}
+	if val, ok, err := parseUintParam(params, "numChannels"); err != nil {
+		return nil, err
+	} else if ok {
+		if val == 0 {
+			return nil, invalidParamError("numChannels", "positive integer", params["numchannels"])
 		}
+		config.NumChannels = int(val)
 	}
-	if strval, ok := connectorConfig.params["rpcpriority"]; ok {
+	if strval, ok := params["rpcpriority"]; ok {
 		var priority spannerpb.RequestOptions_Priority
 		switch strings.ToUpper(strval) {
 		case "LOW":
@@ -234,33 +777,67 @@ func newConnector(d *Driver, dsn string) (*connector, error) {
 		case "HIGH":
 			priority = spannerpb.RequestOptions_PRIORITY_HIGH
 		default:
-			priority = spannerpb.RequestOptions_PRIORITY_UNSPECIFIED
+			return nil, invalidParamError("rpcPriority", "one of HIGH, MEDIUM or LOW", strval)
 		}
 		config.ReadOptions.Priority = priority
 		config.TransactionOptions.CommitPriority = priority
 		config.QueryOptions.Priority = priority
 	}
-	if strval, ok := connectorConfig.params["optimizerversion"]; ok {
+	if strval, ok := params["optimizerversion"]; ok {
 		if config.QueryOptions.Options == nil {
 			config.QueryOptions.Options = &spannerpb.ExecuteSqlRequest_QueryOptions{}
 		}
 		config.QueryOptions.Options.OptimizerVersion = strval
 	}
-	if strval, ok := connectorConfig.params["optimizerstatisticspackage"]; ok {
+	if strval, ok := params["optimizerstatisticspackage"]; ok {
 		if config.QueryOptions.Options == nil {
 			config.QueryOptions.Options = &spannerpb.ExecuteSqlRequest_QueryOptions{}
 		}
 		config.QueryOptions.Options.OptimizerStatisticsPackage = strval
 	}
-	if strval, ok := connectorConfig.params["databaserole"]; ok {
+	if strval, ok := params["databaserole"]; ok {
 		config.DatabaseRole = strval
 	}
-	if strval, ok := connectorConfig.params["disableroutetoleader"]; ok {
-		if val, err := strconv.ParseBool(strval); err == nil {
-			config.DisableRouteToLeader = val
-		}
+	if val, ok, err := parseBoolParam(params, "disableRouteToLeader"); err != nil {
+		return nil, err
+	} else if ok {
+		config.DisableRouteToLeader = val
 	}
 	config.UserAgent = userAgent
+	if connConfig.Retryer != nil {
+		if connConfig.Retryer() == nil {
+			return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "ConnectorConfig.Retryer returned nil"))
+		}
+		config.CallOptions = retryerCallOptions(connConfig.Retryer)
+	}
+	if connConfig.MaxConcurrentReads < 0 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "ConnectorConfig.MaxConcurrentReads must not be negative, got %d", connConfig.MaxConcurrentReads))
+	}
+	var readSemaphore chan struct{}
+	if connConfig.MaxConcurrentReads > 0 {
+		readSemaphore = make(chan struct{}, connConfig.MaxConcurrentReads)
+	}
+	if connConfig.MeterProvider != nil {
+		config.OpenTelemetryMeterProvider = connConfig.MeterProvider
+	}
+	metrics := newMetrics(connConfig.MeterProvider, fmt.Sprintf(
+		"projects/%s/instances/%s/databases/%s",
+		connectorConfig.project,
+		connectorConfig.instance,
+		connectorConfig.database))
+	sessions := newSessionCounter()
+	opts = append(opts,
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(unarySessionInterceptor, metrics.unarySessionsInterceptor, sessions.unaryInterceptor)),
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(streamSessionInterceptor)))
+	if connConfig.OnStatementComplete != nil {
+		opts = append(opts,
+			option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(unaryMetricsInterceptor)),
+			option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(streamMetricsInterceptor)))
+	}
+	var queryCache *queryCache
+	if connConfig.QueryCacheSize > 0 && connConfig.QueryCacheTTL > 0 {
+		queryCache = newQueryCache(connConfig.QueryCacheSize, connConfig.QueryCacheTTL)
+	}
 	c := &connector{
 		driver:                d,
 		dsn:                   dsn,
@@ -268,8 +845,22 @@ func newConnector(d *Driver, dsn string) (*connector, error) {
 		spannerClientConfig:   config,
 		options:               opts,
 		retryAbortsInternally: retryAbortsInternally,
+		autoMarshalJSON:       autoMarshalJSON,
+		config:                connConfig,
+		queryCache:            queryCache,
+		readSemaphore:         readSemaphore,
+		tracer:                newTracer(connConfig.TracerProvider),
+		metrics:               metrics,
+		sessions:              sessions,
+		dialect:               dialect,
+	}
+	if connConfig.PrewarmSessions {
+		c.prewarmDone = make(chan struct{})
+		go func() {
+			defer close(c.prewarmDone)
+			c.createClient(context.Background())
+		}()
 	}
-	d.connectors[dsn] = c
 	return c, nil
 }
 
@@ -278,23 +869,25 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 }
 
 func openDriverConn(ctx context.Context, c *connector) (driver.Conn, error) {
-	opts := append(c.options, option.WithUserAgent(userAgent))
 	databaseName := fmt.Sprintf(
 		"projects/%s/instances/%s/databases/%s",
 		c.connectorConfig.project,
 		c.connectorConfig.instance,
 		c.connectorConfig.database)
 
-	c.initClient.Do(func() {
-		c.client, c.clientErr = spanner.NewClientWithConfig(ctx, databaseName, c.spannerClientConfig, opts...)
-		c.adminClient, c.adminClientErr = adminapi.NewDatabaseAdminClient(ctx, opts...)
-	})
+	c.createClient(ctx)
 	if c.clientErr != nil {
 		return nil, c.clientErr
 	}
 	if c.adminClientErr != nil {
 		return nil, c.adminClientErr
 	}
+	if err := c.verifyConnection(ctx, databaseName); err != nil {
+		return nil, err
+	}
+	if err := c.waitForMinSessions(ctx, databaseName); err != nil {
+		return nil, err
+	}
 	atomic.AddInt32(&c.connCount, 1)
 	return &conn{
 		connector:                  c,
@@ -302,18 +895,190 @@ func openDriverConn(ctx context.Context, c *connector) (driver.Conn, error) {
 		adminClient:                c.adminClient,
 		database:                   databaseName,
 		retryAborts:                c.retryAbortsInternally,
+		maxCommitRetries:           c.config.MaxCommitRetries,
 		execSingleQuery:            queryInSingleUse,
 		execSingleDMLTransactional: execInNewRWTransaction,
 		execSingleDMLPartitioned:   execAsPartitionedDML,
 	}, nil
 }
 
+// createClient creates the Spanner clients of the connector if these have not
+// been created yet. The given context is only used if the clients are created
+// by this call.
+func (c *connector) createClient(ctx context.Context) {
+	// Copy the options of the connector, as appending directly to c.options
+	// could write to the same backing array for concurrent calls.
+	opts := make([]option.ClientOption, 0, len(c.options)+1)
+	opts = append(opts, c.options...)
+	opts = append(opts, option.WithUserAgent(userAgent))
+	databaseName := fmt.Sprintf(
+		"projects/%s/instances/%s/databases/%s",
+		c.connectorConfig.project,
+		c.connectorConfig.instance,
+		c.connectorConfig.database)
+	c.initClient.Do(func() {
+		clientCtx := ctx
+		if c.config.ConnectTimeout > 0 {
+			var cancel context.CancelFunc
+			clientCtx, cancel = context.WithTimeout(ctx, c.config.ConnectTimeout)
+			defer cancel()
+		}
+		if c.config.AutoConfigEmulator {
+			if c.clientErr = c.createEmulatorDatabase(clientCtx, opts); c.clientErr != nil {
+				return
+			}
+		}
+		c.client, c.clientErr = spanner.NewClientWithConfig(clientCtx, databaseName, c.spannerClientConfig, opts...)
+		c.adminClient, c.adminClientErr = adminapi.NewDatabaseAdminClient(clientCtx, opts...)
+		if c.clientErr == nil && c.config.KeepAliveInterval > 0 && atomic.LoadInt32(&c.closed) == 0 {
+			c.startKeepAlive()
+		}
+	})
+}
+
+// verifyConnection verifies that the given database can be reached within
+// ConnectTimeout by executing a query. The query is only executed if
+// ConnectTimeout has been set and no other connection has been verified.
+func (c *connector) verifyConnection(ctx context.Context, databaseName string) error {
+	timeout := c.config.ConnectTimeout
+	if timeout <= 0 || atomic.LoadInt32(&c.connected) == 1 {
+		return nil
+	}
+	verifyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	it := c.client.Single().Query(verifyCtx, spanner.NewStatement("SELECT 1"))
+	defer it.Stop()
+	if _, err := it.Next(); err != nil {
+		if ctx.Err() == nil && verifyCtx.Err() == context.DeadlineExceeded {
+			return spanner.ToSpannerError(status.Errorf(codes.DeadlineExceeded, "could not connect to %s within %v: %v", databaseName, timeout, err))
+		}
+		return err
+	}
+	atomic.StoreInt32(&c.connected, 1)
+	return nil
+}
+
+// startKeepAlive starts a goroutine that executes a keep-alive query at the
+// KeepAliveInterval of the connector until stopKeepAlive is called.
+func (c *connector) startKeepAlive() {
+	c.keepAliveMu.Lock()
+	defer c.keepAliveMu.Unlock()
+	stop, done := make(chan struct{}), make(chan struct{})
+	c.keepAliveStop, c.keepAliveDone = stop, done
+	interval := c.config.KeepAliveInterval
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if err := c.keepAlive(stop, interval); err != nil && c.config.OnKeepAliveFailed != nil {
+				c.config.OnKeepAliveFailed(err)
+			}
+		}
+	}()
+}
+
+// keepAlive executes a keep-alive query with the given timeout. The query is
+// cancelled if the given stop channel is closed.
+func (c *connector) keepAlive(stop chan struct{}, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	it := c.client.Single().Query(ctx, spanner.NewStatement("SELECT 1"))
+	defer it.Stop()
+	_, err := it.Next()
+	return err
+}
+
+// stopKeepAlive stops the keep-alive goroutine of the connector, if any, and
+// waits until it has stopped.
+func (c *connector) stopKeepAlive() {
+	c.keepAliveMu.Lock()
+	stop, done := c.keepAliveStop, c.keepAliveDone
+	c.keepAliveStop = nil
+	c.keepAliveMu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Close stops the background keep-alive queries of the connector, and closes
+// the Spanner clients of the connector if it has no open connections. Close
+// waits until the clients that are created for PrewarmSessions have been
+// created, so these are also closed if no connection was ever opened. It is
+// called by sql.DB.Close.
+func (c *connector) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	if c.prewarmDone != nil {
+		<-c.prewarmDone
+	}
+	c.stopKeepAlive()
+	if atomic.LoadInt32(&c.connCount) > 0 {
+		return nil
+	}
+	return c.closeClients()
+}
+
+// closeClients closes the Spanner clients of the connector. It is a no-op if
+// the clients have not been created or have already been closed.
+func (c *connector) closeClients() error {
+	c.clientsMu.Lock()
+	defer c.clientsMu.Unlock()
+	if c.clientsClosed {
+		return nil
+	}
+	c.clientsClosed = true
+	if c.client != nil {
+		c.client.Close()
+	}
+	if c.adminClient != nil {
+		return c.adminClient.Close()
+	}
+	return nil
+}
+
+// retryerCallOptions returns the call options for the RPCs of the Spanner
+// client that use the given retryer.
+func retryerCallOptions(retryer func() gax.Retryer) *vkit.CallOptions {
+	retry := []gax.CallOption{gax.WithRetry(retryer)}
+	return &vkit.CallOptions{
+		ExecuteSql:          retry,
+		ExecuteStreamingSql: retry,
+		ExecuteBatchDml:     retry,
+		Read:                retry,
+		StreamingRead:       retry,
+		BeginTransaction:    retry,
+		Commit:              retry,
+		PartitionQuery:      retry,
+		PartitionRead:       retry,
+	}
+}
+
 func (c *connector) Driver() driver.Driver {
 	return c.driver
 }
 
 // SpannerConn is the public interface for the raw Spanner connection for the
 // sql driver. This interface can be used with the db.Conn().Raw() method.
+//
+// A SpannerConn may only be used inside the function that is passed to Raw,
+// and may not be used by multiple goroutines at the same time. Statements,
+// transactions and mutations on a connection that is already executing an
+// operation for another goroutine fail with ErrConnectionInUse instead of
+// changing the state of the connection.
 type SpannerConn interface {
 	// StartBatchDDL starts a DDL batch on the connection. After calling this
 	// method all subsequent DDL statements will be cached locally. Calling
@@ -352,8 +1117,32 @@ type SpannerConn interface {
 	RetryAbortsInternally() bool
 	// SetRetryAbortsInternally enables/disables the automatic retry of aborted
 	// transactions. If disabled, any aborted error from a transaction will be
-	// propagated to the application.
+	// propagated to the application. All statements and the commit that
+	// follow an aborted statement in the same transaction then also return an
+	// Aborted error, and the transaction must be rolled back and retried by
+	// the application.
 	SetRetryAbortsInternally(retry bool) error
+	// MaxCommitRetries returns the maximum number of times that an aborted
+	// read/write transaction is retried internally. Zero means that the
+	// number of retries is not limited.
+	MaxCommitRetries() int
+	// SetMaxCommitRetries sets the maximum number of times that an aborted
+	// read/write transaction is retried internally. The statement or commit
+	// that was aborted returns a *RetryBudgetExceededError if the transaction
+	// is still aborted after this number of retries. The new value is used
+	// for transactions that are started after this call. Set the value to
+	// zero to not limit the number of retries.
+	SetMaxCommitRetries(retries int) error
+
+	// Autocommit returns true if statements that are executed outside a
+	// transaction are committed automatically. This is the default.
+	Autocommit() bool
+	// SetAutocommit enables/disables autocommit for the connection. If
+	// disabled, a read/write transaction is started automatically by the
+	// first statement that is executed outside a transaction. The transaction
+	// must be ended by executing a COMMIT or ROLLBACK statement on the
+	// connection. Autocommit cannot be changed while a transaction is active.
+	SetAutocommit(autocommit bool) error
 
 	// AutocommitDMLMode returns the current mode that is used for DML
 	// statements outside a transaction. The default is Transactional.
@@ -361,8 +1150,9 @@ type SpannerConn interface {
 	// SetAutocommitDMLMode sets the mode to use for DML statements that are
 	// executed outside transactions. The default is Transactional. Change to
 	// PartitionedNonAtomic to use Partitioned DML instead of Transactional DML.
-	// See https://cloud.google.com/spanner/docs/dml-partitioned for more
-	// information on Partitioned DML.
+	// The mode cannot be changed to PartitionedNonAtomic while a transaction
+	// is active. See https://cloud.google.com/spanner/docs/dml-partitioned for
+	// more information on Partitioned DML.
 	SetAutocommitDMLMode(mode AutocommitDMLMode) error
 
 	// ReadOnlyStaleness returns the current staleness that is used for
@@ -372,27 +1162,226 @@ type SpannerConn interface {
 	// mode and for read-only transaction.
 	SetReadOnlyStaleness(staleness spanner.TimestampBound) error
 
+	// DirectedReadOptions returns the directed read options that are used for
+	// queries in autocommit mode and for read-only transactions.
+	DirectedReadOptions() *spannerpb.DirectedReadOptions
+	// SetDirectedReadOptions sets the directed read options to use for queries
+	// in autocommit mode and for read-only transactions. The options can either
+	// include or exclude a set of replicas. Directed read options are not used
+	// for read/write transactions, as Spanner only supports directed reads for
+	// read-only operations. Set the options to nil to remove any directed read
+	// options from the connection.
+	// See https://cloud.google.com/spanner/docs/directed-reads for more
+	// information.
+	SetDirectedReadOptions(options *spannerpb.DirectedReadOptions) error
+
 	// ExcludeTxnFromChangeStreams returns true if the next transaction should be excluded from change streams with the
 	// DDL option `allow_txn_exclusion=true`.
 	ExcludeTxnFromChangeStreams() bool
 	// SetExcludeTxnFromChangeStreams sets whether the next transaction should be excluded from change streams with the
-	// DDL option `allow_txn_exclusion=true`.
+	// DDL option `allow_txn_exclusion=true`. This applies to the next explicit transaction, and to the next DML
+	// statement, DML batch or set of mutations that is executed in autocommit mode. The setting is reset after it
+	// has been used. Use ReadWriteTransactionOptions.ExcludeTxnFromChangeStreams to exclude a single transaction.
 	SetExcludeTxnFromChangeStreams(excludeTxnFromChangeStreams bool) error
 
+	// RPCPriority returns the default RPC priority of the connection. The
+	// priority of the connector is used if this is unspecified.
+	RPCPriority() spannerpb.RequestOptions_Priority
+	// SetRPCPriority sets the default RPC priority for all queries, reads,
+	// DML statements and commits on this connection. ExecOptions.Priority and
+	// ReadWriteTransactionOptions.Priority override this priority for a
+	// statement or a transaction. Set the priority to
+	// PRIORITY_UNSPECIFIED to use the priority of the connector.
+	SetRPCPriority(priority spannerpb.RequestOptions_Priority) error
+
+	// MaxCommitDelay returns the default maximum commit delay of read/write
+	// transactions on this connection. It returns nil if no max commit delay
+	// has been set.
+	MaxCommitDelay() *time.Duration
+	// SetMaxCommitDelay sets the maximum amount of time that Spanner may delay
+	// the commit of read/write transactions on this connection to batch them
+	// with other commits. This can increase the write throughput at the cost
+	// of a higher commit latency. The delay must be between 0 and 500ms, and
+	// ReadWriteTransactionOptions.MaxCommitDelay overrides it for a single
+	// transaction. Set the delay to nil to use the default of Spanner.
+	SetMaxCommitDelay(delay *time.Duration) error
+
+	// ReadOnly returns true if the connection is in read-only mode.
+	ReadOnly() bool
+	// SetReadOnly enables/disables read-only mode for the connection. All
+	// transactions on a read-only connection are read-only transactions, and
+	// DML statements and mutations return a FailedPrecondition error.
+	// Read-only mode cannot be changed while a transaction is active.
+	SetReadOnly(readOnly bool) error
+
+	// OptimizerVersion returns the default query optimizer version of the
+	// connection. The version of the connector is used if this is empty.
+	OptimizerVersion() string
+	// SetOptimizerVersion sets the default query optimizer version for all
+	// queries and DML statements on this connection.
+	// ExecOptions.OptimizerVersion overrides it for a single statement. Set
+	// the version to an empty string to use the version of the connector.
+	SetOptimizerVersion(version string) error
+	// OptimizerStatisticsPackage returns the default query optimizer
+	// statistics package of the connection. The package of the connector is
+	// used if this is empty.
+	OptimizerStatisticsPackage() string
+	// SetOptimizerStatisticsPackage sets the default query optimizer
+	// statistics package for all queries and DML statements on this
+	// connection. ExecOptions.OptimizerStatisticsPackage overrides it for a
+	// single statement.
+	SetOptimizerStatisticsPackage(statisticsPackage string) error
+
+	// StatementTimeout returns the default timeout of statements on this
+	// connection. It returns zero if no statement timeout has been set.
+	StatementTimeout() time.Duration
+	// SetStatementTimeout sets a timeout that is applied to every statement
+	// that is executed on this connection. The statement fails with a
+	// DeadlineExceeded error if it has not finished within the timeout. If
+	// the context of a statement also has a deadline, then the earlier of the
+	// two is used. A query has finished when all rows have been consumed or
+	// the rows have been closed. Set the timeout to zero to disable it.
+	SetStatementTimeout(timeout time.Duration) error
+
 	// Apply writes an array of mutations to the database. This method may only be called while the connection
 	// is outside a transaction. Use BufferWrite to write mutations in a transaction.
 	// See also spanner.Client#Apply
 	Apply(ctx context.Context, ms []*spanner.Mutation, opts ...spanner.ApplyOption) (commitTimestamp time.Time, err error)
 
-	// BufferWrite writes an array of mutations to the current transaction. This method may only be called while the
-	// connection is in a read/write transaction. Use Apply to write mutations outside a transaction.
+	// BufferWrite writes an array of mutations to the current transaction. The mutations are buffered until the
+	// transaction is committed if the connection is in a read/write transaction. The mutations are applied directly
+	// in a separate transaction if the connection is not in a transaction. BufferWrite returns an error if the
+	// connection is in a read-only transaction.
 	// See also spanner.ReadWriteTransaction#BufferWrite
 	BufferWrite(ms []*spanner.Mutation) error
+	// BufferWriteContext is the same as BufferWrite, but uses the given
+	// context for the transaction that applies the mutations if the connection
+	// is not in a transaction.
+	BufferWriteContext(ctx context.Context, ms []*spanner.Mutation) error
+
+	// BufferedMutations returns the mutations that have been buffered in the
+	// current read/write transaction and that have not yet been committed.
+	// The returned slice is a copy and can safely be modified by the caller.
+	// It is empty if the connection is not in a read/write transaction, or if
+	// no mutations have been buffered in the transaction. DML statements are
+	// not included.
+	BufferedMutations() []*spanner.Mutation
+
+	// BatchWrite applies the given mutation groups to the database in a
+	// non-atomic way. Each mutation group is applied atomically in a separate
+	// transaction, and the mutation groups can be applied in any order. The
+	// result contains one MutationGroupResult for each mutation group, in the
+	// same order as the given mutation groups, with the commit timestamp or
+	// the error of that group. The returned error is only set if the BatchWrite
+	// request itself failed.
+	//
+	// This method may only be called while the connection is outside a
+	// transaction.
+	// See also spanner.Client#BatchWrite
+	BatchWrite(ctx context.Context, groups []*spanner.MutationGroup) ([]MutationGroupResult, error)
+
+	// Read reads the rows with the given keys from the given table, and
+	// returns the given columns of these rows. The read uses the Read API of
+	// Spanner instead of a SQL query, which means that the statement does not
+	// need to be parsed and planned by Spanner. This is the cheapest way to
+	// look up rows by primary key or by the key of a secondary index.
+	//
+	// The read uses the current transaction of the connection, or a single-use
+	// read-only transaction if the connection is not in a transaction. The
+	// returned rows must be closed before the function that is passed to
+	// sql.Conn.Raw returns.
+	// See also spanner.ReadOnlyTransaction#ReadWithOptions
+	Read(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts ReadOptions) (driver.Rows, error)
+
+	// ReadRow reads the row with the given key from the given table, and
+	// returns the given columns of the row. The returned rows contain no row
+	// if the row does not exist. See Read for more information. Use the
+	// ReadRow function to read a single row into Go variables with a *sql.DB,
+	// *sql.Conn or *sql.Tx.
+	ReadRow(ctx context.Context, table string, key spanner.Key, columns []string, opts ReadOptions) (driver.Rows, error)
+
+	// Checkpoint marks the current position in the read/write transaction on
+	// this connection. RollbackToCheckpoint can be used to undo all statements
+	// and mutations after this position. See TransactionCheckpoint for the
+	// constraints of checkpoints.
+	//
+	// This method may only be called while the connection is in a read/write
+	// transaction that is retried internally if it is aborted.
+	Checkpoint() (*TransactionCheckpoint, error)
+
+	// RollbackToCheckpoint undoes all statements and mutations in the current
+	// read/write transaction after the given checkpoint. This is done by
+	// rolling back the Spanner transaction and replaying all statements and
+	// mutations before the checkpoint on a new Spanner transaction. The
+	// method returns ErrAbortedDueToConcurrentModification if the replayed
+	// statements return different results than the first time they were
+	// executed. The transaction must then be rolled back.
+	RollbackToCheckpoint(ctx context.Context, checkpoint *TransactionCheckpoint) error
+
+	// DatabaseDialect returns the SQL dialect of the database that the
+	// connection is connected to. The dialect is read from the database the
+	// first time it is requested, and is cached for all connections of the
+	// same connector.
+	DatabaseDialect(ctx context.Context) (Dialect, error)
 
 	// CommitTimestamp returns the commit timestamp of the last implicit or explicit read/write transaction that
 	// was executed on the connection, or an error if the connection has not executed a read/write transaction
-	// that committed successfully. The timestamp is in the local timezone.
+	// that committed successfully, or if the last transaction on the connection was a read-only transaction.
+	// The timestamp is in UTC, unless a different location has been set with SetCommitTimestampLocation.
 	CommitTimestamp() (commitTimestamp time.Time, err error)
+	// CommitTimestampLocation returns the location of the commit timestamps that are returned by the connection.
+	CommitTimestampLocation() *time.Location
+	// SetCommitTimestampLocation sets the location of the commit timestamps that are returned by CommitTimestamp,
+	// Apply and the SHOW VARIABLE COMMIT_TIMESTAMP statement. The default is UTC. Setting the location to nil
+	// resets it to UTC. This option only changes how commit timestamps are presented, and is intended for
+	// displaying commit timestamps in interactive tools.
+	SetCommitTimestampLocation(loc *time.Location) error
+	// ReadTimestamp returns the read timestamp of the current or the last
+	// read-only transaction on the connection. The read timestamp is only
+	// known after the transaction has executed a query or a read. An error
+	// is returned if the connection has not executed a read-only transaction,
+	// or if the transaction has not yet read any data. The same value is
+	// returned by the SHOW VARIABLE READ_TIMESTAMP statement, which returns
+	// NULL instead of an error.
+	ReadTimestamp() (readTimestamp time.Time, err error)
+
+	// QueryPlan returns the query plan of the last statement that was
+	// executed on the connection with AnalyzeMode AnalyzePlan or
+	// AnalyzeProfile, or an error if the last statement on the connection was
+	// not executed in one of those modes. The query plan of a query is only
+	// available after all rows of the query have been consumed.
+	QueryPlan() (*spannerpb.QueryPlan, error)
+
+	// ResultSetStats returns the query plan and the execution statistics of
+	// the last query that was executed on the connection with AnalyzeMode
+	// AnalyzePlan or AnalyzeProfile. The statistics are only available after
+	// all rows of the query have been consumed, and only contain execution
+	// statistics if the query was executed with AnalyzeProfile. An error is
+	// returned if the last statement on the connection was not a query that
+	// was executed in one of those modes.
+	ResultSetStats() (*spannerpb.ResultSetStats, error)
+
+	// LastSessionName returns the name of the Spanner session that was used
+	// by the last statement that was sent to Spanner on the connection, in
+	// the format `projects/p/instances/i/databases/d/sessions/s`. This can be
+	// used to correlate statements with sessions when debugging session leaks.
+	// An error is returned if the connection has not executed a statement, or
+	// if the last statement did not use a session.
+	LastSessionName() (string, error)
+
+	// LastStatementType returns the type of the last statement that was
+	// executed on the connection with ExecContext or QueryContext, including
+	// client-side statements such as RUN BATCH. It returns
+	// StatementTypeUnknown if the connection has not executed a statement.
+	LastStatementType() StatementType
+	// LastRowsAffected returns the number of rows that were affected by the
+	// last statement that was executed on the connection. This is the total
+	// of all statements for RUN BATCH of a DML batch. For a query, it is the
+	// number of rows that the query has returned, which is only final after
+	// all rows have been consumed or the rows have been closed. It returns
+	// zero if the last statement failed, or did not affect or return any
+	// rows.
+	LastRowsAffected() int64
 }
 
 type conn struct {
@@ -402,16 +1391,38 @@ type conn struct {
 	adminClient *adminapi.DatabaseAdminClient
 	tx          contextTransaction
 	commitTs    *time.Time
-	database    string
-	retryAborts bool
-
-	execSingleQuery            func(ctx context.Context, c *spanner.Client, statement spanner.Statement, bound spanner.TimestampBound) *spanner.RowIterator
-	execSingleDMLTransactional func(ctx context.Context, c *spanner.Client, statement spanner.Statement, transactionOptions spanner.TransactionOptions) (int64, time.Time, error)
+	queryPlan   *spannerpb.QueryPlan
+	// resultSetStats are the statistics of the last query that was executed
+	// with AnalyzePlan or AnalyzeProfile.
+	resultSetStats *spannerpb.ResultSetStats
+	database       string
+	retryAborts    bool
+	// maxCommitRetries is the maximum number of internal retries of aborted
+	// read/write transactions on this connection. Zero means no limit.
+	maxCommitRetries int
+	// roTx is the current or last read-only transaction of the connection.
+	// It is used to return the read timestamp of the transaction.
+	roTx *spanner.ReadOnlyTransaction
+	// lastSession records the session of the last statement that was
+	// executed on the connection.
+	lastSession *sessionRecorder
+	// lastStatement records the type and the number of affected rows of the
+	// last statement that was executed on the connection.
+	lastStatement *lastStatement
+
+	execSingleQuery            func(ctx context.Context, c *spanner.Client, statement spanner.Statement, bound spanner.TimestampBound, options spanner.QueryOptions) *spanner.RowIterator
+	execSingleDMLTransactional func(ctx context.Context, c *spanner.Client, statement spanner.Statement, transactionOptions spanner.TransactionOptions, queryOptions spanner.QueryOptions) (int64, time.Time, error)
 	execSingleDMLPartitioned   func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.QueryOptions) (int64, error)
 
 	// batch is the currently active DDL or DML batch on this connection.
 	batch *batch
 
+	// autocommitDisabled indicates that a read/write transaction is started
+	// automatically by the first statement that is executed outside a
+	// transaction. implicitTx is the transaction that was started this way.
+	autocommitDisabled bool
+	implicitTx         contextTransaction
+
 	// autocommitDMLMode determines the type of DML to use when a single DML
 	// statement is executed on a connection. The default is Transactional, but
 	// it can also be set to PartitionedNonAtomic to execute the statement as
@@ -419,9 +1430,334 @@ type conn struct {
 	autocommitDMLMode AutocommitDMLMode
 	// readOnlyStaleness is used for queries in autocommit mode and for read-only transactions.
 	readOnlyStaleness spanner.TimestampBound
+	// directedReadOptions is used for queries in autocommit mode and for read-only transactions.
+	directedReadOptions *spannerpb.DirectedReadOptions
 	// excludeTxnFromChangeStreams is used to exlude the next transaction from change streams with the DDL option
 	// `allow_txn_exclusion=true`
 	excludeTxnFromChangeStreams bool
+	// rpcPriority is the default RPC priority for all statements and commits
+	// on this connection.
+	rpcPriority spannerpb.RequestOptions_Priority
+	// maxCommitDelay is the default maximum commit delay for all read/write
+	// transactions on this connection.
+	maxCommitDelay *time.Duration
+	// readOnly indicates that all transactions on this connection are
+	// read-only transactions, and that DML and mutations are not allowed.
+	readOnly bool
+	// statementTimeout is the default timeout of all statements on this
+	// connection. Zero means no timeout.
+	statementTimeout time.Duration
+	// optimizerVersion and optimizerStatisticsPackage are the default query
+	// optimizer options for all statements on this connection.
+	optimizerVersion           string
+	optimizerStatisticsPackage string
+	// commitTimestampLocation is the location of the commit timestamps that
+	// are returned by the connection. Commit timestamps are returned in UTC
+	// if it is nil.
+	commitTimestampLocation *time.Location
+
+	// execOptions are the options that were passed in as an argument for the
+	// statement that is currently being executed. These are set by
+	// CheckNamedValue and cleared when the statement is executed.
+	execOptions ExecOptions
+	// readRequest is the read that was passed in as an argument by ReadRow
+	// for the next query on this connection.
+	readRequest *readRequest
+	// partitionRequest is the partition that was passed in as an argument by
+	// PartitionedQuery.Execute for the next query on this connection.
+	partitionRequest *partitionRequest
+	// execManyRequest contains the parameter sets that were passed in as an
+	// argument by ExecMany for the next statement on this connection.
+	execManyRequest *execManyRequest
+	// readOnlyTxOptions are the options that should be used for the next
+	// read-only transaction that is started on this connection. These are set
+	// by BeginReadOnlyTransaction and cleared when the transaction starts.
+	readOnlyTxOptions *ReadOnlyTransactionOptions
+	// readWriteTxOptions are the options that should be used for the next
+	// read/write transaction that is started on this connection. These are
+	// set by RunTransaction and cleared when the transaction starts.
+	readWriteTxOptions *ReadWriteTransactionOptions
+
+	// inUse is 1 while the connection is executing an operation. It is used
+	// to detect concurrent use of the connection by multiple goroutines.
+	inUse int32
+}
+
+// ErrConnectionInUse is returned when an operation is started on a connection
+// that is already executing an operation for another goroutine. This happens
+// if a connection that is obtained with Raw is used outside the function that
+// is passed to Raw, or by multiple goroutines at the same time.
+var ErrConnectionInUse = spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "the connection is already in use by another goroutine"))
+
+// enter marks the connection as in use. It returns ErrConnectionInUse if the
+// connection is already in use. Every successful call to enter must be
+// followed by a call to leave.
+func (c *conn) enter() error {
+	if !atomic.CompareAndSwapInt32(&c.inUse, 0, 1) {
+		return ErrConnectionInUse
+	}
+	return nil
+}
+
+// leave marks the connection as no longer in use.
+func (c *conn) leave() {
+	atomic.StoreInt32(&c.inUse, 0)
+}
+
+// acquireRead waits until a streaming read may be started on the connector of
+// the connection. The returned function must be called when the read has
+// finished, and may be called more than once. acquireRead returns the error of
+// the context if the context is done before the read may be started.
+func (c *conn) acquireRead(ctx context.Context) (func(), error) {
+	if c.connector == nil || c.connector.readSemaphore == nil {
+		return func() {}, nil
+	}
+	semaphore := c.connector.readSemaphore
+	select {
+	case semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return nil, spanner.ToSpannerError(ctx.Err())
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-semaphore })
+	}, nil
+}
+
+// newStreamingRows returns rows for the given iterator that count towards the
+// maximum number of concurrent reads of the connector.
+func (c *conn) newStreamingRows(ctx context.Context, r *rows) (*rows, error) {
+	release, err := c.acquireRead(ctx)
+	if err != nil {
+		r.finish(err)
+		return nil, err
+	}
+	r.span = trace.SpanFromContext(ctx)
+	done := r.done
+	r.done = func(err error) {
+		release()
+		if done != nil {
+			done(err)
+		}
+	}
+	return r, nil
+}
+
+// ExecOptions can be passed in as an argument to the Query, QueryContext,
+// Exec, and ExecContext functions to specify additional execution options
+// for a statement. The ExecOptions argument is removed from the list of
+// arguments before the statement is sent to Spanner.
+//
+// An argument is only recognized as ExecOptions if its type is exactly
+// ExecOptions. Pointers to ExecOptions and user-defined types that embed
+// ExecOptions are handled as normal query parameters. The ExecOptions argument
+// may be placed at any position in the list of arguments, and is not counted
+// when positional parameters are assigned to the arguments. If more than one
+// ExecOptions argument is given, the last one is used.
+//
+// Example:
+//
+//	rows, err := db.QueryContext(ctx, "SELECT * FROM Singers WHERE Id=@id",
+//		spannerdriver.ExecOptions{OptimizerStatisticsPackage: "auto_20240101_00_00_00UTC"},
+//		sql.Named("id", 1))
+type ExecOptions struct {
+	// OptimizerVersion is the query optimizer version that should be used
+	// for the statement, for example "4" or "latest". This overrides the
+	// default optimizer version of the connection. Statement hints in the SQL
+	// string, such as @{OPTIMIZER_VERSION=...}, take precedence over this
+	// option.
+	OptimizerVersion string
+	// OptimizerStatisticsPackage is the query optimizer statistics package
+	// that should be used for the statement. This overrides the default
+	// statistics package of the connection. Statement hints in the SQL string,
+	// such as @{OPTIMIZER_STATISTICS_PACKAGE=...}, take precedence over this
+	// option.
+	OptimizerStatisticsPackage string
+	// Priority is the RPC priority that should be used for the statement.
+	// This overrides the default priority of the connection.
+	Priority spannerpb.RequestOptions_Priority
+	// RequestTag is the request tag that should be added to the statement.
+	RequestTag string
+	// DirectedReadOptions are the directed read options that should be used
+	// for the query. This overrides the directed read options of the
+	// connection. Directed reads can only be used for queries in autocommit
+	// mode and in read-only transactions. Executing a statement with
+	// directed read options in a read/write transaction, or executing a DML
+	// statement with directed read options, returns a FailedPrecondition
+	// error.
+	DirectedReadOptions *spannerpb.DirectedReadOptions
+	// QueryOptions are the Spanner query options that should be used for the
+	// statement, for example QueryOptions{RequestTag: "dashboard-query"}.
+	// OptimizerVersion, OptimizerStatisticsPackage, Priority, RequestTag and
+	// DirectedReadOptions take precedence over the corresponding values in
+	// QueryOptions if they are set. Use
+	// ReadWriteTransactionOptions.TransactionTag to set a transaction tag for
+	// a read/write transaction. DataBoostEnabled is not supported, as Data
+	// Boost can only be used with PartitionQuery.
+	QueryOptions spanner.QueryOptions
+
+	// AnalyzeMode determines whether a statement should be executed normally,
+	// only analyzed, or executed with execution statistics. The default is
+	// NoAnalyze, which executes the statement. AnalyzeProfile can only be used
+	// for queries.
+	AnalyzeMode AnalyzeMode
+
+	// Cacheable indicates that the result of the query may be cached in the
+	// query cache of the connector. This option is ignored if the connector
+	// has no query cache, or if the query is executed in a transaction. See
+	// ConnectorConfig.QueryCacheSize for more information.
+	Cacheable bool
+
+	// NullAsZeroValue indicates that NULL values in the result of a query
+	// should be returned as the zero value of the column type, for example 0
+	// for INT64 columns and an empty string for STRING columns. This allows
+	// NULL values to be scanned into non-nullable Go types, such as int64 and
+	// string, instead of returning an error.
+	//
+	// This option is lossy: NULL values can no longer be distinguished from
+	// zero values, also not when they are scanned into nullable types, such as
+	// sql.NullInt64 or *int64. The option only applies to scalar columns. The
+	// default is false, which returns an error if a NULL value is scanned into
+	// a non-nullable Go type.
+	NullAsZeroValue bool
+
+	// DecodeToNativeArrays indicates that ARRAY columns should be returned as
+	// slices of native Go types, for example []int64 instead of
+	// []spanner.NullInt64, so they can be scanned directly into these types.
+	// Decoding an array that contains a NULL element returns an error, as a
+	// NULL element cannot be represented in a slice of a native Go type. A
+	// NULL array is returned as a nil slice, and an empty array as a non-nil
+	// empty slice. ARRAY<JSON> columns are returned as []json.RawMessage.
+	// ARRAY<BYTES> columns are not affected by this option. Use NativeArray to scan a single column into a native Go
+	// slice without setting this option.
+	DecodeToNativeArrays bool
+	// DateLocation is the location that is used to decode DATE values into
+	// time.Time values. DATE values are returned as a time.Time at midnight in
+	// this location, and ARRAY<DATE> values as a []spanner.NullTime, or as a
+	// []time.Time if DecodeToNativeArrays is set. DATE values are returned as
+	// civil.Date if this is nil.
+	DateLocation *time.Location
+	// DecodeNumericAsString indicates that NUMERIC values should be returned
+	// as strings instead of big.Rat values. The strings contain the exact
+	// value that is returned by Spanner. ARRAY<NUMERIC> values are returned
+	// as []spanner.NullString, or as []string if DecodeToNativeArrays is set.
+	DecodeNumericAsString bool
+	// AllowNumericToFloat64 indicates that NUMERIC values should be returned
+	// as float64 values instead of big.Rat values, so they can be scanned
+	// directly into a float64. ARRAY<NUMERIC> values are returned as
+	// []spanner.NullFloat64, or as []float64 if DecodeToNativeArrays is set.
+	//
+	// This option is lossy: NUMERIC values have a precision of 38 digits and
+	// a scale of 9 digits, and are rounded to the nearest float64 value, which
+	// has a precision of approximately 15-17 significant digits. Use this
+	// option only when approximate values are sufficient, for example for
+	// reporting. DecodeNumericAsString takes precedence over this option.
+	AllowNumericToFloat64 bool
+	// DecodeJSONAsBytes indicates that JSON and PostgreSQL JSONB values
+	// should be returned as byte slices that contain the JSON text, instead
+	// of spanner.NullJSON and spanner.PGJsonB values. This allows JSON values
+	// to be scanned directly into a json.RawMessage, a []byte or a string.
+	// NULL values are returned as nil, and can be scanned into a *[]byte, a
+	// *json.RawMessage pointer or a sql.NullString. ARRAY<JSON> values are
+	// not affected by this option. Use DecodeToNativeArrays to decode them
+	// into a []json.RawMessage.
+	DecodeJSONAsBytes bool
+
+	// StatementType forces the driver to execute the statement as the given
+	// type of statement, instead of determining the type from the SQL string.
+	// This can be used if the driver determines the wrong type for a
+	// statement. The statement is never executed as a client-side statement
+	// if StatementType is set. The default is StatementTypeUnknown, which
+	// determines the type from the SQL string.
+	//
+	// ExecContext supports StatementTypeDML and StatementTypeDDL, and
+	// QueryContext supports StatementTypeQuery and StatementTypeDML. Other
+	// values return an InvalidArgument error.
+	StatementType StatementType
+
+	// CaseSensitiveFieldNames indicates that the columns in the result of a
+	// query that is executed with Query may only be mapped to struct fields
+	// with exactly the same name. Columns are also mapped to fields with the
+	// same name ignoring case and underscores if this is false. This option
+	// is ignored by all other functions.
+	CaseSensitiveFieldNames bool
+}
+
+// AnalyzeMode indicates how a statement should be analyzed.
+type AnalyzeMode int
+
+func (mode AnalyzeMode) String() string {
+	switch mode {
+	case NoAnalyze:
+		return "NoAnalyze"
+	case AnalyzePlan:
+		return "AnalyzePlan"
+	case AnalyzeProfile:
+		return "AnalyzeProfile"
+	}
+	return ""
+}
+
+// queryMode returns the Spanner query mode for the analyze mode, or nil for
+// NoAnalyze.
+func (mode AnalyzeMode) queryMode() *spannerpb.ExecuteSqlRequest_QueryMode {
+	var queryMode spannerpb.ExecuteSqlRequest_QueryMode
+	switch mode {
+	case AnalyzePlan:
+		queryMode = spannerpb.ExecuteSqlRequest_PLAN
+	case AnalyzeProfile:
+		queryMode = spannerpb.ExecuteSqlRequest_PROFILE
+	default:
+		return nil
+	}
+	return &queryMode
+}
+
+const (
+	// NoAnalyze executes the statement.
+	NoAnalyze AnalyzeMode = iota
+	// AnalyzePlan validates the statement and returns the query plan of the
+	// statement without executing it. No data is modified, and the returned
+	// result always reports zero affected rows. The query plan can be
+	// retrieved with SpannerConn.QueryPlan after the statement has been
+	// analyzed. A query that is executed with AnalyzePlan returns no rows.
+	// The query plan of a query can be retrieved after the (empty) rows have
+	// been consumed.
+	AnalyzePlan
+	// AnalyzeProfile executes the query and returns both the rows and the
+	// query plan and execution statistics of the query. The statistics can be
+	// retrieved with SpannerConn.ResultSetStats after all rows have been
+	// consumed. AnalyzeProfile can only be used for queries.
+	AnalyzeProfile
+)
+
+// queryOptions returns the Spanner query options that correspond with the
+// given ExecOptions.
+func (o *ExecOptions) queryOptions() spanner.QueryOptions {
+	options := o.QueryOptions
+	if o.OptimizerVersion != "" || o.OptimizerStatisticsPackage != "" {
+		if options.Options == nil {
+			options.Options = &spannerpb.ExecuteSqlRequest_QueryOptions{}
+		} else {
+			options.Options = proto.Clone(options.Options).(*spannerpb.ExecuteSqlRequest_QueryOptions)
+		}
+		if o.OptimizerVersion != "" {
+			options.Options.OptimizerVersion = o.OptimizerVersion
+		}
+		if o.OptimizerStatisticsPackage != "" {
+			options.Options.OptimizerStatisticsPackage = o.OptimizerStatisticsPackage
+		}
+	}
+	if o.Priority != spannerpb.RequestOptions_PRIORITY_UNSPECIFIED {
+		options.Priority = o.Priority
+	}
+	if o.RequestTag != "" {
+		options.RequestTag = o.RequestTag
+	}
+	if o.DirectedReadOptions != nil {
+		options.DirectedReadOptions = o.DirectedReadOptions
+	}
+	return options
 }
 
 type batchType int
@@ -453,6 +1789,13 @@ func (mode AutocommitDMLMode) String() string {
 
 const (
 	Transactional AutocommitDMLMode = iota
+	// PartitionedNonAtomic executes DML statements outside transactions as
+	// Partitioned DML. Partitioned DML is not atomic. Cancelling the context
+	// of a Partitioned DML statement stops the statement and returns an error
+	// with code Canceled or DeadlineExceeded, but the changes of partitions
+	// that have already been applied are not reverted. The number of rows
+	// affected that is returned for a Partitioned DML statement is a lower
+	// bound of the number of rows that were modified.
 	PartitionedNonAtomic
 )
 
@@ -460,7 +1803,66 @@ func (c *conn) CommitTimestamp() (time.Time, error) {
 	if c.commitTs == nil {
 		return time.Time{}, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "this connection has not executed a read/write transaction that committed successfully"))
 	}
-	return *c.commitTs, nil
+	return c.inCommitTimestampLocation(normalizeTime(*c.commitTs)), nil
+}
+
+func (c *conn) ReadTimestamp() (time.Time, error) {
+	if c.roTx == nil {
+		return time.Time{}, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "this connection has not executed a read-only transaction"))
+	}
+	ts, err := c.roTx.Timestamp()
+	if err != nil {
+		return time.Time{}, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "the read-only transaction has not read any data"))
+	}
+	return normalizeTime(ts), nil
+}
+
+func (c *conn) CommitTimestampLocation() *time.Location {
+	if c.commitTimestampLocation == nil {
+		return time.UTC
+	}
+	return c.commitTimestampLocation
+}
+
+func (c *conn) SetCommitTimestampLocation(loc *time.Location) error {
+	_, err := c.setCommitTimestampLocation(loc)
+	return err
+}
+
+func (c *conn) setCommitTimestampLocation(loc *time.Location) (driver.Result, error) {
+	c.commitTimestampLocation = loc
+	return driver.ResultNoRows, nil
+}
+
+// inCommitTimestampLocation returns the given commit timestamp in the commit
+// timestamp location of the connection.
+func (c *conn) inCommitTimestampLocation(ts time.Time) time.Time {
+	if c.commitTimestampLocation == nil {
+		return ts
+	}
+	return ts.In(c.commitTimestampLocation)
+}
+
+// normalizeTime returns the given time in UTC and without a monotonic clock
+// reading. All time.Time values that are returned by the driver are
+// normalized, so timestamps that are read from the database can be compared
+// with commit timestamps using both == and reflect.DeepEqual.
+func normalizeTime(t time.Time) time.Time {
+	return t.Round(0).UTC()
+}
+
+func (c *conn) QueryPlan() (*spannerpb.QueryPlan, error) {
+	if c.queryPlan == nil {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "the last statement on this connection was not executed with AnalyzePlan or AnalyzeProfile, or its rows have not been consumed"))
+	}
+	return c.queryPlan, nil
+}
+
+func (c *conn) ResultSetStats() (*spannerpb.ResultSetStats, error) {
+	if c.resultSetStats == nil {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "the last statement on this connection was not a query that was executed with AnalyzePlan or AnalyzeProfile, or its rows have not been consumed"))
+	}
+	return c.resultSetStats, nil
 }
 
 func (c *conn) RetryAbortsInternally() bool {
@@ -480,6 +1882,50 @@ func (c *conn) setRetryAbortsInternally(retry bool) (driver.Result, error) {
 	return driver.ResultNoRows, nil
 }
 
+func (c *conn) MaxCommitRetries() int {
+	return c.maxCommitRetries
+}
+
+func (c *conn) SetMaxCommitRetries(retries int) error {
+	_, err := c.setMaxCommitRetries(retries)
+	return err
+}
+
+func (c *conn) setMaxCommitRetries(retries int) (driver.Result, error) {
+	if retries < 0 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "max commit retries must not be negative, got %d", retries))
+	}
+	c.maxCommitRetries = retries
+	return driver.ResultNoRows, nil
+}
+
+// abortedRetryBackoff returns a new copy of the backoff of the connector for
+// internal retries of aborted transactions, or nil if no backoff has been set.
+func (c *conn) abortedRetryBackoff() *gax.Backoff {
+	if c.connector == nil || c.connector.config.AbortedRetryBackoff == nil {
+		return nil
+	}
+	backoff := *c.connector.config.AbortedRetryBackoff
+	return &backoff
+}
+
+func (c *conn) Autocommit() bool {
+	return !c.autocommitDisabled
+}
+
+func (c *conn) SetAutocommit(autocommit bool) error {
+	_, err := c.setAutocommit(autocommit)
+	return err
+}
+
+func (c *conn) setAutocommit(autocommit bool) (driver.Result, error) {
+	if c.inTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "cannot change autocommit while a transaction is active"))
+	}
+	c.autocommitDisabled = !autocommit
+	return driver.ResultNoRows, nil
+}
+
 func (c *conn) AutocommitDMLMode() AutocommitDMLMode {
 	return c.autocommitDMLMode
 }
@@ -490,6 +1936,9 @@ func (c *conn) SetAutocommitDMLMode(mode AutocommitDMLMode) error {
 }
 
 func (c *conn) setAutocommitDMLMode(mode AutocommitDMLMode) (driver.Result, error) {
+	if mode == PartitionedNonAtomic && c.inTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "Partitioned DML cannot be used in a transaction"))
+	}
 	c.autocommitDMLMode = mode
 	return driver.ResultNoRows, nil
 }
@@ -508,39 +1957,230 @@ func (c *conn) setReadOnlyStaleness(staleness spanner.TimestampBound) (driver.Re
 	return driver.ResultNoRows, nil
 }
 
-func (c *conn) ExcludeTxnFromChangeStreams() bool {
-	return c.excludeTxnFromChangeStreams
+func (c *conn) DirectedReadOptions() *spannerpb.DirectedReadOptions {
+	return c.directedReadOptions
 }
 
-func (c *conn) SetExcludeTxnFromChangeStreams(excludeTxnFromChangeStreams bool) error {
-	_, err := c.setExcludeTxnFromChangeStreams(excludeTxnFromChangeStreams)
+func (c *conn) SetDirectedReadOptions(options *spannerpb.DirectedReadOptions) error {
+	_, err := c.setDirectedReadOptions(options)
 	return err
 }
 
-func (c *conn) setExcludeTxnFromChangeStreams(excludeTxnFromChangeStreams bool) (driver.Result, error) {
-	if c.inTransaction() {
-		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "cannot set ExcludeTxnFromChangeStreams while a transaction is active"))
+func (c *conn) setDirectedReadOptions(options *spannerpb.DirectedReadOptions) (driver.Result, error) {
+	if err := validateDirectedReadOptions(options); err != nil {
+		return nil, err
 	}
-	c.excludeTxnFromChangeStreams = excludeTxnFromChangeStreams
+	c.directedReadOptions = options
 	return driver.ResultNoRows, nil
 }
 
-func (c *conn) StartBatchDDL() error {
-	_, err := c.startBatchDDL()
-	return err
-}
-
-func (c *conn) StartBatchDML() error {
-	_, err := c.startBatchDML()
-	return err
-}
-
-func (c *conn) RunBatch(ctx context.Context) error {
-	_, err := c.runBatch(ctx)
-	return err
-}
+// maxReplicaSelections is the maximum number of replica selections that
+// Spanner accepts in a set of directed read options.
+const maxReplicaSelections = 10
 
-func (c *conn) AbortBatch() error {
+// validateDirectedReadOptions checks the given directed read options for
+// configurations that would always be rejected by Spanner, or that would
+// exclude all replicas from serving the read. A nil value is valid and means
+// that no directed read options should be used.
+func validateDirectedReadOptions(options *spannerpb.DirectedReadOptions) error {
+	if options == nil {
+		return nil
+	}
+	var selections []*spannerpb.DirectedReadOptions_ReplicaSelection
+	switch replicas := options.Replicas.(type) {
+	case *spannerpb.DirectedReadOptions_IncludeReplicas_:
+		if replicas.IncludeReplicas == nil || len(replicas.IncludeReplicas.ReplicaSelections) == 0 {
+			return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "directed read options must include at least one replica selection when include_replicas is set"))
+		}
+		selections = replicas.IncludeReplicas.ReplicaSelections
+	case *spannerpb.DirectedReadOptions_ExcludeReplicas_:
+		if replicas.ExcludeReplicas == nil || len(replicas.ExcludeReplicas.ReplicaSelections) == 0 {
+			return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "directed read options must exclude at least one replica selection when exclude_replicas is set"))
+		}
+		selections = replicas.ExcludeReplicas.ReplicaSelections
+	default:
+		return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "directed read options must set either include_replicas or exclude_replicas"))
+	}
+	if len(selections) > maxReplicaSelections {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "directed read options may contain at most %d replica selections, got %d", maxReplicaSelections, len(selections)))
+	}
+	excludedTypes := make(map[spannerpb.DirectedReadOptions_ReplicaSelection_Type]bool)
+	for _, selection := range selections {
+		if selection == nil {
+			return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "directed read options contain an empty replica selection"))
+		}
+		if _, ok := options.Replicas.(*spannerpb.DirectedReadOptions_ExcludeReplicas_); ok && selection.Location == "" {
+			excludedTypes[selection.Type] = true
+		}
+	}
+	// Excluding a replica selection without a location excludes all replicas
+	// of that type. Excluding all types in that way leaves no replica that can
+	// serve the read.
+	if excludedTypes[spannerpb.DirectedReadOptions_ReplicaSelection_TYPE_UNSPECIFIED] ||
+		(excludedTypes[spannerpb.DirectedReadOptions_ReplicaSelection_READ_WRITE] && excludedTypes[spannerpb.DirectedReadOptions_ReplicaSelection_READ_ONLY]) {
+		return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "directed read options exclude all replicas"))
+	}
+	return nil
+}
+
+func (c *conn) ExcludeTxnFromChangeStreams() bool {
+	return c.excludeTxnFromChangeStreams
+}
+
+func (c *conn) SetExcludeTxnFromChangeStreams(excludeTxnFromChangeStreams bool) error {
+	_, err := c.setExcludeTxnFromChangeStreams(excludeTxnFromChangeStreams)
+	return err
+}
+
+func (c *conn) setExcludeTxnFromChangeStreams(excludeTxnFromChangeStreams bool) (driver.Result, error) {
+	if c.inTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "cannot set ExcludeTxnFromChangeStreams while a transaction is active"))
+	}
+	c.excludeTxnFromChangeStreams = excludeTxnFromChangeStreams
+	return driver.ResultNoRows, nil
+}
+
+func (c *conn) RPCPriority() spannerpb.RequestOptions_Priority {
+	return c.rpcPriority
+}
+
+func (c *conn) SetRPCPriority(priority spannerpb.RequestOptions_Priority) error {
+	_, err := c.setRPCPriority(priority)
+	return err
+}
+
+func (c *conn) setRPCPriority(priority spannerpb.RequestOptions_Priority) (driver.Result, error) {
+	if _, ok := spannerpb.RequestOptions_Priority_name[int32(priority)]; !ok {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid priority: %d", priority))
+	}
+	c.rpcPriority = priority
+	return driver.ResultNoRows, nil
+}
+
+func (c *conn) MaxCommitDelay() *time.Duration {
+	return c.maxCommitDelay
+}
+
+func (c *conn) SetMaxCommitDelay(delay *time.Duration) error {
+	_, err := c.setMaxCommitDelay(delay)
+	return err
+}
+
+func (c *conn) setMaxCommitDelay(delay *time.Duration) (driver.Result, error) {
+	if err := validateMaxCommitDelay(delay); err != nil {
+		return nil, err
+	}
+	c.maxCommitDelay = delay
+	return driver.ResultNoRows, nil
+}
+
+func (c *conn) ReadOnly() bool {
+	return c.readOnly
+}
+
+func (c *conn) SetReadOnly(readOnly bool) error {
+	_, err := c.setReadOnly(readOnly)
+	return err
+}
+
+func (c *conn) setReadOnly(readOnly bool) (driver.Result, error) {
+	if c.inTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "cannot change read-only mode while a transaction is active"))
+	}
+	c.readOnly = readOnly
+	return driver.ResultNoRows, nil
+}
+
+// checkNotReadOnly returns a FailedPrecondition error if the connection is in
+// read-only mode.
+func (c *conn) checkNotReadOnly() error {
+	if c.readOnly {
+		return spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "DML statements and mutations are not allowed on a read-only connection"))
+	}
+	return nil
+}
+
+func (c *conn) OptimizerVersion() string {
+	return c.optimizerVersion
+}
+
+func (c *conn) SetOptimizerVersion(version string) error {
+	_, err := c.setOptimizerVersion(version)
+	return err
+}
+
+func (c *conn) setOptimizerVersion(version string) (driver.Result, error) {
+	c.optimizerVersion = version
+	return driver.ResultNoRows, nil
+}
+
+func (c *conn) OptimizerStatisticsPackage() string {
+	return c.optimizerStatisticsPackage
+}
+
+func (c *conn) SetOptimizerStatisticsPackage(statisticsPackage string) error {
+	_, err := c.setOptimizerStatisticsPackage(statisticsPackage)
+	return err
+}
+
+func (c *conn) setOptimizerStatisticsPackage(statisticsPackage string) (driver.Result, error) {
+	c.optimizerStatisticsPackage = statisticsPackage
+	return driver.ResultNoRows, nil
+}
+
+func (c *conn) StatementTimeout() time.Duration {
+	return c.statementTimeout
+}
+
+func (c *conn) SetStatementTimeout(timeout time.Duration) error {
+	_, err := c.setStatementTimeout(timeout)
+	return err
+}
+
+func (c *conn) setStatementTimeout(timeout time.Duration) (driver.Result, error) {
+	if timeout < 0 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "statement timeout must not be negative, got %v", timeout))
+	}
+	c.statementTimeout = timeout
+	return driver.ResultNoRows, nil
+}
+
+// maxCommitDelayLimit is the largest maximum commit delay that is accepted by
+// Spanner.
+const maxCommitDelayLimit = 500 * time.Millisecond
+
+// validateMaxCommitDelay returns an InvalidArgument error if the given delay
+// is negative or larger than the maximum that is accepted by Spanner.
+func validateMaxCommitDelay(delay *time.Duration) error {
+	if delay == nil {
+		return nil
+	}
+	if *delay < 0 || *delay > maxCommitDelayLimit {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "max commit delay must be between 0 and %v, got %v", maxCommitDelayLimit, *delay))
+	}
+	return nil
+}
+
+func (c *conn) StartBatchDDL() error {
+	_, err := c.startBatchDDL()
+	return err
+}
+
+func (c *conn) StartBatchDML() error {
+	_, err := c.startBatchDML()
+	return err
+}
+
+func (c *conn) RunBatch(ctx context.Context) error {
+	if err := c.enter(); err != nil {
+		return err
+	}
+	defer c.leave()
+	_, err := c.runBatch(ctx)
+	return err
+}
+
+func (c *conn) AbortBatch() error {
 	_, err := c.abortBatch()
 	return err
 }
@@ -584,8 +2224,12 @@ func (c *conn) startBatchDML() (driver.Result, error) {
 }
 
 func (c *conn) runBatch(ctx context.Context) (driver.Result, error) {
+	// DML batches use the same default RPC priority and query options as
+	// single statements on the connection or transaction.
+	execOptions := c.options()
+	queryOptions := execOptions.queryOptions()
 	if c.inTransaction() {
-		return c.tx.RunBatch(ctx)
+		return c.tx.RunBatch(ctx, queryOptions)
 	}
 
 	if c.batch == nil {
@@ -595,7 +2239,7 @@ func (c *conn) runBatch(ctx context.Context) (driver.Result, error) {
 	case ddl:
 		return c.runDDLBatch(ctx)
 	case dml:
-		return c.runDMLBatch(ctx)
+		return c.runDMLBatch(ctx, queryOptions)
 	default:
 		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "Unknown batch type: %d", c.batch.tp))
 	}
@@ -607,10 +2251,10 @@ func (c *conn) runDDLBatch(ctx context.Context) (driver.Result, error) {
 	return c.execDDL(ctx, statements...)
 }
 
-func (c *conn) runDMLBatch(ctx context.Context) (driver.Result, error) {
+func (c *conn) runDMLBatch(ctx context.Context, options spanner.QueryOptions) (driver.Result, error) {
 	statements := c.batch.statements
 	c.batch = nil
-	return c.execBatchDML(ctx, statements)
+	return c.execBatchDML(ctx, statements, options)
 }
 
 func (c *conn) abortBatch() (driver.Result, error) {
@@ -650,7 +2294,7 @@ func (c *conn) execDDL(ctx context.Context, statements ...spanner.Statement) (dr
 	return driver.ResultNoRows, nil
 }
 
-func (c *conn) execBatchDML(ctx context.Context, statements []spanner.Statement) (driver.Result, error) {
+func (c *conn) execBatchDML(ctx context.Context, statements []spanner.Statement, options spanner.QueryOptions) (driver.Result, error) {
 	if len(statements) == 0 {
 		return &result{}, nil
 	}
@@ -662,10 +2306,10 @@ func (c *conn) execBatchDML(ctx context.Context, statements []spanner.Statement)
 		if !ok {
 			return nil, status.Errorf(codes.FailedPrecondition, "connection is in a transaction that is not a read/write transaction")
 		}
-		affected, err = tx.rwTx.BatchUpdate(ctx, statements)
+		affected, err = tx.rwTx.BatchUpdateWithOptions(ctx, statements, options)
 	} else {
 		_, err = c.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, transaction *spanner.ReadWriteTransaction) error {
-			affected, err = transaction.BatchUpdate(ctx, statements)
+			affected, err = transaction.BatchUpdateWithOptions(ctx, statements, options)
 			return err
 		}, c.createTransactionOptions())
 	}
@@ -681,46 +2325,152 @@ func sum(affected []int64) int64 {
 }
 
 func (c *conn) Apply(ctx context.Context, ms []*spanner.Mutation, opts ...spanner.ApplyOption) (commitTimestamp time.Time, err error) {
+	if err := c.enter(); err != nil {
+		return time.Time{}, err
+	}
+	defer c.leave()
 	if c.inTransaction() {
 		return time.Time{}, spanner.ToSpannerError(
 			status.Error(
 				codes.FailedPrecondition,
 				"Apply may not be called while the connection is in a transaction. Use BufferWrite to write mutations in a transaction."))
 	}
-	return c.client.Apply(ctx, ms, opts...)
+	if err := c.checkNotReadOnly(); err != nil {
+		return time.Time{}, err
+	}
+	return c.apply(ctx, ms, opts...)
+}
+
+func (c *conn) apply(ctx context.Context, ms []*spanner.Mutation, opts ...spanner.ApplyOption) (time.Time, error) {
+	if c.rpcPriority != spannerpb.RequestOptions_PRIORITY_UNSPECIFIED {
+		opts = append([]spanner.ApplyOption{spanner.Priority(c.rpcPriority)}, opts...)
+	}
+	if c.excludeTxnFromChangeStreams {
+		c.excludeTxnFromChangeStreams = false
+		opts = append([]spanner.ApplyOption{spanner.ExcludeTxnFromChangeStreams()}, opts...)
+	}
+	commitTimestamp, err := c.applyMutations(ctx, ms, opts...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	commitTimestamp = c.inCommitTimestampLocation(normalizeTime(commitTimestamp))
+	c.commitTs = &commitTimestamp
+	return commitTimestamp, nil
 }
 
 func (c *conn) BufferWrite(ms []*spanner.Mutation) error {
+	return c.BufferWriteContext(context.Background(), ms)
+}
+
+func (c *conn) BufferWriteContext(ctx context.Context, ms []*spanner.Mutation) error {
+	if err := c.enter(); err != nil {
+		return err
+	}
+	defer c.leave()
+	if err := c.checkNotReadOnly(); err != nil {
+		return err
+	}
+	if err := c.beginImplicitTransaction(); err != nil {
+		return err
+	}
 	if !c.inTransaction() {
-		return spanner.ToSpannerError(
+		// Apply the mutations directly when the connection is in autocommit mode.
+		_, err := c.apply(ctx, ms)
+		return err
+	}
+	return c.tx.BufferWrite(ms)
+}
+
+func (c *conn) BufferedMutations() []*spanner.Mutation {
+	tx, ok := c.tx.(*readWriteTransaction)
+	if !ok {
+		return []*spanner.Mutation{}
+	}
+	return append([]*spanner.Mutation{}, tx.mutations...)
+}
+
+func (c *conn) BatchWrite(ctx context.Context, groups []*spanner.MutationGroup) ([]MutationGroupResult, error) {
+	if err := c.enter(); err != nil {
+		return nil, err
+	}
+	defer c.leave()
+	if c.inTransaction() {
+		return nil, spanner.ToSpannerError(
 			status.Error(
 				codes.FailedPrecondition,
-				"BufferWrite may not be called while the connection is not in a transaction. Use Apply to write mutations outside a transaction."))
+				"BatchWrite may not be called while the connection is in a transaction."))
 	}
-	return c.tx.BufferWrite(ms)
+	if err := c.checkNotReadOnly(); err != nil {
+		return nil, err
+	}
+	results := make([]MutationGroupResult, len(groups))
+	for i := range results {
+		results[i].Index = i
+	}
+	seen := make([]bool, len(groups))
+	err := c.client.BatchWrite(ctx, groups).Do(func(resp *spannerpb.BatchWriteResponse) error {
+		var groupErr error
+		if code := codes.Code(resp.GetStatus().GetCode()); code != codes.OK {
+			groupErr = spanner.ToSpannerError(status.ErrorProto(resp.GetStatus()))
+		}
+		for _, index := range resp.GetIndexes() {
+			if int(index) < 0 || int(index) >= len(results) {
+				return spanner.ToSpannerError(status.Errorf(codes.Internal, "invalid mutation group index in BatchWrite response: %d", index))
+			}
+			seen[index] = true
+			if groupErr != nil {
+				results[index].Err = groupErr
+			} else {
+				results[index].CommitTimestamp = normalizeTime(resp.GetCommitTimestamp().AsTime())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if !seen[i] {
+			results[i].Err = spanner.ToSpannerError(status.Errorf(codes.Unknown, "no result returned for mutation group %d", i))
+		}
+	}
+	return results, nil
 }
 
-// Ping implements the driver.Pinger interface.
-// returns ErrBadConn if the connection is no longer valid.
+// Ping implements the driver.Pinger interface. It executes a `SELECT 1` query
+// on Spanner to verify that the database can be reached. An error that is
+// returned by Spanner is returned with the same gRPC status code, so the
+// caller can distinguish for example a database that does not exist
+// (NotFound) from a network problem (Unavailable). It returns
+// driver.ErrBadConn if the connection has been closed.
 func (c *conn) Ping(ctx context.Context) error {
 	if c.closed {
 		return driver.ErrBadConn
 	}
 	rows, err := c.QueryContext(ctx, "SELECT 1", []driver.NamedValue{})
 	if err != nil {
-		return driver.ErrBadConn
+		return pingError(c.database, err)
 	}
 	defer rows.Close()
 	values := make([]driver.Value, 1)
 	if err := rows.Next(values); err != nil {
-		return driver.ErrBadConn
+		if err == io.EOF {
+			return spanner.ToSpannerError(status.Errorf(codes.Internal, "ping of %s failed: the ping query returned no rows", c.database))
+		}
+		return pingError(c.database, err)
 	}
 	if values[0] != int64(1) {
-		return driver.ErrBadConn
+		return spanner.ToSpannerError(status.Errorf(codes.Internal, "ping of %s failed: the ping query returned %v instead of 1", c.database, values[0]))
 	}
 	return nil
 }
 
+// pingError returns an error for a failed ping of the given database with
+// the same code as the given error.
+func pingError(database string, err error) error {
+	return spanner.ToSpannerError(status.Errorf(spanner.ErrCode(err), "ping of %s failed: %v", database, spanner.ErrDesc(err)))
+}
+
 // ResetSession implements the driver.SessionResetter interface.
 // returns ErrBadConn if the connection is no longer valid.
 func (c *conn) ResetSession(_ context.Context) error {
@@ -733,10 +2483,27 @@ func (c *conn) ResetSession(_ context.Context) error {
 		}
 	}
 	c.commitTs = nil
+	c.roTx = nil
+	c.lastSession = nil
+	c.lastStatement = nil
 	c.batch = nil
+	c.autocommitDisabled = false
+	c.implicitTx = nil
 	c.retryAborts = true
+	c.maxCommitRetries = 0
+	if c.connector != nil {
+		c.maxCommitRetries = c.connector.config.MaxCommitRetries
+	}
 	c.autocommitDMLMode = Transactional
 	c.readOnlyStaleness = spanner.TimestampBound{}
+	c.directedReadOptions = nil
+	c.rpcPriority = spannerpb.RequestOptions_PRIORITY_UNSPECIFIED
+	c.maxCommitDelay = nil
+	c.readOnly = false
+	c.optimizerVersion = ""
+	c.optimizerStatisticsPackage = ""
+	c.statementTimeout = 0
+	c.commitTimestampLocation = nil
 	return nil
 }
 
@@ -764,6 +2531,7 @@ func checkIsValidType(v driver.Value) bool {
 	case []*string:
 	case []byte:
 	case [][]byte:
+	case []NullBytes:
 	case uint:
 	case []uint:
 	case *uint:
@@ -774,6 +2542,12 @@ func checkIsValidType(v driver.Value) bool {
 	case *int:
 	case []*int:
 	case *[]int:
+	case []int8:
+	case []int16:
+	case []int32:
+	case []uint16:
+	case []uint32:
+	case []uint64:
 	case int64:
 	case []int64:
 	case spanner.NullInt64:
@@ -818,6 +2592,10 @@ func checkIsValidType(v driver.Value) bool {
 	case []*civil.Date:
 	case spanner.NullJSON:
 	case []spanner.NullJSON:
+	case json.RawMessage:
+	case []json.RawMessage:
+	case spanner.PGJsonB:
+	case []spanner.PGJsonB:
 	case spanner.GenericColumnValue:
 	}
 	return true
@@ -827,8 +2605,52 @@ func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
 	if value == nil {
 		return nil
 	}
+	err := c.checkNamedValue(value)
+	if err != nil && err != driver.ErrRemoveArgument {
+		// The statement will not be executed, so any options that were set by
+		// earlier arguments of the statement must not be applied to the next
+		// statement.
+		c.execOptions = ExecOptions{}
+		c.readRequest = nil
+		c.partitionRequest = nil
+		c.execManyRequest = nil
+	}
+	return err
+}
+
+func (c *conn) checkNamedValue(value *driver.NamedValue) error {
+	if execOptions, ok := value.Value.(ExecOptions); ok {
+		c.execOptions = execOptions
+		return driver.ErrRemoveArgument
+	}
+	if req, ok := value.Value.(readRequest); ok {
+		c.readRequest = &req
+		return driver.ErrRemoveArgument
+	}
+	if req, ok := value.Value.(partitionRequest); ok {
+		c.partitionRequest = &req
+		return driver.ErrRemoveArgument
+	}
+	if req, ok := value.Value.(execManyRequest); ok {
+		c.execManyRequest = &req
+		return driver.ErrRemoveArgument
+	}
+	if execOptions, ok, err := execOptionsFromTag(value.Value); err != nil {
+		return err
+	} else if ok {
+		c.execOptions = execOptions
+		return driver.ErrRemoveArgument
+	}
 	if checkIsValidType(value.Value) {
-		return nil
+		return checkInt64Range(value.Value)
+	}
+	if slice, ok := arrayToSlice(value.Value); ok && checkIsValidType(slice) {
+		value.Value = slice
+		return checkInt64Range(slice)
+	}
+	if slice, ok := nullArrayToSlice(value.Value); ok && checkIsValidType(slice) {
+		value.Value = slice
+		return checkInt64Range(slice)
 	}
 	if valuer, ok := value.Value.(driver.Valuer); ok {
 		v, err := valuer.Value()
@@ -836,6 +2658,14 @@ func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
 			return err
 		}
 		if checkIsValidType(v) {
+			value.Value = v
+			return checkInt64Range(v)
+		}
+	}
+	if c.connector != nil && c.connector.autoMarshalJSON {
+		if v, ok, err := marshalJSONParam(value.Value); err != nil {
+			return err
+		} else if ok {
 			value.Value = v
 			return nil
 		}
@@ -848,56 +2678,278 @@ func (c *conn) Prepare(query string) (driver.Stmt, error) {
 }
 
 func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
-	parsedSQL, args, err := parseParameters(query)
+	dialect, err := c.statementDialect(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	parsedSQL, args, err := parseParametersForDialect(dialect, query)
 	if err != nil {
 		return nil, err
 	}
 	return &stmt{conn: c, query: parsedSQL, numArgs: len(args)}, nil
 }
 
+// options returns the ExecOptions that were passed in as an argument for the
+// current statement, and clears them from the connection.
+func (c *conn) options() ExecOptions {
+	defer func() { c.execOptions = ExecOptions{} }()
+	options := c.execOptions
+	if options.Priority == spannerpb.RequestOptions_PRIORITY_UNSPECIFIED && options.QueryOptions.Priority == spannerpb.RequestOptions_PRIORITY_UNSPECIFIED {
+		if tx, ok := c.tx.(*readWriteTransaction); ok {
+			options.Priority = tx.priority
+		} else {
+			options.Priority = c.rpcPriority
+		}
+	}
+	if options.OptimizerVersion == "" && options.QueryOptions.Options.GetOptimizerVersion() == "" {
+		options.OptimizerVersion = c.optimizerVersion
+	}
+	if options.OptimizerStatisticsPackage == "" && options.QueryOptions.Options.GetOptimizerStatisticsPackage() == "" {
+		options.OptimizerStatisticsPackage = c.optimizerStatisticsPackage
+	}
+	return options
+}
+
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	// Execute client side statement if it is one.
-	clientStmt, err := parseClientSideStatement(c, query)
-	if err != nil {
+	if err := c.enter(); err != nil {
+		return nil, err
+	}
+	defer c.leave()
+	execOptions := c.options()
+	if c.readRequest != nil {
+		req := *c.readRequest
+		c.readRequest = nil
+		req.options.Priority = execOptions.queryOptions().Priority
+		if err := c.beginImplicitTransaction(); err != nil {
+			return nil, err
+		}
+		return c.read(ctx, query, req)
+	}
+	if c.partitionRequest != nil {
+		req := *c.partitionRequest
+		c.partitionRequest = nil
+		return c.executePartition(ctx, query, req)
+	}
+	switch execOptions.StatementType {
+	case StatementTypeUnknown:
+		// Execute client side statement if it is one.
+		clientStmt, err := parseClientSideStatement(c, query)
+		if err != nil {
+			return nil, err
+		}
+		if clientStmt != nil {
+			c.recordLastStatement(query, clientSideStatementType(clientStmt.clientSideStatement))
+			return clientStmt.QueryContext(ctx, args)
+		}
+	case StatementTypeQuery, StatementTypeDML:
+	default:
+		return nil, unsupportedStatementTypeError(execOptions.StatementType, "QueryContext")
+	}
+	if execOptions.QueryOptions.DataBoostEnabled {
+		return nil, dataBoostNotSupportedError()
+	}
+	if err := validateDirectedReadOptions(execOptions.queryOptions().DirectedReadOptions); err != nil {
 		return nil, err
 	}
-	if clientStmt != nil {
-		return clientStmt.QueryContext(ctx, args)
+	if err := c.beginImplicitTransaction(); err != nil {
+		return nil, err
 	}
 	// Clear the commit timestamp of this connection before we execute the query.
 	c.commitTs = nil
+	c.queryPlan = nil
+	c.resultSetStats = nil
 
-	stmt, err := prepareSpannerStmt(query, args)
+	last := c.recordLastStatement(query, execOptions.StatementType)
+	ctx, done := c.startStatement(ctx, spanQuery, query)
+	stmt, err := c.prepareSpannerStmt(ctx, query, args)
 	if err != nil {
+		done(err)
+		return nil, err
+	}
+	recordExecutedSQL(ctx, stmt.SQL)
+	queryOptions := execOptions.queryOptions()
+	recordRequestTag(ctx, queryOptions.RequestTag)
+	if mode := execOptions.AnalyzeMode.queryMode(); mode != nil {
+		queryOptions.Mode = mode
+	}
+	analyze := queryOptions.Mode != nil && *queryOptions.Mode != spannerpb.ExecuteSqlRequest_NORMAL
+	isDML := execOptions.StatementType == StatementTypeDML
+	if !isDML && c.tx == nil {
+		if isDML, err = isDMLWithReturning(query); err != nil {
+			done(err)
+			return nil, err
+		}
+	}
+	if isDML {
+		if err := c.checkNotReadOnly(); err != nil {
+			done(err)
+			return nil, err
+		}
+	}
+	if analyze && isDML {
+		err := spanner.ToSpannerError(status.Error(codes.InvalidArgument, "DML statements cannot be analyzed with QueryContext, use ExecContext with AnalyzePlan instead"))
+		done(err)
 		return nil, err
 	}
 	var iter rowIterator
-	if c.tx == nil {
-		iter = &readOnlyRowIterator{c.execSingleQuery(ctx, c.client, stmt, c.readOnlyStaleness)}
+	if (c.tx == nil && isDML || c.inReadWriteTransaction()) && queryOptions.DirectedReadOptions != nil {
+		err := directedReadNotSupportedError()
+		done(err)
+		return nil, err
+	}
+	if c.tx == nil && isDML {
+		// DML statements that return rows are executed in a read/write
+		// transaction that is committed before the rows are returned.
+		if iter, err = c.queryInNewRWTransaction(ctx, stmt, queryOptions); err != nil {
+			done(err)
+			return nil, err
+		}
+	} else if c.tx == nil && execOptions.Cacheable && !analyze && c.connector != nil && c.connector.queryCache != nil {
+		cache := c.connector.queryCache
+		options := c.addReadOnlyQueryOptions(queryOptions)
+		key, err := queryCacheKey(stmt, c.readOnlyStaleness, options)
+		if err != nil {
+			// Statements with parameters that cannot be encoded are not
+			// cached. Spanner returns the error when the query is executed.
+			iter = &readOnlyRowIterator{c.execSingleQuery(ctx, c.client, stmt, c.readOnlyStaleness, options)}
+		} else if entry, ok := cache.get(key); ok {
+			iter = &cachedRowIterator{entry: entry}
+		} else {
+			iter = &cachingRowIterator{
+				rowIterator: &readOnlyRowIterator{c.execSingleQuery(ctx, c.client, stmt, c.readOnlyStaleness, options)},
+				cache:       cache,
+				key:         key,
+			}
+		}
+	} else if c.tx == nil {
+		iter = &readOnlyRowIterator{c.execSingleQuery(ctx, c.client, stmt, c.readOnlyStaleness, c.addReadOnlyQueryOptions(queryOptions))}
+	} else if c.inReadOnlyTransaction() {
+		if err := c.checkSingleUseReadOnlyTransaction(); err != nil {
+			done(err)
+			return nil, err
+		}
+		iter = c.tx.Query(ctx, stmt, c.addReadOnlyQueryOptions(queryOptions))
 	} else {
-		iter = c.tx.Query(ctx, stmt)
+		iter = c.tx.Query(ctx, stmt, queryOptions)
+	}
+	r := &rows{
+		it:                   iter,
+		done:                 done,
+		nullAsZeroValue:      execOptions.NullAsZeroValue,
+		decodeToNativeArrays: execOptions.DecodeToNativeArrays,
+		dateLocation:         execOptions.DateLocation,
+		numericAsString:      execOptions.DecodeNumericAsString,
+		numericAsFloat64:     execOptions.AllowNumericToFloat64,
+		jsonAsBytes:          execOptions.DecodeJSONAsBytes,
+		decoders:             c.columnDecoders(),
+		lastStatement:        last,
+	}
+	if analyze {
+		r.stats = func(stats *spannerpb.ResultSetStats) {
+			c.queryPlan = stats.QueryPlan
+			c.resultSetStats = stats
+		}
+	}
+	if _, ok := iter.(*cachedRowIterator); ok {
+		r.span = trace.SpanFromContext(ctx)
+		return r, nil
 	}
-	return &rows{it: iter}, nil
+	return c.newStreamingRows(ctx, r)
 }
 
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	// Execute client side statement if it is one.
-	stmt, err := parseClientSideStatement(c, query)
-	if err != nil {
+	if err := c.enter(); err != nil {
 		return nil, err
 	}
-	if stmt != nil {
-		return stmt.ExecContext(ctx, args)
+	defer c.leave()
+	execOptions := c.options()
+	if c.execManyRequest != nil {
+		req := *c.execManyRequest
+		c.execManyRequest = nil
+		c.commitTs = nil
+		c.queryPlan = nil
+		c.resultSetStats = nil
+		last := c.recordLastStatement(query, execOptions.StatementType)
+		ctx, done := c.startStatement(ctx, spanExec, query)
+		res, err := c.execMany(ctx, query, execOptions, req)
+		last.recordResult(res)
+		recordRowsAffected(ctx, res)
+		done(err)
+		return res, err
+	}
+	switch execOptions.StatementType {
+	case StatementTypeUnknown:
+		// Execute client side statement if it is one.
+		stmt, err := parseClientSideStatement(c, query)
+		if err != nil {
+			return nil, err
+		}
+		if stmt != nil {
+			last := c.recordLastStatement(query, clientSideStatementType(stmt.clientSideStatement))
+			res, err := stmt.ExecContext(ctx, args)
+			last.recordResult(res)
+			return res, err
+		}
+	case StatementTypeDML, StatementTypeDDL:
+	default:
+		return nil, unsupportedStatementTypeError(execOptions.StatementType, "ExecContext")
+	}
+	if execOptions.QueryOptions.DataBoostEnabled {
+		return nil, dataBoostNotSupportedError()
+	}
+	if execOptions.queryOptions().DirectedReadOptions != nil {
+		return nil, directedReadNotSupportedError()
 	}
 	// Clear the commit timestamp of this connection before we execute the statement.
 	c.commitTs = nil
+	c.queryPlan = nil
+	c.resultSetStats = nil
+
+	last := c.recordLastStatement(query, execOptions.StatementType)
+	ctx, done := c.startStatement(ctx, spanExec, query)
+	res, err := c.execContext(ctx, query, execOptions, args)
+	last.recordResult(res)
+	recordRowsAffected(ctx, res)
+	done(err)
+	return res, err
+}
 
+// unsupportedStatementTypeError returns the error that is returned if
+// ExecOptions.StatementType is set to a type that is not supported by the
+// given method.
+func unsupportedStatementTypeError(statementType StatementType, method string) error {
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "statement type %v is not supported by %s", statementType, method))
+}
+
+// dataBoostNotSupportedError returns the error that is returned if
+// ExecOptions.QueryOptions enables Data Boost for a statement that is not
+// executed as a partitioned query.
+func dataBoostNotSupportedError() error {
+	return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "Data Boost can only be used for partitioned queries, use PartitionQuery with PartitionQueryOptions.DataBoostEnabled instead"))
+}
+
+// directedReadNotSupportedError returns the error that is returned if
+// ExecOptions contains directed read options for a statement that is executed
+// in a read/write transaction.
+func directedReadNotSupportedError() error {
+	return spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "directed reads can only be used for queries in autocommit mode and in read-only transactions"))
+}
+
+func (c *conn) execContext(ctx context.Context, query string, execOptions ExecOptions, args []driver.NamedValue) (driver.Result, error) {
 	// Use admin API if DDL statement is provided.
-	isDDL, err := isDDL(query)
-	if err != nil {
-		return nil, err
+	var ddl bool
+	switch execOptions.StatementType {
+	case StatementTypeDDL:
+		ddl = true
+	case StatementTypeDML:
+		ddl = false
+	default:
+		var err error
+		if ddl, err = isDDL(query); err != nil {
+			return nil, err
+		}
 	}
-	if isDDL {
+	if ddl {
 		// Spanner does not support DDL in transactions, and although it is technically possible to execute DDL
 		// statements while a transaction is active, we return an error to avoid any confusion whether the DDL
 		// statement is executed as part of the active transaction or not.
@@ -907,11 +2959,25 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		return c.execDDL(ctx, spanner.NewStatement(query))
 	}
 
-	ss, err := prepareSpannerStmt(query, args)
+	ss, err := c.prepareSpannerStmt(ctx, query, args)
 	if err != nil {
 		return nil, err
 	}
-
+	if err := c.checkNotReadOnly(); err != nil {
+		return nil, err
+	}
+	if err := c.beginImplicitTransaction(); err != nil {
+		return nil, err
+	}
+	recordExecutedSQL(ctx, ss.SQL)
+	queryOptions := execOptions.queryOptions()
+	recordRequestTag(ctx, queryOptions.RequestTag)
+	switch execOptions.AnalyzeMode {
+	case AnalyzePlan:
+		return c.analyzeDML(ctx, ss, queryOptions)
+	case AnalyzeProfile:
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "AnalyzeProfile can only be used for queries"))
+	}
 	var rowsAffected int64
 	var commitTs time.Time
 	if c.tx == nil {
@@ -919,18 +2985,18 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 			c.batch.statements = append(c.batch.statements, ss)
 		} else {
 			if c.autocommitDMLMode == Transactional {
-				rowsAffected, commitTs, err = c.execSingleDMLTransactional(ctx, c.client, ss, c.createTransactionOptions())
+				rowsAffected, commitTs, err = c.execSingleDMLTransactional(ctx, c.client, ss, c.createTransactionOptions(), queryOptions)
 				if err == nil {
 					c.commitTs = &commitTs
 				}
 			} else if c.autocommitDMLMode == PartitionedNonAtomic {
-				rowsAffected, err = c.execSingleDMLPartitioned(ctx, c.client, ss, c.createPartitionedDmlQueryOptions())
+				rowsAffected, err = c.execSingleDMLPartitioned(ctx, c.client, ss, c.createPartitionedDmlQueryOptions(queryOptions))
 			} else {
 				return nil, status.Errorf(codes.FailedPrecondition, "connection in invalid state for DML statements: %s", c.autocommitDMLMode.String())
 			}
 		}
 	} else {
-		rowsAffected, err = c.tx.ExecContext(ctx, ss)
+		rowsAffected, err = c.tx.ExecContext(ctx, ss, queryOptions)
 	}
 	if err != nil {
 		return nil, err
@@ -945,12 +3011,273 @@ func (c *conn) Close() error {
 	}
 
 	// This was the last connection. Remove the connector and close the Spanner clients.
-	c.connector.driver.mu.Lock()
-	delete(c.connector.driver.connectors, c.connector.dsn)
-	c.connector.driver.mu.Unlock()
+	c.connector.stopKeepAlive()
+	if c.connector.cached {
+		c.connector.driver.mu.Lock()
+		delete(c.connector.driver.connectors, c.connector.dsn)
+		c.connector.driver.mu.Unlock()
+	}
+
+	return c.connector.closeClients()
+}
+
+// ReadOnlyTransactionOptions can be used to create a read-only transaction
+// on a Spanner connection with options that are not supported by
+// sql.TxOptions.
+type ReadOnlyTransactionOptions struct {
+	// TimestampBound is the timestamp bound of the transaction. The read-only
+	// staleness of the connection is used if this is not set.
+	TimestampBound *spanner.TimestampBound
+	// SingleUse indicates that the transaction will only execute one query.
+	// The query is executed using a single-use read-only transaction, which
+	// is cheaper than a multi-use read-only transaction, as the transaction
+	// does not need to be started with a separate RPC. Executing more than one
+	// query in a single-use transaction returns a FailedPrecondition error.
+	SingleUse bool
+}
+
+// BeginReadOnlyTransaction starts a read-only transaction on the given
+// connection with the given options.
+//
+// Example:
+//
+//	conn, err := db.Conn(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	tx, err := spannerdriver.BeginReadOnlyTransaction(ctx, conn,
+//		spannerdriver.ReadOnlyTransactionOptions{SingleUse: true})
+func BeginReadOnlyTransaction(ctx context.Context, sqlConn *sql.Conn, options ReadOnlyTransactionOptions) (*sql.Tx, error) {
+	if err := sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unexpected driver connection %v, expected a Spanner connection", driverConn))
+		}
+		c.readOnlyTxOptions = &options
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	tx, err := sqlConn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		// Clear the options if the transaction could not be started.
+		_ = sqlConn.Raw(func(driverConn interface{}) error {
+			driverConn.(*conn).readOnlyTxOptions = nil
+			return nil
+		})
+		return nil, err
+	}
+	return tx, nil
+}
+
+type readOnlyTransactionOptionsKey struct{}
+
+// WithReadOnlyTransactionOptions returns a context that applies the given
+// options to a read-only transaction that is started with the context. This
+// can be used to start a read-only transaction with a specific timestamp bound
+// with db.BeginTx, without reserving a connection first. The options are
+// ignored for read/write transactions. Options that are set with
+// BeginReadOnlyTransaction take precedence over options in the context.
+//
+// Spanner only supports bounded staleness, such as spanner.MaxStaleness and
+// spanner.MinReadTimestamp, for single-use transactions. Set SingleUse in the
+// options to use bounded staleness. The read timestamp of the transaction can
+// be retrieved with SpannerConn.ReadTimestamp after the transaction has read
+// data.
+//
+// Example:
+//
+//	staleness := spanner.ExactStaleness(10 * time.Second)
+//	ctx = spannerdriver.WithReadOnlyTransactionOptions(ctx,
+//		spannerdriver.ReadOnlyTransactionOptions{TimestampBound: &staleness})
+//	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+func WithReadOnlyTransactionOptions(ctx context.Context, options ReadOnlyTransactionOptions) context.Context {
+	return context.WithValue(ctx, readOnlyTransactionOptionsKey{}, options)
+}
+
+type readWriteTransactionOptionsKey struct{}
+
+// WithReadWriteTransactionOptions returns a context that applies the given
+// options to a read/write transaction that is started with the context. This
+// can be used to start a read/write transaction with for example a
+// transaction tag with db.BeginTx. The options are ignored for read-only
+// transactions and for transactions that are executed by RunTransaction.
+// MaxAttempts is only used by RunTransaction and is ignored by BeginTx.
+//
+// Example:
+//
+//	ctx = spannerdriver.WithReadWriteTransactionOptions(ctx,
+//		spannerdriver.ReadWriteTransactionOptions{TransactionTag: "transfer"})
+//	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+func WithReadWriteTransactionOptions(ctx context.Context, options ReadWriteTransactionOptions) context.Context {
+	return context.WithValue(ctx, readWriteTransactionOptionsKey{}, options)
+}
+
+// ReadWriteTransactionOptions contains the options for a read/write
+// transaction that is executed by RunTransaction, or that is started with a
+// context from WithReadWriteTransactionOptions. The options are applied to
+// all statements and to the commit of the transaction.
+type ReadWriteTransactionOptions struct {
+	// ReadLockMode is the lock mode that is used for reads and queries in the
+	// transaction, for example optimistic or pessimistic. The default lock
+	// mode of Spanner is used if this is not set.
+	ReadLockMode spannerpb.TransactionOptions_ReadWrite_ReadLockMode
+	// Priority is the RPC priority that is used for all statements in the
+	// transaction and for the commit. ExecOptions that are passed in to a
+	// statement override this priority for that statement.
+	Priority spannerpb.RequestOptions_Priority
+	// TransactionTag is the transaction tag that is added to all statements
+	// in the transaction and to the commit.
+	TransactionTag string
+	// MaxAttempts is the maximum number of times that the transaction is
+	// attempted if it is aborted by Spanner. The number of attempts is only
+	// limited by the context if this is zero.
+	MaxAttempts int
+	// RetryBackoff is the backoff that is used between attempts of a
+	// transaction that is executed by RunTransaction, if Spanner does not
+	// return a retry delay with the Aborted error. spanner.DefaultRetryBackoff
+	// is used if this is nil.
+	RetryBackoff *gax.Backoff
+	// MaxCommitDelay is the maximum amount of time that Spanner may delay the
+	// commit of the transaction to batch it with other commits. A commit
+	// delay can increase the write throughput at the cost of a higher commit
+	// latency. The value must be between 0 and 500ms. The max commit delay of
+	// the connection is used if this is nil.
+	MaxCommitDelay *time.Duration
+	// ExcludeTxnFromChangeStreams indicates that the changes of the
+	// transaction should not be recorded by change streams that have the
+	// allow_txn_exclusion option set. The transaction is also excluded if
+	// SET EXCLUDE_TXN_FROM_CHANGE_STREAMS = TRUE was executed on the
+	// connection before the transaction was started.
+	ExcludeTxnFromChangeStreams bool
+}
+
+// RetryBudgetExceededError is returned by RunTransaction if the transaction
+// was aborted by Spanner on every attempt until the maximum number of attempts
+// was reached or the context was done. It is also returned by a statement or
+// commit in a read/write transaction that is retried internally, if the
+// transaction is still aborted after MAX_COMMIT_RETRIES retries. Repeated
+// aborts often indicate that multiple transactions are competing for the same
+// rows.
+type RetryBudgetExceededError struct {
+	// Attempts is the number of times that the transaction was attempted.
+	Attempts int
+	// Err is the error that was returned by the last attempt. This is an
+	// Aborted error if the maximum number of attempts was reached, and can be
+	// the error of the context if the context was done during the last
+	// attempt.
+	Err error
+}
+
+func (e *RetryBudgetExceededError) Error() string {
+	return fmt.Sprintf("transaction was aborted %d times: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryBudgetExceededError) Unwrap() error {
+	return e.Err
+}
+
+// RunTransaction runs the given function in a read/write transaction with the
+// given options, and commits the transaction if the function returns nil. The
+// transaction is rolled back if the function returns an error. The entire
+// function is retried if the transaction is aborted by Spanner, and the
+// function should therefore not have any side effects other than the
+// statements that it executes on the transaction. The transaction is not
+// retried internally by the connection while the function is running. Each
+// retry waits for the retry delay that is returned by Spanner, or for the
+// next pause of ReadWriteTransactionOptions.RetryBackoff if Spanner did not
+// return a retry delay.
+//
+// RunTransaction returns a *RetryBudgetExceededError if the transaction is
+// still aborted when ReadWriteTransactionOptions.MaxAttempts attempts have
+// been made, or when the context is done before the next attempt.
+//
+// Example:
+//
+//	err := spannerdriver.RunTransaction(ctx, db,
+//		spannerdriver.ReadWriteTransactionOptions{
+//			Priority:       spannerpb.RequestOptions_PRIORITY_LOW,
+//			TransactionTag: "transfer",
+//		},
+//		func(ctx context.Context, tx *sql.Tx) error {
+//			_, err := tx.ExecContext(ctx, "UPDATE Accounts SET Balance=Balance-10 WHERE Id=1")
+//			return err
+//		})
+func RunTransaction(ctx context.Context, db *sql.DB, options ReadWriteTransactionOptions, f func(ctx context.Context, tx *sql.Tx) error) error {
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sqlConn.Close()
+
+	var retryAborts bool
+	if err := sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unexpected driver connection %v, expected a Spanner connection", driverConn))
+		}
+		retryAborts = c.retryAborts
+		c.retryAborts = false
+		return nil
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		_ = sqlConn.Raw(func(driverConn interface{}) error {
+			c := driverConn.(*conn)
+			c.retryAborts = retryAborts
+			c.readWriteTxOptions = nil
+			return nil
+		})
+	}()
+
+	backoff := spanner.DefaultRetryBackoff
+	if options.RetryBackoff != nil {
+		backoff = *options.RetryBackoff
+	}
+	aborted := false
+	for attempts := 1; ; attempts++ {
+		err := runTransactionAttempt(ctx, sqlConn, options, f)
+		if spanner.ErrCode(err) != codes.Aborted {
+			if err != nil && aborted && ctx.Err() != nil {
+				// The context was done while the transaction was retried.
+				return &RetryBudgetExceededError{Attempts: attempts, Err: err}
+			}
+			return err
+		}
+		aborted = true
+		if options.MaxAttempts > 0 && attempts >= options.MaxAttempts {
+			return &RetryBudgetExceededError{Attempts: attempts, Err: err}
+		}
+		delay, ok := spanner.ExtractRetryDelay(err)
+		if !ok {
+			delay = backoff.Pause()
+		}
+		select {
+		case <-ctx.Done():
+			return &RetryBudgetExceededError{Attempts: attempts, Err: err}
+		case <-time.After(delay):
+		}
+	}
+}
 
-	c.client.Close()
-	return c.adminClient.Close()
+// runTransactionAttempt executes one attempt of a transaction that is run by
+// RunTransaction.
+func runTransactionAttempt(ctx context.Context, sqlConn *sql.Conn, options ReadWriteTransactionOptions, f func(ctx context.Context, tx *sql.Tx) error) error {
+	_ = sqlConn.Raw(func(driverConn interface{}) error {
+		driverConn.(*conn).readWriteTxOptions = &options
+		return nil
+	})
+	tx, err := sqlConn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	if err := f(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 func (c *conn) Begin() (driver.Tx, error) {
@@ -958,49 +3285,188 @@ func (c *conn) Begin() (driver.Tx, error) {
 }
 
 func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.enter(); err != nil {
+		return nil, err
+	}
+	defer c.leave()
+	return c.beginTx(ctx, opts)
+}
+
+func (c *conn) beginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	if c.inTransaction() {
 		return nil, spanner.ToSpannerError(status.Errorf(codes.FailedPrecondition, "already in a transaction"))
 	}
 	if c.inBatch() {
 		return nil, status.Error(codes.FailedPrecondition, "This connection has an active batch. Run or abort the batch before starting a new transaction.")
 	}
+	if err := validateIsolationLevel(sql.IsolationLevel(opts.Isolation), opts.ReadOnly || c.readOnly); err != nil {
+		return nil, err
+	}
 
-	if opts.ReadOnly {
-		ro := c.client.ReadOnlyTransaction().WithTimestampBound(c.readOnlyStaleness)
+	if opts.ReadOnly || c.readOnly {
+		c.readWriteTxOptions = nil
+		roOptions := ReadOnlyTransactionOptions{}
+		if c.readOnlyTxOptions != nil {
+			roOptions = *c.readOnlyTxOptions
+			c.readOnlyTxOptions = nil
+		} else if ctxOptions, ok := ctx.Value(readOnlyTransactionOptionsKey{}).(ReadOnlyTransactionOptions); ok {
+			roOptions = ctxOptions
+		}
+		tb := c.readOnlyStaleness
+		if roOptions.TimestampBound != nil {
+			tb = *roOptions.TimestampBound
+		}
+		var ro *spanner.ReadOnlyTransaction
+		if roOptions.SingleUse {
+			ro = c.client.Single().WithTimestampBound(tb)
+		} else {
+			ro = c.client.ReadOnlyTransaction().WithTimestampBound(tb)
+		}
+		c.roTx = ro
+		// A read-only transaction does not have a commit timestamp.
+		c.commitTs = nil
 		c.tx = &readOnlyTransaction{
-			roTx: ro,
+			roTx:      ro,
+			singleUse: roOptions.SingleUse,
 			close: func() {
 				c.tx = nil
 			},
 		}
 		return c.tx, nil
 	}
+	c.readOnlyTxOptions = nil
 
 	options := c.createTransactionOptions()
+	var rwOptions ReadWriteTransactionOptions
+	if c.readWriteTxOptions != nil {
+		rwOptions = *c.readWriteTxOptions
+		c.readWriteTxOptions = nil
+	} else if ctxOptions, ok := ctx.Value(readWriteTransactionOptionsKey{}).(ReadWriteTransactionOptions); ok {
+		rwOptions = ctxOptions
+	}
+	options.ReadLockMode = rwOptions.ReadLockMode
+	options.TransactionTag = rwOptions.TransactionTag
+	options.ExcludeTxnFromChangeStreams = options.ExcludeTxnFromChangeStreams || rwOptions.ExcludeTxnFromChangeStreams
+	if rwOptions.Priority == spannerpb.RequestOptions_PRIORITY_UNSPECIFIED {
+		rwOptions.Priority = c.rpcPriority
+	}
+	options.CommitPriority = rwOptions.Priority
+	if rwOptions.MaxCommitDelay != nil {
+		if err := validateMaxCommitDelay(rwOptions.MaxCommitDelay); err != nil {
+			return nil, err
+		}
+		options.CommitOptions.MaxCommitDelay = rwOptions.MaxCommitDelay
+	}
 	tx, err := spanner.NewReadWriteStmtBasedTransactionWithOptions(ctx, c.client, options)
 	if err != nil {
 		return nil, err
 	}
 	c.tx = &readWriteTransaction{
-		ctx:    ctx,
-		client: c.client,
-		rwTx:   tx,
+		ctx:     ctx,
+		client:  c.client,
+		rwTx:    tx,
+		tracer:  c.tracer(),
+		metrics: c.metrics(),
 		close: func(commitTs *time.Time, commitErr error) {
 			c.tx = nil
 			if commitErr == nil {
 				c.commitTs = commitTs
 			}
 		},
-		retryAborts: c.retryAborts,
+		retryAborts:  c.retryAborts,
+		maxRetries:   c.maxCommitRetries,
+		retryBackoff: c.abortedRetryBackoff(),
+		priority:     rwOptions.Priority,
+		options:      options,
 	}
 	c.commitTs = nil
 	return c.tx, nil
 }
 
+// validateIsolationLevel returns an error if the given isolation level is not
+// supported for a transaction. The isolation levels map to Spanner as follows:
+//   - LevelDefault, LevelSerializable and LevelLinearizable: Read/write
+//     transactions use serializable isolation with external consistency,
+//     which is the only isolation level that is supported by the Spanner
+//     client. Read-only transactions read from a consistent snapshot.
+//   - LevelSnapshot and LevelRepeatableRead: Supported for read-only
+//     transactions, which read all data at a single timestamp. Mapping
+//     LevelRepeatableRead to the REPEATABLE_READ isolation level of Spanner
+//     for read/write transactions is not implemented, as the version of the
+//     Spanner client that is used by the driver has no isolation level
+//     option. Read/write transactions return an Unimplemented error instead.
+//   - LevelReadUncommitted, LevelReadCommitted and LevelWriteCommitted:
+//     Not supported by Spanner, and return an InvalidArgument error instead
+//     of silently using a stronger isolation level.
+func validateIsolationLevel(level sql.IsolationLevel, readOnly bool) error {
+	switch level {
+	case sql.LevelDefault, sql.LevelSerializable, sql.LevelLinearizable:
+		return nil
+	case sql.LevelSnapshot, sql.LevelRepeatableRead:
+		if readOnly {
+			return nil
+		}
+		return spanner.ToSpannerError(status.Errorf(codes.Unimplemented, "isolation level %v is not supported for read/write transactions: the Spanner client that is used by the driver does not support isolation levels", level))
+	}
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "isolation level %v is not supported", level))
+}
+
 func (c *conn) inTransaction() bool {
 	return c.tx != nil
 }
 
+// inImplicitTransaction returns true if the connection is in a transaction
+// that was started automatically because autocommit is disabled.
+func (c *conn) inImplicitTransaction() bool {
+	return c.tx != nil && c.tx == c.implicitTx
+}
+
+// beginImplicitTransaction starts a read/write transaction if autocommit is
+// disabled and the connection does not have an active transaction or batch.
+// The transaction is committed or rolled back with a COMMIT or ROLLBACK
+// statement.
+func (c *conn) beginImplicitTransaction() error {
+	if !c.autocommitDisabled || c.inTransaction() || c.inBatch() {
+		return nil
+	}
+	// The transaction outlives the statement that starts it, so it must not
+	// use the context of that statement.
+	tx, err := c.beginTx(context.Background(), driver.TxOptions{})
+	if err != nil {
+		return err
+	}
+	c.implicitTx = tx.(contextTransaction)
+	return nil
+}
+
+func (c *conn) commit() (driver.Result, error) {
+	if !c.inTransaction() {
+		return driver.ResultNoRows, nil
+	}
+	if !c.inImplicitTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "use Tx.Commit to commit a transaction that was started with BeginTx"))
+	}
+	c.implicitTx = nil
+	if err := c.tx.Commit(); err != nil {
+		return nil, err
+	}
+	return driver.ResultNoRows, nil
+}
+
+func (c *conn) rollback() (driver.Result, error) {
+	if !c.inTransaction() {
+		return driver.ResultNoRows, nil
+	}
+	if !c.inImplicitTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "use Tx.Rollback to roll back a transaction that was started with BeginTx"))
+	}
+	c.implicitTx = nil
+	if err := c.tx.Rollback(); err != nil {
+		return nil, err
+	}
+	return driver.ResultNoRows, nil
+}
+
 func (c *conn) inReadOnlyTransaction() bool {
 	if c.tx != nil {
 		_, ok := c.tx.(*readOnlyTransaction)
@@ -1009,6 +3475,15 @@ func (c *conn) inReadOnlyTransaction() bool {
 	return false
 }
 
+// checkSingleUseReadOnlyTransaction returns an error if the connection is in a
+// single-use read-only transaction that has already executed a query.
+func (c *conn) checkSingleUseReadOnlyTransaction() error {
+	if tx, ok := c.tx.(*readOnlyTransaction); ok && tx.singleUse && tx.queried {
+		return spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "a single-use read-only transaction can only execute one query"))
+	}
+	return nil
+}
+
 func (c *conn) inReadWriteTransaction() bool {
 	if c.tx != nil {
 		_, ok := c.tx.(*readWriteTransaction)
@@ -1017,14 +3492,14 @@ func (c *conn) inReadWriteTransaction() bool {
 	return false
 }
 
-func queryInSingleUse(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound) *spanner.RowIterator {
-	return c.Single().WithTimestampBound(tb).Query(ctx, statement)
+func queryInSingleUse(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound, options spanner.QueryOptions) *spanner.RowIterator {
+	return c.Single().WithTimestampBound(tb).QueryWithOptions(ctx, statement, options)
 }
 
-func execInNewRWTransaction(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions) (int64, time.Time, error) {
+func execInNewRWTransaction(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions, queryOptions spanner.QueryOptions) (int64, time.Time, error) {
 	var rowsAffected int64
 	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
-		count, err := tx.Update(ctx, statement)
+		count, err := tx.UpdateWithOptions(ctx, statement, queryOptions)
 		rowsAffected = count
 		return err
 	}
@@ -1035,16 +3510,127 @@ func execInNewRWTransaction(ctx context.Context, c *spanner.Client, statement sp
 	return rowsAffected, resp.CommitTs, nil
 }
 
+// queryInNewRWTransaction executes a DML statement that returns rows in a new
+// read/write transaction. All rows are read before the transaction is
+// committed, so the transaction can be retried if it is aborted.
+func (c *conn) queryInNewRWTransaction(ctx context.Context, statement spanner.Statement, queryOptions spanner.QueryOptions) (rowIterator, error) {
+	entry := &queryCacheEntry{}
+	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		it := tx.QueryWithOptions(ctx, statement, queryOptions)
+		defer it.Stop()
+		entry.rows = nil
+		for {
+			row, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			entry.rows = append(entry.rows, row)
+		}
+		entry.metadata = it.Metadata
+		return nil
+	}
+	resp, err := c.client.ReadWriteTransactionWithOptions(ctx, fn, c.createTransactionOptions())
+	if err != nil {
+		return nil, err
+	}
+	c.commitTs = &resp.CommitTs
+	return &cachedRowIterator{entry: entry}, nil
+}
+
 func execAsPartitionedDML(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.QueryOptions) (int64, error) {
-	return c.PartitionedUpdateWithOptions(ctx, statement, options)
+	count, err := c.PartitionedUpdateWithOptions(ctx, statement, options)
+	if err != nil && ctx.Err() != nil {
+		// Partitioned DML is not atomic. Partitions that have already been
+		// applied when the statement is cancelled are not reverted.
+		code := codes.Canceled
+		if ctx.Err() == context.DeadlineExceeded {
+			code = codes.DeadlineExceeded
+		}
+		return 0, spanner.ToSpannerError(status.Errorf(code, "partitioned DML statement was stopped before it finished, changes that have already been applied are not reverted: %v", ctx.Err()))
+	}
+	return count, err
 }
 
 func (c *conn) createTransactionOptions() spanner.TransactionOptions {
 	defer func() { c.excludeTxnFromChangeStreams = false }()
-	return spanner.TransactionOptions{ExcludeTxnFromChangeStreams: c.excludeTxnFromChangeStreams}
+	return spanner.TransactionOptions{
+		ExcludeTxnFromChangeStreams: c.excludeTxnFromChangeStreams,
+		CommitPriority:              c.rpcPriority,
+		CommitOptions:               spanner.CommitOptions{MaxCommitDelay: c.maxCommitDelay},
+	}
+}
+
+// analyzeDML returns the query plan of the given DML statement without
+// executing it. A statement that is analyzed outside a transaction is analyzed
+// in a read/write transaction that is rolled back afterwards.
+func (c *conn) analyzeDML(ctx context.Context, ss spanner.Statement, options spanner.QueryOptions) (driver.Result, error) {
+	if c.InDMLBatch() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "cannot analyze a statement in a DML batch"))
+	}
+	if c.inReadOnlyTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "read-only transactions cannot write"))
+	}
+	mode := spannerpb.ExecuteSqlRequest_PLAN
+	options.Mode = &mode
+	var plan *spannerpb.QueryPlan
+	if c.tx == nil {
+		tx, err := spanner.NewReadWriteStmtBasedTransaction(ctx, c.client)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback(ctx)
+		it := tx.QueryWithOptions(ctx, ss, options)
+		defer it.Stop()
+		if err := drainRowIterator(it); err != nil {
+			return nil, err
+		}
+		plan = it.QueryPlan
+	} else {
+		it := c.tx.Query(ctx, ss, options)
+		defer it.Stop()
+		if err := drainRowIterator(it); err != nil {
+			return nil, err
+		}
+		if stats := resultSetStats(it); stats != nil {
+			plan = stats.QueryPlan
+		}
+	}
+	if plan == nil {
+		return nil, spanner.ToSpannerError(status.Error(codes.Internal, "query plan unavailable"))
+	}
+	c.queryPlan = plan
+	return &result{}, nil
+}
+
+// drainRowIterator consumes all rows of the given iterator.
+func drainRowIterator(it interface {
+	Next() (*spanner.Row, error)
+}) error {
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// addReadOnlyQueryOptions adds the connection defaults for queries in
+// autocommit mode and in read-only transactions to the given query options.
+func (c *conn) addReadOnlyQueryOptions(options spanner.QueryOptions) spanner.QueryOptions {
+	if options.DirectedReadOptions == nil {
+		options.DirectedReadOptions = c.directedReadOptions
+	}
+	return options
 }
 
-func (c *conn) createPartitionedDmlQueryOptions() spanner.QueryOptions {
+func (c *conn) createPartitionedDmlQueryOptions(options spanner.QueryOptions) spanner.QueryOptions {
 	defer func() { c.excludeTxnFromChangeStreams = false }()
-	return spanner.QueryOptions{ExcludeTxnFromChangeStreams: c.excludeTxnFromChangeStreams}
+	options.ExcludeTxnFromChangeStreams = c.excludeTxnFromChangeStreams
+	return options
 }
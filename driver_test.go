@@ -25,6 +25,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestExtractDnsParts(t *testing.T) {
@@ -192,6 +193,51 @@ func TestExtractDnsParts(t *testing.T) {
 				DatabaseRole:         "child",
 			},
 		},
+		{
+			input: "projects/p/instances/i/databases/d?databaseRole=role%3Bwith%3Dspecial%20chars&credentials=/path/my%20credentials.json",
+			wantConnectorConfig: connectorConfig{
+				project:  "p",
+				instance: "i",
+				database: "d",
+				params: map[string]string{
+					"databaserole": "role;with=special chars",
+					"credentials":  "/path/my credentials.json",
+				},
+			},
+			wantSpannerConfig: spanner.ClientConfig{
+				SessionPoolConfig: spanner.DefaultSessionPoolConfig,
+				UserAgent:         userAgent,
+				DatabaseRole:      "role;with=special chars",
+			},
+		},
+		{
+			// '&' is not a separator if the parameters start with ';'.
+			input: "projects/p/instances/i/databases/d;databaseRole=a%26b&c;credentials=/path/with spaces+plus.json",
+			wantConnectorConfig: connectorConfig{
+				project:  "p",
+				instance: "i",
+				database: "d",
+				params: map[string]string{
+					"databaserole": "a&b&c",
+					"credentials":  "/path/with spaces+plus.json",
+				},
+			},
+			wantSpannerConfig: spanner.ClientConfig{
+				SessionPoolConfig: spanner.DefaultSessionPoolConfig,
+				UserAgent:         userAgent,
+				DatabaseRole:      "a&b&c",
+			},
+		},
+		{
+			// invalid percent-encoding
+			input:   "projects/p/instances/i/databases/d;databaseRole=100%",
+			wantErr: true,
+		},
+		{
+			// missing value
+			input:   "projects/p/instances/i/databases/d?databaseRole",
+			wantErr: true,
+		},
 		{
 			// intential error case
 			input:   "project/p/instances/i/databases/d",
@@ -226,6 +272,73 @@ func TestExtractDnsParts(t *testing.T) {
 
 }
 
+func TestInvalidConnectionProperties(t *testing.T) {
+	for _, test := range []struct {
+		params  string
+		wantErr string
+	}{
+		{params: "minSessions=abc", wantErr: `invalid value for minSessions: expected non-negative integer, got "abc"`},
+		{params: "maxSessions=-1", wantErr: `invalid value for maxSessions: expected non-negative integer, got "-1"`},
+		{params: "numChannels=0", wantErr: `invalid value for numChannels: expected positive integer, got "0"`},
+		{params: "usePlainText=yes", wantErr: `invalid value for usePlainText: expected boolean, got "yes"`},
+		{params: "retryAbortsInternally=nope", wantErr: `invalid value for retryAbortsInternally: expected boolean, got "nope"`},
+		{params: "disableRouteToLeader=2", wantErr: `invalid value for disableRouteToLeader: expected boolean, got "2"`},
+		{params: "prewarmSessions=soon", wantErr: `invalid value for prewarmSessions: expected boolean, got "soon"`},
+		{params: "waitForMinSessions=-1s", wantErr: `invalid value for waitForMinSessions: expected non-negative duration, got "-1s"`},
+		{params: "autoConfigEmulator=maybe", wantErr: `invalid value for autoConfigEmulator: expected boolean, got "maybe"`},
+		{params: "maxCommitRetries=-1", wantErr: `invalid value for maxCommitRetries: expected non-negative integer, got "-1"`},
+		{params: "rpcPriority=urgent", wantErr: `invalid value for rpcPriority: expected one of HIGH, MEDIUM or LOW, got "urgent"`},
+		{params: "dialect=mysql", wantErr: `invalid value for dialect: expected one of GoogleSQL or PostgreSQL, got "mysql"`},
+	} {
+		_, err := newConnector(&Driver{connectors: make(map[string]*connector)}, "projects/p/instances/i/databases/d?"+test.params)
+		if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+			t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.params, g, w)
+			continue
+		}
+		if g, w := status.Convert(err).Message(), test.wantErr; g != w {
+			t.Errorf("%s: error message mismatch\n Got: %v\nWant: %v", test.params, g, w)
+		}
+	}
+}
+
+func TestNewConnector(t *testing.T) {
+	poolConfig := spanner.DefaultSessionPoolConfig
+	poolConfig.MinOpened = 10
+	c, err := NewConnector(ConnectorConfig{
+		Project:           "p",
+		Instance:          "i",
+		Database:          "d",
+		CredentialsFile:   "/path/with spaces;and&special%chars.json",
+		SessionPoolConfig: &poolConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The connector is the same as the connector for the equivalent
+	// connection string.
+	want, err := newConnector(&Driver{connectors: make(map[string]*connector)}, "projects/p/instances/i/databases/d?credentials=%2Fpath%2Fwith%20spaces%3Band%26special%25chars.json&minSessions=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.(*connector)
+	if g, w := got.config.CredentialsFile, want.config.CredentialsFile; g != w {
+		t.Errorf("credentials file mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(got.options), len(want.options); g != w {
+		t.Errorf("options count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := got.connectorConfig.project+"/"+got.connectorConfig.instance+"/"+got.connectorConfig.database, "p/i/d"; g != w {
+		t.Errorf("database mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !cmp.Equal(got.spannerClientConfig, want.spannerClientConfig, cmpopts.IgnoreUnexported(spanner.ClientConfig{}, spanner.SessionPoolConfig{}, spanner.InactiveTransactionRemovalOptions{}, spannerpb.ExecuteSqlRequest_QueryOptions{})) {
+		t.Errorf("Spanner client config mismatch\n Got: %v\nWant: %v", got.spannerClientConfig, want.spannerClientConfig)
+	}
+
+	if _, err := NewConnector(ConnectorConfig{Project: "p", Instance: "i"}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
 func TestConnection_Reset(t *testing.T) {
 	txClosed := false
 	c := conn{
@@ -256,6 +369,106 @@ func TestConnection_Reset(t *testing.T) {
 	}
 }
 
+func TestValidateDirectedReadOptions(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		options *spannerpb.DirectedReadOptions
+		wantErr bool
+	}{
+		{"Nil", nil, false},
+		{"No replicas", &spannerpb.DirectedReadOptions{}, true},
+		{
+			"Include one location",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_IncludeReplicas_{
+				IncludeReplicas: &spannerpb.DirectedReadOptions_IncludeReplicas{
+					ReplicaSelections: []*spannerpb.DirectedReadOptions_ReplicaSelection{
+						{Location: "us-east1", Type: spannerpb.DirectedReadOptions_ReplicaSelection_READ_ONLY},
+					},
+					AutoFailoverDisabled: true,
+				},
+			}},
+			false,
+		},
+		{
+			"Include no selections",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_IncludeReplicas_{
+				IncludeReplicas: &spannerpb.DirectedReadOptions_IncludeReplicas{},
+			}},
+			true,
+		},
+		{
+			"Exclude one location",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &spannerpb.DirectedReadOptions_ExcludeReplicas{
+					ReplicaSelections: []*spannerpb.DirectedReadOptions_ReplicaSelection{
+						{Location: "us-east1"},
+					},
+				},
+			}},
+			false,
+		},
+		{
+			"Exclude all read-only replicas",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &spannerpb.DirectedReadOptions_ExcludeReplicas{
+					ReplicaSelections: []*spannerpb.DirectedReadOptions_ReplicaSelection{
+						{Type: spannerpb.DirectedReadOptions_ReplicaSelection_READ_ONLY},
+					},
+				},
+			}},
+			false,
+		},
+		{
+			"Exclude no selections",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &spannerpb.DirectedReadOptions_ExcludeReplicas{},
+			}},
+			true,
+		},
+		{
+			"Exclude all replicas",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &spannerpb.DirectedReadOptions_ExcludeReplicas{
+					ReplicaSelections: []*spannerpb.DirectedReadOptions_ReplicaSelection{
+						{},
+					},
+				},
+			}},
+			true,
+		},
+		{
+			"Exclude all replica types",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &spannerpb.DirectedReadOptions_ExcludeReplicas{
+					ReplicaSelections: []*spannerpb.DirectedReadOptions_ReplicaSelection{
+						{Type: spannerpb.DirectedReadOptions_ReplicaSelection_READ_ONLY},
+						{Type: spannerpb.DirectedReadOptions_ReplicaSelection_READ_WRITE},
+					},
+				},
+			}},
+			true,
+		},
+		{
+			"Too many selections",
+			&spannerpb.DirectedReadOptions{Replicas: &spannerpb.DirectedReadOptions_IncludeReplicas_{
+				IncludeReplicas: &spannerpb.DirectedReadOptions_IncludeReplicas{
+					ReplicaSelections: make([]*spannerpb.DirectedReadOptions_ReplicaSelection, maxReplicaSelections+1),
+				},
+			}},
+			true,
+		},
+	} {
+		err := validateDirectedReadOptions(test.options)
+		if test.wantErr {
+			if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+				t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, g, w)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
 func TestConnection_NoNestedTransactions(t *testing.T) {
 	c := conn{
 		tx: &readOnlyTransaction{},
@@ -356,10 +569,10 @@ func TestConn_StartBatchDml(t *testing.T) {
 func TestConn_NonDdlStatementsInDdlBatch(t *testing.T) {
 	c := &conn{
 		batch: &batch{tp: ddl},
-		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound) *spanner.RowIterator {
+		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound, options spanner.QueryOptions) *spanner.RowIterator {
 			return &spanner.RowIterator{}
 		},
-		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions) (int64, time.Time, error) {
+		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions, queryOptions spanner.QueryOptions) (int64, time.Time, error) {
 			return 0, time.Time{}, nil
 		},
 		execSingleDMLPartitioned: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.QueryOptions) (int64, error) {
@@ -389,10 +602,10 @@ func TestConn_NonDdlStatementsInDdlBatch(t *testing.T) {
 func TestConn_NonDmlStatementsInDmlBatch(t *testing.T) {
 	c := &conn{
 		batch: &batch{tp: dml},
-		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound) *spanner.RowIterator {
+		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound, options spanner.QueryOptions) *spanner.RowIterator {
 			return &spanner.RowIterator{}
 		},
-		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions) (int64, time.Time, error) {
+		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions, queryOptions spanner.QueryOptions) (int64, time.Time, error) {
 			return 0, time.Time{}, nil
 		},
 		execSingleDMLPartitioned: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.QueryOptions) (int64, error) {
@@ -423,10 +636,10 @@ func TestConn_NonDmlStatementsInDmlBatch(t *testing.T) {
 func TestConn_GetCommitTimestampAfterAutocommitDml(t *testing.T) {
 	want := time.Now()
 	c := &conn{
-		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound) *spanner.RowIterator {
+		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound, options spanner.QueryOptions) *spanner.RowIterator {
 			return &spanner.RowIterator{}
 		},
-		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions) (int64, time.Time, error) {
+		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions, queryOptions spanner.QueryOptions) (int64, time.Time, error) {
 			return 0, want, nil
 		},
 		execSingleDMLPartitioned: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.QueryOptions) (int64, error) {
@@ -448,10 +661,10 @@ func TestConn_GetCommitTimestampAfterAutocommitDml(t *testing.T) {
 
 func TestConn_GetCommitTimestampAfterAutocommitQuery(t *testing.T) {
 	c := &conn{
-		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound) *spanner.RowIterator {
+		execSingleQuery: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, tb spanner.TimestampBound, options spanner.QueryOptions) *spanner.RowIterator {
 			return &spanner.RowIterator{}
 		},
-		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions) (int64, time.Time, error) {
+		execSingleDMLTransactional: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.TransactionOptions, queryOptions spanner.QueryOptions) (int64, time.Time, error) {
 			return 0, time.Time{}, nil
 		},
 		execSingleDMLPartitioned: func(ctx context.Context, c *spanner.Client, statement spanner.Statement, options spanner.QueryOptions) (int64, error) {
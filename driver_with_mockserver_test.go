@@ -20,11 +20,13 @@ import (
 	"database/sql/driver"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -35,6 +37,7 @@ import (
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/googleapis/go-sql-spanner/testutil"
 	"google.golang.org/api/option"
 	"google.golang.org/protobuf/proto"
@@ -61,10 +64,17 @@ func TestPingContext_Fails(t *testing.T) {
 
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-	s := gstatus.Newf(codes.PermissionDenied, "Permission denied for database")
-	_ = server.TestSpanner.PutStatementResult("SELECT 1", &testutil.StatementResult{Err: s.Err()})
-	if g, w := db.PingContext(context.Background()), driver.ErrBadConn; g != w {
-		t.Fatalf("ping error mismatch\nGot: %v\nWant: %v", g, w)
+	for _, code := range []codes.Code{codes.PermissionDenied, codes.NotFound, codes.InvalidArgument} {
+		s := gstatus.Newf(code, "Ping failed")
+		_ = server.TestSpanner.PutStatementResult("SELECT 1", &testutil.StatementResult{Err: s.Err()})
+		// The error is returned with the same code as the error of Spanner.
+		err := db.PingContext(context.Background())
+		if g, w := spanner.ErrCode(err), code; g != w {
+			t.Fatalf("ping error code mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		if errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("ping error should not be ErrBadConn: %v", err)
+		}
 	}
 }
 
@@ -304,2030 +314,4820 @@ func TestReadOnlyTransactionWithStaleness(t *testing.T) {
 	}
 }
 
-func TestSimpleReadWriteTransaction(t *testing.T) {
+func TestReadOnlyTransactionOptionsInContext(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-	tx, err := db.Begin()
+
+	conn, err := db.Conn(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
-	rows, err := tx.Query(testutil.SelectFooFromBar)
+	defer conn.Close()
+	readTimestamp := func() (time.Time, error) {
+		var ts time.Time
+		err := conn.Raw(func(driverConn interface{}) (err error) {
+			ts, err = driverConn.(SpannerConn).ReadTimestamp()
+			return err
+		})
+		return ts, err
+	}
+	if _, err := readTimestamp(); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+
+	tb := spanner.ExactStaleness(10 * time.Second)
+	tx, err := conn.BeginTx(WithReadOnlyTransactionOptions(ctx, ReadOnlyTransactionOptions{TimestampBound: &tb}), &sql.TxOptions{ReadOnly: true})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer rows.Close()
-
-	for want := int64(1); rows.Next(); want++ {
-		cols, err := rows.Columns()
-		if err != nil {
-			t.Fatal(err)
-		}
-		if !cmp.Equal(cols, []string{"FOO"}) {
-			t.Fatalf("cols mismatch\nGot: %v\nWant: %v", cols, []string{"FOO"})
-		}
-		var got int64
-		err = rows.Scan(&got)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if got != want {
-			t.Fatalf("value mismatch\nGot: %v\nWant: %v", got, want)
-		}
+	rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
 	}
 	if rows.Err() != nil {
 		t.Fatal(rows.Err())
 	}
-	err = tx.Commit()
+	_ = rows.Close()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// The read timestamp is also available after the transaction has finished.
+	ts, err := readTimestamp()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if ts.IsZero() {
+		t.Fatal("missing read timestamp")
+	}
 
 	requests := drainRequestsFromServer(server.TestSpanner)
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if g, w := len(sqlRequests), 1; g != w {
-		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
-	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if req.Transaction == nil {
-		t.Fatalf("missing transaction for ExecuteSqlRequest")
-	}
-	if req.Transaction.GetId() == nil {
-		t.Fatalf("missing id selector for ExecuteSqlRequest")
-	}
-	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
-	if g, w := len(commitRequests), 1; g != w {
-		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	beginReadOnlyRequests := filterBeginReadOnlyRequests(requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{})))
+	if g, w := len(beginReadOnlyRequests), 1; g != w {
+		t.Fatalf("begin requests count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-	commitReq := commitRequests[0].(*sppb.CommitRequest)
-	if c, e := commitReq.GetTransactionId(), req.Transaction.GetId(); !cmp.Equal(c, e) {
-		t.Fatalf("transaction id mismatch\nCommit: %c\nExecute: %v", c, e)
+	if beginReadOnlyRequests[0].GetOptions().GetReadOnly().GetExactStaleness() == nil {
+		t.Fatalf("missing exact_staleness option on BeginTransaction request")
 	}
 }
 
-func TestPreparedQuery(t *testing.T) {
+func TestSingleUseReadOnlyTransaction(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-	_ = server.TestSpanner.PutStatementResult(
-		"SELECT * FROM Test WHERE Id=@id",
-		&testutil.StatementResult{
-			Type:      testutil.StatementResultResultSet,
-			ResultSet: testutil.CreateSelect1ResultSet(),
-		},
-	)
 
-	stmt, err := db.Prepare("SELECT * FROM Test WHERE Id=@id")
+	conn, err := db.Conn(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer stmt.Close()
-	rows, err := stmt.Query(1)
+	defer conn.Close()
+	tb := spanner.ExactStaleness(10 * time.Second)
+	tx, err := BeginReadOnlyTransaction(ctx, conn, ReadOnlyTransactionOptions{TimestampBound: &tb, SingleUse: true})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer rows.Close()
-
-	for want := int64(1); rows.Next(); want++ {
-		var got int64
-		err = rows.Scan(&got)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if got != want {
-			t.Fatalf("value mismatch\nGot: %v\nWant: %v", got, want)
-		}
+	rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
 	}
 	if rows.Err() != nil {
 		t.Fatal(rows.Err())
 	}
+	_ = rows.Close()
+	// A single-use transaction can only execute one query.
+	_, err = tx.QueryContext(ctx, testutil.SelectFooFromBar)
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
 	requests := drainRequestsFromServer(server.TestSpanner)
+	beginReadOnlyRequests := filterBeginReadOnlyRequests(requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{})))
+	if g, w := len(beginReadOnlyRequests), 0; g != w {
+		t.Fatalf("begin requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
 	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
 	if g, w := len(sqlRequests), 1; g != w {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", g, w)
+		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
 	}
 	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if g, w := len(req.ParamTypes), 1; g != w {
-		t.Fatalf("param types length mismatch\nGot: %v\nWant: %v", g, w)
+	if req.GetTransaction().GetSingleUse().GetReadOnly().GetExactStaleness() == nil {
+		t.Fatalf("missing single-use transaction with exact staleness: %v", req.GetTransaction())
 	}
-	if pt, ok := req.ParamTypes["id"]; ok {
-		if g, w := pt.Code, sppb.TypeCode_INT64; g != w {
-			t.Fatalf("param type mismatch\nGot: %v\nWant: %v", g, w)
+
+	// The options are only used for one transaction.
+	tx, err = conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+		if err != nil {
+			t.Fatal(err)
 		}
-	} else {
-		t.Fatalf("no param type found for @id")
+		for rows.Next() {
+		}
+		if rows.Err() != nil {
+			t.Fatal(rows.Err())
+		}
+		_ = rows.Close()
 	}
-	if g, w := len(req.Params.Fields), 1; g != w {
-		t.Fatalf("params length mismatch\nGot: %v\nWant: %v", g, w)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
 	}
-	if val, ok := req.Params.Fields["id"]; ok {
-		if g, w := val.GetStringValue(), "1"; g != w {
-			t.Fatalf("param value mismatch\nGot: %v\nWant: %v", g, w)
-		}
-	} else {
-		t.Fatalf("no value found for param @id")
+	requests = drainRequestsFromServer(server.TestSpanner)
+	beginReadOnlyRequests = filterBeginReadOnlyRequests(requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{})))
+	if g, w := len(beginReadOnlyRequests), 1; g != w {
+		t.Fatalf("begin requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if !beginReadOnlyRequests[0].GetOptions().GetReadOnly().GetStrong() {
+		t.Fatalf("missing strong option on BeginTransaction request")
 	}
 }
 
-func TestQueryWithAllTypes(t *testing.T) {
+func TestRunTransaction(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-	query := `SELECT *
-             FROM Test
-             WHERE ColBool=@bool 
-             AND   ColString=@string
-             AND   ColBytes=@bytes
-             AND   ColInt=@int64
-             AND   ColFloat32=@float32
-             AND   ColFloat64=@float64
-             AND   ColNumeric=@numeric
-             AND   ColDate=@date
-             AND   ColTimestamp=@timestamp
-             AND   ColJson=@json
-             AND   ColBoolArray=@boolArray
-             AND   ColStringArray=@stringArray
-             AND   ColBytesArray=@bytesArray
-             AND   ColIntArray=@int64Array
-             AND   ColFloat32Array=@float32Array
-             AND   ColFloat64Array=@float64Array
-             AND   ColNumericArray=@numericArray
-             AND   ColDateArray=@dateArray
-             AND   ColTimestampArray=@timestampArray
-             AND   ColJsonArray=@jsonArray`
-	_ = server.TestSpanner.PutStatementResult(
-		query,
-		&testutil.StatementResult{
-			Type:      testutil.StatementResultResultSet,
-			ResultSet: testutil.CreateResultSetWithAllTypes(false),
-		},
-	)
 
-	stmt, err := db.Prepare(query)
+	// The first commit is aborted, which causes the function to be retried.
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{gstatus.Error(codes.Aborted, "Aborted")},
+	})
+	attempts := 0
+	err := RunTransaction(ctx, db, ReadWriteTransactionOptions{
+		ReadLockMode:   sppb.TransactionOptions_ReadWrite_PESSIMISTIC,
+		Priority:       sppb.RequestOptions_PRIORITY_LOW,
+		TransactionTag: "test-tag",
+	}, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+			return err
+		}
+		// ExecOptions override the default priority of the transaction.
+		_, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo, ExecOptions{Priority: sppb.RequestOptions_PRIORITY_HIGH})
+		return err
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer stmt.Close()
-	ts, _ := time.Parse(time.RFC3339Nano, "2021-07-22T10:26:17.123Z")
-	ts1, _ := time.Parse(time.RFC3339Nano, "2021-07-21T21:07:59.339911800Z")
-	ts2, _ := time.Parse(time.RFC3339Nano, "2021-07-27T21:07:59.339911800Z")
-	rows, err := stmt.QueryContext(
-		context.Background(),
-		true,
-		"test",
-		[]byte("testbytes"),
-		uint(5),
-		float32(3.14),
-		3.14,
-		numeric("6.626"),
-		civil.Date{Year: 2021, Month: 7, Day: 21},
-		ts,
-		nullJson(true, `{"key":"value","other-key":["value1","value2"]}`),
-		[]spanner.NullBool{{Valid: true, Bool: true}, {}, {Valid: true, Bool: false}},
-		[]spanner.NullString{{Valid: true, StringVal: "test1"}, {}, {Valid: true, StringVal: "test2"}},
-		[][]byte{[]byte("testbytes1"), nil, []byte("testbytes2")},
-		[]spanner.NullInt64{{Valid: true, Int64: 1}, {}, {Valid: true, Int64: 2}},
-		[]spanner.NullFloat32{{Valid: true, Float32: 3.14}, {}, {Valid: true, Float32: -99.99}},
-		[]spanner.NullFloat64{{Valid: true, Float64: 6.626}, {}, {Valid: true, Float64: 10.01}},
-		[]spanner.NullNumeric{nullNumeric(true, "3.14"), {}, nullNumeric(true, "10.01")},
-		[]spanner.NullDate{{Valid: true, Date: civil.Date{Year: 2000, Month: 2, Day: 29}}, {}, {Valid: true, Date: civil.Date{Year: 2021, Month: 7, Day: 27}}},
-		[]spanner.NullTime{{Valid: true, Time: ts1}, {}, {Valid: true, Time: ts2}},
-		[]spanner.NullJSON{
-			nullJson(true, `{"key1": "value1", "other-key1": ["value1", "value2"]}`),
-			nullJson(false, ""),
-			nullJson(true, `{"key2": "value2", "other-key2": ["value1", "value2"]}`),
-		},
-	)
-	if err != nil {
-		t.Fatal(err)
+	if g, w := attempts, 2; g != w {
+		t.Fatalf("attempts mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var b bool
-		var s string
-		var bt []byte
-		var i int64
-		var f32 float32
-		var f float64
-		var r big.Rat
-		var d civil.Date
-		var ts time.Time
-		var j spanner.NullJSON
-		var bArray []spanner.NullBool
-		var sArray []spanner.NullString
-		var btArray [][]byte
-		var iArray []spanner.NullInt64
-		var f32Array []spanner.NullFloat32
-		var fArray []spanner.NullFloat64
-		var rArray []spanner.NullNumeric
-		var dArray []spanner.NullDate
-		var tsArray []spanner.NullTime
-		var jArray []spanner.NullJSON
-		err = rows.Scan(&b, &s, &bt, &i, &f32, &f, &r, &d, &ts, &j, &bArray, &sArray, &btArray, &iArray, &f32Array, &fArray, &rArray, &dArray, &tsArray, &jArray)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if g, w := b, true; g != w {
-			t.Errorf("row value mismatch for bool\nGot: %v\nWant: %v", g, w)
+	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 2; g != w {
+		t.Fatalf("begin requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for _, req := range beginRequests {
+		if g, w := req.(*sppb.BeginTransactionRequest).GetOptions().GetReadWrite().GetReadLockMode(), sppb.TransactionOptions_ReadWrite_PESSIMISTIC; g != w {
+			t.Fatalf("read lock mode mismatch\n Got: %v\nWant: %v", g, w)
 		}
-		if g, w := s, "test"; g != w {
-			t.Errorf("row value mismatch for string\nGot: %v\nWant: %v", g, w)
+	}
+	executeRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(executeRequests), 4; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, req := range executeRequests {
+		want := sppb.RequestOptions_PRIORITY_LOW
+		if i%2 == 1 {
+			want = sppb.RequestOptions_PRIORITY_HIGH
 		}
-		if g, w := bt, []byte("testbytes"); !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for bytes\nGot: %v\nWant: %v", g, w)
+		options := req.(*sppb.ExecuteSqlRequest).GetRequestOptions()
+		if g, w := options.GetPriority(), want; g != w {
+			t.Fatalf("%d: priority mismatch\n Got: %v\nWant: %v", i, g, w)
 		}
-		if g, w := i, int64(5); g != w {
-			t.Errorf("row value mismatch for int64\nGot: %v\nWant: %v", g, w)
+		if g, w := options.GetTransactionTag(), "test-tag"; g != w {
+			t.Fatalf("%d: transaction tag mismatch\n Got: %v\nWant: %v", i, g, w)
 		}
-		if g, w := f32, float32(3.14); g != w {
-			t.Errorf("row value mismatch for float32\nGot: %v\nWant: %v", g, w)
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 2; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for _, req := range commitRequests {
+		options := req.(*sppb.CommitRequest).GetRequestOptions()
+		if g, w := options.GetPriority(), sppb.RequestOptions_PRIORITY_LOW; g != w {
+			t.Fatalf("commit priority mismatch\n Got: %v\nWant: %v", g, w)
 		}
-		if g, w := f, 3.14; g != w {
-			t.Errorf("row value mismatch for float64\nGot: %v\nWant: %v", g, w)
+		if g, w := options.GetTransactionTag(), "test-tag"; g != w {
+			t.Fatalf("commit transaction tag mismatch\n Got: %v\nWant: %v", g, w)
 		}
-		if g, w := r, numeric("6.626"); g.Cmp(&w) != 0 {
-			t.Errorf("row value mismatch for numeric\nGot: %v\nWant: %v", g, w)
-		}
-		if g, w := d, date("2021-07-21"); !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for date\nGot: %v\nWant: %v", g, w)
-		}
-		if g, w := ts, time.Date(2021, 7, 21, 21, 7, 59, 339911800, time.UTC); g != w {
-			t.Errorf("row value mismatch for timestamp\nGot: %v\nWant: %v", g, w)
-		}
-		if !runsOnEmulator() {
-			if g, w := j, nullJson(true, `{"key":"value","other-key":["value1","value2"]}`); !cmp.Equal(g, w) {
-				t.Errorf("row value mismatch for json\nGot: %v\nWant: %v", g, w)
-			}
+	}
+
+	// The options are not applied to transactions that are not started by
+	// RunTransaction.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	executeRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := executeRequests[0].(*sppb.ExecuteSqlRequest).GetRequestOptions().GetPriority(), sppb.RequestOptions_PRIORITY_UNSPECIFIED; g != w {
+		t.Fatalf("priority mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Other errors are returned without retrying the transaction.
+	attempts = 0
+	wantErr := errors.New("test error")
+	err = RunTransaction(ctx, db, ReadWriteTransactionOptions{}, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, wantErr)
+	}
+	if g, w := attempts, 1; g != w {
+		t.Fatalf("attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRunTransaction_RetryBudgetExceeded(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// All commits are aborted.
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors:    []error{gstatus.Error(codes.Aborted, "Aborted")},
+		KeepError: true,
+	})
+	attempts := 0
+	err := RunTransaction(ctx, db, ReadWriteTransactionOptions{MaxAttempts: 3}, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		_, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo)
+		return err
+	})
+	var budgetErr *RetryBudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %T", err, budgetErr)
+	}
+	if g, w := budgetErr.Attempts, 3; g != w {
+		t.Fatalf("error attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := attempts, 3; g != w {
+		t.Fatalf("attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := spanner.ErrCode(budgetErr.Err), codes.Aborted; g != w {
+		t.Fatalf("last error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// The retries also stop when the context is done.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	err = RunTransaction(timeoutCtx, db, ReadWriteTransactionOptions{}, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo)
+		return err
+	})
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %T", err, budgetErr)
+	}
+
+	// The transaction waits for the backoff before it is retried.
+	timeoutCtx, cancel = context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	attempts = 0
+	err = RunTransaction(timeoutCtx, db, ReadWriteTransactionOptions{RetryBackoff: &gax.Backoff{Initial: time.Hour, Max: time.Hour}}, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		_, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo)
+		return err
+	})
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %T", err, budgetErr)
+	}
+	if g, w := attempts, 1; g != w {
+		t.Fatalf("attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRunTransaction_RequestAndTransactionTags(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	err := RunTransaction(ctx, db, ReadWriteTransactionOptions{TransactionTag: "tx-tag"}, func(ctx context.Context, tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar, ExecOptions{RequestTag: "query-tag"})
+		if err != nil {
+			return err
 		}
-		if g, w := bArray, []spanner.NullBool{{Valid: true, Bool: true}, {}, {Valid: true, Bool: false}}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for bool array\nGot: %v\nWant: %v", g, w)
+		for rows.Next() {
 		}
-		if g, w := sArray, []spanner.NullString{{Valid: true, StringVal: "test1"}, {}, {Valid: true, StringVal: "test2"}}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for string array\nGot: %v\nWant: %v", g, w)
+		if err := rows.Close(); err != nil {
+			return err
 		}
-		if g, w := btArray, [][]byte{[]byte("testbytes1"), nil, []byte("testbytes2")}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for bytes array\nGot: %v\nWant: %v", g, w)
+		if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo, ExecOptions{RequestTag: "update-tag"}); err != nil {
+			return err
 		}
-		if g, w := iArray, []spanner.NullInt64{{Valid: true, Int64: 1}, {}, {Valid: true, Int64: 2}}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for int array\nGot: %v\nWant: %v", g, w)
+		// A statement without a request tag only has the transaction tag.
+		_, err = tx.ExecContext(ctx, testutil.UpdateBarSetFoo)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 3; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, want := range []string{"query-tag", "update-tag", ""} {
+		options := requests[i].(*sppb.ExecuteSqlRequest).GetRequestOptions()
+		if g, w := options.GetRequestTag(), want; g != w {
+			t.Fatalf("%d: request tag mismatch\n Got: %v\nWant: %v", i, g, w)
 		}
-		if g, w := f32Array, []spanner.NullFloat32{{Valid: true, Float32: 3.14}, {}, {Valid: true, Float32: -99.99}}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for float32 array\nGot: %v\nWant: %v", g, w)
+		if g, w := options.GetTransactionTag(), "tx-tag"; g != w {
+			t.Fatalf("%d: transaction tag mismatch\n Got: %v\nWant: %v", i, g, w)
 		}
-		if g, w := fArray, []spanner.NullFloat64{{Valid: true, Float64: 6.626}, {}, {Valid: true, Float64: 10.01}}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for float64 array\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryOptionsRequestAndTransactionTags(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar, ExecOptions{QueryOptions: spanner.QueryOptions{RequestTag: "dashboard-query"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := requests[0].(*sppb.ExecuteSqlRequest).GetRequestOptions().GetRequestTag(), "dashboard-query"; g != w {
+		t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// The transaction tag in the context is applied to a transaction that is
+	// started with BeginTx. RequestTag takes precedence over QueryOptions.
+	txCtx := WithReadWriteTransactionOptions(ctx, ReadWriteTransactionOptions{TransactionTag: "tx-tag"})
+	tx, err := db.BeginTx(txCtx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo, ExecOptions{RequestTag: "update-tag", QueryOptions: spanner.QueryOptions{RequestTag: "other-tag"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	executeRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(executeRequests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	options := executeRequests[0].(*sppb.ExecuteSqlRequest).GetRequestOptions()
+	if g, w := options.GetRequestTag(), "update-tag"; g != w {
+		t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := options.GetTransactionTag(), "tx-tag"; g != w {
+		t.Fatalf("transaction tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := commitRequests[0].(*sppb.CommitRequest).GetRequestOptions().GetTransactionTag(), "tx-tag"; g != w {
+		t.Fatalf("commit transaction tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestDirectedReadOptions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	directedReadOptions := &sppb.DirectedReadOptions{
+		Replicas: &sppb.DirectedReadOptions_ExcludeReplicas_{
+			ExcludeReplicas: &sppb.DirectedReadOptions_ExcludeReplicas{
+				ReplicaSelections: []*sppb.DirectedReadOptions_ReplicaSelection{
+					{Location: "us-east1"},
+				},
+			},
+		},
+	}
+	if err := conn.Raw(func(driverConn interface{}) error {
+		return driverConn.(SpannerConn).SetDirectedReadOptions(directedReadOptions)
+	}); err != nil {
+		t.Fatalf("failed to set directed read options: %v", err)
+	}
+
+	// Directed read options are used for queries in autocommit mode and in
+	// read-only transactions, but not in read/write transactions.
+	for _, readOnly := range []bool{true, false} {
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+		if err != nil {
+			t.Fatal(err)
 		}
-		if g, w := rArray, []spanner.NullNumeric{nullNumeric(true, "3.14"), {}, nullNumeric(true, "10.01")}; !cmp.Equal(g, w, cmp.AllowUnexported(big.Rat{}, big.Int{})) {
-			t.Errorf("row value mismatch for numeric array\nGot: %v\nWant: %v", g, w)
+		rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+		if err != nil {
+			t.Fatal(err)
 		}
-		if g, w := dArray, []spanner.NullDate{{Valid: true, Date: civil.Date{Year: 2000, Month: 2, Day: 29}}, {}, {Valid: true, Date: civil.Date{Year: 2021, Month: 7, Day: 27}}}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for date array\nGot: %v\nWant: %v", g, w)
+		for rows.Next() {
 		}
-		if g, w := tsArray, []spanner.NullTime{{Valid: true, Time: ts1}, {}, {Valid: true, Time: ts2}}; !cmp.Equal(g, w) {
-			t.Errorf("row value mismatch for timestamp array\nGot: %v\nWant: %v", g, w)
+		if rows.Err() != nil {
+			t.Fatal(rows.Err())
 		}
-		if !runsOnEmulator() {
-			if g, w := jArray, []spanner.NullJSON{
-				nullJson(true, `{"key1": "value1", "other-key1": ["value1", "value2"]}`),
-				nullJson(false, ""),
-				nullJson(true, `{"key2": "value2", "other-key2": ["value1", "value2"]}`),
-			}; !cmp.Equal(g, w) {
-				t.Errorf("row value mismatch for json array\nGot: %v\nWant: %v", g, w)
-			}
+		_ = rows.Close()
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
 		}
 	}
+	rows, err := conn.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
 	if rows.Err() != nil {
 		t.Fatal(rows.Err())
 	}
+	_ = rows.Close()
+
 	requests := drainRequestsFromServer(server.TestSpanner)
 	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if g, w := len(sqlRequests), 1; g != w {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", g, w)
+	if g, w := len(sqlRequests), 3; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if g, w := len(req.ParamTypes), 20; g != w {
-		t.Fatalf("param types length mismatch\nGot: %v\nWant: %v", g, w)
+	for i, wantDirectedRead := range []bool{true, false, true} {
+		req := sqlRequests[i].(*sppb.ExecuteSqlRequest)
+		if wantDirectedRead {
+			if !proto.Equal(req.DirectedReadOptions, directedReadOptions) {
+				t.Errorf("%d: directed read options mismatch\n Got: %v\nWant: %v", i, req.DirectedReadOptions, directedReadOptions)
+			}
+		} else if req.DirectedReadOptions != nil {
+			t.Errorf("%d: unexpected directed read options: %v", i, req.DirectedReadOptions)
+		}
 	}
-	if g, w := len(req.Params.Fields), 20; g != w {
-		t.Fatalf("params length mismatch\nGot: %v\nWant: %v", g, w)
+
+	// Setting invalid directed read options should fail.
+	err = conn.Raw(func(driverConn interface{}) error {
+		return driverConn.(SpannerConn).SetDirectedReadOptions(&sppb.DirectedReadOptions{
+			Replicas: &sppb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &sppb.DirectedReadOptions_ExcludeReplicas{
+					ReplicaSelections: []*sppb.DirectedReadOptions_ReplicaSelection{{}},
+				},
+			},
+		})
+	})
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	wantParams := []struct {
-		name  string
-		code  sppb.TypeCode
-		array bool
-		value interface{}
-	}{
-		{
-			name:  "bool",
-			code:  sppb.TypeCode_BOOL,
-			value: true,
-		},
-		{
-			name:  "string",
-			code:  sppb.TypeCode_STRING,
-			value: "test",
-		},
-		{
-			name:  "bytes",
-			code:  sppb.TypeCode_BYTES,
-			value: base64.StdEncoding.EncodeToString([]byte("testbytes")),
-		},
-		{
-			name:  "int64",
-			code:  sppb.TypeCode_INT64,
-			value: "5",
-		},
-		{
-			name:  "float32",
-			code:  sppb.TypeCode_FLOAT32,
-			value: float64(float32(3.14)),
-		},
-		{
-			name:  "float64",
-			code:  sppb.TypeCode_FLOAT64,
-			value: 3.14,
-		},
-		{
-			name:  "numeric",
-			code:  sppb.TypeCode_NUMERIC,
-			value: "6.626000000",
-		},
-		{
-			name:  "date",
-			code:  sppb.TypeCode_DATE,
-			value: "2021-07-21",
-		},
-		{
-			name:  "timestamp",
-			code:  sppb.TypeCode_TIMESTAMP,
-			value: "2021-07-22T10:26:17.123Z",
-		},
-		{
-			name:  "json",
-			code:  sppb.TypeCode_JSON,
-			value: `{"key":"value","other-key":["value1","value2"]}`,
+}
+
+func TestDirectedReadOptions_ExecOptionsAndSetStatement(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET DIRECTED_READ = 'us-east1/READ_ONLY'"); err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE DIRECTED_READ").Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := value, "us-east1/READ_ONLY"; g != w {
+		t.Fatalf("directed read mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	execOptionsDirectedRead := &sppb.DirectedReadOptions{
+		Replicas: &sppb.DirectedReadOptions_IncludeReplicas_{
+			IncludeReplicas: &sppb.DirectedReadOptions_IncludeReplicas{
+				ReplicaSelections: []*sppb.DirectedReadOptions_ReplicaSelection{{Location: "us-west1"}},
+			},
 		},
-		{
-			name:  "boolArray",
-			code:  sppb.TypeCode_BOOL,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_BoolValue{BoolValue: true}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_BoolValue{BoolValue: false}},
-			}},
-		},
-		{
-			name:  "stringArray",
-			code:  sppb.TypeCode_STRING,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_StringValue{StringValue: "test1"}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_StringValue{StringValue: "test2"}},
-			}},
-		},
-		{
-			name:  "bytesArray",
-			code:  sppb.TypeCode_BYTES,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_StringValue{StringValue: base64.StdEncoding.EncodeToString([]byte("testbytes1"))}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_StringValue{StringValue: base64.StdEncoding.EncodeToString([]byte("testbytes2"))}},
-			}},
-		},
-		{
-			name:  "int64Array",
-			code:  sppb.TypeCode_INT64,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_StringValue{StringValue: "1"}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_StringValue{StringValue: "2"}},
-			}},
-		},
-		{
-			name:  "float32Array",
-			code:  sppb.TypeCode_FLOAT32,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_NumberValue{NumberValue: float64(float32(3.14))}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_NumberValue{NumberValue: float64(float32(-99.99))}},
-			}},
+	}
+	var v int64
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	// ExecOptions override the directed read options of the connection.
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar, ExecOptions{DirectedReadOptions: execOptionsDirectedRead}).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	sqlRequests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	selection := sqlRequests[0].(*sppb.ExecuteSqlRequest).GetDirectedReadOptions().GetIncludeReplicas().GetReplicaSelections()
+	if g, w := len(selection), 1; g != w {
+		t.Fatalf("replica selections count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := selection[0].Location, "us-east1"; g != w {
+		t.Fatalf("location mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := selection[0].Type, sppb.DirectedReadOptions_ReplicaSelection_READ_ONLY; g != w {
+		t.Fatalf("type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := sqlRequests[1].(*sppb.ExecuteSqlRequest).DirectedReadOptions, execOptionsDirectedRead; !proto.Equal(g, w) {
+		t.Fatalf("directed read options mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Directed reads are rejected for DML statements and in read/write
+	// transactions.
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo, ExecOptions{DirectedReadOptions: execOptionsDirectedRead}); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.QueryContext(ctx, testutil.SelectFooFromBar, ExecOptions{DirectedReadOptions: execOptionsDirectedRead}); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid directed read options are rejected.
+	if _, err := conn.ExecContext(ctx, "SET DIRECTED_READ = 'us-east1/READ_SOMETIMES'"); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if _, err := conn.ExecContext(ctx, "SET DIRECTED_READ = 'NULL'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE DIRECTED_READ").Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := value, "NULL"; g != w {
+		t.Fatalf("directed read mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestOptimizerStatisticsPackage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnectionWithParams(t, "optimizerStatisticsPackage=latest")
+	defer teardown()
+
+	// Query without any ExecOptions uses the default of the connection.
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	_ = rows.Close()
+	// ExecOptions override the default of the connection for a single statement.
+	rows, err = db.QueryContext(ctx, testutil.SelectFooFromBar, ExecOptions{OptimizerStatisticsPackage: "auto_20240101_00_00_00UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	_ = rows.Close()
+	if _, err := db.ExecContext(ctx, testutil.UpdateBarSetFoo, ExecOptions{OptimizerStatisticsPackage: "auto_20240102_00_00_00UTC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 3; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, want := range []string{"latest", "auto_20240101_00_00_00UTC", "auto_20240102_00_00_00UTC"} {
+		req := sqlRequests[i].(*sppb.ExecuteSqlRequest)
+		if g, w := req.GetQueryOptions().GetOptimizerStatisticsPackage(), want; g != w {
+			t.Errorf("%d: optimizer statistics package mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+	}
+}
+
+func TestOptimizerVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnectionWithParams(t, "optimizerVersion=1")
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The default of the connector is used if the connection has no default.
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "SET OPTIMIZER_VERSION = '4'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	// ExecOptions override the default of the connection for a single statement.
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar, ExecOptions{OptimizerVersion: "latest"}).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar, ExecOptions{QueryOptions: spanner.QueryOptions{Options: &sppb.ExecuteSqlRequest_QueryOptions{OptimizerVersion: "2"}}}).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 5; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, want := range []string{"1", "4", "4", "latest", "2"} {
+		req := sqlRequests[i].(*sppb.ExecuteSqlRequest)
+		if g, w := req.GetQueryOptions().GetOptimizerVersion(), want; g != w {
+			t.Errorf("%d: optimizer version mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+	}
+}
+
+type testStructParam struct {
+	ID int64
+}
+
+type testValuerStructParam struct {
+	ID int64
+}
+
+func (p testValuerStructParam) Value() (driver.Value, error) {
+	return p.ID, nil
+}
+
+func TestExecOptionsArgumentPosition(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	query := "UPDATE Singers SET Name=@p1 WHERE Id=@p2"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	options := ExecOptions{RequestTag: "tag"}
+	stmt, err := db.PrepareContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for i, test := range []struct {
+		name string
+		exec func(args ...interface{}) (sql.Result, error)
+		args []interface{}
+	}{
+		{"first", func(args ...interface{}) (sql.Result, error) {
+			return db.ExecContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?", args...)
+		}, []interface{}{options, "foo", int64(1)}},
+		{"middle", func(args ...interface{}) (sql.Result, error) {
+			return db.ExecContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?", args...)
+		}, []interface{}{"foo", options, int64(1)}},
+		{"last", func(args ...interface{}) (sql.Result, error) {
+			return db.ExecContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?", args...)
+		}, []interface{}{"foo", int64(1), options}},
+		{"prepared", func(args ...interface{}) (sql.Result, error) {
+			return stmt.ExecContext(ctx, args...)
+		}, []interface{}{"foo", options, int64(1)}},
+		{"valuer struct", func(args ...interface{}) (sql.Result, error) {
+			return db.ExecContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?", args...)
+		}, []interface{}{"foo", testValuerStructParam{ID: 1}, options}},
+	} {
+		if _, err := test.exec(test.args...); err != nil {
+			t.Fatalf("%d: %s: exec failed: %v", i, test.name, err)
+		}
+		requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		if g, w := len(requests), 1; g != w {
+			t.Fatalf("%s: requests count mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		req := requests[0].(*sppb.ExecuteSqlRequest)
+		if g, w := req.GetRequestOptions().GetRequestTag(), "tag"; g != w {
+			t.Fatalf("%s: request tag mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		if g, w := req.Params.Fields["p1"].GetStringValue(), "foo"; g != w {
+			t.Fatalf("%s: p1 mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		if g, w := req.Params.Fields["p2"].GetStringValue(), "1"; g != w {
+			t.Fatalf("%s: p2 mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+
+	// A pointer to ExecOptions and a struct that embeds ExecOptions are normal
+	// parameters, and are rejected as unsupported types.
+	type embeddedOptions struct {
+		ExecOptions
+	}
+	for _, arg := range []interface{}{&options, embeddedOptions{options}, testStructParam{ID: 1}} {
+		_, err := db.ExecContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?", "foo", arg)
+		if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+			t.Fatalf("%T: error code mismatch\n Got: %v\nWant: %v", arg, g, w)
+		}
+	}
+
+	// ExecOptions are not applied to the next statement if the statement
+	// fails because of an invalid argument.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?", options, "foo", testStructParam{ID: 1}); err == nil {
+		t.Fatal("missing error for unsupported argument")
+	}
+	if _, err := conn.ExecContext(ctx, "UPDATE Singers SET Name=? WHERE Id=?", "foo", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := requests[0].(*sppb.ExecuteSqlRequest).GetRequestOptions().GetRequestTag(), ""; g != w {
+		t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAnalyzeDML(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	query := "UPDATE Singers SET Active=false WHERE LastSeen < @ts"
+	queryPlan := &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{{Index: 0, DisplayName: "Distributed Union"}}}
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{}},
+			Stats:    &sppb.ResultSetStats{QueryPlan: queryPlan},
 		},
-		{
-			name:  "float64Array",
-			code:  sppb.TypeCode_FLOAT64,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_NumberValue{NumberValue: 6.626}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_NumberValue{NumberValue: 10.01}},
-			}},
+	})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, inTransaction := range []bool{false, true} {
+		var tx *sql.Tx
+		var res sql.Result
+		if inTransaction {
+			if tx, err = conn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			res, err = tx.ExecContext(ctx, query, ExecOptions{AnalyzeMode: AnalyzePlan}, time.Now())
+		} else {
+			res, err = conn.ExecContext(ctx, query, ExecOptions{AnalyzeMode: AnalyzePlan}, time.Now())
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := res.RowsAffected(); c != 0 {
+			t.Fatalf("%v: rows affected mismatch\n Got: %v\nWant: %v", inTransaction, c, 0)
+		}
+		if err := conn.Raw(func(driverConn interface{}) error {
+			plan, err := driverConn.(SpannerConn).QueryPlan()
+			if err != nil {
+				return err
+			}
+			if !proto.Equal(plan, queryPlan) {
+				t.Fatalf("%v: query plan mismatch\n Got: %v\nWant: %v", inTransaction, plan, queryPlan)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if tx != nil {
+			if err := tx.Rollback(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		requests := drainRequestsFromServer(server.TestSpanner)
+		sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		if g, w := len(sqlRequests), 1; g != w {
+			t.Fatalf("%v: ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+		}
+		if g, w := sqlRequests[0].(*sppb.ExecuteSqlRequest).QueryMode, sppb.ExecuteSqlRequest_PLAN; g != w {
+			t.Fatalf("%v: query mode mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+		}
+		// The statement is only analyzed, and the transaction is never committed.
+		if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 0; g != w {
+			t.Fatalf("%v: commit requests count mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+		}
+	}
+
+	// Executing the statement normally clears the query plan.
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).QueryPlan()
+		return err
+	})
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAnalyzeQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	query := "SELECT * FROM Singers WHERE Active=@active"
+	queryPlan := &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{{Index: 0, DisplayName: "Distributed Union"}}}
+	queryStats, _ := structpb.NewStruct(map[string]interface{}{"elapsed_time": "1.22 msecs", "rows_returned": "2"})
+	resultSet := testutil.CreateSingleColumnResultSet([]int64{1, 2}, "SingerId")
+	resultSet.Stats = &sppb.ResultSetStats{QueryPlan: queryPlan, QueryStats: queryStats}
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: resultSet,
+	})
+	// Spanner does not return any rows in PLAN mode.
+	_ = server.TestSpanner.PutStatementResult(query+" AND 1=1", &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: resultSet.Metadata,
+			Stats:    &sppb.ResultSetStats{QueryPlan: queryPlan},
 		},
-		{
-			name:  "numericArray",
-			code:  sppb.TypeCode_NUMERIC,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_StringValue{StringValue: "3.140000000"}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_StringValue{StringValue: "10.010000000"}},
-			}},
+	})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	resultSetStats := func() (stats *sppb.ResultSetStats, err error) {
+		err = conn.Raw(func(driverConn interface{}) error {
+			stats, err = driverConn.(SpannerConn).ResultSetStats()
+			return err
+		})
+		return stats, err
+	}
+
+	for _, test := range []struct {
+		mode      AnalyzeMode
+		query     string
+		wantRows  int
+		wantMode  sppb.ExecuteSqlRequest_QueryMode
+		wantStats *sppb.ResultSetStats
+	}{
+		{AnalyzeProfile, query, 2, sppb.ExecuteSqlRequest_PROFILE, &sppb.ResultSetStats{QueryPlan: queryPlan, QueryStats: queryStats}},
+		{AnalyzePlan, query + " AND 1=1", 0, sppb.ExecuteSqlRequest_PLAN, &sppb.ResultSetStats{QueryPlan: queryPlan}},
+	} {
+		rows, err := conn.QueryContext(ctx, test.query, ExecOptions{AnalyzeMode: test.mode}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+		if g, w := count, test.wantRows; g != w {
+			t.Fatalf("%v: row count mismatch\n Got: %v\nWant: %v", test.mode, g, w)
+		}
+		stats, err := resultSetStats()
+		if err != nil {
+			t.Fatalf("%v: %v", test.mode, err)
+		}
+		if !proto.Equal(stats, test.wantStats) {
+			t.Fatalf("%v: stats mismatch\n Got: %v\nWant: %v", test.mode, stats, test.wantStats)
+		}
+		if err := conn.Raw(func(driverConn interface{}) error {
+			plan, err := driverConn.(SpannerConn).QueryPlan()
+			if err != nil {
+				return err
+			}
+			if !proto.Equal(plan, queryPlan) {
+				t.Fatalf("%v: query plan mismatch\n Got: %v\nWant: %v", test.mode, plan, queryPlan)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sqlRequests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		if g, w := len(sqlRequests), 1; g != w {
+			t.Fatalf("%v: ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", test.mode, g, w)
+		}
+		if g, w := sqlRequests[0].(*sppb.ExecuteSqlRequest).QueryMode, test.wantMode; g != w {
+			t.Fatalf("%v: query mode mismatch\n Got: %v\nWant: %v", test.mode, g, w)
+		}
+	}
+
+	// The statistics are only available after all rows have been consumed.
+	rows, err := conn.QueryContext(ctx, query, ExecOptions{AnalyzeMode: AnalyzeProfile}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal("missing row")
+	}
+	if _, err := resultSetStats(); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	_ = rows.Close()
+
+	// AnalyzeProfile cannot be used for DML statements.
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo, ExecOptions{AnalyzeMode: AnalyzeProfile}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if _, err := resultSetStats(); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+}
+
+func TestSimpleReadWriteTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.Query(testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	for want := int64(1); rows.Next(); want++ {
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(cols, []string{"FOO"}) {
+			t.Fatalf("cols mismatch\nGot: %v\nWant: %v", cols, []string{"FOO"})
+		}
+		var got int64
+		err = rows.Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("value mismatch\nGot: %v\nWant: %v", got, want)
+		}
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if req.Transaction == nil {
+		t.Fatalf("missing transaction for ExecuteSqlRequest")
+	}
+	if req.Transaction.GetId() == nil {
+		t.Fatalf("missing id selector for ExecuteSqlRequest")
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	commitReq := commitRequests[0].(*sppb.CommitRequest)
+	if c, e := commitReq.GetTransactionId(), req.Transaction.GetId(); !cmp.Equal(c, e) {
+		t.Fatalf("transaction id mismatch\nCommit: %c\nExecute: %v", c, e)
+	}
+}
+
+func TestReadWriteTransactionIgnoresMultiplexedSessions(t *testing.T) {
+	// These are the environment variables that enable multiplexed sessions in
+	// newer versions of the Spanner client. The version that is used by the
+	// driver does not support multiplexed sessions, and read/write
+	// transactions should therefore still use a regular session.
+	t.Setenv("GOOGLE_CLOUD_SPANNER_MULTIPLEXED_SESSIONS", "true")
+	t.Setenv("GOOGLE_CLOUD_SPANNER_MULTIPLEXED_SESSIONS_FOR_RW", "true")
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	// Multiplexed sessions are created with CreateSession. Regular sessions
+	// are created with BatchCreateSessions.
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CreateSessionRequest{}))), 0; g != w {
+		t.Fatalf("CreateSessionRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	session := commitRequests[0].(*sppb.CommitRequest).Session
+	for i, req := range sqlRequests {
+		if g, w := req.(*sppb.ExecuteSqlRequest).Session, session; g != w {
+			t.Fatalf("%d: session mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+	}
+	if !server.TestSpanner.DumpSessions()[session] {
+		t.Fatalf("unknown session used for commit: %v", session)
+	}
+}
+
+func TestPreparedQuery(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	_ = server.TestSpanner.PutStatementResult(
+		"SELECT * FROM Test WHERE Id=@id",
+		&testutil.StatementResult{
+			Type:      testutil.StatementResultResultSet,
+			ResultSet: testutil.CreateSelect1ResultSet(),
 		},
-		{
-			name:  "dateArray",
-			code:  sppb.TypeCode_DATE,
-			array: true,
-			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_StringValue{StringValue: "2000-02-29"}},
-				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_StringValue{StringValue: "2021-07-27"}},
-			}},
+	)
+
+	stmt, err := db.Prepare("SELECT * FROM Test WHERE Id=@id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	for want := int64(1); rows.Next(); want++ {
+		var got int64
+		err = rows.Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("value mismatch\nGot: %v\nWant: %v", got, want)
+		}
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := len(req.ParamTypes), 1; g != w {
+		t.Fatalf("param types length mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if pt, ok := req.ParamTypes["id"]; ok {
+		if g, w := pt.Code, sppb.TypeCode_INT64; g != w {
+			t.Fatalf("param type mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	} else {
+		t.Fatalf("no param type found for @id")
+	}
+	if g, w := len(req.Params.Fields), 1; g != w {
+		t.Fatalf("params length mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if val, ok := req.Params.Fields["id"]; ok {
+		if g, w := val.GetStringValue(), "1"; g != w {
+			t.Fatalf("param value mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	} else {
+		t.Fatalf("no value found for param @id")
+	}
+}
+
+func TestQueryWithAllTypes(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	query := `SELECT *
+             FROM Test
+             WHERE ColBool=@bool 
+             AND   ColString=@string
+             AND   ColBytes=@bytes
+             AND   ColInt=@int64
+             AND   ColFloat32=@float32
+             AND   ColFloat64=@float64
+             AND   ColNumeric=@numeric
+             AND   ColDate=@date
+             AND   ColTimestamp=@timestamp
+             AND   ColJson=@json
+             AND   ColBoolArray=@boolArray
+             AND   ColStringArray=@stringArray
+             AND   ColBytesArray=@bytesArray
+             AND   ColIntArray=@int64Array
+             AND   ColFloat32Array=@float32Array
+             AND   ColFloat64Array=@float64Array
+             AND   ColNumericArray=@numericArray
+             AND   ColDateArray=@dateArray
+             AND   ColTimestampArray=@timestampArray
+             AND   ColJsonArray=@jsonArray`
+	_ = server.TestSpanner.PutStatementResult(
+		query,
+		&testutil.StatementResult{
+			Type:      testutil.StatementResultResultSet,
+			ResultSet: testutil.CreateResultSetWithAllTypes(false),
 		},
-		{
-			name:  "timestampArray",
+	)
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	ts, _ := time.Parse(time.RFC3339Nano, "2021-07-22T10:26:17.123Z")
+	ts1, _ := time.Parse(time.RFC3339Nano, "2021-07-21T21:07:59.339911800Z")
+	ts2, _ := time.Parse(time.RFC3339Nano, "2021-07-27T21:07:59.339911800Z")
+	rows, err := stmt.QueryContext(
+		context.Background(),
+		true,
+		"test",
+		[]byte("testbytes"),
+		uint(5),
+		float32(3.14),
+		3.14,
+		numeric("6.626"),
+		civil.Date{Year: 2021, Month: 7, Day: 21},
+		ts,
+		nullJson(true, `{"key":"value","other-key":["value1","value2"]}`),
+		[]spanner.NullBool{{Valid: true, Bool: true}, {}, {Valid: true, Bool: false}},
+		[]spanner.NullString{{Valid: true, StringVal: "test1"}, {}, {Valid: true, StringVal: "test2"}},
+		[][]byte{[]byte("testbytes1"), nil, []byte("testbytes2")},
+		[]spanner.NullInt64{{Valid: true, Int64: 1}, {}, {Valid: true, Int64: 2}},
+		[]spanner.NullFloat32{{Valid: true, Float32: 3.14}, {}, {Valid: true, Float32: -99.99}},
+		[]spanner.NullFloat64{{Valid: true, Float64: 6.626}, {}, {Valid: true, Float64: 10.01}},
+		[]spanner.NullNumeric{nullNumeric(true, "3.14"), {}, nullNumeric(true, "10.01")},
+		[]spanner.NullDate{{Valid: true, Date: civil.Date{Year: 2000, Month: 2, Day: 29}}, {}, {Valid: true, Date: civil.Date{Year: 2021, Month: 7, Day: 27}}},
+		[]spanner.NullTime{{Valid: true, Time: ts1}, {}, {Valid: true, Time: ts2}},
+		[]spanner.NullJSON{
+			nullJson(true, `{"key1": "value1", "other-key1": ["value1", "value2"]}`),
+			nullJson(false, ""),
+			nullJson(true, `{"key2": "value2", "other-key2": ["value1", "value2"]}`),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b bool
+		var s string
+		var bt []byte
+		var i int64
+		var f32 float32
+		var f float64
+		var r big.Rat
+		var d civil.Date
+		var ts time.Time
+		var j spanner.NullJSON
+		var bArray []spanner.NullBool
+		var sArray []spanner.NullString
+		var btArray [][]byte
+		var iArray []spanner.NullInt64
+		var f32Array []spanner.NullFloat32
+		var fArray []spanner.NullFloat64
+		var rArray []spanner.NullNumeric
+		var dArray []spanner.NullDate
+		var tsArray []spanner.NullTime
+		var jArray []spanner.NullJSON
+		err = rows.Scan(&b, &s, &bt, &i, &f32, &f, &r, &d, &ts, &j, &bArray, &sArray, &btArray, &iArray, &f32Array, &fArray, &rArray, &dArray, &tsArray, &jArray)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, w := b, true; g != w {
+			t.Errorf("row value mismatch for bool\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := s, "test"; g != w {
+			t.Errorf("row value mismatch for string\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := bt, []byte("testbytes"); !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for bytes\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := i, int64(5); g != w {
+			t.Errorf("row value mismatch for int64\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := f32, float32(3.14); g != w {
+			t.Errorf("row value mismatch for float32\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := f, 3.14; g != w {
+			t.Errorf("row value mismatch for float64\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := r, numeric("6.626"); g.Cmp(&w) != 0 {
+			t.Errorf("row value mismatch for numeric\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := d, date("2021-07-21"); !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for date\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := ts, time.Date(2021, 7, 21, 21, 7, 59, 339911800, time.UTC); g != w {
+			t.Errorf("row value mismatch for timestamp\nGot: %v\nWant: %v", g, w)
+		}
+		if !runsOnEmulator() {
+			if g, w := j, nullJson(true, `{"key":"value","other-key":["value1","value2"]}`); !cmp.Equal(g, w) {
+				t.Errorf("row value mismatch for json\nGot: %v\nWant: %v", g, w)
+			}
+		}
+		if g, w := bArray, []spanner.NullBool{{Valid: true, Bool: true}, {}, {Valid: true, Bool: false}}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for bool array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := sArray, []spanner.NullString{{Valid: true, StringVal: "test1"}, {}, {Valid: true, StringVal: "test2"}}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for string array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := btArray, [][]byte{[]byte("testbytes1"), nil, []byte("testbytes2")}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for bytes array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := iArray, []spanner.NullInt64{{Valid: true, Int64: 1}, {}, {Valid: true, Int64: 2}}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for int array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := f32Array, []spanner.NullFloat32{{Valid: true, Float32: 3.14}, {}, {Valid: true, Float32: -99.99}}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for float32 array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := fArray, []spanner.NullFloat64{{Valid: true, Float64: 6.626}, {}, {Valid: true, Float64: 10.01}}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for float64 array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := rArray, []spanner.NullNumeric{nullNumeric(true, "3.14"), {}, nullNumeric(true, "10.01")}; !cmp.Equal(g, w, cmp.AllowUnexported(big.Rat{}, big.Int{})) {
+			t.Errorf("row value mismatch for numeric array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := dArray, []spanner.NullDate{{Valid: true, Date: civil.Date{Year: 2000, Month: 2, Day: 29}}, {}, {Valid: true, Date: civil.Date{Year: 2021, Month: 7, Day: 27}}}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for date array\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := tsArray, []spanner.NullTime{{Valid: true, Time: ts1}, {}, {Valid: true, Time: ts2}}; !cmp.Equal(g, w) {
+			t.Errorf("row value mismatch for timestamp array\nGot: %v\nWant: %v", g, w)
+		}
+		if !runsOnEmulator() {
+			if g, w := jArray, []spanner.NullJSON{
+				nullJson(true, `{"key1": "value1", "other-key1": ["value1", "value2"]}`),
+				nullJson(false, ""),
+				nullJson(true, `{"key2": "value2", "other-key2": ["value1", "value2"]}`),
+			}; !cmp.Equal(g, w) {
+				t.Errorf("row value mismatch for json array\nGot: %v\nWant: %v", g, w)
+			}
+		}
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := len(req.ParamTypes), 20; g != w {
+		t.Fatalf("param types length mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(req.Params.Fields), 20; g != w {
+		t.Fatalf("params length mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	wantParams := []struct {
+		name  string
+		code  sppb.TypeCode
+		array bool
+		value interface{}
+	}{
+		{
+			name:  "bool",
+			code:  sppb.TypeCode_BOOL,
+			value: true,
+		},
+		{
+			name:  "string",
+			code:  sppb.TypeCode_STRING,
+			value: "test",
+		},
+		{
+			name:  "bytes",
+			code:  sppb.TypeCode_BYTES,
+			value: base64.StdEncoding.EncodeToString([]byte("testbytes")),
+		},
+		{
+			name:  "int64",
+			code:  sppb.TypeCode_INT64,
+			value: "5",
+		},
+		{
+			name:  "float32",
+			code:  sppb.TypeCode_FLOAT32,
+			value: float64(float32(3.14)),
+		},
+		{
+			name:  "float64",
+			code:  sppb.TypeCode_FLOAT64,
+			value: 3.14,
+		},
+		{
+			name:  "numeric",
+			code:  sppb.TypeCode_NUMERIC,
+			value: "6.626000000",
+		},
+		{
+			name:  "date",
+			code:  sppb.TypeCode_DATE,
+			value: "2021-07-21",
+		},
+		{
+			name:  "timestamp",
 			code:  sppb.TypeCode_TIMESTAMP,
+			value: "2021-07-22T10:26:17.123Z",
+		},
+		{
+			name:  "json",
+			code:  sppb.TypeCode_JSON,
+			value: `{"key":"value","other-key":["value1","value2"]}`,
+		},
+		{
+			name:  "boolArray",
+			code:  sppb.TypeCode_BOOL,
 			array: true,
 			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_StringValue{StringValue: "2021-07-21T21:07:59.3399118Z"}},
+				{Kind: &structpb.Value_BoolValue{BoolValue: true}},
 				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_StringValue{StringValue: "2021-07-27T21:07:59.3399118Z"}},
+				{Kind: &structpb.Value_BoolValue{BoolValue: false}},
 			}},
 		},
 		{
-			name:  "jsonArray",
-			code:  sppb.TypeCode_JSON,
+			name:  "stringArray",
+			code:  sppb.TypeCode_STRING,
 			array: true,
 			value: &structpb.ListValue{Values: []*structpb.Value{
-				{Kind: &structpb.Value_StringValue{StringValue: `{"key1":"value1","other-key1":["value1","value2"]}`}},
+				{Kind: &structpb.Value_StringValue{StringValue: "test1"}},
 				{Kind: &structpb.Value_NullValue{}},
-				{Kind: &structpb.Value_StringValue{StringValue: `{"key2":"value2","other-key2":["value1","value2"]}`}},
+				{Kind: &structpb.Value_StringValue{StringValue: "test2"}},
+			}},
+		},
+		{
+			name:  "bytesArray",
+			code:  sppb.TypeCode_BYTES,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_StringValue{StringValue: base64.StdEncoding.EncodeToString([]byte("testbytes1"))}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_StringValue{StringValue: base64.StdEncoding.EncodeToString([]byte("testbytes2"))}},
+			}},
+		},
+		{
+			name:  "int64Array",
+			code:  sppb.TypeCode_INT64,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_StringValue{StringValue: "1"}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_StringValue{StringValue: "2"}},
+			}},
+		},
+		{
+			name:  "float32Array",
+			code:  sppb.TypeCode_FLOAT32,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_NumberValue{NumberValue: float64(float32(3.14))}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_NumberValue{NumberValue: float64(float32(-99.99))}},
 			}},
 		},
+		{
+			name:  "float64Array",
+			code:  sppb.TypeCode_FLOAT64,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_NumberValue{NumberValue: 6.626}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_NumberValue{NumberValue: 10.01}},
+			}},
+		},
+		{
+			name:  "numericArray",
+			code:  sppb.TypeCode_NUMERIC,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_StringValue{StringValue: "3.140000000"}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_StringValue{StringValue: "10.010000000"}},
+			}},
+		},
+		{
+			name:  "dateArray",
+			code:  sppb.TypeCode_DATE,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_StringValue{StringValue: "2000-02-29"}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_StringValue{StringValue: "2021-07-27"}},
+			}},
+		},
+		{
+			name:  "timestampArray",
+			code:  sppb.TypeCode_TIMESTAMP,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_StringValue{StringValue: "2021-07-21T21:07:59.3399118Z"}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_StringValue{StringValue: "2021-07-27T21:07:59.3399118Z"}},
+			}},
+		},
+		{
+			name:  "jsonArray",
+			code:  sppb.TypeCode_JSON,
+			array: true,
+			value: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_StringValue{StringValue: `{"key1":"value1","other-key1":["value1","value2"]}`}},
+				{Kind: &structpb.Value_NullValue{}},
+				{Kind: &structpb.Value_StringValue{StringValue: `{"key2":"value2","other-key2":["value1","value2"]}`}},
+			}},
+		},
+	}
+	for _, wantParam := range wantParams {
+		if pt, ok := req.ParamTypes[wantParam.name]; ok {
+			if wantParam.array {
+				if g, w := pt.Code, sppb.TypeCode_ARRAY; g != w {
+					t.Errorf("param type mismatch\nGot: %v\nWant: %v", g, w)
+				}
+				if g, w := pt.ArrayElementType.Code, wantParam.code; g != w {
+					t.Errorf("param array element type mismatch\nGot: %v\nWant: %v", g, w)
+				}
+			} else {
+				if g, w := pt.Code, wantParam.code; g != w {
+					t.Errorf("param type mismatch\nGot: %v\nWant: %v", g, w)
+				}
+			}
+		} else {
+			t.Errorf("no param type found for @%s", wantParam.name)
+		}
+		if val, ok := req.Params.Fields[wantParam.name]; ok {
+			var g interface{}
+			if wantParam.array {
+				g = val.GetListValue()
+			} else {
+				switch wantParam.code {
+				case sppb.TypeCode_BOOL:
+					g = val.GetBoolValue()
+				case sppb.TypeCode_FLOAT32:
+					g = val.GetNumberValue()
+				case sppb.TypeCode_FLOAT64:
+					g = val.GetNumberValue()
+				default:
+					g = val.GetStringValue()
+				}
+			}
+			if wantParam.array {
+				if !cmp.Equal(g, wantParam.value, cmpopts.IgnoreUnexported(structpb.ListValue{}, structpb.Value{})) {
+					t.Errorf("array param value mismatch\nGot:  %v\nWant: %v", g, wantParam.value)
+				}
+			} else {
+				if g != wantParam.value {
+					t.Errorf("param value mismatch\nGot: %v\nWant: %v", g, wantParam.value)
+				}
+			}
+		} else {
+			t.Errorf("no value found for param @%s", wantParam.name)
+		}
+	}
+}
+
+func TestQueryWithNullParameters(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	query := `SELECT *
+             FROM Test
+             WHERE ColBool=@bool 
+             AND   ColString=@string
+             AND   ColBytes=@bytes
+             AND   ColInt=@int64
+             AND   ColFloat32=@float32
+             AND   ColFloat64=@float64
+             AND   ColNumeric=@numeric
+             AND   ColDate=@date
+             AND   ColTimestamp=@timestamp
+             AND   ColJson=@json
+             AND   ColBoolArray=@boolArray
+             AND   ColStringArray=@stringArray
+             AND   ColBytesArray=@bytesArray
+             AND   ColIntArray=@int64Array
+             AND   ColFloat32Array=@float32Array
+             AND   ColFloat64Array=@float64Array
+             AND   ColNumericArray=@numericArray
+             AND   ColDateArray=@dateArray
+             AND   ColTimestampArray=@timestampArray
+             AND   ColJsonArray=@jsonArray`
+	_ = server.TestSpanner.PutStatementResult(
+		query,
+		&testutil.StatementResult{
+			Type:      testutil.StatementResultResultSet,
+			ResultSet: testutil.CreateResultSetWithAllTypes(true),
+		},
+	)
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, p := range []struct {
+		typed  int
+		values []interface{}
+	}{
+		{
+			typed: 0,
+			values: []interface{}{
+				nil, // bool
+				nil, // string
+				nil, // bytes
+				nil, // int64
+				nil, // float32
+				nil, // float64
+				nil, // numeric
+				nil, // date
+				nil, // timestamp
+				nil, // json
+				nil, // bool array
+				nil, // string array
+				nil, // bytes array
+				nil, // int64 array
+				nil, // float32 array
+				nil, // float64 array
+				nil, // numeric array
+				nil, // date array
+				nil, // timestamp array
+				nil, // json array
+			}},
+		{
+			typed: 9,
+			values: []interface{}{
+				spanner.NullBool{},
+				spanner.NullString{},
+				nil, // bytes
+				spanner.NullInt64{},
+				spanner.NullFloat32{},
+				spanner.NullFloat64{},
+				spanner.NullNumeric{},
+				spanner.NullDate{},
+				spanner.NullTime{},
+				spanner.NullJSON{},
+				nil, // bool array
+				nil, // string array
+				nil, // bytes array
+				nil, // int64 array
+				nil, // float32 array
+				nil, // float64 array
+				nil, // numeric array
+				nil, // date array
+				nil, // timestamp array
+				nil, // json array
+			}},
+	} {
+		rows, err := stmt.QueryContext(context.Background(), p.values...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var b sql.NullBool
+			var s sql.NullString
+			var bt []byte
+			var i sql.NullInt64
+			var f32 spanner.NullFloat32 // There's no equivalent sql type.
+			var f sql.NullFloat64
+			var r spanner.NullNumeric // There's no equivalent sql type.
+			var d spanner.NullDate    // There's no equivalent sql type.
+			var ts sql.NullTime
+			var j spanner.NullJSON // There's no equivalent sql type.
+			var bArray []spanner.NullBool
+			var sArray []spanner.NullString
+			var btArray [][]byte
+			var iArray []spanner.NullInt64
+			var f32Array []spanner.NullFloat32
+			var fArray []spanner.NullFloat64
+			var rArray []spanner.NullNumeric
+			var dArray []spanner.NullDate
+			var tsArray []spanner.NullTime
+			var jArray []spanner.NullJSON
+			err = rows.Scan(&b, &s, &bt, &i, &f32, &f, &r, &d, &ts, &j, &bArray, &sArray, &btArray, &iArray, &f32Array, &fArray, &rArray, &dArray, &tsArray, &jArray)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Valid {
+				t.Errorf("row value mismatch for bool\nGot: %v\nWant: %v", b, spanner.NullBool{})
+			}
+			if s.Valid {
+				t.Errorf("row value mismatch for string\nGot: %v\nWant: %v", s, spanner.NullString{})
+			}
+			if bt != nil {
+				t.Errorf("row value mismatch for bytes\nGot: %v\nWant: %v", bt, nil)
+			}
+			if i.Valid {
+				t.Errorf("row value mismatch for int64\nGot: %v\nWant: %v", i, spanner.NullInt64{})
+			}
+			if f32.Valid {
+				t.Errorf("row value mismatch for float32\nGot: %v\nWant: %v", f, spanner.NullFloat32{})
+			}
+			if f.Valid {
+				t.Errorf("row value mismatch for float64\nGot: %v\nWant: %v", f, spanner.NullFloat64{})
+			}
+			if r.Valid {
+				t.Errorf("row value mismatch for numeric\nGot: %v\nWant: %v", r, spanner.NullNumeric{})
+			}
+			if d.Valid {
+				t.Errorf("row value mismatch for date\nGot: %v\nWant: %v", d, spanner.NullDate{})
+			}
+			if ts.Valid {
+				t.Errorf("row value mismatch for timestamp\nGot: %v\nWant: %v", ts, spanner.NullTime{})
+			}
+			if j.Valid {
+				t.Errorf("row value mismatch for json\nGot: %v\nWant: %v", j, spanner.NullJSON{})
+			}
+			if bArray != nil {
+				t.Errorf("row value mismatch for bool array\nGot: %v\nWant: %v", bArray, nil)
+			}
+			if sArray != nil {
+				t.Errorf("row value mismatch for string array\nGot: %v\nWant: %v", sArray, nil)
+			}
+			if btArray != nil {
+				t.Errorf("row value mismatch for bytes array array\nGot: %v\nWant: %v", btArray, nil)
+			}
+			if iArray != nil {
+				t.Errorf("row value mismatch for int64 array\nGot: %v\nWant: %v", iArray, nil)
+			}
+			if f32Array != nil {
+				t.Errorf("row value mismatch for float32 array\nGot: %v\nWant: %v", f32Array, nil)
+			}
+			if fArray != nil {
+				t.Errorf("row value mismatch for float64 array\nGot: %v\nWant: %v", fArray, nil)
+			}
+			if rArray != nil {
+				t.Errorf("row value mismatch for numeric array\nGot: %v\nWant: %v", rArray, nil)
+			}
+			if dArray != nil {
+				t.Errorf("row value mismatch for date array\nGot: %v\nWant: %v", dArray, nil)
+			}
+			if tsArray != nil {
+				t.Errorf("row value mismatch for timestamp array\nGot: %v\nWant: %v", tsArray, nil)
+			}
+			if jArray != nil {
+				t.Errorf("row value mismatch for json array\nGot: %v\nWant: %v", jArray, nil)
+			}
+		}
+		if rows.Err() != nil {
+			t.Fatal(rows.Err())
+		}
+		requests := drainRequestsFromServer(server.TestSpanner)
+		sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		if g, w := len(sqlRequests), 1; g != w {
+			t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+		// The param types map should be empty when we are only sending untyped nil params.
+		if g, w := len(req.ParamTypes), p.typed; g != w {
+			t.Fatalf("param types length mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := len(req.Params.Fields), 20; g != w {
+			t.Fatalf("params length mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		for _, param := range req.Params.Fields {
+			if _, ok := param.GetKind().(*structpb.Value_NullValue); !ok {
+				t.Errorf("param value mismatch\nGot: %v\nWant: %v", param.GetKind(), structpb.Value_NullValue{})
+			}
+		}
+	}
+}
+
+func TestDmlInAutocommit(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	res, err := db.ExecContext(context.Background(), testutil.UpdateBarSetFoo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := affected, int64(testutil.UpdateBarSetFooRowCount); g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The DML statement should use a transaction even though no explicit
+	// transaction was created.
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if req.Transaction == nil {
+		t.Fatalf("missing transaction for ExecuteSqlRequest")
+	}
+	if _, ok := req.Transaction.Selector.(*sppb.TransactionSelector_Begin); !ok {
+		t.Fatalf("unsupported transaction type %T", req.Transaction.Selector)
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	commitReq := commitRequests[0].(*sppb.CommitRequest)
+	if commitReq.GetTransactionId() == nil {
+		t.Fatalf("missing id selector for CommitRequest")
+	}
+}
+
+func TestQueryWithDuplicateNamedParameter(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	s := "insert into users (id, name) values (@name, @name)"
+	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	_, err := db.Exec(s, sql.Named("name", "foo"), sql.Named("name", "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Verify that 'bar' is used for both instances of the parameter @name.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if len(sqlRequests) != 1 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := len(req.Params.Fields), 1; g != w {
+		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields["name"].GetStringValue(), "bar"; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryWithReusedNamedParameter(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	s := "insert into users (id, name) values (@name, @name)"
+	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	_, err := db.Exec(s, sql.Named("name", "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Verify that 'foo' is used for both instances of the parameter @name.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if len(sqlRequests) != 1 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := len(req.Params.Fields), 1; g != w {
+		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields["name"].GetStringValue(), "foo"; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryWithReusedPositionalParameter(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	s := "insert into users (id, name) values (@name, @name)"
+	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	_, err := db.Exec(s, "foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Verify that 'bar' is used for both instances of the parameter @name.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if len(sqlRequests) != 1 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := len(req.Params.Fields), 1; g != w {
+		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields["name"].GetStringValue(), "bar"; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryWithMissingPositionalParameter(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	s := "insert into users (id, name) values (@name, @name)"
+	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	_, err := db.Exec(s, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Verify that 'foo' is used for the parameter @name.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if len(sqlRequests) != 1 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := len(req.Params.Fields), 1; g != w {
+		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields["name"].GetStringValue(), "foo"; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestDdlInAutocommit(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	var expectedResponse = &emptypb.Empty{}
+	any, _ := anypb.New(expectedResponse)
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: any},
+			Name:   "test-operation",
+		},
+	})
+	query := "CREATE TABLE Singers (SingerId INT64, FirstName STRING(100), LastName STRING(100)) PRIMARY KEY (SingerId)"
+	_, err := db.ExecContext(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if req, ok := requests[0].(*databasepb.UpdateDatabaseDdlRequest); ok {
+		if g, w := len(req.Statements), 1; g != w {
+			t.Fatalf("statement count mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := req.Statements[0], query; g != w {
+			t.Fatalf("statement mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	} else {
+		t.Fatalf("request type mismatch, got %v", requests[0])
+	}
+}
+
+func TestDdlInTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	query := "CREATE TABLE Singers (SingerId INT64, FirstName STRING(100), LastName STRING(100)) PRIMARY KEY (SingerId)"
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(context.Background(), query); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error mismatch\nGot:  %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 0; g != w {
+		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestExecOptionsStatementType(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// A statement that starts with a DDL keyword is sent to Spanner as DML.
+	dml := "CREATE_AUDIT_ENTRY_UPDATE"
+	_ = server.TestSpanner.PutStatementResult(dml, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	res, err := db.ExecContext(ctx, dml, ExecOptions{StatementType: StatementTypeDML})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := res.RowsAffected(); c != 1 {
+		t.Fatalf("rows affected mismatch\n Got: %v\nWant: %v", c, 1)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// A statement that is not recognized as DDL is sent to the admin API.
+	any, _ := anypb.New(&emptypb.Empty{})
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: any},
+			Name:   "test-operation",
+		},
+	})
+	ddl := "GRANT SELECT ON TABLE Singers TO ROLE analyst"
+	if _, err := db.ExecContext(ctx, ddl, ExecOptions{StatementType: StatementTypeDDL}); err != nil {
+		t.Fatal(err)
+	}
+	adminRequests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(adminRequests), 1; g != w {
+		t.Fatalf("admin requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := adminRequests[0].(*databasepb.UpdateDatabaseDdlRequest).Statements, []string{ddl}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("ddl statements mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// A query that looks like a client-side statement is sent to Spanner.
+	query := "SHOW VARIABLE RETRY_ABORTS_INTERNALLY"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateSingleColumnResultSet([]int64{42}, "Value"),
+	})
+	var value int64
+	if err := db.QueryRowContext(ctx, query, ExecOptions{StatementType: StatementTypeQuery}).Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := value, int64(42); g != w {
+		t.Fatalf("value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests = requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Statement types that are not supported by the method return an error.
+	if _, err := db.ExecContext(ctx, dml, ExecOptions{StatementType: StatementTypeQuery}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if _, err := db.QueryContext(ctx, ddl, ExecOptions{StatementType: StatementTypeDDL}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if _, err := db.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = false", ExecOptions{StatementType: StatementTypeSet}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestBegin(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// Ensure that the old Begin method works.
+	_, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// Ensure that the old Query method works.
+	rows, err := db.Query(testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer rows.Close()
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// Ensure that the old Exec method works.
+	_, err := db.Exec(testutil.UpdateBarSetFoo)
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// Ensure that the old Prepare method works.
+	_, err := db.Prepare(testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+}
+
+func TestApplyMutations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	var commitTimestamp time.Time
+	if err := conn.Raw(func(driverConn interface{}) error {
+		spannerConn, ok := driverConn.(SpannerConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
+		}
+		commitTimestamp, err = spannerConn.Apply(ctx, []*spanner.Mutation{
+			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
+			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
+		})
+		return err
+	}); err != nil {
+		t.Fatalf("failed to apply mutations: %v", err)
+	}
+	if commitTimestamp.Equal(time.Time{}) {
+		t.Fatal("no commit timestamp returned")
+	}
+
+	// Even though the Apply method is used outside a transaction, the connection will internally start a read/write
+	// transaction for the mutations.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	commitRequest := commitRequests[0].(*sppb.CommitRequest)
+	if g, w := len(commitRequest.Mutations), 2; g != w {
+		t.Fatalf("mutation count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestApplyMutationsFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	con, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	_, err = con.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	if g, w := spanner.ErrCode(con.Raw(func(driverConn interface{}) error {
+		spannerConn, ok := driverConn.(SpannerConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
+		}
+		_, err = spannerConn.Apply(ctx, []*spanner.Mutation{
+			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
+			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
+		})
+		return err
+	})), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch for Apply during transaction\nGot:  %v\nWant: %v", g, w)
+	}
+}
+
+func TestBufferWriteMutations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	con, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	tx, err := con.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	if err := con.Raw(func(driverConn interface{}) error {
+		spannerConn, ok := driverConn.(SpannerConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
+		}
+		return spannerConn.BufferWrite([]*spanner.Mutation{
+			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
+			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
+		})
+	}); err != nil {
+		t.Fatalf("failed to buffer mutations: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	commitRequest := commitRequests[0].(*sppb.CommitRequest)
+	if g, w := len(commitRequest.Mutations), 2; g != w {
+		t.Fatalf("mutation count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBufferWriteMutationsAutocommit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	con, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	defer con.Close()
+	bufferWrite := func() error {
+		return con.Raw(func(driverConn interface{}) error {
+			spannerConn, ok := driverConn.(SpannerConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
+			}
+			return spannerConn.BufferWrite([]*spanner.Mutation{
+				spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
+				spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
+			})
+		})
+	}
+	// The mutations are applied directly when the connection is not in a transaction.
+	if err := bufferWrite(); err != nil {
+		t.Fatalf("failed to write mutations: %v", err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(commitRequests[0].(*sppb.CommitRequest).Mutations), 2; g != w {
+		t.Fatalf("mutation count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if err := con.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).CommitTimestamp()
+		return err
+	}); err != nil {
+		t.Fatalf("missing commit timestamp: %v", err)
+	}
+
+	// Read-only transactions cannot write mutations.
+	tx, err := con.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := spanner.ErrCode(bufferWrite()), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch for BufferWrite in read-only transaction\nGot:  %v\nWant: %v", g, w)
+	}
+	_ = tx.Rollback()
+}
+
+func TestBufferWriteContextAutocommit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	con, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	defer con.Close()
+	// The context is used for the transaction that applies the mutations when
+	// the connection is not in a transaction.
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = con.Raw(func(driverConn interface{}) error {
+		return driverConn.(SpannerConn).BufferWriteContext(cancelledCtx, []*spanner.Mutation{
+			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
+		})
+	})
+	if g, w := spanner.ErrCode(err), codes.Canceled; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 0; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// Ensure that the old Ping method works.
+	err := db.Ping()
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+}
+
+func TestDdlBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	var expectedResponse = &emptypb.Empty{}
+	any, _ := anypb.New(expectedResponse)
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: any},
+			Name:   "test-operation",
+		},
+	})
+
+	statements := []string{"CREATE TABLE FOO", "CREATE TABLE BAR"}
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "START BATCH DDL"); err != nil {
+		t.Fatalf("failed to start DDL batch: %v", err)
+	}
+	for _, stmt := range statements {
+		if _, err = conn.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("failed to execute statement in DDL batch: %v", err)
+		}
+	}
+	if _, err = conn.ExecContext(ctx, "RUN BATCH"); err != nil {
+		t.Fatalf("failed to run DDL batch: %v", err)
+	}
+
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-	for _, wantParam := range wantParams {
-		if pt, ok := req.ParamTypes[wantParam.name]; ok {
-			if wantParam.array {
-				if g, w := pt.Code, sppb.TypeCode_ARRAY; g != w {
-					t.Errorf("param type mismatch\nGot: %v\nWant: %v", g, w)
-				}
-				if g, w := pt.ArrayElementType.Code, wantParam.code; g != w {
-					t.Errorf("param array element type mismatch\nGot: %v\nWant: %v", g, w)
-				}
-			} else {
-				if g, w := pt.Code, wantParam.code; g != w {
-					t.Errorf("param type mismatch\nGot: %v\nWant: %v", g, w)
-				}
-			}
-		} else {
-			t.Errorf("no param type found for @%s", wantParam.name)
+	if req, ok := requests[0].(*databasepb.UpdateDatabaseDdlRequest); ok {
+		if g, w := len(req.Statements), len(statements); g != w {
+			t.Fatalf("statement count mismatch\nGot: %v\nWant: %v", g, w)
 		}
-		if val, ok := req.Params.Fields[wantParam.name]; ok {
-			var g interface{}
-			if wantParam.array {
-				g = val.GetListValue()
-			} else {
-				switch wantParam.code {
-				case sppb.TypeCode_BOOL:
-					g = val.GetBoolValue()
-				case sppb.TypeCode_FLOAT32:
-					g = val.GetNumberValue()
-				case sppb.TypeCode_FLOAT64:
-					g = val.GetNumberValue()
-				default:
-					g = val.GetStringValue()
-				}
-			}
-			if wantParam.array {
-				if !cmp.Equal(g, wantParam.value, cmpopts.IgnoreUnexported(structpb.ListValue{}, structpb.Value{})) {
-					t.Errorf("array param value mismatch\nGot:  %v\nWant: %v", g, wantParam.value)
-				}
-			} else {
-				if g != wantParam.value {
-					t.Errorf("param value mismatch\nGot: %v\nWant: %v", g, wantParam.value)
-				}
+		for i, stmt := range statements {
+			if g, w := req.Statements[i], stmt; g != w {
+				t.Fatalf("statement mismatch\nGot: %v\nWant: %v", g, w)
 			}
-		} else {
-			t.Errorf("no value found for param @%s", wantParam.name)
 		}
+	} else {
+		t.Fatalf("request type mismatch, got %v", requests[0])
 	}
 }
 
-func TestQueryWithNullParameters(t *testing.T) {
+func TestAbortDdlBatch(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-	query := `SELECT *
-             FROM Test
-             WHERE ColBool=@bool 
-             AND   ColString=@string
-             AND   ColBytes=@bytes
-             AND   ColInt=@int64
-             AND   ColFloat32=@float32
-             AND   ColFloat64=@float64
-             AND   ColNumeric=@numeric
-             AND   ColDate=@date
-             AND   ColTimestamp=@timestamp
-             AND   ColJson=@json
-             AND   ColBoolArray=@boolArray
-             AND   ColStringArray=@stringArray
-             AND   ColBytesArray=@bytesArray
-             AND   ColIntArray=@int64Array
-             AND   ColFloat32Array=@float32Array
-             AND   ColFloat64Array=@float64Array
-             AND   ColNumericArray=@numericArray
-             AND   ColDateArray=@dateArray
-             AND   ColTimestampArray=@timestampArray
-             AND   ColJsonArray=@jsonArray`
-	_ = server.TestSpanner.PutStatementResult(
-		query,
-		&testutil.StatementResult{
-			Type:      testutil.StatementResultResultSet,
-			ResultSet: testutil.CreateResultSetWithAllTypes(true),
-		},
-	)
 
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		t.Fatal(err)
+	statements := []string{"CREATE TABLE FOO", "CREATE TABLE BAR"}
+	c, err := db.Conn(ctx)
+	defer c.Close()
+
+	if _, err = c.ExecContext(ctx, "START BATCH DDL"); err != nil {
+		t.Fatalf("failed to start DDL batch: %v", err)
 	}
-	defer stmt.Close()
-	for _, p := range []struct {
-		typed  int
-		values []interface{}
-	}{
-		{
-			typed: 0,
-			values: []interface{}{
-				nil, // bool
-				nil, // string
-				nil, // bytes
-				nil, // int64
-				nil, // float32
-				nil, // float64
-				nil, // numeric
-				nil, // date
-				nil, // timestamp
-				nil, // json
-				nil, // bool array
-				nil, // string array
-				nil, // bytes array
-				nil, // int64 array
-				nil, // float32 array
-				nil, // float64 array
-				nil, // numeric array
-				nil, // date array
-				nil, // timestamp array
-				nil, // json array
-			}},
-		{
-			typed: 9,
-			values: []interface{}{
-				spanner.NullBool{},
-				spanner.NullString{},
-				nil, // bytes
-				spanner.NullInt64{},
-				spanner.NullFloat32{},
-				spanner.NullFloat64{},
-				spanner.NullNumeric{},
-				spanner.NullDate{},
-				spanner.NullTime{},
-				spanner.NullJSON{},
-				nil, // bool array
-				nil, // string array
-				nil, // bytes array
-				nil, // int64 array
-				nil, // float32 array
-				nil, // float64 array
-				nil, // numeric array
-				nil, // date array
-				nil, // timestamp array
-				nil, // json array
-			}},
-	} {
-		rows, err := stmt.QueryContext(context.Background(), p.values...)
-		if err != nil {
-			t.Fatal(err)
+	for _, stmt := range statements {
+		if _, err = c.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("failed to execute statement in DDL batch: %v", err)
 		}
-		defer rows.Close()
+	}
+	// Check that the statements have been batched.
+	_ = c.Raw(func(driverConn interface{}) error {
+		conn := driverConn.(*conn)
+		if conn.batch == nil {
+			t.Fatalf("missing batch on connection")
+		}
+		if g, w := len(conn.batch.statements), 2; g != w {
+			t.Fatalf("batch length mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		return nil
+	})
 
-		for rows.Next() {
-			var b sql.NullBool
-			var s sql.NullString
-			var bt []byte
-			var i sql.NullInt64
-			var f32 spanner.NullFloat32 // There's no equivalent sql type.
-			var f sql.NullFloat64
-			var r spanner.NullNumeric // There's no equivalent sql type.
-			var d spanner.NullDate    // There's no equivalent sql type.
-			var ts sql.NullTime
-			var j spanner.NullJSON // There's no equivalent sql type.
-			var bArray []spanner.NullBool
-			var sArray []spanner.NullString
-			var btArray [][]byte
-			var iArray []spanner.NullInt64
-			var f32Array []spanner.NullFloat32
-			var fArray []spanner.NullFloat64
-			var rArray []spanner.NullNumeric
-			var dArray []spanner.NullDate
-			var tsArray []spanner.NullTime
-			var jArray []spanner.NullJSON
-			err = rows.Scan(&b, &s, &bt, &i, &f32, &f, &r, &d, &ts, &j, &bArray, &sArray, &btArray, &iArray, &f32Array, &fArray, &rArray, &dArray, &tsArray, &jArray)
-			if err != nil {
-				t.Fatal(err)
-			}
-			if b.Valid {
-				t.Errorf("row value mismatch for bool\nGot: %v\nWant: %v", b, spanner.NullBool{})
-			}
-			if s.Valid {
-				t.Errorf("row value mismatch for string\nGot: %v\nWant: %v", s, spanner.NullString{})
-			}
-			if bt != nil {
-				t.Errorf("row value mismatch for bytes\nGot: %v\nWant: %v", bt, nil)
-			}
-			if i.Valid {
-				t.Errorf("row value mismatch for int64\nGot: %v\nWant: %v", i, spanner.NullInt64{})
-			}
-			if f32.Valid {
-				t.Errorf("row value mismatch for float32\nGot: %v\nWant: %v", f, spanner.NullFloat32{})
-			}
-			if f.Valid {
-				t.Errorf("row value mismatch for float64\nGot: %v\nWant: %v", f, spanner.NullFloat64{})
-			}
-			if r.Valid {
-				t.Errorf("row value mismatch for numeric\nGot: %v\nWant: %v", r, spanner.NullNumeric{})
-			}
-			if d.Valid {
-				t.Errorf("row value mismatch for date\nGot: %v\nWant: %v", d, spanner.NullDate{})
-			}
-			if ts.Valid {
-				t.Errorf("row value mismatch for timestamp\nGot: %v\nWant: %v", ts, spanner.NullTime{})
-			}
-			if j.Valid {
-				t.Errorf("row value mismatch for json\nGot: %v\nWant: %v", j, spanner.NullJSON{})
-			}
-			if bArray != nil {
-				t.Errorf("row value mismatch for bool array\nGot: %v\nWant: %v", bArray, nil)
-			}
-			if sArray != nil {
-				t.Errorf("row value mismatch for string array\nGot: %v\nWant: %v", sArray, nil)
-			}
-			if btArray != nil {
-				t.Errorf("row value mismatch for bytes array array\nGot: %v\nWant: %v", btArray, nil)
-			}
-			if iArray != nil {
-				t.Errorf("row value mismatch for int64 array\nGot: %v\nWant: %v", iArray, nil)
-			}
-			if f32Array != nil {
-				t.Errorf("row value mismatch for float32 array\nGot: %v\nWant: %v", f32Array, nil)
-			}
-			if fArray != nil {
-				t.Errorf("row value mismatch for float64 array\nGot: %v\nWant: %v", fArray, nil)
-			}
-			if rArray != nil {
-				t.Errorf("row value mismatch for numeric array\nGot: %v\nWant: %v", rArray, nil)
-			}
-			if dArray != nil {
-				t.Errorf("row value mismatch for date array\nGot: %v\nWant: %v", dArray, nil)
-			}
-			if tsArray != nil {
-				t.Errorf("row value mismatch for timestamp array\nGot: %v\nWant: %v", tsArray, nil)
-			}
-			if jArray != nil {
-				t.Errorf("row value mismatch for json array\nGot: %v\nWant: %v", jArray, nil)
-			}
+	if _, err = c.ExecContext(ctx, "ABORT BATCH"); err != nil {
+		t.Fatalf("failed to abort DDL batch: %v", err)
+	}
+
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 0; g != w {
+		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	_ = c.Raw(func(driverConn interface{}) error {
+		spannerConn := driverConn.(SpannerConn)
+		if spannerConn.InDDLBatch() {
+			t.Fatalf("connection still has an active DDL batch")
 		}
-		if rows.Err() != nil {
-			t.Fatal(rows.Err())
+		return nil
+	})
+}
+
+func TestRunDdlBatch_EmptyAndFailed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// An empty batch does not send any request to Spanner.
+	if _, err := c.ExecContext(ctx, "START BATCH DDL"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "RUN BATCH"); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(server.TestDatabaseAdmin.Reqs()), 0; g != w {
+		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// RUN BATCH returns the error of the operation, and the batch is
+	// cleared from the connection.
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Done:   true,
+			Result: &longrunningpb.Operation_Error{Error: gstatus.New(codes.FailedPrecondition, "table already exists").Proto()},
+			Name:   "test-operation",
+		},
+	})
+	if _, err := c.ExecContext(ctx, "START BATCH DDL"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "CREATE TABLE FOO"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "RUN BATCH"); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	if g, w := len(server.TestDatabaseAdmin.Reqs()), 1; g != w {
+		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	_ = c.Raw(func(driverConn interface{}) error {
+		if driverConn.(SpannerConn).InDDLBatch() {
+			t.Fatalf("connection still has an active DDL batch")
 		}
-		requests := drainRequestsFromServer(server.TestSpanner)
-		sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-		if g, w := len(sqlRequests), 1; g != w {
-			t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", g, w)
+		return nil
+	})
+}
+
+func TestShowAndSetVariableRetryAbortsInternally(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to obtain a connection: %v", err)
+	}
+	defer c.Close()
+
+	for _, tc := range []struct {
+		expected bool
+		set      bool
+	}{
+		{expected: true, set: false},
+		{expected: false, set: true},
+		{expected: true, set: true},
+	} {
+		// Get the current value.
+		rows, err := c.QueryContext(ctx, "SHOW VARIABLE RETRY_ABORTS_INTERNALLY")
+		if err != nil {
+			t.Fatalf("failed to execute get variable retry_aborts_internally: %v", err)
 		}
-		req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-		// The param types map should be empty when we are only sending untyped nil params.
-		if g, w := len(req.ParamTypes), p.typed; g != w {
-			t.Fatalf("param types length mismatch\nGot: %v\nWant: %v", g, w)
+		defer rows.Close()
+		for rows.Next() {
+			var retry bool
+			if err := rows.Scan(&retry); err != nil {
+				t.Fatalf("failed to scan value for retry_aborts_internally: %v", err)
+			}
+			if g, w := retry, tc.expected; g != w {
+				t.Fatalf("retry_aborts_internally mismatch\nGot: %v\nWant: %v", g, w)
+			}
 		}
-		if g, w := len(req.Params.Fields), 20; g != w {
-			t.Fatalf("params length mismatch\nGot: %v\nWant: %v", g, w)
+		if err := rows.Err(); err != nil {
+			t.Fatalf("failed to iterate over result for get variable retry_aborts_internally: %v", err)
 		}
-		for _, param := range req.Params.Fields {
-			if _, ok := param.GetKind().(*structpb.Value_NullValue); !ok {
-				t.Errorf("param value mismatch\nGot: %v\nWant: %v", param.GetKind(), structpb.Value_NullValue{})
-			}
+
+		// Check that the behavior matches the setting.
+		tx, _ := c.BeginTx(ctx, nil)
+		server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+			Errors: []error{gstatus.Error(codes.Aborted, "Aborted")},
+		})
+		err = tx.Commit()
+		if tc.expected && err != nil {
+			t.Fatalf("unexpected error for commit: %v", err)
+		} else if !tc.expected && spanner.ErrCode(err) != codes.Aborted {
+			t.Fatalf("error code mismatch\nGot: %v\nWant: %v", spanner.ErrCode(err), codes.Aborted)
 		}
+
+		// Set a new value for the variable.
+		if _, err := c.ExecContext(ctx, fmt.Sprintf("SET RETRY_ABORTS_INTERNALLY = %v", tc.set)); err != nil {
+			t.Fatalf("failed to set value for retry_aborts_internally: %v", err)
+		}
+	}
+
+	// Verify that the value cannot be set during a transaction.
+	tx, _ := c.BeginTx(ctx, nil)
+	defer tx.Rollback()
+	_, err = c.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = TRUE")
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch for setting retry_aborts_internally during a transaction\nGot: %v\nWant: %v", g, w)
 	}
 }
 
-func TestDmlInAutocommit(t *testing.T) {
+func TestPartitionedDml(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-	res, err := db.ExecContext(context.Background(), testutil.UpdateBarSetFoo)
+
+	c, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("failed to obtain a connection: %v", err)
 	}
-	affected, err := res.RowsAffected()
+	defer c.Close()
+
+	if _, err := c.ExecContext(ctx, "set autocommit_dml_mode = 'Partitioned_Non_Atomic'"); err != nil {
+		t.Fatalf("could not set autocommit dml mode: %v", err)
+	}
+
+	server.TestSpanner.PutStatementResult("DELETE FROM Foo WHERE TRUE", &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 200,
+	})
+	// The following statement should be executed using PDML instead of DML.
+	res, err := c.ExecContext(ctx, "DELETE FROM Foo WHERE TRUE")
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("could not execute DML statement: %v", err)
 	}
-	if g, w := affected, int64(testutil.UpdateBarSetFooRowCount); g != w {
-		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	affected, _ := res.RowsAffected()
+	if affected != 200 {
+		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 200)
 	}
 	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	var beginPdml *sppb.BeginTransactionRequest
+	for _, req := range beginRequests {
+		if req.(*sppb.BeginTransactionRequest).Options.GetPartitionedDml() != nil {
+			beginPdml = req.(*sppb.BeginTransactionRequest)
+			break
+		}
+	}
+	if beginPdml == nil {
+		t.Fatal("no begin request for Partitioned DML found")
+	}
 	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if g, w := len(sqlRequests), 1; g != w {
-		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
+	if len(sqlRequests) != 1 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
 	}
-	// The DML statement should use a transaction even though no explicit
-	// transaction was created.
 	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if req.Transaction == nil {
-		t.Fatalf("missing transaction for ExecuteSqlRequest")
+	if req.Transaction == nil || req.Transaction.GetId() == nil {
+		t.Fatal("missing transaction id for sql request")
 	}
-	if _, ok := req.Transaction.Selector.(*sppb.TransactionSelector_Begin); !ok {
-		t.Fatalf("unsupported transaction type %T", req.Transaction.Selector)
+	if !server.TestSpanner.IsPartitionedDmlTransaction(req.Transaction.GetId()) {
+		t.Fatalf("sql request did not use a PDML transaction")
+	}
+}
+
+func TestAutocommitBatchDml(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to obtain a connection: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.ExecContext(ctx, "START BATCH DML"); err != nil {
+		t.Fatalf("could not start a DML batch: %v", err)
+	}
+	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (1, 'One')", &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (2, 'Two')", &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+
+	// The following statements should be batched locally and only sent to Spanner once
+	// 'RUN BATCH' is executed.
+	res, err := c.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (1, 'One')")
+	if err != nil {
+		t.Fatalf("could not execute DML statement: %v", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected != 0 {
+		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	}
+	res, err = c.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (2, 'Two')")
+	if err != nil {
+		t.Fatalf("could not execute DML statement: %v", err)
+	}
+	affected, _ = res.RowsAffected()
+	if affected != 0 {
+		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	}
+
+	// There should be no ExecuteSqlRequest statements on the server.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if len(sqlRequests) != 0 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
+	}
+	batchRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if len(batchRequests) != 0 {
+		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 0)
+	}
+
+	// Execute a RUN BATCH statement. This should trigger a BatchDML request followed by a Commit request.
+	res, err = c.ExecContext(ctx, "RUN BATCH")
+	if err != nil {
+		t.Fatalf("failed to execute RUN BATCH: %v", err)
+	}
+	affected, err = res.RowsAffected()
+	if err != nil {
+		t.Fatalf("could not get rows affected from batch: %v", err)
+	}
+	if affected != 2 {
+		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 2)
+	}
+
+	requests = drainRequestsFromServer(server.TestSpanner)
+	// There should still be no ExecuteSqlRequests on the server.
+	sqlRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if len(sqlRequests) != 0 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
+	}
+	batchRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if len(batchRequests) != 1 {
+		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 1)
 	}
+	// The transaction should also have been committed.
 	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
-	if g, w := len(commitRequests), 1; g != w {
-		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
-	}
-	commitReq := commitRequests[0].(*sppb.CommitRequest)
-	if commitReq.GetTransactionId() == nil {
-		t.Fatalf("missing id selector for CommitRequest")
+	if len(commitRequests) != 1 {
+		t.Fatalf("Commit requests count mismatch\nGot: %v\nWant: %v", len(commitRequests), 1)
 	}
 }
 
-func TestQueryWithDuplicateNamedParameter(t *testing.T) {
+func TestTransactionBatchDml(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	s := "insert into users (id, name) values (@name, @name)"
-	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "START BATCH DML"); err != nil {
+		t.Fatalf("could not start a DML batch: %v", err)
+	}
+	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (1, 'One')", &testutil.StatementResult{
 		Type:        testutil.StatementResultUpdateCount,
 		UpdateCount: 1,
 	})
-	_, err := db.Exec(s, sql.Named("name", "foo"), sql.Named("name", "bar"))
+	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (2, 'Two')", &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+
+	// The following statements should be batched locally and only sent to Spanner once
+	// 'RUN BATCH' is executed.
+	res, err := tx.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (1, 'One')")
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("could not execute DML statement: %v", err)
 	}
-	// Verify that 'bar' is used for both instances of the parameter @name.
+	affected, _ := res.RowsAffected()
+	if affected != 0 {
+		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	}
+	res, err = tx.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (2, 'Two')")
+	if err != nil {
+		t.Fatalf("could not execute DML statement: %v", err)
+	}
+	affected, _ = res.RowsAffected()
+	if affected != 0 {
+		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	}
+
+	// There should be no ExecuteSqlRequest statements on the server.
 	requests := drainRequestsFromServer(server.TestSpanner)
 	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 1 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
-	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if g, w := len(req.Params.Fields), 1; g != w {
-		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	if len(sqlRequests) != 0 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
 	}
-	if g, w := req.Params.Fields["name"].GetStringValue(), "bar"; g != w {
-		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	batchRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if len(batchRequests) != 0 {
+		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 0)
 	}
-}
 
-func TestQueryWithReusedNamedParameter(t *testing.T) {
-	t.Parallel()
+	// Execute a RUN BATCH statement. This should trigger a BatchDML request.
+	res, err = tx.ExecContext(ctx, "RUN BATCH")
+	if err != nil {
+		t.Fatalf("failed to execute RUN BATCH: %v", err)
+	}
+	affected, err = res.RowsAffected()
+	if err != nil {
+		t.Fatalf("could not get rows affected from batch: %v", err)
+	}
+	if affected != 2 {
+		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 2)
+	}
 
-	db, server, teardown := setupTestDBConnection(t)
-	defer teardown()
+	requests = drainRequestsFromServer(server.TestSpanner)
+	// There should still be no ExecuteSqlRequests on the server.
+	sqlRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if len(sqlRequests) != 0 {
+		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
+	}
+	batchRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if len(batchRequests) != 1 {
+		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 1)
+	}
+	// The transaction should still be active.
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if len(commitRequests) != 0 {
+		t.Fatalf("Commit requests count mismatch\nGot: %v\nWant: %v", len(commitRequests), 0)
+	}
 
-	s := "insert into users (id, name) values (@name, @name)"
-	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
+	// Executing another DML statement on the same transaction now that the batch has been
+	// executed should cause the statement to be sent to Spanner.
+	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (3, 'Three')", &testutil.StatementResult{
 		Type:        testutil.StatementResultUpdateCount,
 		UpdateCount: 1,
 	})
-	_, err := db.Exec(s, sql.Named("name", "foo"))
-	if err != nil {
-		t.Fatal(err)
+	if _, err := tx.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (3, 'Three')"); err != nil {
+		t.Fatalf("failed to execute DML statement after batch: %v", err)
 	}
-	// Verify that 'foo' is used for both instances of the parameter @name.
-	requests := drainRequestsFromServer(server.TestSpanner)
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction after batch: %v", err)
+	}
+
+	requests = drainRequestsFromServer(server.TestSpanner)
+	// There should now be one ExecuteSqlRequests on the server.
+	sqlRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
 	if len(sqlRequests) != 1 {
 		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
 	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if g, w := len(req.Params.Fields), 1; g != w {
-		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	// There should be no new Batch DML requests.
+	batchRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if len(batchRequests) != 0 {
+		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 0)
 	}
-	if g, w := req.Params.Fields["name"].GetStringValue(), "foo"; g != w {
-		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	// The transaction should now be committed.
+	commitRequests = requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+	if len(commitRequests) != 1 {
+		t.Fatalf("Commit requests count mismatch\nGot: %v\nWant: %v", len(commitRequests), 1)
 	}
 }
 
-func TestQueryWithReusedPositionalParameter(t *testing.T) {
+func TestCommitTimestamp(t *testing.T) {
 	t.Parallel()
 
-	db, server, teardown := setupTestDBConnection(t)
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	s := "insert into users (id, name) values (@name, @name)"
-	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 1,
-	})
-	_, err := db.Exec(s, "foo", "bar")
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	// Verify that 'bar' is used for both instances of the parameter @name.
-	requests := drainRequestsFromServer(server.TestSpanner)
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 1 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
 	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if g, w := len(req.Params.Fields), 1; g != w {
-		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
 	}
-	if g, w := req.Params.Fields["name"].GetStringValue(), "bar"; g != w {
-		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	// Get the commit timestamp from the connection.
+	// We do this in a simple loop to verify that we can get it multiple times.
+	for i := 0; i < 2; i++ {
+		var ts time.Time
+		if err := conn.Raw(func(driverConn interface{}) error {
+			ts, err = driverConn.(SpannerConn).CommitTimestamp()
+			return err
+		}); err != nil {
+			t.Fatalf("failed to get commit timestamp: %v", err)
+		}
+		if cmp.Equal(time.Time{}, ts) {
+			t.Fatalf("got zero commit timestamp: %v", ts)
+		}
 	}
 }
 
-func TestQueryWithMissingPositionalParameter(t *testing.T) {
+func TestCommitTimestampAutocommit(t *testing.T) {
 	t.Parallel()
 
-	db, server, teardown := setupTestDBConnection(t)
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	s := "insert into users (id, name) values (@name, @name)"
-	server.TestSpanner.PutStatementResult(s, &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 1,
-	})
-	_, err := db.Exec(s, "foo")
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
 	if err != nil {
-		t.Fatal(err)
-	}
-	// Verify that 'foo' is used for the parameter @name.
-	requests := drainRequestsFromServer(server.TestSpanner)
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 1 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if g, w := len(req.Params.Fields), 1; g != w {
-		t.Fatalf("params count mismatch\n Got: %v\nWant: %v", g, w)
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
 	}
-	if g, w := req.Params.Fields["name"].GetStringValue(), "foo"; g != w {
-		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	// Get the commit timestamp from the connection.
+	// We do this in a simple loop to verify that we can get it multiple times.
+	for i := 0; i < 2; i++ {
+		var ts time.Time
+		if err := conn.Raw(func(driverConn interface{}) error {
+			ts, err = driverConn.(SpannerConn).CommitTimestamp()
+			return err
+		}); err != nil {
+			t.Fatalf("failed to get commit timestamp: %v", err)
+		}
+		if cmp.Equal(time.Time{}, ts) {
+			t.Fatalf("got zero commit timestamp: %v", ts)
+		}
 	}
 }
 
-func TestDdlInAutocommit(t *testing.T) {
+func TestTimestampsAreNormalized(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	var expectedResponse = &emptypb.Empty{}
-	any, _ := anypb.New(expectedResponse)
-	server.TestDatabaseAdmin.SetResps([]proto.Message{
-		&longrunningpb.Operation{
-			Done:   true,
-			Result: &longrunningpb.Operation_Response{Response: any},
-			Name:   "test-operation",
-		},
-	})
-	query := "CREATE TABLE Singers (SingerId INT64, FirstName STRING(100), LastName STRING(100)) PRIMARY KEY (SingerId)"
-	_, err := db.ExecContext(context.Background(), query)
+	conn, err := db.Conn(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
-	requests := server.TestDatabaseAdmin.Reqs()
-	if g, w := len(requests), 1; g != w {
-		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
 	}
-	if req, ok := requests[0].(*databasepb.UpdateDatabaseDdlRequest); ok {
-		if g, w := len(req.Statements), 1; g != w {
-			t.Fatalf("statement count mismatch\nGot: %v\nWant: %v", g, w)
-		}
-		if g, w := req.Statements[0], query; g != w {
-			t.Fatalf("statement mismatch\nGot: %v\nWant: %v", g, w)
-		}
-	} else {
-		t.Fatalf("request type mismatch, got %v", requests[0])
+	var commitTs time.Time
+	if err := conn.Raw(func(driverConn interface{}) error {
+		commitTs, err = driverConn.(SpannerConn).CommitTimestamp()
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := commitTs.Location(), time.UTC; g != w {
+		t.Fatalf("commit timestamp location mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var applyTs time.Time
+	if err := conn.Raw(func(driverConn interface{}) error {
+		applyTs, err = driverConn.(SpannerConn).Apply(ctx, []*spanner.Mutation{spanner.Insert("Foo", []string{"Id"}, []interface{}{int64(1)})})
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := applyTs.Location(), time.UTC; g != w {
+		t.Fatalf("apply timestamp location mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Read the commit timestamp back from the database, both as a single value
+	// and as an array element.
+	query := "SELECT LastUpdated, AllUpdates FROM Foo"
+	tsValue := structpb.NewStringValue(commitTs.Format(time.RFC3339Nano))
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "LastUpdated", Type: &sppb.Type{Code: sppb.TypeCode_TIMESTAMP}},
+						{Name: "AllUpdates", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_TIMESTAMP}}},
+					},
+				},
+			},
+			Rows: []*structpb.ListValue{
+				{Values: []*structpb.Value{tsValue, structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{tsValue}})}},
+			},
+		},
+	})
+	var readTs time.Time
+	var readTsArray []spanner.NullTime
+	if err := conn.QueryRowContext(ctx, query).Scan(&readTs, &readTsArray); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := readTs.Location(), time.UTC; g != w {
+		t.Fatalf("read timestamp location mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !reflect.DeepEqual(readTs, commitTs) {
+		t.Fatalf("read timestamp mismatch\n Got: %#v\nWant: %#v", readTs, commitTs)
+	}
+	if g, w := len(readTsArray), 1; g != w {
+		t.Fatalf("array length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !reflect.DeepEqual(readTsArray[0].Time, commitTs) {
+		t.Fatalf("array timestamp mismatch\n Got: %#v\nWant: %#v", readTsArray[0].Time, commitTs)
 	}
 }
 
-func TestDdlInTransaction(t *testing.T) {
+func TestCommitTimestampFailsAfterRollback(t *testing.T) {
 	t.Parallel()
 
-	db, server, teardown := setupTestDBConnection(t)
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	query := "CREATE TABLE Singers (SingerId INT64, FirstName STRING(100), LastName STRING(100)) PRIMARY KEY (SingerId)"
-	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{})
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	if _, err := tx.ExecContext(context.Background(), query); spanner.ErrCode(err) != codes.FailedPrecondition {
-		t.Fatalf("error mismatch\nGot:  %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
 	}
-	requests := server.TestDatabaseAdmin.Reqs()
-	if g, w := len(requests), 0; g != w {
-		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	// Try to get the commit timestamp from the connection.
+	err = conn.Raw(func(driverConn interface{}) error {
+		_, err = driverConn.(SpannerConn).CommitTimestamp()
+		return err
+	})
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("get commit timestamp error code mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestBegin(t *testing.T) {
+func TestCommitTimestampFailsAfterAutocommitQuery(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, _, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	// Ensure that the old Begin method works.
-	_, err := db.Begin()
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
 	if err != nil {
-		t.Fatalf("Begin failed: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
+	}
+	var v string
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(&v); err != nil {
+		t.Fatalf("failed to execute query: %v", err)
+	}
+	// Try to get the commit timestamp from the connection. This should not be possible as a query in autocommit mode
+	// will not return a commit timestamp.
+	err = conn.Raw(func(driverConn interface{}) error {
+		_, err = driverConn.(SpannerConn).CommitTimestamp()
+		return err
+	})
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("get commit timestamp error code mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestQuery(t *testing.T) {
+func TestCommitTimestampFailsAfterReadOnlyTransaction(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, _, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	// Ensure that the old Query method works.
-	rows, err := db.Query(testutil.SelectFooFromBar)
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("Query failed: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
+	}
+	defer conn.Close()
+	getCommitTimestamp := func() (ts time.Time, err error) {
+		err = conn.Raw(func(driverConn interface{}) error {
+			ts, err = driverConn.(SpannerConn).CommitTimestamp()
+			return err
+		})
+		return ts, err
 	}
-	defer rows.Close()
-}
-
-func TestExec(t *testing.T) {
-	t.Parallel()
 
-	db, _, teardown := setupTestDBConnection(t)
-	defer teardown()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if _, err := getCommitTimestamp(); err != nil {
+		t.Fatalf("failed to get commit timestamp: %v", err)
+	}
 
-	// Ensure that the old Exec method works.
-	_, err := db.Exec(testutil.UpdateBarSetFoo)
+	// The commit timestamp is cleared by a read-only transaction.
+	tx, err = conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
-		t.Fatalf("Exec failed: %v", err)
+		t.Fatalf("failed to start read-only transaction: %v", err)
+	}
+	var v string
+	if err := tx.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(&v); err != nil {
+		t.Fatalf("failed to execute query: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if _, err := getCommitTimestamp(); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("get commit timestamp error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
 	}
 }
 
-func TestPrepare(t *testing.T) {
+func TestShowVariableCommitTimestamp(t *testing.T) {
 	t.Parallel()
 
+	ctx := context.Background()
 	db, _, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	// Ensure that the old Prepare method works.
-	_, err := db.Prepare(testutil.SelectFooFromBar)
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
 	if err != nil {
-		t.Fatalf("Prepare failed: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	// Get the commit timestamp from the connection using a custom SQL statement.
+	// We do this in a simple loop to verify that we can get it multiple times.
+	for i := 0; i < 2; i++ {
+		var ts time.Time
+		if err := conn.QueryRowContext(ctx, "SHOW VARIABLE COMMIT_TIMESTAMP").Scan(&ts); err != nil {
+			t.Fatalf("failed to get commit timestamp: %v", err)
+		}
+		if cmp.Equal(time.Time{}, ts) {
+			t.Fatalf("got zero commit timestamp: %v", ts)
+		}
 	}
 }
 
-func TestApplyMutations(t *testing.T) {
+func TestShowVariableReadTimestamp(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	db, server, teardown := setupTestDBConnection(t)
+	db, _, teardown := setupTestDBConnection(t)
 	defer teardown()
 
 	conn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("failed to get connection: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	var commitTimestamp time.Time
-	if err := conn.Raw(func(driverConn interface{}) error {
-		spannerConn, ok := driverConn.(SpannerConn)
-		if !ok {
-			return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
+	defer conn.Close()
+	showReadTimestamp := func() spanner.NullTime {
+		var ts spanner.NullTime
+		if err := conn.QueryRowContext(ctx, "SHOW VARIABLE READ_TIMESTAMP").Scan(&ts); err != nil {
+			t.Fatalf("failed to get read timestamp: %v", err)
 		}
-		commitTimestamp, err = spannerConn.Apply(ctx, []*spanner.Mutation{
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
-		})
-		return err
-	}); err != nil {
-		t.Fatalf("failed to apply mutations: %v", err)
+		return ts
 	}
-	if commitTimestamp.Equal(time.Time{}) {
-		t.Fatal("no commit timestamp returned")
+	// The read timestamp is NULL if the connection has not executed a read-only transaction.
+	if ts := showReadTimestamp(); ts.Valid {
+		t.Fatalf("read timestamp mismatch\n Got: %v\nWant: NULL", ts)
+	}
+	// The commit timestamp is also NULL if the connection has not committed a read/write transaction.
+	var commitTs spanner.NullTime
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE COMMIT_TIMESTAMP").Scan(&commitTs); err != nil {
+		t.Fatalf("failed to get commit timestamp: %v", err)
+	}
+	if commitTs.Valid {
+		t.Fatalf("commit timestamp mismatch\n Got: %v\nWant: NULL", commitTs)
 	}
 
-	// Even though the Apply method is used outside a transaction, the connection will internally start a read/write
-	// transaction for the mutations.
-	requests := drainRequestsFromServer(server.TestSpanner)
-	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
-	if g, w := len(commitRequests), 1; g != w {
-		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	tb := spanner.ExactStaleness(10 * time.Second)
+	tx, err := conn.BeginTx(WithReadOnlyTransactionOptions(ctx, ReadOnlyTransactionOptions{TimestampBound: &tb}), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
 	}
-	commitRequest := commitRequests[0].(*sppb.CommitRequest)
-	if g, w := len(commitRequest.Mutations), 2; g != w {
-		t.Fatalf("mutation count mismatch\nGot: %v\nWant: %v", g, w)
+	rows, err := tx.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	// The read timestamp of the most recent read-only transaction is returned after it has finished.
+	ts := showReadTimestamp()
+	if !ts.Valid || ts.Time.IsZero() {
+		t.Fatalf("read timestamp mismatch\n Got: %v\nWant: a valid timestamp", ts)
+	}
+	var want time.Time
+	if err := conn.Raw(func(driverConn interface{}) (err error) {
+		want, err = driverConn.(SpannerConn).ReadTimestamp()
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !ts.Time.Equal(want) {
+		t.Fatalf("read timestamp mismatch\n Got: %v\nWant: %v", ts.Time, want)
 	}
 }
 
-func TestApplyMutationsFailure(t *testing.T) {
+func TestMinSessions(t *testing.T) {
 	t.Parallel()
 
+	minSessions := int32(10)
 	ctx := context.Background()
-	db, _, teardown := setupTestDBConnection(t)
+	db, server, teardown := setupTestDBConnectionWithParams(t, fmt.Sprintf("minSessions=%v", minSessions))
 	defer teardown()
 
-	con, err := db.Conn(ctx)
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("failed to get connection: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	_, err = con.BeginTx(ctx, &sql.TxOptions{})
-	if err != nil {
-		t.Fatalf("failed to begin transaction: %v", err)
+	var res int64
+	if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
+		t.Fatalf("failed to execute query on connection: %v", err)
 	}
-	if g, w := spanner.ErrCode(con.Raw(func(driverConn interface{}) error {
-		spannerConn, ok := driverConn.(SpannerConn)
-		if !ok {
-			return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
+	// Wait until all sessions have been created.
+	waitFor(t, func() error {
+		created := int32(server.TestSpanner.TotalSessionsCreated())
+		if created != minSessions {
+			return fmt.Errorf("num open sessions mismatch\n Got: %d\nWant: %d", created, minSessions)
 		}
-		_, err = spannerConn.Apply(ctx, []*spanner.Mutation{
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
-		})
-		return err
-	})), codes.FailedPrecondition; g != w {
-		t.Fatalf("error code mismatch for Apply during transaction\nGot:  %v\nWant: %v", g, w)
+		return nil
+	})
+	_ = conn.Close()
+	_ = db.Close()
+
+	// Verify that the connector created 10 sessions on the server.
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
+	numCreated := int32(0)
+	for _, req := range createReqs {
+		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	}
+	if g, w := numCreated, minSessions; g != w {
+		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestBufferWriteMutations(t *testing.T) {
+func TestMaxSessions(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	db, server, teardown := setupTestDBConnection(t)
+	db, server, teardown := setupTestDBConnectionWithParams(t, "minSessions=0;maxSessions=2")
 	defer teardown()
 
-	con, err := db.Conn(ctx)
-	if err != nil {
-		t.Fatalf("failed to get connection: %v", err)
-	}
-	tx, err := con.BeginTx(ctx, &sql.TxOptions{})
-	if err != nil {
-		t.Fatalf("failed to begin transaction: %v", err)
-	}
-	if err := con.Raw(func(driverConn interface{}) error {
-		spannerConn, ok := driverConn.(SpannerConn)
-		if !ok {
-			return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
-		}
-		return spannerConn.BufferWrite([]*spanner.Mutation{
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
-		})
-	}); err != nil {
-		t.Fatalf("failed to buffer mutations: %v", err)
-	}
-	if err := tx.Commit(); err != nil {
-		t.Fatalf("failed to commit transaction: %v", err)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				t.Errorf("failed to get a connection: %v", err)
+			}
+			var res int64
+			if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
+				t.Errorf("failed to execute query on connection: %v", err)
+			}
+			_ = conn.Close()
+		}()
 	}
+	wg.Wait()
 
-	requests := drainRequestsFromServer(server.TestSpanner)
-	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
-	if g, w := len(commitRequests), 1; g != w {
-		t.Fatalf("commit requests count mismatch\nGot: %v\nWant: %v", g, w)
+	// Verify that the connector only created 2 sessions on the server.
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
+	numCreated := int32(0)
+	for _, req := range createReqs {
+		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
 	}
-	commitRequest := commitRequests[0].(*sppb.CommitRequest)
-	if g, w := len(commitRequest.Mutations), 2; g != w {
-		t.Fatalf("mutation count mismatch\nGot: %v\nWant: %v", g, w)
+	if g, w := numCreated, int32(2); g != w {
+		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestBufferWriteMutationsFails(t *testing.T) {
+func TestClientReuse(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	db, _, teardown := setupTestDBConnection(t)
+	db, server, teardown := setupTestDBConnectionWithParams(t, "minSessions=2")
 	defer teardown()
 
-	con, err := db.Conn(ctx)
+	// Repeatedly get a connection and close it using the same DB instance. These
+	// connections should all share the same Spanner client, and only initialized
+	// one session pool.
+	for i := 0; i < 5; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("failed to get a connection: %v", err)
+		}
+		var res int64
+		if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
+			t.Fatalf("failed to execute query on connection: %v", err)
+		}
+		_ = conn.Close()
+	}
+	// Verify that the connector only created 2 sessions on the server.
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
+	numCreated := int32(0)
+	for _, req := range createReqs {
+		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	}
+	if g, w := numCreated, int32(2); g != w {
+		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Now close the DB instance and create a new DB connection.
+	// This should cause the first Spanner client to be closed and
+	// a new one to be opened.
+	_ = db.Close()
+
+	db, err := sql.Open(
+		"spanner",
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;minSessions=2", server.Address))
 	if err != nil {
-		t.Fatalf("failed to get connection: %v", err)
+		t.Fatalf("failed to open new DB instance: %v", err)
 	}
-	if g, w := spanner.ErrCode(con.Raw(func(driverConn interface{}) error {
-		spannerConn, ok := driverConn.(SpannerConn)
-		if !ok {
-			return fmt.Errorf("unexpected driver connection %v, expected SpannerConn", driverConn)
-		}
-		return spannerConn.BufferWrite([]*spanner.Mutation{
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(1), "Foo", int64(50)}),
-			spanner.Insert("Accounts", []string{"AccountId", "Nickname", "Balance"}, []interface{}{int64(2), "Bar", int64(1)}),
-		})
-	})), codes.FailedPrecondition; g != w {
-		t.Fatalf("error code mismatch for BufferWrite outside transaction\nGot:  %v\nWant: %v", g, w)
+	var res int64
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
+		t.Fatalf("failed to execute query on db: %v", err)
+	}
+	reqs = drainRequestsFromServer(server.TestSpanner)
+	createReqs = requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
+	numCreated = int32(0)
+	for _, req := range createReqs {
+		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	}
+	if g, w := numCreated, int32(2); g != w {
+		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestPing(t *testing.T) {
+func TestStressClientReuse(t *testing.T) {
 	t.Parallel()
 
-	db, _, teardown := setupTestDBConnection(t)
+	ctx := context.Background()
+	_, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	// Ensure that the old Ping method works.
-	err := db.Ping()
-	if err != nil {
-		t.Fatalf("Ping failed: %v", err)
+	rand.Seed(time.Now().UnixNano())
+	numSessions := 10
+	numClients := 5
+	numParallel := 50
+	var wg sync.WaitGroup
+	for clientIndex := 0; clientIndex < numClients; clientIndex++ {
+		// Open a DB using a dsn that contains a meaningless number. This will ensure that
+		// the underlying client will be different from the other connections that use a
+		// different number.
+		db, err := sql.Open("spanner",
+			fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;minSessions=%v;maxSessions=%v;randomNumber=%v", server.Address, numSessions, numSessions, clientIndex))
+		if err != nil {
+			t.Fatalf("failed to open DB: %v", err)
+		}
+		// Execute random operations in parallel on the database.
+		for i := 0; i < numParallel; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				conn, err := db.Conn(ctx)
+				if err != nil {
+					t.Errorf("failed to get a connection: %v", err)
+				}
+				if rand.Int()%2 == 0 {
+					if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+						t.Errorf("failed to execute update on connection: %v", err)
+					}
+				} else {
+					var res int64
+					if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
+						t.Errorf("failed to execute query on connection: %v", err)
+					}
+				}
+				_ = conn.Close()
+			}()
+		}
+	}
+	wg.Wait()
+
+	// Verify that each unique connection string created numSessions (10) sessions on the server.
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
+	numCreated := int32(0)
+	for _, req := range createReqs {
+		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	}
+	if g, w := numCreated, int32(numSessions*numClients); g != w {
+		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlReqs), numClients*numParallel; g != w {
+		t.Errorf("ExecuteSql request count mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestDdlBatch(t *testing.T) {
+func TestExcludeTxnFromChangeStreams_AutoCommitUpdate(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	var expectedResponse = &emptypb.Empty{}
-	any, _ := anypb.New(expectedResponse)
-	server.TestDatabaseAdmin.SetResps([]proto.Message{
-		&longrunningpb.Operation{
-			Done:   true,
-			Result: &longrunningpb.Operation_Response{Response: any},
-			Name:   "test-operation",
-		},
-	})
-
-	statements := []string{"CREATE TABLE FOO", "CREATE TABLE BAR"}
 	conn, err := db.Conn(ctx)
 	defer conn.Close()
+	if err != nil {
+		t.Fatalf("failed to get a connection: %v", err)
+	}
 
-	if _, err = conn.ExecContext(ctx, "START BATCH DDL"); err != nil {
-		t.Fatalf("failed to start DDL batch: %v", err)
+	var exclude bool
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE EXCLUDE_TXN_FROM_CHANGE_STREAMS").Scan(&exclude); err != nil {
+		t.Fatalf("failed to get exclude setting: %v", err)
 	}
-	for _, stmt := range statements {
-		if _, err = conn.ExecContext(ctx, stmt); err != nil {
-			t.Fatalf("failed to execute statement in DDL batch: %v", err)
-		}
+	if g, w := exclude, false; g != w {
+		t.Fatalf("exclude_txn_from_change_streams mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	if _, err = conn.ExecContext(ctx, "RUN BATCH"); err != nil {
-		t.Fatalf("failed to run DDL batch: %v", err)
+	if _, err := conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true"); err != nil {
+		t.Fatal(err)
 	}
-
-	requests := server.TestDatabaseAdmin.Reqs()
-	if g, w := len(requests), 1; g != w {
-		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	if _, err = conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
 	}
-	if req, ok := requests[0].(*databasepb.UpdateDatabaseDdlRequest); ok {
-		if g, w := len(req.Statements), len(statements); g != w {
-			t.Fatalf("statement count mismatch\nGot: %v\nWant: %v", g, w)
-		}
-		for i, stmt := range statements {
-			if g, w := req.Statements[i], stmt; g != w {
-				t.Fatalf("statement mismatch\nGot: %v\nWant: %v", g, w)
-			}
-		}
-	} else {
-		t.Fatalf("request type mismatch, got %v", requests[0])
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if req.Transaction == nil {
+		t.Fatalf("missing transaction for ExecuteSqlRequest")
+	}
+	if _, ok := req.Transaction.Selector.(*sppb.TransactionSelector_Begin); !ok {
+		t.Fatalf("unsupported transaction type %T", req.Transaction.Selector)
+	}
+	begin := req.Transaction.Selector.(*sppb.TransactionSelector_Begin)
+	if !begin.Begin.ExcludeTxnFromChangeStreams {
+		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
 	}
 }
 
-func TestAbortDdlBatch(t *testing.T) {
+func TestExcludeTxnFromChangeStreams_AutoCommitBatchDml(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	statements := []string{"CREATE TABLE FOO", "CREATE TABLE BAR"}
-	c, err := db.Conn(ctx)
-	defer c.Close()
-
-	if _, err = c.ExecContext(ctx, "START BATCH DDL"); err != nil {
-		t.Fatalf("failed to start DDL batch: %v", err)
-	}
-	for _, stmt := range statements {
-		if _, err = c.ExecContext(ctx, stmt); err != nil {
-			t.Fatalf("failed to execute statement in DDL batch: %v", err)
-		}
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
+	if err != nil {
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	// Check that the statements have been batched.
-	_ = c.Raw(func(driverConn interface{}) error {
-		conn := driverConn.(*conn)
-		if conn.batch == nil {
-			t.Fatalf("missing batch on connection")
-		}
-		if g, w := len(conn.batch.statements), 2; g != w {
-			t.Fatalf("batch length mismatch\nGot: %v\nWant: %v", g, w)
-		}
-		return nil
-	})
 
-	if _, err = c.ExecContext(ctx, "ABORT BATCH"); err != nil {
-		t.Fatalf("failed to abort DDL batch: %v", err)
+	conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true")
+	conn.ExecContext(ctx, "start batch dml")
+	conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
+	conn.ExecContext(ctx, "run batch")
+	requests := drainRequestsFromServer(server.TestSpanner)
+	batchRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if g, w := len(batchRequests), 1; g != w {
+		t.Fatalf("ExecuteBatchDmlRequest count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-
-	requests := server.TestDatabaseAdmin.Reqs()
-	if g, w := len(requests), 0; g != w {
-		t.Fatalf("requests count mismatch\nGot: %v\nWant: %v", g, w)
+	req := batchRequests[0].(*sppb.ExecuteBatchDmlRequest)
+	if req.Transaction == nil {
+		t.Fatalf("missing transaction for ExecuteBatchDmlRequest")
+	}
+	if _, ok := req.Transaction.Selector.(*sppb.TransactionSelector_Begin); !ok {
+		t.Fatalf("unsupported transaction type %T", req.Transaction.Selector)
+	}
+	begin := req.Transaction.Selector.(*sppb.TransactionSelector_Begin)
+	if !begin.Begin.ExcludeTxnFromChangeStreams {
+		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
 	}
-
-	_ = c.Raw(func(driverConn interface{}) error {
-		spannerConn := driverConn.(SpannerConn)
-		if spannerConn.InDDLBatch() {
-			t.Fatalf("connection still has an active DDL batch")
-		}
-		return nil
-	})
 }
 
-func TestShowAndSetVariableRetryAbortsInternally(t *testing.T) {
+func TestExcludeTxnFromChangeStreams_PartitionedDml(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	c, err := db.Conn(ctx)
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
 	if err != nil {
-		t.Fatalf("failed to obtain a connection: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	defer c.Close()
-
-	for _, tc := range []struct {
-		expected bool
-		set      bool
-	}{
-		{expected: true, set: false},
-		{expected: false, set: true},
-		{expected: true, set: true},
-	} {
-		// Get the current value.
-		rows, err := c.QueryContext(ctx, "SHOW VARIABLE RETRY_ABORTS_INTERNALLY")
-		if err != nil {
-			t.Fatalf("failed to execute get variable retry_aborts_internally: %v", err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var retry bool
-			if err := rows.Scan(&retry); err != nil {
-				t.Fatalf("failed to scan value for retry_aborts_internally: %v", err)
-			}
-			if g, w := retry, tc.expected; g != w {
-				t.Fatalf("retry_aborts_internally mismatch\nGot: %v\nWant: %v", g, w)
-			}
-		}
-		if err := rows.Err(); err != nil {
-			t.Fatalf("failed to iterate over result for get variable retry_aborts_internally: %v", err)
-		}
-
-		// Check that the behavior matches the setting.
-		tx, _ := c.BeginTx(ctx, nil)
-		server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
-			Errors: []error{gstatus.Error(codes.Aborted, "Aborted")},
-		})
-		err = tx.Commit()
-		if tc.expected && err != nil {
-			t.Fatalf("unexpected error for commit: %v", err)
-		} else if !tc.expected && spanner.ErrCode(err) != codes.Aborted {
-			t.Fatalf("error code mismatch\nGot: %v\nWant: %v", spanner.ErrCode(err), codes.Aborted)
-		}
 
-		// Set a new value for the variable.
-		if _, err := c.ExecContext(ctx, fmt.Sprintf("SET RETRY_ABORTS_INTERNALLY = %v", tc.set)); err != nil {
-			t.Fatalf("failed to set value for retry_aborts_internally: %v", err)
-		}
+	conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true")
+	conn.ExecContext(ctx, "set autocommit_dml_mode = 'partitioned_non_atomic'")
+	conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
+	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("BeginTransactionRequest count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-
-	// Verify that the value cannot be set during a transaction.
-	tx, _ := c.BeginTx(ctx, nil)
-	defer tx.Rollback()
-	_, err = c.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = TRUE")
-	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
-		t.Fatalf("error code mismatch for setting retry_aborts_internally during a transaction\nGot: %v\nWant: %v", g, w)
+	req := beginRequests[0].(*sppb.BeginTransactionRequest)
+	if !req.Options.ExcludeTxnFromChangeStreams {
+		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
 	}
 }
 
-func TestPartitionedDml(t *testing.T) {
+func TestPartitionedDml_Cancel(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	c, err := db.Conn(ctx)
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("failed to obtain a connection: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	defer c.Close()
-
-	if _, err := c.ExecContext(ctx, "set autocommit_dml_mode = 'Partitioned_Non_Atomic'"); err != nil {
-		t.Fatalf("could not set autocommit dml mode: %v", err)
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "set autocommit_dml_mode = 'partitioned_non_atomic'"); err != nil {
+		t.Fatal(err)
 	}
-
-	server.TestSpanner.PutStatementResult("DELETE FROM Foo WHERE TRUE", &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 200,
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteSql, testutil.SimulatedExecutionTime{
+		MinimumExecutionTime: 10 * time.Second,
 	})
-	// The following statement should be executed using PDML instead of DML.
-	res, err := c.ExecContext(ctx, "DELETE FROM Foo WHERE TRUE")
+	cancelCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	_, err = conn.ExecContext(cancelCtx, testutil.UpdateBarSetFoo)
+	if g, w := spanner.ErrCode(err), codes.Canceled; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("statement did not return promptly after cancellation: %v", elapsed)
+	}
+}
+
+func TestPartitionedDml_InTransaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("could not execute DML statement: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	affected, _ := res.RowsAffected()
-	if affected != 200 {
-		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 200)
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
 	}
-	requests := drainRequestsFromServer(server.TestSpanner)
-	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
-	var beginPdml *sppb.BeginTransactionRequest
-	for _, req := range beginRequests {
-		if req.(*sppb.BeginTransactionRequest).Options.GetPartitionedDml() != nil {
-			beginPdml = req.(*sppb.BeginTransactionRequest)
-			break
-		}
+	_, err = conn.ExecContext(ctx, "set autocommit_dml_mode = 'partitioned_non_atomic'")
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	if beginPdml == nil {
-		t.Fatal("no begin request for Partitioned DML found")
+	// Changing back to the default mode is allowed.
+	if _, err := conn.ExecContext(ctx, "set autocommit_dml_mode = 'transactional'"); err != nil {
+		t.Fatal(err)
 	}
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 1 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
 	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if req.Transaction == nil || req.Transaction.GetId() == nil {
-		t.Fatal("missing transaction id for sql request")
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
 	}
-	if !server.TestSpanner.IsPartitionedDmlTransaction(req.Transaction.GetId()) {
-		t.Fatalf("sql request did not use a PDML transaction")
+	requests := drainRequestsFromServer(server.TestSpanner)
+	for _, req := range requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{})) {
+		if req.(*sppb.BeginTransactionRequest).Options.GetPartitionedDml() != nil {
+			t.Fatal("unexpected begin request for Partitioned DML")
+		}
+	}
+
+	// The mode can be changed after the transaction has finished.
+	if _, err := conn.ExecContext(ctx, "set autocommit_dml_mode = 'partitioned_non_atomic'"); err != nil {
+		t.Fatal(err)
 	}
 }
 
-func TestAutocommitBatchDml(t *testing.T) {
+func TestConnectionInUse(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	c, err := db.Conn(ctx)
+	sqlConn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("failed to obtain a connection: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	defer c.Close()
-
-	if _, err := c.ExecContext(ctx, "START BATCH DML"); err != nil {
-		t.Fatalf("could not start a DML batch: %v", err)
+	defer sqlConn.Close()
+	tx, err := sqlConn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leak the driver connection outside of Raw. This is not allowed.
+	var spannerConn *conn
+	if err := sqlConn.Raw(func(driverConn interface{}) error {
+		spannerConn = driverConn.(*conn)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
 	}
-	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (1, 'One')", &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 1,
-	})
-	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (2, 'Two')", &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 1,
-	})
 
-	// The following statements should be batched locally and only sent to Spanner once
-	// 'RUN BATCH' is executed.
-	res, err := c.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (1, 'One')")
-	if err != nil {
-		t.Fatalf("could not execute DML statement: %v", err)
+	server.TestSpanner.Freeze()
+	errCh := make(chan error)
+	go func() {
+		_, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo)
+		errCh <- err
+	}()
+	for atomic.LoadInt32(&spannerConn.inUse) == 0 {
+		time.Sleep(time.Millisecond)
 	}
-	affected, _ := res.RowsAffected()
-	if affected != 0 {
-		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	// Using the leaked connection while the transaction is executing a
+	// statement on another goroutine fails.
+	if err := spannerConn.BufferWrite([]*spanner.Mutation{spanner.Delete("Singers", spanner.AllKeys())}); err != ErrConnectionInUse {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, ErrConnectionInUse)
 	}
-	res, err = c.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (2, 'Two')")
-	if err != nil {
-		t.Fatalf("could not execute DML statement: %v", err)
+	if _, err := spannerConn.ExecContext(ctx, testutil.UpdateBarSetFoo, nil); err != ErrConnectionInUse {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, ErrConnectionInUse)
 	}
-	affected, _ = res.RowsAffected()
-	if affected != 0 {
-		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	if _, err := spannerConn.BatchWrite(ctx, []*spanner.MutationGroup{{Mutations: []*spanner.Mutation{spanner.Delete("Singers", spanner.AllKeys())}}}); err != ErrConnectionInUse {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, ErrConnectionInUse)
 	}
-
-	// There should be no ExecuteSqlRequest statements on the server.
-	requests := drainRequestsFromServer(server.TestSpanner)
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 0 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
+	server.TestSpanner.Unfreeze()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
 	}
-	batchRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
-	if len(batchRequests) != 0 {
-		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 0)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// The transaction did not include the mutation of the failed call.
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requests[0].(*sppb.CommitRequest).Mutations), 0; g != w {
+		t.Fatalf("mutations count mismatch\n Got: %v\nWant: %v", g, w)
 	}
+}
 
-	// Execute a RUN BATCH statement. This should trigger a BatchDML request followed by a Commit request.
-	res, err = c.ExecContext(ctx, "RUN BATCH")
+func TestConnectorRetryer(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address)
+
+	// A retryer must return a non-nil value.
+	if _, err := CreateConnector(dsn, ConnectorConfig{Retryer: func() gax.Retryer { return nil }}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+
+	var retries int32
+	connector, err := CreateConnector(dsn, ConnectorConfig{
+		Retryer: func() gax.Retryer {
+			// Never retry.
+			return gax.OnErrorFunc(gax.Backoff{}, func(err error) bool {
+				atomic.AddInt32(&retries, 1)
+				return false
+			})
+		},
+	})
 	if err != nil {
-		t.Fatalf("failed to execute RUN BATCH: %v", err)
+		t.Fatal(err)
 	}
-	affected, err = res.RowsAffected()
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	// The default retry policy of the Spanner client would retry this error.
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{gstatus.Error(codes.Unavailable, "transient error")},
+	})
+	if _, err := db.ExecContext(ctx, testutil.UpdateBarSetFoo); spanner.ErrCode(err) != codes.Unavailable {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.Unavailable)
+	}
+	if g, w := atomic.LoadInt32(&retries), int32(1); g != w {
+		t.Fatalf("retryer calls mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestExcludeTxnFromChangeStreams_Transaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	conn, err := db.Conn(ctx)
+	defer conn.Close()
 	if err != nil {
-		t.Fatalf("could not get rows affected from batch: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
-	if affected != 2 {
-		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 2)
+
+	var exclude bool
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE EXCLUDE_TXN_FROM_CHANGE_STREAMS").Scan(&exclude); err != nil {
+		t.Fatalf("failed to get exclude setting: %v", err)
+	}
+	if g, w := exclude, false; g != w {
+		t.Fatalf("exclude_txn_from_change_streams mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true")
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
 	}
+	conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
+	tx.Commit()
 
-	requests = drainRequestsFromServer(server.TestSpanner)
-	// There should still be no ExecuteSqlRequests on the server.
-	sqlRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 0 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
+	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("BeginTransactionRequest count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-	batchRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
-	if len(batchRequests) != 1 {
-		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 1)
+	req := beginRequests[0].(*sppb.BeginTransactionRequest)
+	if !req.Options.ExcludeTxnFromChangeStreams {
+		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
 	}
-	// The transaction should also have been committed.
-	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
-	if len(commitRequests) != 1 {
-		t.Fatalf("Commit requests count mismatch\nGot: %v\nWant: %v", len(commitRequests), 1)
+
+	// Verify that the flag is reset after the transaction.
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE EXCLUDE_TXN_FROM_CHANGE_STREAMS").Scan(&exclude); err != nil {
+		t.Fatalf("failed to get exclude setting: %v", err)
 	}
+	if g, w := exclude, false; g != w {
+		t.Fatalf("exclude_txn_from_change_streams mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
 }
 
-func TestTransactionBatchDml(t *testing.T) {
+func TestExcludeTxnFromChangeStreams_AutoCommitMutations(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
 
-	tx, err := db.BeginTx(ctx, nil)
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("failed to start transaction: %v", err)
+		t.Fatalf("failed to get a connection: %v", err)
 	}
+	defer conn.Close()
 
-	if _, err := tx.ExecContext(ctx, "START BATCH DML"); err != nil {
-		t.Fatalf("could not start a DML batch: %v", err)
+	if _, err := conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).Apply(ctx, []*spanner.Mutation{
+			spanner.Insert("Singers", []string{"SingerId", "Name"}, []interface{}{int64(1), "Alice"}),
+		})
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("BeginTransactionRequest count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if !beginRequests[0].(*sppb.BeginTransactionRequest).Options.ExcludeTxnFromChangeStreams {
+		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
 	}
-	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (1, 'One')", &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 1,
-	})
-	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (2, 'Two')", &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 1,
-	})
 
-	// The following statements should be batched locally and only sent to Spanner once
-	// 'RUN BATCH' is executed.
-	res, err := tx.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (1, 'One')")
-	if err != nil {
-		t.Fatalf("could not execute DML statement: %v", err)
+	// The flag is reset after the mutations have been applied.
+	var exclude bool
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE EXCLUDE_TXN_FROM_CHANGE_STREAMS").Scan(&exclude); err != nil {
+		t.Fatalf("failed to get exclude setting: %v", err)
 	}
-	affected, _ := res.RowsAffected()
-	if affected != 0 {
-		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	if g, w := exclude, false; g != w {
+		t.Fatalf("exclude_txn_from_change_streams mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	res, err = tx.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (2, 'Two')")
+}
+
+func TestExcludeTxnFromChangeStreams_TransactionOptions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	txCtx := WithReadWriteTransactionOptions(ctx, ReadWriteTransactionOptions{ExcludeTxnFromChangeStreams: true})
+	tx, err := db.BeginTx(txCtx, &sql.TxOptions{})
 	if err != nil {
-		t.Fatalf("could not execute DML statement: %v", err)
+		t.Fatal(err)
 	}
-	affected, _ = res.RowsAffected()
-	if affected != 0 {
-		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 0)
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
 	}
-
-	// There should be no ExecuteSqlRequest statements on the server.
 	requests := drainRequestsFromServer(server.TestSpanner)
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 0 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("BeginTransactionRequest count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-	batchRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
-	if len(batchRequests) != 0 {
-		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 0)
+	if !beginRequests[0].(*sppb.BeginTransactionRequest).Options.ExcludeTxnFromChangeStreams {
+		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
 	}
 
-	// Execute a RUN BATCH statement. This should trigger a BatchDML request.
-	res, err = tx.ExecContext(ctx, "RUN BATCH")
+	// The option only applies to the transaction that it is used for.
+	tx, err = db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		t.Fatalf("failed to execute RUN BATCH: %v", err)
+		t.Fatal(err)
 	}
-	affected, err = res.RowsAffected()
-	if err != nil {
-		t.Fatalf("could not get rows affected from batch: %v", err)
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
 	}
-	if affected != 2 {
-		t.Fatalf("affected rows mismatch\nGot: %v\nWant: %v", affected, 2)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
 	}
-
 	requests = drainRequestsFromServer(server.TestSpanner)
-	// There should still be no ExecuteSqlRequests on the server.
-	sqlRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 0 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 0)
-	}
-	batchRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
-	if len(batchRequests) != 1 {
-		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 1)
+	beginRequests = requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("BeginTransactionRequest count mismatch\nGot: %v\nWant: %v", g, w)
 	}
-	// The transaction should still be active.
-	commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
-	if len(commitRequests) != 0 {
-		t.Fatalf("Commit requests count mismatch\nGot: %v\nWant: %v", len(commitRequests), 0)
+	if beginRequests[0].(*sppb.BeginTransactionRequest).Options.ExcludeTxnFromChangeStreams {
+		t.Fatalf("unexpected ExcludeTxnFromChangeStreams option on BeginTransaction option")
 	}
+}
 
-	// Executing another DML statement on the same transaction now that the batch has been
-	// executed should cause the statement to be sent to Spanner.
-	server.TestSpanner.PutStatementResult("INSERT INTO Foo (Id, Val) VALUES (3, 'Three')", &testutil.StatementResult{
-		Type:        testutil.StatementResultUpdateCount,
-		UpdateCount: 1,
-	})
-	if _, err := tx.ExecContext(ctx, "INSERT INTO Foo (Id, Val) VALUES (3, 'Three')"); err != nil {
-		t.Fatalf("failed to execute DML statement after batch: %v", err)
+func numeric(v string) big.Rat {
+	res, _ := big.NewRat(1, 1).SetString(v)
+	return *res
+}
+
+func nullNumeric(valid bool, v string) spanner.NullNumeric {
+	if !valid {
+		return spanner.NullNumeric{}
 	}
-	if err := tx.Commit(); err != nil {
-		t.Fatalf("failed to commit transaction after batch: %v", err)
+	return spanner.NullNumeric{Valid: true, Numeric: numeric(v)}
+}
+
+func date(v string) civil.Date {
+	res, _ := civil.ParseDate(v)
+	return res
+}
+
+func nullDate(valid bool, v string) spanner.NullDate {
+	if !valid {
+		return spanner.NullDate{}
 	}
+	return spanner.NullDate{Valid: true, Date: date(v)}
+}
 
-	requests = drainRequestsFromServer(server.TestSpanner)
-	// There should now be one ExecuteSqlRequests on the server.
-	sqlRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if len(sqlRequests) != 1 {
-		t.Fatalf("sql requests count mismatch\nGot: %v\nWant: %v", len(sqlRequests), 1)
+func nullJson(valid bool, v string) spanner.NullJSON {
+	if !valid {
+		return spanner.NullJSON{}
 	}
-	// There should be no new Batch DML requests.
-	batchRequests = requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
-	if len(batchRequests) != 0 {
-		t.Fatalf("BatchDML requests count mismatch\nGot: %v\nWant: %v", len(batchRequests), 0)
+	var m map[string]interface{}
+	_ = json.Unmarshal([]byte(v), &m)
+	return spanner.NullJSON{Valid: true, Value: m}
+}
+
+func setupTestDBConnection(t testing.TB) (db *sql.DB, server *testutil.MockedSpannerInMemTestServer, teardown func()) {
+	return setupTestDBConnectionWithParams(t, "")
+}
+
+func setupTestDBConnectionWithParams(t testing.TB, params string) (db *sql.DB, server *testutil.MockedSpannerInMemTestServer, teardown func()) {
+	server, _, serverTeardown := setupMockedTestServer(t)
+	db, err := sql.Open(
+		"spanner",
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;%s", server.Address, params))
+	if err != nil {
+		serverTeardown()
+		t.Fatal(err)
 	}
-	// The transaction should now be committed.
-	commitRequests = requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
-	if len(commitRequests) != 1 {
-		t.Fatalf("Commit requests count mismatch\nGot: %v\nWant: %v", len(commitRequests), 1)
+	return db, server, func() {
+		_ = db.Close()
+		serverTeardown()
 	}
 }
 
-func TestCommitTimestamp(t *testing.T) {
-	t.Parallel()
+func setupMockedTestServer(t testing.TB) (server *testutil.MockedSpannerInMemTestServer, client *spanner.Client, teardown func()) {
+	return setupMockedTestServerWithConfig(t, spanner.ClientConfig{})
+}
 
-	ctx := context.Background()
-	db, _, teardown := setupTestDBConnection(t)
-	defer teardown()
+func setupMockedTestServerWithConfig(t testing.TB, config spanner.ClientConfig) (server *testutil.MockedSpannerInMemTestServer, client *spanner.Client, teardown func()) {
+	return setupMockedTestServerWithConfigAndClientOptions(t, config, []option.ClientOption{})
+}
 
-	conn, err := db.Conn(ctx)
-	defer conn.Close()
+func setupMockedTestServerWithConfigAndClientOptions(t testing.TB, config spanner.ClientConfig, clientOptions []option.ClientOption) (server *testutil.MockedSpannerInMemTestServer, client *spanner.Client, teardown func()) {
+	server, opts, serverTeardown := testutil.NewMockedSpannerInMemTestServer(t)
+	opts = append(opts, clientOptions...)
+	ctx := context.Background()
+	formattedDatabase := fmt.Sprintf("projects/%s/instances/%s/databases/%s", "[PROJECT]", "[INSTANCE]", "[DATABASE]")
+	client, err := spanner.NewClientWithConfig(ctx, formattedDatabase, config, opts...)
 	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
+		t.Fatal(err)
 	}
-	tx, err := conn.BeginTx(ctx, nil)
-	if err != nil {
-		t.Fatalf("failed to start transaction: %v", err)
+	return server, client, func() {
+		client.Close()
+		serverTeardown()
 	}
-	if err := tx.Commit(); err != nil {
-		t.Fatalf("commit failed: %v", err)
+}
+
+func filterBeginReadOnlyRequests(requests []interface{}) []*sppb.BeginTransactionRequest {
+	res := make([]*sppb.BeginTransactionRequest, 0)
+	for _, r := range requests {
+		if req, ok := r.(*sppb.BeginTransactionRequest); ok {
+			if req.Options != nil && req.Options.GetReadOnly() != nil {
+				res = append(res, req)
+			}
+		}
 	}
-	// Get the commit timestamp from the connection.
-	// We do this in a simple loop to verify that we can get it multiple times.
-	for i := 0; i < 2; i++ {
-		var ts time.Time
-		if err := conn.Raw(func(driverConn interface{}) error {
-			ts, err = driverConn.(SpannerConn).CommitTimestamp()
-			return err
-		}); err != nil {
-			t.Fatalf("failed to get commit timestamp: %v", err)
+	return res
+}
+
+func requestsOfType(requests []interface{}, t reflect.Type) []interface{} {
+	res := make([]interface{}, 0)
+	for _, req := range requests {
+		if reflect.TypeOf(req) == t {
+			res = append(res, req)
+		}
+	}
+	return res
+}
+
+func drainRequestsFromServer(server testutil.InMemSpannerServer) []interface{} {
+	var reqs []interface{}
+loop:
+	for {
+		select {
+		case req := <-server.ReceivedRequests():
+			reqs = append(reqs, req)
+		default:
+			break loop
+		}
+	}
+	return reqs
+}
+
+func waitFor(t *testing.T, assert func() error) {
+	t.Helper()
+	timeout := 5 * time.Second
+	ta := time.After(timeout)
+
+	for {
+		select {
+		case <-ta:
+			if err := assert(); err != nil {
+				t.Fatalf("after %v waiting, got %v", timeout, err)
+			}
+			return
+		default:
 		}
-		if cmp.Equal(time.Time{}, ts) {
-			t.Fatalf("got zero commit timestamp: %v", ts)
+
+		if err := assert(); err != nil {
+			// Fail. Let's pause and retry.
+			time.Sleep(time.Millisecond)
+			continue
 		}
+
+		return
 	}
 }
 
-func TestCommitTimestampAutocommit(t *testing.T) {
+func TestMaxConcurrentReads(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.Background()
-	db, _, teardown := setupTestDBConnection(t)
-	defer teardown()
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address)
 
-	conn, err := db.Conn(ctx)
-	defer conn.Close()
+	if _, err := CreateConnector(dsn, ConnectorConfig{MaxConcurrentReads: -1}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	connector, err := CreateConnector(dsn, ConnectorConfig{MaxConcurrentReads: 1})
 	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
+		t.Fatal(err)
 	}
-	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
-		t.Fatalf("failed to execute update: %v", err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Get the commit timestamp from the connection.
-	// We do this in a simple loop to verify that we can get it multiple times.
-	for i := 0; i < 2; i++ {
-		var ts time.Time
-		if err := conn.Raw(func(driverConn interface{}) error {
-			ts, err = driverConn.(SpannerConn).CommitTimestamp()
-			return err
-		}); err != nil {
-			t.Fatalf("failed to get commit timestamp: %v", err)
-		}
-		if cmp.Equal(time.Time{}, ts) {
-			t.Fatalf("got zero commit timestamp: %v", ts)
+	// A second query waits until the first query has finished, and respects
+	// the deadline of its context while it is waiting.
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := db.QueryContext(waitCtx, testutil.SelectFooFromBar); spanner.ErrCode(err) != codes.DeadlineExceeded {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.DeadlineExceeded)
+	}
+	queried := make(chan error, 1)
+	go func() {
+		rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Close()
 		}
+		queried <- err
+	}()
+	select {
+	case err := <-queried:
+		t.Fatalf("query finished while another query was active: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Consuming all rows releases the read.
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-queried; err != nil {
+		t.Fatal(err)
 	}
+	_ = rows.Close()
 }
 
-func TestCommitTimestampFailsAfterRollback(t *testing.T) {
+func TestConnectTimeout(t *testing.T) {
 	t.Parallel()
 
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address)
+	connector, err := CreateConnector(dsn, ConnectorConfig{ConnectTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
 	ctx := context.Background()
-	db, _, teardown := setupTestDBConnection(t)
-	defer teardown()
 
-	conn, err := db.Conn(ctx)
-	defer conn.Close()
-	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
+	// The server does not respond, so the first connection cannot be opened.
+	server.TestSpanner.Freeze()
+	err = db.PingContext(ctx)
+	server.TestSpanner.Unfreeze()
+	if g, w := spanner.ErrCode(err), codes.DeadlineExceeded; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	tx, err := conn.BeginTx(ctx, nil)
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeepAliveInterval(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address)
+	failed := make(chan error, 1)
+	c, err := CreateConnector(dsn, ConnectorConfig{
+		KeepAliveInterval: 20 * time.Millisecond,
+		OnKeepAliveFailed: func(err error) {
+			select {
+			case failed <- err:
+			default:
+			}
+		},
+	})
 	if err != nil {
-		t.Fatalf("failed to start transaction: %v", err)
+		t.Fatal(err)
 	}
-	if err := tx.Rollback(); err != nil {
-		t.Fatalf("rollback failed: %v", err)
+	db := sql.OpenDB(c)
+	ctx := context.Background()
+	// The keep-alive queries are started when the first connection is opened.
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
 	}
-	// Try to get the commit timestamp from the connection.
-	err = conn.Raw(func(driverConn interface{}) error {
-		_, err = driverConn.(SpannerConn).CommitTimestamp()
-		return err
+
+	countKeepAlives := func() int {
+		count := 0
+		for _, req := range requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{})) {
+			if req.(*sppb.ExecuteSqlRequest).Sql == "SELECT 1" {
+				count++
+			}
+		}
+		return count
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for checks := 0; checks < 3; checks += countKeepAlives() {
+		if time.Now().After(deadline) {
+			t.Fatalf("keep-alive queries mismatch\n Got: %v\nWant at least: 3", checks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Failed keep-alive queries are reported.
+	_ = server.TestSpanner.PutStatementResult("SELECT 1", &testutil.StatementResult{
+		Type: testutil.StatementResultError,
+		Err:  gstatus.Error(codes.PermissionDenied, "permission denied"),
 	})
-	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
-		t.Fatalf("get commit timestamp error code mismatch\n Got: %v\nWant: %v", g, w)
+	for reported := false; !reported; {
+		select {
+		case err := <-failed:
+			// Slow keep-alive queries can also fail with DeadlineExceeded.
+			reported = spanner.ErrCode(err) == codes.PermissionDenied
+		case <-time.After(5 * time.Second):
+			t.Fatal("keep-alive failure was not reported")
+		}
+	}
+
+	// Closing the database stops the keep-alive queries.
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	countKeepAlives()
+	time.Sleep(50 * time.Millisecond)
+	if g, w := countKeepAlives(), 0; g != w {
+		t.Fatalf("keep-alive queries after close mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestCommitTimestampFailsAfterAutocommitQuery(t *testing.T) {
+func TestNewConnector_EmulatorHost(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.Background()
-	db, _, teardown := setupTestDBConnection(t)
-	defer teardown()
-
-	conn, err := db.Conn(ctx)
-	defer conn.Close()
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	c, err := NewConnector(ConnectorConfig{
+		Project:      "p",
+		Instance:     "i",
+		Database:     "d",
+		EmulatorHost: server.Address,
+	})
 	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
-	}
-	var v string
-	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(&v); err != nil {
-		t.Fatalf("failed to execute query: %v", err)
+		t.Fatal(err)
 	}
-	// Try to get the commit timestamp from the connection. This should not be possible as a query in autocommit mode
-	// will not return a commit timestamp.
-	err = conn.Raw(func(driverConn interface{}) error {
-		_, err = driverConn.(SpannerConn).CommitTimestamp()
-		return err
-	})
-	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
-		t.Fatalf("get commit timestamp error code mismatch\n Got: %v\nWant: %v", g, w)
+	db := sql.OpenDB(c)
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
 	}
 }
 
-func TestShowVariableCommitTimestamp(t *testing.T) {
+func TestDateParamsInAllExecutionPaths(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	db, _, teardown := setupTestDBConnection(t)
+	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
+	query := "UPDATE Singers SET BirthDate=@d WHERE TRUE"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
 
-	conn, err := db.Conn(ctx)
-	defer conn.Close()
-	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
-	}
-	tx, err := conn.BeginTx(ctx, nil)
-	if err != nil {
-		t.Fatalf("failed to start transaction: %v", err)
+	date := civil.Date{Year: 2000, Month: 2, Day: 29}
+	for _, test := range []struct {
+		name     string
+		value    interface{}
+		wantType *sppb.Type
+		want     *structpb.Value
+	}{
+		{
+			name:     "civil.Date",
+			value:    date,
+			wantType: &sppb.Type{Code: sppb.TypeCode_DATE},
+			want:     structpb.NewStringValue("2000-02-29"),
+		},
+		{
+			name:     "spanner.NullDate",
+			value:    spanner.NullDate{Date: date, Valid: true},
+			wantType: &sppb.Type{Code: sppb.TypeCode_DATE},
+			want:     structpb.NewStringValue("2000-02-29"),
+		},
+		{
+			name:     "null spanner.NullDate",
+			value:    spanner.NullDate{},
+			wantType: &sppb.Type{Code: sppb.TypeCode_DATE},
+			want:     structpb.NewNullValue(),
+		},
+		{
+			name:     "[]civil.Date",
+			value:    []civil.Date{date},
+			wantType: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_DATE}},
+			want:     structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("2000-02-29")}}),
+		},
+		{
+			name:     "[]spanner.NullDate",
+			value:    []spanner.NullDate{{Date: date, Valid: true}, {}},
+			wantType: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_DATE}},
+			want:     structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("2000-02-29"), structpb.NewNullValue()}}),
+		},
+		{
+			name:     "*civil.Date",
+			value:    &date,
+			wantType: &sppb.Type{Code: sppb.TypeCode_DATE},
+			want:     structpb.NewStringValue("2000-02-29"),
+		},
+		{
+			name:     "nil *civil.Date",
+			value:    (*civil.Date)(nil),
+			wantType: &sppb.Type{Code: sppb.TypeCode_DATE},
+			want:     structpb.NewNullValue(),
+		},
+		{
+			name:     "[]*civil.Date",
+			value:    []*civil.Date{&date, nil},
+			wantType: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_DATE}},
+			want:     structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("2000-02-29"), structpb.NewNullValue()}}),
+		},
+		{
+			name:     "[1]civil.Date",
+			value:    [1]civil.Date{date},
+			wantType: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_DATE}},
+			want:     structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("2000-02-29")}}),
+		},
+	} {
+		for _, path := range []struct {
+			name string
+			exec func(value interface{}) error
+		}{
+			{
+				name: "autocommit",
+				exec: func(value interface{}) error {
+					_, err := db.ExecContext(ctx, query, sql.Named("d", value))
+					return err
+				},
+			},
+			{
+				name: "transaction",
+				exec: func(value interface{}) error {
+					tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+					if err != nil {
+						return err
+					}
+					if _, err := tx.ExecContext(ctx, query, sql.Named("d", value)); err != nil {
+						_ = tx.Rollback()
+						return err
+					}
+					return tx.Commit()
+				},
+			},
+			{
+				name: "batch dml",
+				exec: func(value interface{}) error {
+					conn, err := db.Conn(ctx)
+					if err != nil {
+						return err
+					}
+					defer conn.Close()
+					if _, err := conn.ExecContext(ctx, "START BATCH DML"); err != nil {
+						return err
+					}
+					if _, err := conn.ExecContext(ctx, query, sql.Named("d", value)); err != nil {
+						return err
+					}
+					_, err = conn.ExecContext(ctx, "RUN BATCH")
+					return err
+				},
+			},
+			{
+				name: "exec many",
+				exec: func(value interface{}) error {
+					_, err := ExecMany(ctx, db, query, [][]interface{}{{sql.Named("d", value)}})
+					return err
+				},
+			},
+			{
+				name: "prepared statement",
+				exec: func(value interface{}) error {
+					stmt, err := db.PrepareContext(ctx, query)
+					if err != nil {
+						return err
+					}
+					defer stmt.Close()
+					_, err = stmt.ExecContext(ctx, sql.Named("d", value))
+					return err
+				},
+			},
+		} {
+			if err := path.exec(test.value); err != nil {
+				t.Errorf("%s/%s: %v", test.name, path.name, err)
+				continue
+			}
+			var params *structpb.Struct
+			var paramTypes map[string]*sppb.Type
+			for _, req := range drainRequestsFromServer(server.TestSpanner) {
+				switch r := req.(type) {
+				case *sppb.ExecuteSqlRequest:
+					params, paramTypes = r.Params, r.ParamTypes
+				case *sppb.ExecuteBatchDmlRequest:
+					params, paramTypes = r.Statements[0].Params, r.Statements[0].ParamTypes
+				}
+			}
+			if params == nil {
+				t.Errorf("%s/%s: no statement was executed", test.name, path.name)
+				continue
+			}
+			if g, w := paramTypes["d"], test.wantType; !proto.Equal(g, w) {
+				t.Errorf("%s/%s: param type mismatch\n Got: %v\nWant: %v", test.name, path.name, g, w)
+			}
+			if g, w := params.Fields["d"], test.want; !proto.Equal(g, w) {
+				t.Errorf("%s/%s: param value mismatch\n Got: %v\nWant: %v", test.name, path.name, g, w)
+			}
+		}
 	}
-	if err := tx.Commit(); err != nil {
-		t.Fatalf("commit failed: %v", err)
+}
+
+func TestCancelQueryReleasesSession(t *testing.T) {
+	t.Parallel()
+
+	// The session pool only contains one session, so a session that is not
+	// returned to the pool blocks all following queries.
+	db, _, teardown := setupTestDBConnectionWithParams(t, "minSessions=1;maxSessions=1")
+	defer teardown()
+
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rows.Next() {
+			t.Fatalf("%d: missing row: %v", i, rows.Err())
+		}
+		// Cancel the query while the rows are still being read.
+		cancel()
+		for rows.Next() {
+		}
+		if i%2 == 0 {
+			_ = rows.Close()
+		}
 	}
-	// Get the commit timestamp from the connection using a custom SQL statement.
-	// We do this in a simple loop to verify that we can get it multiple times.
 	for i := 0; i < 2; i++ {
-		var ts time.Time
-		if err := conn.QueryRowContext(ctx, "SHOW VARIABLE COMMIT_TIMESTAMP").Scan(&ts); err != nil {
-			t.Fatalf("failed to get commit timestamp: %v", err)
+		// Rows that are not consumed completely release the session when
+		// they are closed.
+		rows, err := db.QueryContext(context.Background(), testutil.SelectFooFromBar)
+		if err != nil {
+			t.Fatal(err)
 		}
-		if cmp.Equal(time.Time{}, ts) {
-			t.Fatalf("got zero commit timestamp: %v", ts)
+		if !rows.Next() {
+			t.Fatalf("missing row: %v", rows.Err())
 		}
+		_ = rows.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
 	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("session was not returned to the pool: %v", err)
+	}
+	_ = rows.Close()
 }
 
-func TestMinSessions(t *testing.T) {
+func TestDmlWithThenReturn(t *testing.T) {
 	t.Parallel()
 
-	minSessions := int32(10)
-	ctx := context.Background()
-	db, server, teardown := setupTestDBConnectionWithParams(t, fmt.Sprintf("minSessions=%v", minSessions))
+	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
+	ctx := context.Background()
 
+	query := "INSERT INTO Singers (Name) VALUES ('foo') THEN RETURN SingerId"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateSingleColumnResultSet([]int64{1, 2}, "SingerId"),
+	})
 	conn, err := db.Conn(ctx)
 	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
-	}
-	var res int64
-	if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
-		t.Fatalf("failed to execute query on connection: %v", err)
+		t.Fatal(err)
 	}
-	// Wait until all sessions have been created.
-	waitFor(t, func() error {
-		created := int32(server.TestSpanner.TotalSessionsCreated())
-		if created != minSessions {
-			return fmt.Errorf("num open sessions mismatch\n Got: %d\nWant: %d", created, minSessions)
+	defer conn.Close()
+
+	queryIds := func(q interface {
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	}) []int64 {
+		rows, err := q.QueryContext(ctx, query)
+		if err != nil {
+			t.Fatal(err)
 		}
-		return nil
-	})
-	_ = conn.Close()
-	_ = db.Close()
+		defer rows.Close()
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
 
-	// Verify that the connector created 10 sessions on the server.
-	reqs := drainRequestsFromServer(server.TestSpanner)
-	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
-	numCreated := int32(0)
-	for _, req := range createReqs {
-		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	// The statement is executed and committed in a read/write transaction in
+	// autocommit mode.
+	if g, w := queryIds(conn), []int64{1, 2}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("ids mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	executeRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(executeRequests), 1; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if executeRequests[0].(*sppb.ExecuteSqlRequest).GetTransaction().GetBegin().GetReadWrite() == nil {
+		t.Fatalf("missing read/write transaction: %v", executeRequests[0].(*sppb.ExecuteSqlRequest).GetTransaction())
+	}
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).CommitTimestamp()
+		return err
+	}); err != nil {
+		t.Fatalf("missing commit timestamp: %v", err)
+	}
+
+	// The statement is executed in the current transaction.
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := queryIds(tx), []int64{1, 2}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("ids mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))), 1; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	if g, w := numCreated, minSessions; g != w {
-		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestMaxSessions(t *testing.T) {
+func TestMaxCommitDelay(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	db, server, teardown := setupTestDBConnectionWithParams(t, "minSessions=0;maxSessions=2")
+	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
 
-	var wg sync.WaitGroup
-	for i := 0; i < 20; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			conn, err := db.Conn(ctx)
-			if err != nil {
-				t.Errorf("failed to get a connection: %v", err)
-			}
-			var res int64
-			if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
-				t.Errorf("failed to execute query on connection: %v", err)
+	verify := func(name string, want *time.Duration) {
+		requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.CommitRequest{}))
+		if g, w := len(requests), 1; g != w {
+			t.Fatalf("%s: commit requests count mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		delay := requests[0].(*sppb.CommitRequest).MaxCommitDelay
+		if want == nil {
+			if delay != nil {
+				t.Fatalf("%s: max commit delay mismatch\n Got: %v\nWant: nil", name, delay.AsDuration())
 			}
-			_ = conn.Close()
-		}()
+			return
+		}
+		if delay == nil {
+			t.Fatalf("%s: missing max commit delay", name)
+		}
+		if g, w := delay.AsDuration(), *want; g != w {
+			t.Fatalf("%s: max commit delay mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
 	}
-	wg.Wait()
 
-	// Verify that the connector only created 2 sessions on the server.
-	reqs := drainRequestsFromServer(server.TestSpanner)
-	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
-	numCreated := int32(0)
-	for _, req := range createReqs {
-		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	// The max commit delay of the connection is used for DML in autocommit
+	// mode and for transactions.
+	if _, err := conn.ExecContext(ctx, "SET MAX_COMMIT_DELAY = '100ms'"); err != nil {
+		t.Fatal(err)
 	}
-	if g, w := numCreated, int32(2); g != w {
-		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
+	var value string
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE MAX_COMMIT_DELAY").Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := value, "100ms"; g != w {
+		t.Fatalf("max commit delay mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	connDelay := 100 * time.Millisecond
+	verify("autocommit", &connDelay)
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	verify("transaction", &connDelay)
+
+	// The transaction options override the max commit delay of the
+	// connection.
+	txDelay := 50 * time.Millisecond
+	tx, err = conn.BeginTx(WithReadWriteTransactionOptions(ctx, ReadWriteTransactionOptions{MaxCommitDelay: &txDelay}), &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	verify("transaction options", &txDelay)
+
+	// A max commit delay that is larger than the maximum of Spanner is
+	// rejected before the transaction is started.
+	tooLong := time.Second
+	if _, err := conn.BeginTx(WithReadWriteTransactionOptions(ctx, ReadWriteTransactionOptions{MaxCommitDelay: &tooLong}), &sql.TxOptions{}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
 	}
+	if _, err := conn.ExecContext(ctx, "SET MAX_COMMIT_DELAY = '1s'"); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SET MAX_COMMIT_DELAY = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	verify("default", nil)
 }
 
-func TestClientReuse(t *testing.T) {
+func TestRPCPriority(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	db, server, teardown := setupTestDBConnectionWithParams(t, "minSessions=2")
+	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
+	_ = server.TestSpanner.PutStatementResult("SELECT SingerId, Rating FROM Singers", &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1, 100}}, [2]string{"SingerId", "Rating"}),
+	})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
 
-	// Repeatedly get a connection and close it using the same DB instance. These
-	// connections should all share the same Spanner client, and only initialized
-	// one session pool.
-	for i := 0; i < 5; i++ {
-		conn, err := db.Conn(ctx)
-		if err != nil {
-			t.Fatalf("failed to get a connection: %v", err)
+	if _, err := conn.ExecContext(ctx, "SET RPC_PRIORITY = 'LOW'"); err != nil {
+		t.Fatal(err)
+	}
+	var priority string
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE RPC_PRIORITY").Scan(&priority); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := priority, "LOW"; g != w {
+		t.Fatalf("priority mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	var id, rating int64
+	run := func() {
+		if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(&id); err != nil {
+			t.Fatal(err)
 		}
-		var res int64
-		if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
-			t.Fatalf("failed to execute query on connection: %v", err)
+		if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+			t.Fatal(err)
+		}
+		if err := ReadRow(ctx, conn, "Singers", spanner.Key{int64(1)}, []string{"SingerId", "Rating"}, &id, &rating); err != nil {
+			t.Fatal(err)
 		}
-		_ = conn.Close()
-	}
-	// Verify that the connector only created 2 sessions on the server.
-	reqs := drainRequestsFromServer(server.TestSpanner)
-	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
-	numCreated := int32(0)
-	for _, req := range createReqs {
-		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
 	}
-	if g, w := numCreated, int32(2); g != w {
-		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
+	verify := func(name string, want sppb.RequestOptions_Priority, wantCommits int) {
+		requests := drainRequestsFromServer(server.TestSpanner)
+		executeRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		if g, w := len(executeRequests), 2; g != w {
+			t.Fatalf("%s: execute requests count mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		for _, req := range executeRequests {
+			if g, w := req.(*sppb.ExecuteSqlRequest).GetRequestOptions().GetPriority(), want; g != w {
+				t.Fatalf("%s: execute priority mismatch\n Got: %v\nWant: %v", name, g, w)
+			}
+		}
+		readRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ReadRequest{}))
+		if g, w := len(readRequests), 1; g != w {
+			t.Fatalf("%s: read requests count mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		if g, w := readRequests[0].(*sppb.ReadRequest).GetRequestOptions().GetPriority(), want; g != w {
+			t.Fatalf("%s: read priority mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		commitRequests := requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))
+		if g, w := len(commitRequests), wantCommits; g != w {
+			t.Fatalf("%s: commit requests count mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		for _, req := range commitRequests {
+			if g, w := req.(*sppb.CommitRequest).GetRequestOptions().GetPriority(), want; g != w {
+				t.Fatalf("%s: commit priority mismatch\n Got: %v\nWant: %v", name, g, w)
+			}
+		}
 	}
 
-	// Now close the DB instance and create a new DB connection.
-	// This should cause the first Spanner client to be closed and
-	// a new one to be opened.
-	_ = db.Close()
+	// The priority of the connection is used in autocommit mode.
+	run()
+	verify("autocommit", sppb.RequestOptions_PRIORITY_LOW, 1)
 
-	db, err := sql.Open(
-		"spanner",
-		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;minSessions=2", server.Address))
+	// The priority of the connection is also used in transactions.
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		t.Fatalf("failed to open new DB instance: %v", err)
+		t.Fatal(err)
 	}
-	var res int64
-	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
-		t.Fatalf("failed to execute query on db: %v", err)
+	run()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
 	}
-	reqs = drainRequestsFromServer(server.TestSpanner)
-	createReqs = requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
-	numCreated = int32(0)
-	for _, req := range createReqs {
-		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	verify("transaction", sppb.RequestOptions_PRIORITY_LOW, 1)
+
+	// ExecOptions override the priority of the connection.
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar, ExecOptions{Priority: sppb.RequestOptions_PRIORITY_HIGH}).Scan(&id); err != nil {
+		t.Fatal(err)
 	}
-	if g, w := numCreated, int32(2); g != w {
-		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := requests[0].(*sppb.ExecuteSqlRequest).GetRequestOptions().GetPriority(), sppb.RequestOptions_PRIORITY_HIGH; g != w {
+		t.Fatalf("priority mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Setting the priority to NULL uses the default priority of the client.
+	if _, err := conn.ExecContext(ctx, "SET RPC_PRIORITY = 'NULL'"); err != nil {
+		t.Fatal(err)
 	}
+	run()
+	verify("default", sppb.RequestOptions_PRIORITY_UNSPECIFIED, 1)
 }
 
-func TestStressClientReuse(t *testing.T) {
+func TestRPCPriority_DmlBatch(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	_, server, teardown := setupTestDBConnection(t)
+	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
 
-	rand.Seed(time.Now().UnixNano())
-	numSessions := 10
-	numClients := 5
-	numParallel := 50
-	var wg sync.WaitGroup
-	for clientIndex := 0; clientIndex < numClients; clientIndex++ {
-		// Open a DB using a dsn that contains a meaningless number. This will ensure that
-		// the underlying client will be different from the other connections that use a
-		// different number.
-		db, err := sql.Open("spanner",
-			fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;minSessions=%v;maxSessions=%v;randomNumber=%v", server.Address, numSessions, numSessions, clientIndex))
-		if err != nil {
-			t.Fatalf("failed to open DB: %v", err)
+	if _, err := conn.ExecContext(ctx, "SET RPC_PRIORITY = 'LOW'"); err != nil {
+		t.Fatal(err)
+	}
+	runBatch := func(execer interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	}) {
+		for _, stmt := range []string{"START BATCH DML", testutil.UpdateBarSetFoo, testutil.UpdateBarSetFoo, "RUN BATCH"} {
+			if _, err := execer.ExecContext(ctx, stmt); err != nil {
+				t.Fatal(err)
+			}
 		}
-		// Execute random operations in parallel on the database.
-		for i := 0; i < numParallel; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				conn, err := db.Conn(ctx)
-				if err != nil {
-					t.Errorf("failed to get a connection: %v", err)
-				}
-				if rand.Int()%2 == 0 {
-					if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
-						t.Errorf("failed to execute update on connection: %v", err)
-					}
-				} else {
-					var res int64
-					if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&res); err != nil {
-						t.Errorf("failed to execute query on connection: %v", err)
-					}
-				}
-				_ = conn.Close()
-			}()
+	}
+	verify := func(name string, wantTransactionTag string) {
+		requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+		if g, w := len(requests), 1; g != w {
+			t.Fatalf("%s: batch DML requests count mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		options := requests[0].(*sppb.ExecuteBatchDmlRequest).GetRequestOptions()
+		if g, w := options.GetPriority(), sppb.RequestOptions_PRIORITY_LOW; g != w {
+			t.Fatalf("%s: priority mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		if g, w := options.GetTransactionTag(), wantTransactionTag; g != w {
+			t.Fatalf("%s: transaction tag mismatch\n Got: %v\nWant: %v", name, g, w)
 		}
 	}
-	wg.Wait()
 
-	// Verify that each unique connection string created numSessions (10) sessions on the server.
-	reqs := drainRequestsFromServer(server.TestSpanner)
-	createReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.BatchCreateSessionsRequest{}))
-	numCreated := int32(0)
-	for _, req := range createReqs {
-		numCreated += req.(*sppb.BatchCreateSessionsRequest).SessionCount
+	// The priority of the connection is used for batches in autocommit mode.
+	runBatch(conn)
+	verify("autocommit", "")
+
+	// The priority of the connection is also used for batches in transactions.
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
 	}
-	if g, w := numCreated, int32(numSessions*numClients); g != w {
-		t.Errorf("session creation count mismatch\n Got: %v\nWant: %v", g, w)
+	runBatch(tx)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
 	}
-	sqlReqs := requestsOfType(reqs, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if g, w := len(sqlReqs), numClients*numParallel; g != w {
-		t.Errorf("ExecuteSql request count mismatch\n Got: %v\nWant: %v", g, w)
+	verify("transaction", "")
+
+	// The priority and the tag of the transaction are used for batches in
+	// the transaction.
+	if err := RunTransaction(ctx, db, ReadWriteTransactionOptions{Priority: sppb.RequestOptions_PRIORITY_LOW, TransactionTag: "tx-tag"}, func(ctx context.Context, tx *sql.Tx) error {
+		runBatch(tx)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
 	}
+	verify("RunTransaction", "tx-tag")
 }
 
-func TestExcludeTxnFromChangeStreams_AutoCommitUpdate(t *testing.T) {
+func TestAutocommitDisabled(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-
 	conn, err := db.Conn(ctx)
-	defer conn.Close()
 	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET AUTOCOMMIT = FALSE"); err != nil {
+		t.Fatal(err)
+	}
+	var autocommit bool
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE AUTOCOMMIT").Scan(&autocommit); err != nil {
+		t.Fatal(err)
+	}
+	if autocommit {
+		t.Fatal("autocommit mismatch\n Got: true\nWant: false")
+	}
+
+	// The first statement starts a transaction that is committed by COMMIT.
+	drainRequestsFromServer(server.TestSpanner)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "SET AUTOCOMMIT = TRUE"); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))), 1; g != w {
+		t.Fatalf("begin requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 3; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, req := range sqlRequests {
+		if _, ok := req.(*sppb.ExecuteSqlRequest).Transaction.GetSelector().(*sppb.TransactionSelector_Id); !ok {
+			t.Fatalf("%d: statement was not executed in the transaction: %v", i, req.(*sppb.ExecuteSqlRequest).Transaction)
+		}
+	}
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var commitTs time.Time
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE COMMIT_TIMESTAMP").Scan(&commitTs); err != nil {
+		t.Fatal(err)
+	}
+
+	// ROLLBACK rolls back the transaction, and the next statement starts a
+	// new transaction.
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "ROLLBACK TRANSACTION"); err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.RollbackRequest{}))), 1; g != w {
+		t.Fatalf("rollback requests count mismatch\n Got: %v\nWant: %v", g, w)
 	}
-
-	var exclude bool
-	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE EXCLUDE_TXN_FROM_CHANGE_STREAMS").Scan(&exclude); err != nil {
-		t.Fatalf("failed to get exclude setting: %v", err)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 0; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	if g, w := exclude, false; g != w {
-		t.Fatalf("exclude_txn_from_change_streams mismatch\n Got: %v\nWant: %v", g, w)
+	// COMMIT and ROLLBACK are no-ops without a transaction.
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		t.Fatal(err)
 	}
-	if _, err := conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true"); err != nil {
+	if _, err := conn.ExecContext(ctx, "ROLLBACK"); err != nil {
 		t.Fatal(err)
 	}
-	if _, err = conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+
+	// Transactions that are started with BeginTx must be ended with
+	// Tx.Commit or Tx.Rollback.
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
 		t.Fatal(err)
 	}
-	requests := drainRequestsFromServer(server.TestSpanner)
-	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
-	if g, w := len(sqlRequests), 1; g != w {
-		t.Fatalf("ExecuteSqlRequests count mismatch\nGot: %v\nWant: %v", g, w)
+	if _, err := tx.ExecContext(ctx, "COMMIT"); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
 	}
-	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
-	if req.Transaction == nil {
-		t.Fatalf("missing transaction for ExecuteSqlRequest")
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
 	}
-	if _, ok := req.Transaction.Selector.(*sppb.TransactionSelector_Begin); !ok {
-		t.Fatalf("unsupported transaction type %T", req.Transaction.Selector)
+
+	// Switching autocommit back on executes statements without a transaction.
+	if _, err := conn.ExecContext(ctx, "SET AUTOCOMMIT = TRUE"); err != nil {
+		t.Fatal(err)
 	}
-	begin := req.Transaction.Selector.(*sppb.TransactionSelector_Begin)
-	if !begin.Begin.ExcludeTxnFromChangeStreams {
-		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
+	drainRequestsFromServer(server.TestSpanner)
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))), 0; g != w {
+		t.Fatalf("begin requests count mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func TestExcludeTxnFromChangeStreams_AutoCommitBatchDml(t *testing.T) {
+func TestSessionVariables(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
 	db, server, teardown := setupTestDBConnection(t)
 	defer teardown()
-
 	conn, err := db.Conn(ctx)
-	defer conn.Close()
 	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
+		t.Fatal(err)
 	}
+	defer conn.Close()
 
-	conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true")
-	conn.ExecContext(ctx, "start batch dml")
-	conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
-	conn.ExecContext(ctx, "run batch")
-	requests := drainRequestsFromServer(server.TestSpanner)
-	batchRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
-	if g, w := len(batchRequests), 1; g != w {
-		t.Fatalf("ExecuteBatchDmlRequest count mismatch\nGot: %v\nWant: %v", g, w)
+	// The optimizer options of the connection are used for all queries.
+	for _, stmt := range []string{"SET OPTIMIZER_VERSION = '3'", "SET OPTIMIZER_STATISTICS_PACKAGE = 'auto_20191128_14_47_22UTC'"} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
 	}
-	req := batchRequests[0].(*sppb.ExecuteBatchDmlRequest)
-	if req.Transaction == nil {
-		t.Fatalf("missing transaction for ExecuteBatchDmlRequest")
+	var version string
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE OPTIMIZER_VERSION").Scan(&version); err != nil {
+		t.Fatal(err)
 	}
-	if _, ok := req.Transaction.Selector.(*sppb.TransactionSelector_Begin); !ok {
-		t.Fatalf("unsupported transaction type %T", req.Transaction.Selector)
+	if g, w := version, "3"; g != w {
+		t.Fatalf("optimizer version mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	begin := req.Transaction.Selector.(*sppb.TransactionSelector_Begin)
-	if !begin.Begin.ExcludeTxnFromChangeStreams {
-		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
+	drainRequestsFromServer(server.TestSpanner)
+	if err := conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	options := requests[0].(*sppb.ExecuteSqlRequest).QueryOptions
+	if g, w := options.GetOptimizerVersion(), "3"; g != w {
+		t.Fatalf("optimizer version mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := options.GetOptimizerStatisticsPackage(), "auto_20191128_14_47_22UTC"; g != w {
+		t.Fatalf("optimizer statistics package mismatch\n Got: %v\nWant: %v", g, w)
 	}
-}
-
-func TestExcludeTxnFromChangeStreams_PartitionedDml(t *testing.T) {
-	t.Parallel()
-
-	ctx := context.Background()
-	db, server, teardown := setupTestDBConnection(t)
-	defer teardown()
 
-	conn, err := db.Conn(ctx)
-	defer conn.Close()
+	// A read-only connection only allows queries, and all transactions are
+	// read-only transactions.
+	if _, err := conn.ExecContext(ctx, "SET READONLY = TRUE"); err != nil {
+		t.Fatal(err)
+	}
+	var readOnly bool
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE READONLY").Scan(&readOnly); err != nil {
+		t.Fatal(err)
+	}
+	if !readOnly {
+		t.Fatal("read-only mismatch\n Got: false\nWant: true")
+	}
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		t.Fatalf("failed to get a connection: %v", err)
+		t.Fatal(err)
 	}
-
-	conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true")
-	conn.ExecContext(ctx, "set autocommit_dml_mode = 'partitioned_non_atomic'")
-	conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
-	requests := drainRequestsFromServer(server.TestSpanner)
-	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
-	if g, w := len(beginRequests), 1; g != w {
-		t.Fatalf("BeginTransactionRequest count mismatch\nGot: %v\nWant: %v", g, w)
+	if err := tx.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(new(int64)); err != nil {
+		t.Fatal(err)
 	}
-	req := beginRequests[0].(*sppb.BeginTransactionRequest)
-	if !req.Options.ExcludeTxnFromChangeStreams {
-		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
+	if _, err := conn.ExecContext(ctx, "SET READONLY = FALSE"); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	requests = requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.BeginTransactionRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("begin requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if requests[0].(*sppb.BeginTransactionRequest).Options.GetReadOnly() == nil {
+		t.Fatalf("transaction mode mismatch\n Got: %v\nWant: read-only", requests[0].(*sppb.BeginTransactionRequest).Options)
 	}
 }
 
-func TestExcludeTxnFromChangeStreams_Transaction(t *testing.T) {
+func TestStatementTimeout(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
@@ -2335,178 +5135,103 @@ func TestExcludeTxnFromChangeStreams_Transaction(t *testing.T) {
 	defer teardown()
 
 	conn, err := db.Conn(ctx)
-	defer conn.Close()
 	if err != nil {
 		t.Fatalf("failed to get a connection: %v", err)
 	}
-
-	var exclude bool
-	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE EXCLUDE_TXN_FROM_CHANGE_STREAMS").Scan(&exclude); err != nil {
-		t.Fatalf("failed to get exclude setting: %v", err)
-	}
-	if g, w := exclude, false; g != w {
-		t.Fatalf("exclude_txn_from_change_streams mismatch\n Got: %v\nWant: %v", g, w)
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "set statement_timeout = '50ms'"); err != nil {
+		t.Fatal(err)
 	}
-	conn.ExecContext(ctx, "set exclude_txn_from_change_streams = true")
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
-	if err != nil {
+	var timeout string
+	if err := conn.QueryRowContext(ctx, "show variable statement_timeout").Scan(&timeout); err != nil {
 		t.Fatal(err)
 	}
-	conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
-	tx.Commit()
-
-	requests := drainRequestsFromServer(server.TestSpanner)
-	beginRequests := requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))
-	if g, w := len(beginRequests), 1; g != w {
-		t.Fatalf("BeginTransactionRequest count mismatch\nGot: %v\nWant: %v", g, w)
+	if g, w := timeout, "50ms"; g != w {
+		t.Fatalf("statement timeout mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	req := beginRequests[0].(*sppb.BeginTransactionRequest)
-	if !req.Options.ExcludeTxnFromChangeStreams {
-		t.Fatalf("missing ExcludeTxnFromChangeStreams option on BeginTransaction option")
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteStreamingSql, testutil.SimulatedExecutionTime{
+		MinimumExecutionTime: 10 * time.Second,
+	})
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		_ = rows.Close()
 	}
-
-	// Verify that the flag is reset after the transaction.
-	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE EXCLUDE_TXN_FROM_CHANGE_STREAMS").Scan(&exclude); err != nil {
-		t.Fatalf("failed to get exclude setting: %v", err)
+	if g, w := spanner.ErrCode(err), codes.DeadlineExceeded; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	if g, w := exclude, false; g != w {
-		t.Fatalf("exclude_txn_from_change_streams mismatch\n Got: %v\nWant: %v", g, w)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("statement did not time out promptly: %v", elapsed)
 	}
-
-}
-
-func numeric(v string) big.Rat {
-	res, _ := big.NewRat(1, 1).SetString(v)
-	return *res
-}
-
-func nullNumeric(valid bool, v string) spanner.NullNumeric {
-	if !valid {
-		return spanner.NullNumeric{}
+	// The earlier deadline of the context wins.
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "set statement_timeout = '1h'"); err != nil {
+		t.Fatal(err)
 	}
-	return spanner.NullNumeric{Valid: true, Numeric: numeric(v)}
-}
-
-func date(v string) civil.Date {
-	res, _ := civil.ParseDate(v)
-	return res
-}
-
-func nullDate(valid bool, v string) spanner.NullDate {
-	if !valid {
-		return spanner.NullDate{}
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteSql, testutil.SimulatedExecutionTime{
+		MinimumExecutionTime: 10 * time.Second,
+	})
+	start = time.Now()
+	_, err = conn.ExecContext(shortCtx, testutil.UpdateBarSetFoo)
+	if g, w := spanner.ErrCode(err), codes.DeadlineExceeded; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
 	}
-	return spanner.NullDate{Valid: true, Date: date(v)}
-}
-
-func nullJson(valid bool, v string) spanner.NullJSON {
-	if !valid {
-		return spanner.NullJSON{}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("statement did not time out promptly: %v", elapsed)
 	}
-	var m map[string]interface{}
-	_ = json.Unmarshal([]byte(v), &m)
-	return spanner.NullJSON{Valid: true, Value: m}
-}
-
-func setupTestDBConnection(t *testing.T) (db *sql.DB, server *testutil.MockedSpannerInMemTestServer, teardown func()) {
-	return setupTestDBConnectionWithParams(t, "")
-}
 
-func setupTestDBConnectionWithParams(t *testing.T, params string) (db *sql.DB, server *testutil.MockedSpannerInMemTestServer, teardown func()) {
-	server, _, serverTeardown := setupMockedTestServer(t)
-	db, err := sql.Open(
-		"spanner",
-		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;%s", server.Address, params))
-	if err != nil {
-		serverTeardown()
+	// A timeout of '0' disables the statement timeout.
+	if _, err := conn.ExecContext(ctx, "set statement_timeout = '0'"); err != nil {
 		t.Fatal(err)
 	}
-	return db, server, func() {
-		_ = db.Close()
-		serverTeardown()
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteSql, testutil.SimulatedExecutionTime{})
+	if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func setupMockedTestServer(t *testing.T) (server *testutil.MockedSpannerInMemTestServer, client *spanner.Client, teardown func()) {
-	return setupMockedTestServerWithConfig(t, spanner.ClientConfig{})
-}
-
-func setupMockedTestServerWithConfig(t *testing.T, config spanner.ClientConfig) (server *testutil.MockedSpannerInMemTestServer, client *spanner.Client, teardown func()) {
-	return setupMockedTestServerWithConfigAndClientOptions(t, config, []option.ClientOption{})
-}
-
-func setupMockedTestServerWithConfigAndClientOptions(t *testing.T, config spanner.ClientConfig, clientOptions []option.ClientOption) (server *testutil.MockedSpannerInMemTestServer, client *spanner.Client, teardown func()) {
-	server, opts, serverTeardown := testutil.NewMockedSpannerInMemTestServer(t)
-	opts = append(opts, clientOptions...)
-	ctx := context.Background()
-	formattedDatabase := fmt.Sprintf("projects/%s/instances/%s/databases/%s", "[PROJECT]", "[INSTANCE]", "[DATABASE]")
-	client, err := spanner.NewClientWithConfig(ctx, formattedDatabase, config, opts...)
-	if err != nil {
+	if err := conn.QueryRowContext(ctx, "show variable statement_timeout").Scan(&timeout); err != nil {
 		t.Fatal(err)
 	}
-	return server, client, func() {
-		client.Close()
-		serverTeardown()
+	if g, w := timeout, "NULL"; g != w {
+		t.Fatalf("statement timeout mismatch\n Got: %v\nWant: %v", g, w)
 	}
 }
 
-func filterBeginReadOnlyRequests(requests []interface{}) []*sppb.BeginTransactionRequest {
-	res := make([]*sppb.BeginTransactionRequest, 0)
-	for _, r := range requests {
-		if req, ok := r.(*sppb.BeginTransactionRequest); ok {
-			if req.Options != nil && req.Options.GetReadOnly() != nil {
-				res = append(res, req)
-			}
-		}
-	}
-	return res
-}
+func TestBeginTxIsolationLevel(t *testing.T) {
+	t.Parallel()
 
-func requestsOfType(requests []interface{}, t reflect.Type) []interface{} {
-	res := make([]interface{}, 0)
-	for _, req := range requests {
-		if reflect.TypeOf(req) == t {
-			res = append(res, req)
-		}
-	}
-	return res
-}
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
 
-func drainRequestsFromServer(server testutil.InMemSpannerServer) []interface{} {
-	var reqs []interface{}
-loop:
-	for {
-		select {
-		case req := <-server.ReceivedRequests():
-			reqs = append(reqs, req)
-		default:
-			break loop
+	for _, test := range []struct {
+		level    sql.IsolationLevel
+		readOnly bool
+		wantCode codes.Code
+	}{
+		{sql.LevelDefault, false, codes.OK},
+		{sql.LevelSerializable, false, codes.OK},
+		{sql.LevelLinearizable, false, codes.OK},
+		{sql.LevelRepeatableRead, false, codes.Unimplemented},
+		{sql.LevelSnapshot, false, codes.Unimplemented},
+		{sql.LevelReadUncommitted, false, codes.InvalidArgument},
+		{sql.LevelReadCommitted, false, codes.InvalidArgument},
+		{sql.LevelWriteCommitted, false, codes.InvalidArgument},
+		{sql.LevelRepeatableRead, true, codes.OK},
+		{sql.LevelSnapshot, true, codes.OK},
+		{sql.LevelReadCommitted, true, codes.InvalidArgument},
+	} {
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: test.level, ReadOnly: test.readOnly})
+		if g, w := spanner.ErrCode(err), test.wantCode; g != w {
+			t.Errorf("%v (read-only: %v): error code mismatch\n Got: %v\nWant: %v", test.level, test.readOnly, g, w)
 		}
-	}
-	return reqs
-}
-
-func waitFor(t *testing.T, assert func() error) {
-	t.Helper()
-	timeout := 5 * time.Second
-	ta := time.After(timeout)
-
-	for {
-		select {
-		case <-ta:
-			if err := assert(); err != nil {
-				t.Fatalf("after %v waiting, got %v", timeout, err)
+		if err == nil {
+			if err := tx.Rollback(); err != nil {
+				t.Fatal(err)
 			}
-			return
-		default:
 		}
-
-		if err := assert(); err != nil {
-			// Fail. Let's pause and retry.
-			time.Sleep(time.Millisecond)
-			continue
-		}
-
-		return
 	}
 }
@@ -0,0 +1,146 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultEndpoint is the endpoint of Spanner that is used if neither the
+// connection string nor the ConnectorConfig specifies an endpoint.
+const defaultEndpoint = "spanner.googleapis.com:443"
+
+// EffectiveConnectorConfig is the configuration of a connector after the
+// connection string has been parsed and all defaults have been applied. It
+// is intended for diagnostics, for example to log the configuration that a
+// connector actually uses. It never contains any credentials.
+type EffectiveConnectorConfig struct {
+	// Config is the ConnectorConfig of the connector, including the values
+	// in the connection string. CredentialsJSON is always nil, and
+	// SessionPoolConfig is the session pool configuration that is used by
+	// the Spanner client, including the minSessions and maxSessions
+	// connection properties. MinSessions is always equal to
+	// SessionPoolConfig.MinOpened.
+	Config ConnectorConfig
+
+	// Endpoint is the host and port of the Spanner API that the connector
+	// connects to.
+	Endpoint string
+	// Emulator indicates that the connector connects using plain text and
+	// without authentication, for example to the Spanner emulator.
+	Emulator bool
+	// NumChannels is the number of gRPC channels of the Spanner client. The
+	// default of the Spanner client is used if this is zero.
+	NumChannels int
+	// DatabaseRole is the database role that is used for fine-grained access
+	// control.
+	DatabaseRole string
+	// DisableRouteToLeader indicates that read/write transactions and
+	// Partitioned DML are not routed to the leader region.
+	DisableRouteToLeader bool
+
+	// Priority is the default RPC priority of all requests. The default of
+	// Spanner is used if this is PRIORITY_UNSPECIFIED.
+	Priority spannerpb.RequestOptions_Priority
+	// OptimizerVersion and OptimizerStatisticsPackage are the default query
+	// optimizer options.
+	OptimizerVersion           string
+	OptimizerStatisticsPackage string
+	// RetryAbortsInternally is the default for new connections.
+	RetryAbortsInternally bool
+	// AutoMarshalJSON indicates that query parameters of unsupported types
+	// are marshalled to JSON.
+	AutoMarshalJSON bool
+
+	// Dialect is the dialect of the database. It is DialectUnspecified if the
+	// dialect has not yet been read from the database.
+	Dialect Dialect
+	// Shared indicates that the connector was created by sql.Open, and is
+	// shared by all sql.DB instances that are opened with the same
+	// connection string. Closing one of them does not close the Spanner
+	// client of the connector.
+	Shared bool
+}
+
+// EffectiveConfig returns the effective configuration of the given connector.
+// The connector must have been created by this driver, for example with
+// CreateConnector or NewConnector. EffectiveConfig does not connect to
+// Spanner.
+//
+// Example:
+//
+//	connector, err := spannerdriver.CreateConnector(dsn, spannerdriver.ConnectorConfig{})
+//	if err != nil {
+//		return err
+//	}
+//	config, err := spannerdriver.EffectiveConfig(connector)
+//	if err != nil {
+//		return err
+//	}
+//	log.Printf("connecting to %s with %d max sessions", config.Endpoint, config.Config.SessionPoolConfig.MaxOpened)
+func EffectiveConfig(c driver.Connector) (*EffectiveConnectorConfig, error) {
+	spannerConnector, ok := c.(*connector)
+	if !ok {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "not a Spanner connector: %T", c))
+	}
+	return spannerConnector.effectiveConfig(), nil
+}
+
+func (c *connector) effectiveConfig() *EffectiveConnectorConfig {
+	config := c.config
+	config.Project = c.connectorConfig.project
+	config.Instance = c.connectorConfig.instance
+	config.Database = c.connectorConfig.database
+	config.CredentialsJSON = nil
+	sessionPoolConfig := c.spannerClientConfig.SessionPoolConfig
+	config.SessionPoolConfig = &sessionPoolConfig
+	config.MinSessions = sessionPoolConfig.MinOpened
+
+	// The connection properties have already been validated when the
+	// connector was created.
+	usePlainText, _, _ := parseBoolParam(c.connectorConfig.params, "usePlainText")
+	endpoint := defaultEndpoint
+	if config.EmulatorHost != "" {
+		endpoint = config.EmulatorHost
+	} else if c.connectorConfig.host != "" {
+		endpoint = c.connectorConfig.host
+	}
+	clientConfig := c.spannerClientConfig
+	res := &EffectiveConnectorConfig{
+		Config:                config,
+		Endpoint:              endpoint,
+		Emulator:              usePlainText || config.EmulatorHost != "",
+		NumChannels:           clientConfig.NumChannels,
+		DatabaseRole:          clientConfig.DatabaseRole,
+		DisableRouteToLeader:  clientConfig.DisableRouteToLeader,
+		Priority:              clientConfig.QueryOptions.Priority,
+		RetryAbortsInternally: c.retryAbortsInternally,
+		AutoMarshalJSON:       c.autoMarshalJSON,
+		Shared:                c.cached,
+	}
+	if clientConfig.QueryOptions.Options != nil {
+		res.OptimizerVersion = clientConfig.QueryOptions.Options.OptimizerVersion
+		res.OptimizerStatisticsPackage = clientConfig.QueryOptions.Options.OptimizerStatisticsPackage
+	}
+	c.dialectMu.Lock()
+	res.Dialect = c.dialect
+	c.dialectMu.Unlock()
+	return res
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	t.Parallel()
+
+	c, err := CreateConnector("localhost:9010/projects/p/instances/i/databases/d?usePlainText=true&maxSessions=20&rpcPriority=LOW&optimizerVersion=3&retryAbortsInternally=false&databaseRole=reader",
+		ConnectorConfig{CredentialsJSON: []byte(`{"type": "service_account", "private_key": "secret"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := EffectiveConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Config.CredentialsJSON != nil {
+		t.Fatalf("credentials were not redacted: %s", config.Config.CredentialsJSON)
+	}
+	if g, w := config.Config.Project+"/"+config.Config.Instance+"/"+config.Config.Database, "p/i/d"; g != w {
+		t.Errorf("database mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := config.Endpoint, "localhost:9010"; g != w {
+		t.Errorf("endpoint mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !config.Emulator {
+		t.Error("emulator mismatch\n Got: false\nWant: true")
+	}
+	if g, w := config.Config.SessionPoolConfig.MaxOpened, uint64(20); g != w {
+		t.Errorf("max sessions mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := config.Config.SessionPoolConfig.MinOpened, spanner.DefaultSessionPoolConfig.MinOpened; g != w {
+		t.Errorf("min sessions mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := config.Priority, spannerpb.RequestOptions_PRIORITY_LOW; g != w {
+		t.Errorf("priority mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := config.OptimizerVersion, "3"; g != w {
+		t.Errorf("optimizer version mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := config.DatabaseRole, "reader"; g != w {
+		t.Errorf("database role mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if config.RetryAbortsInternally {
+		t.Error("retry aborts internally mismatch\n Got: true\nWant: false")
+	}
+	if g, w := config.Dialect, DialectUnspecified; g != w {
+		t.Errorf("dialect mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if config.Shared {
+		t.Error("shared mismatch\n Got: true\nWant: false")
+	}
+
+	// The defaults are returned for a connection string without properties.
+	d := &Driver{connectors: make(map[string]*connector)}
+	c, err = d.OpenConnector("projects/p/instances/i/databases/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err = EffectiveConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := config.Endpoint, defaultEndpoint; g != w {
+		t.Errorf("endpoint mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if config.Emulator {
+		t.Error("emulator mismatch\n Got: true\nWant: false")
+	}
+	if g, w := *config.Config.SessionPoolConfig, spanner.DefaultSessionPoolConfig; g.MinOpened != w.MinOpened || g.MaxOpened != w.MaxOpened {
+		t.Errorf("session pool mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if !config.RetryAbortsInternally {
+		t.Error("retry aborts internally mismatch\n Got: false\nWant: true")
+	}
+	if !config.Shared {
+		t.Error("shared mismatch\n Got: false\nWant: true")
+	}
+
+	if _, err := EffectiveConfig(nil); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
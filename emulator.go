@@ -0,0 +1,73 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	instanceapi "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultEmulatorHost is the host of the Spanner emulator that is used with
+// AutoConfigEmulator if no other host has been set.
+const defaultEmulatorHost = "localhost:9010"
+
+// createEmulatorDatabase creates the instance and the database of the
+// connector on the emulator if they do not already exist.
+func (c *connector) createEmulatorDatabase(ctx context.Context, opts []option.ClientOption) error {
+	project := fmt.Sprintf("projects/%s", c.connectorConfig.project)
+	instance := fmt.Sprintf("%s/instances/%s", project, c.connectorConfig.instance)
+
+	instanceClient, err := instanceapi.NewInstanceAdminClient(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer instanceClient.Close()
+	instanceOp, err := instanceClient.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     project,
+		InstanceId: c.connectorConfig.instance,
+		Instance: &instancepb.Instance{
+			Config:      fmt.Sprintf("%s/instanceConfigs/emulator-config", project),
+			DisplayName: c.connectorConfig.instance,
+			NodeCount:   1,
+		},
+	})
+	if err == nil {
+		_, err = instanceOp.Wait(ctx)
+	}
+	if err != nil && spanner.ErrCode(err) != codes.AlreadyExists {
+		return err
+	}
+
+	databaseClient, err := adminapi.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer databaseClient.Close()
+	databaseOp, err := databaseClient.CreateDatabase(ctx, createDatabaseRequest(instance, c.connectorConfig.database, c.dialect))
+	if err == nil {
+		_, err = databaseOp.Wait(ctx)
+	}
+	if err != nil && spanner.ErrCode(err) != codes.AlreadyExists {
+		return err
+	}
+	return nil
+}
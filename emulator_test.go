@@ -0,0 +1,177 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAutoConfigEmulator(t *testing.T) {
+	t.Parallel()
+
+	server, _, teardown := setupMockedTestServer(t)
+	defer teardown()
+	server.TestInstanceAdmin.SetResps([]proto.Message{doneOperation(t, &instancepb.Instance{Name: "projects/p/instances/i"})})
+	server.TestDatabaseAdmin.SetResps([]proto.Message{doneOperation(t, &databasepb.Database{Name: "projects/p/instances/i/databases/d"})})
+
+	// The connection string does not contain usePlainText=true, as this is
+	// implied by autoConfigEmulator=true.
+	db, err := sql.Open("spanner", fmt.Sprintf("%s/projects/p/instances/i/databases/d?autoConfigEmulator=true", server.Address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	instanceRequests := server.TestInstanceAdmin.Reqs()
+	if g, w := len(instanceRequests), 1; g != w {
+		t.Fatalf("instance requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	createInstance := instanceRequests[0].(*instancepb.CreateInstanceRequest)
+	if g, w := createInstance.Parent, "projects/p"; g != w {
+		t.Fatalf("parent mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := createInstance.InstanceId, "i"; g != w {
+		t.Fatalf("instance id mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := createInstance.Instance.Config, "projects/p/instanceConfigs/emulator-config"; g != w {
+		t.Fatalf("instance config mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	databaseRequests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(databaseRequests), 1; g != w {
+		t.Fatalf("database requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	createDatabase := databaseRequests[0].(*databasepb.CreateDatabaseRequest)
+	if g, w := createDatabase.Parent, "projects/p/instances/i"; g != w {
+		t.Fatalf("parent mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := createDatabase.CreateStatement, "CREATE DATABASE `d`"; g != w {
+		t.Fatalf("create statement mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAutoConfigEmulator_AlreadyExists(t *testing.T) {
+	t.Parallel()
+
+	server, _, teardown := setupMockedTestServer(t)
+	defer teardown()
+	server.TestInstanceAdmin.SetErr(status.Error(codes.AlreadyExists, "Instance already exists"))
+	server.TestDatabaseAdmin.SetErr(status.Error(codes.AlreadyExists, "Database already exists"))
+
+	connector, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?dialect=postgresql", server.Address),
+		ConnectorConfig{AutoConfigEmulator: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	databaseRequests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(databaseRequests), 1; g != w {
+		t.Fatalf("database requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	createDatabase := databaseRequests[0].(*databasepb.CreateDatabaseRequest)
+	if g, w := createDatabase.CreateStatement, `CREATE DATABASE "d"`; g != w {
+		t.Fatalf("create statement mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := createDatabase.DatabaseDialect, databasepb.DatabaseDialect_POSTGRESQL; g != w {
+		t.Fatalf("dialect mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAutoConfigEmulator_Failed(t *testing.T) {
+	t.Parallel()
+
+	server, _, teardown := setupMockedTestServer(t)
+	defer teardown()
+	server.TestInstanceAdmin.SetErr(status.Error(codes.PermissionDenied, "Permission denied"))
+
+	connector, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d", server.Address),
+		ConnectorConfig{AutoConfigEmulator: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if err := db.PingContext(context.Background()); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", status.Code(err), codes.PermissionDenied)
+	}
+	if g, w := len(server.TestDatabaseAdmin.Reqs()), 0; g != w {
+		t.Fatalf("database requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAutoConfigEmulator_Host(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		dsn     string
+		envHost string
+		want    string
+	}{
+		{
+			name: "default",
+			dsn:  "projects/p/instances/i/databases/d?autoConfigEmulator=true",
+			want: "localhost:9010",
+		},
+		{
+			name:    "environment",
+			dsn:     "projects/p/instances/i/databases/d?autoConfigEmulator=true",
+			envHost: "localhost:9020",
+			want:    "localhost:9020",
+		},
+		{
+			name:    "connection string",
+			dsn:     "localhost:9030/projects/p/instances/i/databases/d?autoConfigEmulator=true",
+			envHost: "localhost:9020",
+			want:    "localhost:9030",
+		},
+		{
+			name:    "environment without autoConfigEmulator",
+			dsn:     "projects/p/instances/i/databases/d",
+			envHost: "localhost:9020",
+			want:    "localhost:9020",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("SPANNER_EMULATOR_HOST", test.envHost)
+			c, err := createConnector(&Driver{connectors: make(map[string]*connector)}, test.dsn, ConnectorConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := c.effectiveConfig()
+			if g, w := config.Endpoint, test.want; g != w {
+				t.Fatalf("endpoint mismatch\n Got: %v\nWant: %v", g, w)
+			}
+			if !config.Emulator {
+				t.Fatal("emulator mismatch\n Got: false\nWant: true")
+			}
+		})
+	}
+}
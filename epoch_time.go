@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EpochUnit is the unit of an INT64 value that contains the time since the
+// Unix epoch.
+type EpochUnit int
+
+const (
+	// EpochSeconds indicates that the value is the number of seconds since
+	// the Unix epoch.
+	EpochSeconds EpochUnit = iota + 1
+	// EpochMillis indicates that the value is the number of milliseconds
+	// since the Unix epoch.
+	EpochMillis
+	// EpochMicros indicates that the value is the number of microseconds
+	// since the Unix epoch.
+	EpochMicros
+)
+
+func (u EpochUnit) String() string {
+	switch u {
+	case EpochSeconds:
+		return "EpochSeconds"
+	case EpochMillis:
+		return "EpochMillis"
+	case EpochMicros:
+		return "EpochMicros"
+	}
+	return "EpochUnit(unspecified)"
+}
+
+// EpochTime is a time.Time that is stored in an INT64 column as the time
+// since the Unix epoch in the given unit. It can be used to scan an INT64
+// column into a time.Time, and as a query parameter that is sent to Spanner
+// as an INT64. Plain INT64 columns and parameters are never converted to or
+// from time.Time. Unit must be set, and Valid is false if the column value
+// is NULL.
+//
+// Values are truncated to the precision of the unit when they are sent to
+// Spanner. Scanned values are returned in UTC.
+//
+// Example:
+//
+//	created := spannerdriver.EpochTime{Unit: spannerdriver.EpochMillis}
+//	err := db.QueryRowContext(ctx, "SELECT CreatedMillis FROM Events WHERE Id=1").Scan(&created)
+//
+//	_, err = db.ExecContext(ctx, "UPDATE Events SET CreatedMillis=@created WHERE Id=1",
+//		sql.Named("created", spannerdriver.EpochTime{Time: time.Now(), Unit: spannerdriver.EpochMillis, Valid: true}))
+type EpochTime struct {
+	Time  time.Time
+	Unit  EpochUnit
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (e *EpochTime) Scan(src interface{}) error {
+	e.Time, e.Valid = time.Time{}, false
+	if err := e.Unit.validate(); err != nil {
+		return err
+	}
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case int64:
+		switch e.Unit {
+		case EpochSeconds:
+			e.Time = time.Unix(v, 0).UTC()
+		case EpochMillis:
+			e.Time = time.UnixMilli(v).UTC()
+		case EpochMicros:
+			e.Time = time.UnixMicro(v).UTC()
+		}
+		e.Valid = true
+		return nil
+	}
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid type for EpochTime: %T, expected an INT64 column", src))
+}
+
+// Value implements the driver.Valuer interface.
+func (e EpochTime) Value() (driver.Value, error) {
+	if err := e.Unit.validate(); err != nil {
+		return nil, err
+	}
+	if !e.Valid {
+		// Return a typed NULL, so Spanner knows the type of the parameter.
+		return spanner.NullInt64{}, nil
+	}
+	switch e.Unit {
+	case EpochSeconds:
+		return e.Time.Unix(), nil
+	case EpochMillis:
+		return e.Time.UnixMilli(), nil
+	default:
+		return e.Time.UnixMicro(), nil
+	}
+}
+
+func (u EpochUnit) validate() error {
+	switch u {
+	case EpochSeconds, EpochMillis, EpochMicros:
+		return nil
+	}
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid unit for EpochTime: %v", u))
+}
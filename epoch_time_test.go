@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestEpochTime_ScanAndValue(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	for _, test := range []struct {
+		unit  EpochUnit
+		value int64
+		want  time.Time
+	}{
+		{EpochSeconds, 1714979289, ts.Truncate(time.Second)},
+		{EpochMillis, 1714979289123, ts.Truncate(time.Millisecond)},
+		{EpochMicros, 1714979289123456, ts.Truncate(time.Microsecond)},
+	} {
+		e := EpochTime{Unit: test.unit}
+		if err := e.Scan(test.value); err != nil {
+			t.Fatalf("%v: %v", test.unit, err)
+		}
+		if !e.Valid || !e.Time.Equal(test.want) || e.Time.Location() != time.UTC {
+			t.Fatalf("%v: time mismatch\n Got: %v\nWant: %v", test.unit, e.Time, test.want)
+		}
+		v, err := EpochTime{Time: ts, Unit: test.unit, Valid: true}.Value()
+		if err != nil {
+			t.Fatalf("%v: %v", test.unit, err)
+		}
+		if g, w := v, test.value; g != w {
+			t.Fatalf("%v: value mismatch\n Got: %v\nWant: %v", test.unit, g, w)
+		}
+	}
+
+	e := EpochTime{Unit: EpochMillis}
+	if err := e.Scan(nil); err != nil || e.Valid {
+		t.Fatalf("NULL scan mismatch: %v, %v", e, err)
+	}
+	if v, err := e.Value(); err != nil || v != (spanner.NullInt64{}) {
+		t.Fatalf("NULL value mismatch: %v, %v", v, err)
+	}
+	if err := e.Scan("2024-05-06"); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	// The unit must be set explicitly.
+	if err := (&EpochTime{}).Scan(int64(1)); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestEpochTime(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT CreatedMillis, CreatedMicros FROM Events"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1714979289123, 1714979289123456}}, [2]string{"CreatedMillis", "CreatedMicros"}),
+	})
+	millis := EpochTime{Unit: EpochMillis}
+	micros := EpochTime{Unit: EpochMicros}
+	if err := db.QueryRowContext(ctx, query).Scan(&millis, &micros); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	if g, w := millis.Time, want.Truncate(time.Millisecond); !g.Equal(w) {
+		t.Fatalf("millis mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := micros.Time, want; !g.Equal(w) {
+		t.Fatalf("micros mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// EpochTime parameters are sent to Spanner as INT64.
+	update := "UPDATE Events SET CreatedMillis=@millis, CreatedMicros=@micros WHERE Id=1"
+	_ = server.TestSpanner.PutStatementResult(update, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	if _, err := db.ExecContext(ctx, update,
+		sql.Named("millis", EpochTime{Time: want, Unit: EpochMillis, Valid: true}),
+		sql.Named("micros", EpochTime{Time: want, Unit: EpochMicros, Valid: true})); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	req := requests[len(requests)-1].(*sppb.ExecuteSqlRequest)
+	for name, want := range map[string]string{"millis": "1714979289123", "micros": "1714979289123456"} {
+		if g, w := req.ParamTypes[name].GetCode(), sppb.TypeCode_INT64; g != w {
+			t.Fatalf("%s: type mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		if g, w := req.Params.Fields[name].GetStringValue(), want; g != w {
+			t.Fatalf("%s: value mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+	}
+}
@@ -24,7 +24,7 @@ import (
 
 	"cloud.google.com/go/civil"
 	"cloud.google.com/go/spanner"
-	_ "github.com/googleapis/go-sql-spanner"
+	spannerdriver "github.com/googleapis/go-sql-spanner"
 	"github.com/googleapis/go-sql-spanner/examples"
 )
 
@@ -87,10 +87,10 @@ func dataTypes(projectId, instanceId, databaseId string) error {
                       boolArray, stringArray, bytesArray, int64Array, float32Array, float64Array, numericArray, dateArray, timestampArray)
                       VALUES (@key, @bool, @string, @bytes, @int64, @float32, @float64, @numeric, @date, @timestamp,
                               @boolArray, @stringArray, @bytesArray, @int64Array, @float32Array, @float64Array, @numericArray, @dateArray, @timestampArray)`,
-		2, spanner.NullBool{}, spanner.NullString{}, []byte(nil), // There is no NullBytes type
+		2, spanner.NullBool{}, spanner.NullString{}, spannerdriver.NullBytes{},
 		spanner.NullInt64{}, spanner.NullFloat32{}, spanner.NullFloat64{}, spanner.NullNumeric{}, spanner.NullDate{}, spanner.NullTime{},
 		// These array values all contain two NULL values in the (non-null) array.
-		[]spanner.NullBool{{}, {}}, []spanner.NullString{{}, {}}, [][]byte{[]byte(nil), []byte(nil)},
+		[]spanner.NullBool{{}, {}}, []spanner.NullString{{}, {}}, []spannerdriver.NullBytes{{}, {}},
 		[]spanner.NullInt64{{}, {}}, []spanner.NullFloat32{{}, {}}, []spanner.NullFloat64{{}, {}}, []spanner.NullNumeric{{}, {}},
 		[]spanner.NullDate{{}, {}}, []spanner.NullTime{{}, {}}); err != nil {
 		return fmt.Errorf("failed to insert a record with all null values using DML: %v", err)
@@ -147,6 +147,29 @@ func dataTypes(projectId, instanceId, databaseId string) error {
 	}
 	fmt.Print("Queried a test record with all null values and stored these in sql.Null* variables\n")
 
+	// Arrays that do not contain any NULL elements can be decoded into native Go slices with the
+	// DecodeToNativeArrays option. DATE values can be decoded into time.Time values at midnight in a
+	// given location with the DateLocation option.
+	var int64Array []int64
+	var dateArray []time.Time
+	if err := db.QueryRowContext(ctx, "SELECT int64Array, dateArray FROM AllTypes WHERE key=@key",
+		spannerdriver.ExecOptions{DecodeToNativeArrays: true, DateLocation: time.UTC}, 1).Scan(
+		&int64Array, &dateArray,
+	); err != nil {
+		return fmt.Errorf("failed to get arrays as native Go slices: %v", err)
+	}
+	fmt.Printf("Queried arrays as native Go slices: %v, %v\n", int64Array, dateArray)
+
+	// A single array column can also be scanned into a native Go slice with spannerdriver.NativeArray.
+	// Native Go slices can be used both as query parameters and as scan targets.
+	var dates []civil.Date
+	if err := db.QueryRowContext(ctx, "SELECT dateArray FROM AllTypes WHERE key=@key", 1).Scan(
+		spannerdriver.NativeArray(&dates),
+	); err != nil {
+		return fmt.Errorf("failed to get array as native Go slice: %v", err)
+	}
+	fmt.Printf("Queried array as native Go slice: %v\n", dates)
+
 	return nil
 }
 
@@ -179,7 +202,7 @@ type nullTypes struct {
 	key            int64
 	bool           spanner.NullBool
 	string         spanner.NullString
-	bytes          []byte // There is no spanner.NullBytes type
+	bytes          spannerdriver.NullBytes // There is no spanner.NullBytes type
 	int64          spanner.NullInt64
 	float32        spanner.NullFloat32
 	float64        spanner.NullFloat64
@@ -201,7 +224,7 @@ type sqlNullTypes struct {
 	key       int64
 	bool      sql.NullBool
 	string    sql.NullString
-	bytes     []byte // There is no sql.NullBytes type
+	bytes     spannerdriver.NullBytes // There is no sql.NullBytes type
 	int64     sql.NullInt64
 	float32   spanner.NullFloat32 // sql.Null[float32] can be used from Go 1.22
 	float64   sql.NullFloat64
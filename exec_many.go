@@ -0,0 +1,154 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchDMLStatements is the maximum number of statements that ExecMany
+// sends to Spanner in one Batch DML request.
+const maxBatchDMLStatements = 20000
+
+// Execer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ExecManyError is returned by ExecMany if one of the parameter sets fails.
+type ExecManyError struct {
+	// Index is the index of the parameter set that failed.
+	Index int
+	// Err is the error that was returned for the parameter set.
+	Err error
+}
+
+func (e *ExecManyError) Error() string {
+	return fmt.Sprintf("parameter set %d failed: %v", e.Index, e.Err)
+}
+
+func (e *ExecManyError) Unwrap() error {
+	return e.Err
+}
+
+// ExecMany executes the given DML statement once for each of the given sets
+// of arguments, and returns the number of affected rows for each set. The
+// statements are sent to Spanner as Batch DML requests, which is a lot more
+// efficient than executing each statement separately. Large numbers of
+// argument sets are automatically split into multiple Batch DML requests.
+//
+// Execution stops at the first statement that fails. The returned error is
+// then an *ExecManyError with the index of the failed set of arguments, and
+// the returned counts contain the affected rows of the sets that were
+// executed successfully before the failure.
+//
+// The statements are executed in the transaction of the given execer if it is
+// a *sql.Tx. Otherwise, each Batch DML request is executed in a separate
+// transaction. In that case, the statements are not applied atomically if
+// more than one Batch DML request is needed, and the counts do not include
+// the statements of the request that failed, as that request is rolled back.
+//
+// Example:
+//
+//	counts, err := spannerdriver.ExecMany(ctx, db,
+//		"UPDATE Singers SET Active=@active WHERE SingerId=@id",
+//		[][]interface{}{
+//			{sql.Named("active", true), sql.Named("id", 1)},
+//			{sql.Named("active", false), sql.Named("id", 2)},
+//		})
+func ExecMany(ctx context.Context, execer Execer, query string, paramSets [][]interface{}) ([]int64, error) {
+	var counts []int64
+	req := execManyRequest{paramSets: paramSets, counts: &counts}
+	_, err := execer.ExecContext(ctx, query, req)
+	return counts, err
+}
+
+// execManyRequest is a set of parameter sets for a DML statement that is
+// passed in as an argument to the statement in the same way as ExecOptions.
+// The number of affected rows for each set is stored in counts.
+type execManyRequest struct {
+	paramSets [][]interface{}
+	counts    *[]int64
+}
+
+// execMany executes the given DML statement for each of the parameter sets of
+// the given request using Batch DML.
+func (c *conn) execMany(ctx context.Context, query string, execOptions ExecOptions, req execManyRequest) (driver.Result, error) {
+	if c.inBatch() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "ExecMany cannot be used while a batch is active"))
+	}
+	if c.inReadOnlyTransaction() {
+		return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "read-only transactions cannot write"))
+	}
+	statements := make([]spanner.Statement, len(req.paramSets))
+	for i, params := range req.paramSets {
+		namedValues, err := c.toNamedValues(params)
+		if err == nil {
+			statements[i], err = c.prepareSpannerStmt(ctx, query, namedValues)
+		}
+		if err != nil {
+			return nil, &ExecManyError{Index: i, Err: err}
+		}
+	}
+	options := execOptions.queryOptions()
+
+	counts := make([]int64, 0, len(statements))
+	var err error
+	for start := 0; start < len(statements); start += maxBatchDMLStatements {
+		end := start + maxBatchDMLStatements
+		if end > len(statements) {
+			end = len(statements)
+		}
+		var affected []int64
+		affected, err = c.batchUpdate(ctx, statements[start:end], options)
+		if err != nil {
+			index := start + len(affected)
+			if c.tx == nil {
+				// The request was executed in its own transaction, which was
+				// rolled back.
+				affected = nil
+			}
+			counts = append(counts, affected...)
+			err = &ExecManyError{Index: index, Err: err}
+			break
+		}
+		counts = append(counts, affected...)
+	}
+	*req.counts = counts
+	return &result{rowsAffected: sum(counts)}, err
+}
+
+// batchUpdate executes the given statements as one Batch DML request in the
+// current read/write transaction, or in a new read/write transaction if the
+// connection is not in a transaction.
+func (c *conn) batchUpdate(ctx context.Context, statements []spanner.Statement, options spanner.QueryOptions) ([]int64, error) {
+	if tx, ok := c.tx.(*readWriteTransaction); ok {
+		return tx.batchUpdate(ctx, statements, options)
+	}
+	var affected []int64
+	_, err := c.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, transaction *spanner.ReadWriteTransaction) error {
+		var err error
+		affected, err = transaction.BatchUpdateWithOptions(ctx, statements, options)
+		return err
+	}, c.createTransactionOptions())
+	return affected, err
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExecMany(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "UPDATE Singers SET Active=@active WHERE SingerId=@id"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	paramSets := [][]interface{}{
+		{sql.Named("active", true), sql.Named("id", 1)},
+		{sql.Named("active", false), sql.Named("id", 2)},
+		{sql.Named("active", true), sql.Named("id", 3)},
+	}
+	counts, err := ExecMany(ctx, db, query, paramSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := counts, []int64{1, 1, 1}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("counts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("batch requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteBatchDmlRequest)
+	if g, w := len(req.Statements), 3; g != w {
+		t.Fatalf("statements count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Statements[1].Params.Fields["id"].GetStringValue(), "2"; g != w {
+		t.Fatalf("id mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// The statements are executed in the transaction.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExecMany(ctx, tx, query, paramSets); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	batchRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if g, w := len(batchRequests), 1; g != w {
+		t.Fatalf("batch requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.CommitRequest{}))), 1; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// An invalid argument returns the index of the parameter set.
+	_, err = ExecMany(ctx, db, query, [][]interface{}{
+		{sql.Named("active", true), sql.Named("id", 1)},
+		{sql.Named("active", true), sql.Named("id", struct{}{})},
+	})
+	var execManyErr *ExecManyError
+	if !errors.As(err, &execManyErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g, w := execManyErr.Index, 1; g != w {
+		t.Fatalf("index mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestExecMany_Chunks(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "UPDATE Singers SET Active=true WHERE SingerId=@id"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	paramSets := make([][]interface{}, maxBatchDMLStatements+1)
+	for i := range paramSets {
+		paramSets[i] = []interface{}{sql.Named("id", i)}
+	}
+	counts, err := ExecMany(ctx, db, query, paramSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(counts), len(paramSets); g != w {
+		t.Fatalf("counts length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteBatchDmlRequest{}))
+	if g, w := len(requests), 2; g != w {
+		t.Fatalf("batch requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requests[1].(*sppb.ExecuteBatchDmlRequest).Statements), 1; g != w {
+		t.Fatalf("statements count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestExecMany_Error(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "UPDATE Singers SET Active=true WHERE SingerId=@id"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type: testutil.StatementResultError,
+		Err:  status.Error(codes.FailedPrecondition, "constraint violation"),
+	})
+	counts, err := ExecMany(ctx, db, query, [][]interface{}{{sql.Named("id", 1)}, {sql.Named("id", 2)}})
+	var execManyErr *ExecManyError
+	if !errors.As(err, &execManyErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g, w := execManyErr.Index, 0; g != w {
+		t.Fatalf("index mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(counts), 0; g != w {
+		t.Fatalf("counts length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
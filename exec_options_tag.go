@@ -0,0 +1,204 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExecOptionsTag can be embedded in a struct to define ExecOptions with a
+// struct tag. A value of the struct can then be passed in as an argument to
+// the Query, QueryContext, Exec and ExecContext functions in the same way as
+// ExecOptions. This allows generated query types to carry their own execution
+// options.
+//
+// The tag is a comma-separated list of `key=value` pairs. Keys are
+// case-insensitive. The supported keys are:
+//   - priority: The RPC priority of the statement (low, medium or high).
+//   - tag: The request tag of the statement.
+//   - optimizerVersion: The optimizer version to use.
+//   - optimizerStatisticsPackage: The optimizer statistics package to use.
+//   - analyze: The analyze mode of the statement (plan or profile).
+//   - cacheable: Whether the result of the query may be cached (true or false).
+//   - nullAsZeroValue: Whether NULL values should be returned as zero values
+//     (true or false).
+//   - decodeToNativeArrays: Whether arrays should be returned as slices of
+//     native Go types (true or false).
+//   - decodeNumericAsString: Whether NUMERIC values should be returned as
+//     strings (true or false).
+//   - allowNumericToFloat64: Whether NUMERIC values should be returned as
+//     float64 values (true or false). This is lossy.
+//   - decodeJsonAsBytes: Whether JSON values should be returned as byte
+//     slices (true or false).
+//   - statementType: The type of the statement (query, dml or ddl).
+//
+// An unknown key or an invalid value causes the statement to fail with an
+// InvalidArgument error.
+//
+// Example:
+//
+//	type reportsQuery struct {
+//		spannerdriver.ExecOptionsTag `spanner:"priority=low,tag=reports"`
+//	}
+//
+//	rows, err := db.QueryContext(ctx, "SELECT * FROM Reports", reportsQuery{})
+type ExecOptionsTag struct{}
+
+var execOptionsTagType = reflect.TypeOf(ExecOptionsTag{})
+
+// execOptionsTagCache contains the parsed ExecOptions of each struct type
+// that has been checked for an embedded ExecOptionsTag.
+var execOptionsTagCache sync.Map
+
+type cachedExecOptions struct {
+	found   bool
+	options ExecOptions
+	err     error
+}
+
+// execOptionsFromTag returns the ExecOptions that are defined by the struct
+// tag of an embedded ExecOptionsTag in the given value. The returned ok value
+// is false if the value is not a struct that embeds ExecOptionsTag.
+func execOptionsFromTag(value interface{}) (ExecOptions, bool, error) {
+	t := reflect.TypeOf(value)
+	if t == nil || t.Kind() != reflect.Struct {
+		return ExecOptions{}, false, nil
+	}
+	if cached, ok := execOptionsTagCache.Load(t); ok {
+		c := cached.(*cachedExecOptions)
+		return c.options, c.found, c.err
+	}
+	c := &cachedExecOptions{}
+	if field, ok := findExecOptionsTagField(t); ok {
+		c.found = true
+		c.options, c.err = parseExecOptionsTag(field.Tag.Get(structTagName))
+	}
+	execOptionsTagCache.Store(t, c)
+	return c.options, c.found, c.err
+}
+
+func findExecOptionsTagField(t reflect.Type) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type == execOptionsTagType {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// parseExecOptionsTag parses a struct tag value into ExecOptions.
+func parseExecOptionsTag(tag string) (ExecOptions, error) {
+	var options ExecOptions
+	for _, item := range strings.Split(tag, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "priority":
+			switch strings.ToUpper(value) {
+			case "LOW":
+				options.Priority = spannerpb.RequestOptions_PRIORITY_LOW
+			case "MEDIUM":
+				options.Priority = spannerpb.RequestOptions_PRIORITY_MEDIUM
+			case "HIGH":
+				options.Priority = spannerpb.RequestOptions_PRIORITY_HIGH
+			default:
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+		case "tag":
+			options.RequestTag = value
+		case "optimizerversion":
+			options.OptimizerVersion = value
+		case "optimizerstatisticspackage":
+			options.OptimizerStatisticsPackage = value
+		case "analyze":
+			switch strings.ToLower(value) {
+			case "plan":
+				options.AnalyzeMode = AnalyzePlan
+			case "profile":
+				options.AnalyzeMode = AnalyzeProfile
+			default:
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+		case "cacheable":
+			cacheable, err := strconv.ParseBool(value)
+			if err != nil {
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+			options.Cacheable = cacheable
+		case "nullaszerovalue":
+			nullAsZeroValue, err := strconv.ParseBool(value)
+			if err != nil {
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+			options.NullAsZeroValue = nullAsZeroValue
+		case "decodetonativearrays":
+			decodeToNativeArrays, err := strconv.ParseBool(value)
+			if err != nil {
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+			options.DecodeToNativeArrays = decodeToNativeArrays
+		case "decodenumericasstring":
+			decodeNumericAsString, err := strconv.ParseBool(value)
+			if err != nil {
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+			options.DecodeNumericAsString = decodeNumericAsString
+		case "allownumerictofloat64":
+			allowNumericToFloat64, err := strconv.ParseBool(value)
+			if err != nil {
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+			options.AllowNumericToFloat64 = allowNumericToFloat64
+		case "decodejsonasbytes":
+			decodeJSONAsBytes, err := strconv.ParseBool(value)
+			if err != nil {
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+			options.DecodeJSONAsBytes = decodeJSONAsBytes
+		case "statementtype":
+			switch strings.ToLower(value) {
+			case "query":
+				options.StatementType = StatementTypeQuery
+			case "dml":
+				options.StatementType = StatementTypeDML
+			case "ddl":
+				options.StatementType = StatementTypeDDL
+			default:
+				return ExecOptions{}, invalidTagValueError(key, value)
+			}
+		default:
+			return ExecOptions{}, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unknown ExecOptions tag key: %q", key))
+		}
+	}
+	return options, nil
+}
+
+func invalidTagValueError(key, value string) error {
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid value for ExecOptions tag key %s: %q", key, value))
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestParseExecOptionsTag(t *testing.T) {
+	for _, test := range []struct {
+		tag     string
+		want    ExecOptions
+		wantErr bool
+	}{
+		{tag: ""},
+		{tag: "priority=low,tag=reports", want: ExecOptions{Priority: sppb.RequestOptions_PRIORITY_LOW, RequestTag: "reports"}},
+		{tag: " Priority = HIGH , analyze=plan", want: ExecOptions{Priority: sppb.RequestOptions_PRIORITY_HIGH, AnalyzeMode: AnalyzePlan}},
+		{tag: "optimizerVersion=4", want: ExecOptions{OptimizerVersion: "4"}},
+		{tag: "optimizerStatisticsPackage=latest", want: ExecOptions{OptimizerStatisticsPackage: "latest"}},
+		{tag: "tag=lookup,cacheable=true", want: ExecOptions{RequestTag: "lookup", Cacheable: true}},
+		{tag: "priority=urgent", wantErr: true},
+		{tag: "cacheable=maybe", wantErr: true},
+		{tag: "nullAsZeroValue=true", want: ExecOptions{NullAsZeroValue: true}},
+		{tag: "decodeToNativeArrays=true", want: ExecOptions{DecodeToNativeArrays: true}},
+		{tag: "decodeJsonAsBytes=true", want: ExecOptions{DecodeJSONAsBytes: true}},
+		{tag: "statementType=DML", want: ExecOptions{StatementType: StatementTypeDML}},
+		{tag: "statementType=set", wantErr: true},
+		{tag: "analyze=profile", want: ExecOptions{AnalyzeMode: AnalyzeProfile}},
+		{tag: "analyze=normal", wantErr: true},
+		{tag: "nativeArrays", wantErr: true},
+	} {
+		got, err := parseExecOptionsTag(test.tag)
+		if test.wantErr {
+			if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+				t.Errorf("%q: error code mismatch\n Got: %v\nWant: %v", test.tag, g, w)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.tag, err)
+		}
+		if !cmp.Equal(got, test.want) {
+			t.Errorf("%q: options mismatch\n Got: %+v\nWant: %+v", test.tag, got, test.want)
+		}
+	}
+}
+
+func TestExecOptionsFromTag(t *testing.T) {
+	type reportsQuery struct {
+		ExecOptionsTag `spanner:"priority=low,tag=reports"`
+	}
+	options, ok, err := execOptionsFromTag(reportsQuery{})
+	if err != nil || !ok {
+		t.Fatalf("failed to get options from tag: %v, %v", ok, err)
+	}
+	if g, w := options, (ExecOptions{Priority: sppb.RequestOptions_PRIORITY_LOW, RequestTag: "reports"}); !cmp.Equal(g, w) {
+		t.Fatalf("options mismatch\n Got: %+v\nWant: %+v", g, w)
+	}
+	// Other struct values are not ExecOptions.
+	for _, v := range []interface{}{time.Now(), spanner.NullString{}, struct{ Name string }{}} {
+		if _, ok, err := execOptionsFromTag(v); ok || err != nil {
+			t.Fatalf("%T: unexpected result: %v, %v", v, ok, err)
+		}
+	}
+}
+
+func TestQueryWithExecOptionsTag(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	type reportsQuery struct {
+		ExecOptionsTag `spanner:"priority=low,tag=reports"`
+	}
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar, reportsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	_ = rows.Close()
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := req.GetRequestOptions().GetPriority(), sppb.RequestOptions_PRIORITY_LOW; g != w {
+		t.Fatalf("priority mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.GetRequestOptions().GetRequestTag(), "reports"; g != w {
+		t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	type invalidQuery struct {
+		ExecOptionsTag `spanner:"unknown=true"`
+	}
+	_, err = db.QueryContext(ctx, testutil.SelectFooFromBar, invalidQuery{})
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
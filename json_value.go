@@ -0,0 +1,153 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// JSONValue can be used to scan a GoogleSQL JSON or a PostgreSQL JSONB column
+// directly into a value of type T, for example a struct. Valid is false if the
+// column value is NULL.
+//
+// Example:
+//
+//	var v spannerdriver.JSONValue[Address]
+//	err := db.QueryRowContext(ctx, "SELECT Address FROM Singers WHERE SingerId=1").Scan(&v)
+type JSONValue[T any] struct {
+	Value T
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (j *JSONValue[T]) Scan(src interface{}) error {
+	var zero T
+	j.Value, j.Valid = zero, false
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case spanner.NullJSON:
+		if !v.Valid {
+			return nil
+		}
+		b, err := json.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+		data = b
+	case spanner.PGJsonB:
+		if !v.Valid {
+			return nil
+		}
+		b, err := json.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+		data = b
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid type for JSONValue: %T", src))
+	}
+	if err := json.Unmarshal(data, &j.Value); err != nil {
+		return err
+	}
+	j.Valid = true
+	return nil
+}
+
+// marshalJSONParam marshals the given query parameter to JSON if it is a map,
+// struct, slice or array, or a pointer to one of these. The returned ok value
+// is false if the value cannot be sent to Spanner as JSON. A nil map, slice or
+// pointer is marshalled to a JSON null value.
+func marshalJSONParam(value interface{}) (spanner.NullJSON, bool, error) {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return spanner.NullJSON{}, false, nil
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+	default:
+		return spanner.NullJSON{}, false, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return spanner.NullJSON{}, false, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "failed to marshal %T to JSON: %v", value, err))
+	}
+	return spanner.NullJSON{Value: json.RawMessage(b), Valid: true}, true, nil
+}
+
+// rawMessageToNullJSON converts a raw JSON query parameter to a NullJSON
+// value. A nil raw message is sent to Spanner as NULL.
+func rawMessageToNullJSON(v json.RawMessage) spanner.NullJSON {
+	if v == nil {
+		return spanner.NullJSON{}
+	}
+	return spanner.NullJSON{Value: v, Valid: true}
+}
+
+// rawMessagesToNullJSON converts a slice of raw JSON query parameters to a
+// slice of NullJSON values, where a nil raw message is a NULL element.
+func rawMessagesToNullJSON(v []json.RawMessage) []spanner.NullJSON {
+	if v == nil {
+		return nil
+	}
+	res := make([]spanner.NullJSON, len(v))
+	for i, e := range v {
+		res[i] = rawMessageToNullJSON(e)
+	}
+	return res
+}
+
+// decodeJSONBytes returns the JSON text of a JSON or JSONB column value, or
+// nil if the value is NULL.
+func decodeJSONBytes(col spanner.GenericColumnValue) driver.Value {
+	if _, ok := col.Value.GetKind().(*structpb.Value_NullValue); ok {
+		return nil
+	}
+	return []byte(col.Value.GetStringValue())
+}
+
+// decodeRawMessageArray decodes an ARRAY<JSON> or ARRAY<JSONB> column value
+// into a slice of raw JSON values. An error is returned if the array contains
+// a NULL element.
+func decodeRawMessageArray(col spanner.GenericColumnValue) (driver.Value, error) {
+	list, ok := col.Value.GetKind().(*structpb.Value_ListValue)
+	if !ok {
+		return []json.RawMessage(nil), nil
+	}
+	res := make([]json.RawMessage, len(list.ListValue.Values))
+	for i, v := range list.ListValue.Values {
+		if _, ok := v.GetKind().(*structpb.Value_NullValue); ok {
+			return nil, fmt.Errorf("array element %d is NULL and cannot be decoded to %T", i, res)
+		}
+		res[i] = json.RawMessage(v.GetStringValue())
+	}
+	return res, nil
+}
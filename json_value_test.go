@@ -0,0 +1,403 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type testAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+func createPGJsonBResultSet() *sppb.ResultSet {
+	jsonb := &sppb.Type{Code: sppb.TypeCode_JSON, TypeAnnotation: sppb.TypeAnnotationCode_PG_JSONB}
+	return &sppb.ResultSet{
+		Metadata: &sppb.ResultSetMetadata{
+			RowType: &sppb.StructType{
+				Fields: []*sppb.StructType_Field{
+					{Name: "Address", Type: jsonb},
+					{Name: "Addresses", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: jsonb}},
+				},
+			},
+		},
+		Rows: []*structpb.ListValue{
+			{Values: []*structpb.Value{
+				structpb.NewStringValue(`{"street": "Main St", "city": "Springfield"}`),
+				structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+					structpb.NewStringValue(`{"street": "High St", "city": "Shelbyville"}`),
+					structpb.NewNullValue(),
+				}}),
+			}},
+			{Values: []*structpb.Value{
+				structpb.NewNullValue(),
+				structpb.NewNullValue(),
+			}},
+		},
+	}
+}
+
+func TestPGJsonB(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT Address, Addresses FROM Singers"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: createPGJsonBResultSet(),
+	})
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	// Non-null values.
+	if !rows.Next() {
+		t.Fatal("missing first row")
+	}
+	var address spanner.PGJsonB
+	var addresses []spanner.PGJsonB
+	if err := rows.Scan(&address, &addresses); err != nil {
+		t.Fatal(err)
+	}
+	wantAddress := spanner.PGJsonB{Value: map[string]interface{}{"street": "Main St", "city": "Springfield"}, Valid: true}
+	if g, w := address, wantAddress; !cmp.Equal(g, w) {
+		t.Fatalf("address mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	wantAddresses := []spanner.PGJsonB{{Value: map[string]interface{}{"street": "High St", "city": "Shelbyville"}, Valid: true}, {}}
+	if g, w := addresses, wantAddresses; !cmp.Equal(g, w) {
+		t.Fatalf("addresses mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// The value can also be unmarshalled into a struct.
+	var jsonAddress JSONValue[testAddress]
+	if err := rows.Scan(&jsonAddress, &addresses); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := jsonAddress, (JSONValue[testAddress]{Value: testAddress{Street: "Main St", City: "Springfield"}, Valid: true}); g != w {
+		t.Fatalf("json address mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Null values.
+	if !rows.Next() {
+		t.Fatal("missing second row")
+	}
+	if err := rows.Scan(&address, &addresses); err != nil {
+		t.Fatal(err)
+	}
+	if address.Valid {
+		t.Fatalf("unexpected valid address: %v", address)
+	}
+	if addresses != nil {
+		t.Fatalf("unexpected addresses: %v", addresses)
+	}
+	if err := rows.Scan(&jsonAddress, &addresses); err != nil {
+		t.Fatal(err)
+	}
+	if jsonAddress.Valid {
+		t.Fatalf("unexpected valid json address: %v", jsonAddress)
+	}
+	if rows.Next() {
+		t.Fatal("unexpected row")
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGJsonBParams(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "UPDATE Singers SET Address=@p1, Addresses=@p2 WHERE TRUE"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	address := spanner.PGJsonB{Value: testAddress{Street: "Main St", City: "Springfield"}, Valid: true}
+	addresses := []spanner.PGJsonB{address, {}}
+	if _, err := db.ExecContext(ctx, query, address, addresses); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := req.ParamTypes["p1"].GetTypeAnnotation(), sppb.TypeAnnotationCode_PG_JSONB; g != w {
+		t.Fatalf("type annotation mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.ParamTypes["p2"].GetArrayElementType().GetTypeAnnotation(), sppb.TypeAnnotationCode_PG_JSONB; g != w {
+		t.Fatalf("array element type annotation mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields["p1"].GetStringValue(), `{"street":"Main St","city":"Springfield"}`; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestJSONValue_Scan(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name string
+		src  interface{}
+		want JSONValue[testAddress]
+	}{
+		{"nil", nil, JSONValue[testAddress]{}},
+		{"null json", spanner.NullJSON{}, JSONValue[testAddress]{}},
+		{"null jsonb", spanner.PGJsonB{}, JSONValue[testAddress]{}},
+		{"json", spanner.NullJSON{Value: map[string]interface{}{"street": "a", "city": "b"}, Valid: true}, JSONValue[testAddress]{Value: testAddress{Street: "a", City: "b"}, Valid: true}},
+		{"jsonb", spanner.PGJsonB{Value: map[string]interface{}{"street": "a"}, Valid: true}, JSONValue[testAddress]{Value: testAddress{Street: "a"}, Valid: true}},
+		{"string", `{"city": "b"}`, JSONValue[testAddress]{Value: testAddress{City: "b"}, Valid: true}},
+	} {
+		v := JSONValue[testAddress]{Value: testAddress{Street: "old"}, Valid: true}
+		if err := v.Scan(test.src); err != nil {
+			t.Fatalf("%s: scan failed: %v", test.name, err)
+		}
+		if g, w := v, test.want; g != w {
+			t.Fatalf("%s: value mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+	var v JSONValue[testAddress]
+	if err := v.Scan(int64(1)); err == nil {
+		t.Fatal("missing error for invalid type")
+	}
+}
+
+func TestAutoMarshalJSONParams(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnectionWithParams(t, "autoMarshalJson=true")
+	defer teardown()
+	ctx := context.Background()
+
+	query := "UPDATE Singers SET Address=@p1, Tags=@p2, Properties=@p3, Extra=@p4 WHERE TRUE"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	var nilMap map[string]interface{}
+	if _, err := db.ExecContext(ctx, query,
+		testAddress{Street: "Main St", City: "Springfield"},
+		[]testAddress{{City: "Springfield"}},
+		map[string]interface{}{"active": true},
+		nilMap); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	for name, want := range map[string]string{
+		"p1": `{"street":"Main St","city":"Springfield"}`,
+		"p2": `[{"street":"","city":"Springfield"}]`,
+		"p3": `{"active":true}`,
+		"p4": `null`,
+	} {
+		if g, w := req.ParamTypes[name].GetCode(), sppb.TypeCode_JSON; g != w {
+			t.Errorf("%s: type mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+		if g, w := req.Params.Fields[name].GetStringValue(), want; g != w {
+			t.Errorf("%s: param value mismatch\n Got: %v\nWant: %v", name, g, w)
+		}
+	}
+
+	// Values that cannot be marshalled to JSON return an error.
+	if _, err := db.ExecContext(ctx, query, map[string]interface{}{"f": func() {}}, nil, nil, nil); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestAutoMarshalJSONParams_Disabled(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	_, err := db.ExecContext(context.Background(), "UPDATE Singers SET Properties=@p1 WHERE TRUE", map[string]interface{}{"active": true})
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestJSONAsRawMessage(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	jsonType := &sppb.Type{Code: sppb.TypeCode_JSON}
+	query := "SELECT Info, Tags FROM Singers"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "Info", Type: jsonType},
+						{Name: "Tags", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: jsonType}},
+					},
+				},
+			},
+			Rows: []*structpb.ListValue{
+				{Values: []*structpb.Value{
+					structpb.NewStringValue(`{"name":"Alice"}`),
+					structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+						structpb.NewStringValue(`1`),
+						structpb.NewStringValue(`"rock"`),
+					}}),
+				}},
+				{Values: []*structpb.Value{
+					structpb.NewNullValue(),
+					structpb.NewNullValue(),
+				}},
+			},
+		},
+	})
+	rows, err := db.QueryContext(ctx, query, ExecOptions{DecodeJSONAsBytes: true, DecodeToNativeArrays: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatal("missing first row")
+	}
+	var (
+		info    json.RawMessage
+		tags    []json.RawMessage
+		infoStr string
+		infoB   []byte
+	)
+	if err := rows.Scan(&info, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := string(info), `{"name":"Alice"}`; g != w {
+		t.Fatalf("info mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := tags, []json.RawMessage{json.RawMessage(`1`), json.RawMessage(`"rock"`)}; !cmp.Equal(g, w) {
+		t.Fatalf("tags mismatch\n Got: %s\nWant: %s", g, w)
+	}
+	if err := rows.Scan(&infoStr, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := infoStr, `{"name":"Alice"}`; g != w {
+		t.Fatalf("info string mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := rows.Scan(&infoB, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := string(infoB), `{"name":"Alice"}`; g != w {
+		t.Fatalf("info bytes mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if !rows.Next() {
+		t.Fatal("missing second row")
+	}
+	var nullInfo *json.RawMessage
+	var nullInfoStr sql.NullString
+	if err := rows.Scan(&nullInfo, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if nullInfo != nil {
+		t.Fatalf("null info mismatch\n Got: %s\nWant: nil", *nullInfo)
+	}
+	if tags != nil {
+		t.Fatalf("null tags mismatch\n Got: %s\nWant: nil", tags)
+	}
+	if err := rows.Scan(&nullInfoStr, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if nullInfoStr.Valid {
+		t.Fatalf("null info string mismatch\n Got: %v\nWant: NULL", nullInfoStr)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// JSON values are returned as spanner.NullJSON by default.
+	var nullJSON spanner.NullJSON
+	var nullJSONs []spanner.NullJSON
+	if err := db.QueryRowContext(ctx, query).Scan(&nullJSON, &nullJSONs); err != nil {
+		t.Fatal(err)
+	}
+	if !nullJSON.Valid || len(nullJSONs) != 2 {
+		t.Fatalf("default decoding mismatch\n Got: %v, %v", nullJSON, nullJSONs)
+	}
+}
+
+func TestRawMessageParams(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "UPDATE Singers SET Info=@p1, NullInfo=@p2, Tags=@p3 WHERE TRUE"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	if _, err := db.ExecContext(ctx, query,
+		json.RawMessage(`{"name": "Alice"}`),
+		json.RawMessage(nil),
+		[]json.RawMessage{json.RawMessage(`1`), nil}); err != nil {
+		t.Fatal(err)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := req.ParamTypes["p1"].GetCode(), sppb.TypeCode_JSON; g != w {
+		t.Fatalf("param type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields["p1"].GetStringValue(), `{"name":"Alice"}`; g != w {
+		t.Fatalf("param value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if _, ok := req.Params.Fields["p2"].GetKind().(*structpb.Value_NullValue); !ok {
+		t.Fatalf("null param mismatch\n Got: %v\nWant: NULL", req.Params.Fields["p2"])
+	}
+	if g, w := req.ParamTypes["p3"].GetArrayElementType().GetCode(), sppb.TypeCode_JSON; g != w {
+		t.Fatalf("array element type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	values := req.Params.Fields["p3"].GetListValue().GetValues()
+	if g, w := len(values), 2; g != w {
+		t.Fatalf("array length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if _, ok := values[1].GetKind().(*structpb.Value_NullValue); !ok {
+		t.Fatalf("null array element mismatch\n Got: %v\nWant: NULL", values[1])
+	}
+}
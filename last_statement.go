@@ -0,0 +1,75 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+	"sync/atomic"
+)
+
+// lastStatement records the type and the number of affected rows of the last
+// statement that was executed on a connection. The number of rows of a query
+// is updated when the rows are finished, which can be on another goroutine
+// than the one that reads it.
+type lastStatement struct {
+	// query is the SQL string of the statement. It is used to determine the
+	// type of the statement when it is requested, so statements only need to
+	// be classified if the type is actually used.
+	query         string
+	statementType StatementType
+	rowsAffected  atomic.Int64
+}
+
+// recordLastStatement records that the given statement is the last statement
+// on the connection. The type of the statement is determined from the SQL
+// string if statementType is StatementTypeUnknown.
+func (c *conn) recordLastStatement(query string, statementType StatementType) *lastStatement {
+	s := &lastStatement{query: query, statementType: statementType}
+	c.lastStatement = s
+	return s
+}
+
+// recordResult records the number of rows that were affected by the
+// statement.
+func (s *lastStatement) recordResult(res driver.Result) {
+	if res == nil {
+		return
+	}
+	if rowsAffected, err := res.RowsAffected(); err == nil {
+		s.rowsAffected.Store(rowsAffected)
+	}
+}
+
+func (c *conn) LastStatementType() StatementType {
+	s := c.lastStatement
+	if s == nil {
+		return StatementTypeUnknown
+	}
+	if s.statementType == StatementTypeUnknown {
+		statementType, err := classifySQLStatement(s.query)
+		if err != nil {
+			return StatementTypeUnknown
+		}
+		return statementType
+	}
+	return s.statementType
+}
+
+func (c *conn) LastRowsAffected() int64 {
+	if c.lastStatement == nil {
+		return 0
+	}
+	return c.lastStatement.rowsAffected.Load()
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestLastStatement(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	lastStatement := func() (statementType StatementType, rowsAffected int64) {
+		_ = conn.Raw(func(driverConn interface{}) error {
+			spannerConn := driverConn.(SpannerConn)
+			statementType = spannerConn.LastStatementType()
+			rowsAffected = spannerConn.LastRowsAffected()
+			return nil
+		})
+		return statementType, rowsAffected
+	}
+
+	if g, _ := lastStatement(); g != StatementTypeUnknown {
+		t.Fatalf("statement type mismatch\n Got: %v\nWant: %v", g, StatementTypeUnknown)
+	}
+	for _, test := range []struct {
+		name             string
+		exec             func() error
+		wantType         StatementType
+		wantRowsAffected int64
+	}{
+		{"dml", func() error {
+			_, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
+			return err
+		}, StatementTypeDML, testutil.UpdateBarSetFooRowCount},
+		{"query", func() error {
+			rows, err := conn.QueryContext(ctx, testutil.SelectFooFromBar)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+			}
+			return rows.Close()
+		}, StatementTypeQuery, 2},
+		{"start batch", func() error {
+			_, err := conn.ExecContext(ctx, "START BATCH DML")
+			return err
+		}, StatementTypeStartBatch, 0},
+		{"batched dml", func() error {
+			if _, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+				return err
+			}
+			_, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
+			return err
+		}, StatementTypeDML, 0},
+		{"run batch", func() error {
+			_, err := conn.ExecContext(ctx, "RUN BATCH")
+			return err
+		}, StatementTypeRunBatch, 2 * testutil.UpdateBarSetFooRowCount},
+		{"show", func() error {
+			var v bool
+			return conn.QueryRowContext(ctx, "SHOW VARIABLE AUTOCOMMIT").Scan(&v)
+		}, StatementTypeShow, 0},
+		{"failed dml", func() error {
+			if _, err := conn.ExecContext(ctx, "UPDATE Unknown SET Foo=1 WHERE TRUE"); err == nil {
+				t.Fatal("missing expected error")
+			}
+			return nil
+		}, StatementTypeDML, 0},
+	} {
+		if err := test.exec(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		statementType, rowsAffected := lastStatement()
+		if g, w := statementType, test.wantType; g != w {
+			t.Errorf("%s: statement type mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		if g, w := rowsAffected, test.wantRowsAffected; g != w {
+			t.Errorf("%s: rows affected mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
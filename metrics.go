@@ -0,0 +1,169 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+)
+
+// The names of the metrics of the driver.
+const (
+	metricStatementLatency       = "go_sql_spanner/statement_latency"
+	metricSessionsCreated        = "go_sql_spanner/sessions_created"
+	metricTransactionsCommitted  = "go_sql_spanner/transactions_committed"
+	metricTransactionsRolledBack = "go_sql_spanner/transactions_rolled_back"
+	metricTransactionsRetried    = "go_sql_spanner/transactions_retried"
+)
+
+// The attributes of the metrics of the driver.
+const (
+	attrDatabase = attribute.Key("database")
+	attrMethod   = attribute.Key("method")
+	attrStatus   = attribute.Key("status")
+)
+
+// driverMetrics contains the OpenTelemetry instruments of a connector. All
+// measurements include the name of the database of the connector.
+type driverMetrics struct {
+	database               attribute.KeyValue
+	statementLatency       metric.Float64Histogram
+	sessionsCreated        metric.Int64Counter
+	transactionsCommitted  metric.Int64Counter
+	transactionsRolledBack metric.Int64Counter
+	transactionsRetried    metric.Int64Counter
+}
+
+// newMetrics creates the instruments of a connector for the given database
+// with the given meter provider, or with the global meter provider if it is
+// nil. Errors are reported to the global OpenTelemetry error handler, and do
+// not prevent the connector from being created.
+func newMetrics(provider metric.MeterProvider, database string) *driverMetrics {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter(tracerName)
+	m := &driverMetrics{database: attrDatabase.String(database)}
+	var err error
+	if m.statementLatency, err = meter.Float64Histogram(metricStatementLatency,
+		metric.WithDescription("The latency of the statements that are executed by the driver, until all rows have been consumed."),
+		metric.WithUnit("ms")); err != nil {
+		otel.Handle(err)
+	}
+	if m.sessionsCreated, err = meter.Int64Counter(metricSessionsCreated,
+		metric.WithDescription("The number of sessions that have been created by the session pool."),
+		metric.WithUnit("1")); err != nil {
+		otel.Handle(err)
+	}
+	if m.transactionsCommitted, err = meter.Int64Counter(metricTransactionsCommitted,
+		metric.WithDescription("The number of read/write transactions that have been committed."),
+		metric.WithUnit("1")); err != nil {
+		otel.Handle(err)
+	}
+	if m.transactionsRolledBack, err = meter.Int64Counter(metricTransactionsRolledBack,
+		metric.WithDescription("The number of read/write transactions that have been rolled back."),
+		metric.WithUnit("1")); err != nil {
+		otel.Handle(err)
+	}
+	if m.transactionsRetried, err = meter.Int64Counter(metricTransactionsRetried,
+		metric.WithDescription("The number of times that a read/write transaction was aborted by Spanner and retried by the driver."),
+		metric.WithUnit("1")); err != nil {
+		otel.Handle(err)
+	}
+	return m
+}
+
+// metrics returns the metrics of the connector of the connection, or nil if
+// the connection has no connector.
+func (c *conn) metrics() *driverMetrics {
+	if c.connector == nil {
+		return nil
+	}
+	return c.connector.metrics
+}
+
+// recordStatementLatency records the latency of a statement that was started
+// at the given time, with the method that was used to execute it and the
+// error code of the statement.
+func (m *driverMetrics) recordStatementLatency(ctx context.Context, method string, start time.Time, err error) {
+	if m == nil || m.statementLatency == nil {
+		return
+	}
+	latency := float64(time.Since(start)) / float64(time.Millisecond)
+	m.statementLatency.Record(ctx, latency, metric.WithAttributes(
+		m.database,
+		attrMethod.String(method),
+		attrStatus.String(spanner.ErrCode(err).String())))
+}
+
+// addTransactionsCommitted, addTransactionsRolledBack and
+// addTransactionsRetried count the outcome of read/write transactions.
+func (m *driverMetrics) addTransactionsCommitted(ctx context.Context) {
+	if m != nil {
+		m.add(ctx, m.transactionsCommitted, 1)
+	}
+}
+
+func (m *driverMetrics) addTransactionsRolledBack(ctx context.Context) {
+	if m != nil {
+		m.add(ctx, m.transactionsRolledBack, 1)
+	}
+}
+
+func (m *driverMetrics) addTransactionsRetried(ctx context.Context) {
+	if m != nil {
+		m.add(ctx, m.transactionsRetried, 1)
+	}
+}
+
+func (m *driverMetrics) add(ctx context.Context, counter metric.Int64Counter, incr int64) {
+	if counter != nil {
+		counter.Add(ctx, incr, metric.WithAttributes(m.database))
+	}
+}
+
+// unarySessionsInterceptor counts the sessions that are created by the
+// session pool of the Spanner client.
+func (m *driverMetrics) unarySessionsInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		return err
+	}
+	if n := createdSessions(req, reply); n > 0 {
+		m.add(ctx, m.sessionsCreated, int64(n))
+	}
+	return nil
+}
+
+// createdSessions returns the number of sessions that were created by the
+// RPC with the given request and reply.
+func createdSessions(req, reply interface{}) int {
+	switch r := reply.(type) {
+	case *spannerpb.BatchCreateSessionsResponse:
+		return len(r.Session)
+	case *spannerpb.Session:
+		if _, ok := req.(*spannerpb.CreateSessionRequest); ok {
+			return 1
+		}
+	}
+	return 0
+}
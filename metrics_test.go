@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testMeterProvider returns a testMeter for all meters.
+type testMeterProvider struct {
+	noop.MeterProvider
+	meter *testMeter
+}
+
+func newTestMeterProvider() *testMeterProvider {
+	return &testMeterProvider{meter: &testMeter{
+		counters:     make(map[string]int64),
+		measurements: make(map[string][]attribute.Set),
+	}}
+}
+
+func (p *testMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return p.meter
+}
+
+// testMeter records the values of its Int64Counter and Float64Histogram
+// instruments. All other instruments are no-ops.
+type testMeter struct {
+	noop.Meter
+
+	mu           sync.Mutex
+	counters     map[string]int64
+	measurements map[string][]attribute.Set
+}
+
+func (m *testMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &testInt64Counter{meter: m, name: name}, nil
+}
+
+func (m *testMeter) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return &testFloat64Histogram{meter: m, name: name}, nil
+}
+
+func (m *testMeter) record(name string, incr int64, attributes attribute.Set) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name] += incr
+	m.measurements[name] = append(m.measurements[name], attributes)
+}
+
+func (m *testMeter) counter(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+func (m *testMeter) attributes(name string) []attribute.Set {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]attribute.Set(nil), m.measurements[name]...)
+}
+
+type testInt64Counter struct {
+	noop.Int64Counter
+	meter *testMeter
+	name  string
+}
+
+func (c *testInt64Counter) Add(_ context.Context, incr int64, options ...metric.AddOption) {
+	c.meter.record(c.name, incr, metric.NewAddConfig(options).Attributes())
+}
+
+type testFloat64Histogram struct {
+	noop.Float64Histogram
+	meter *testMeter
+	name  string
+}
+
+func (h *testFloat64Histogram) Record(_ context.Context, _ float64, options ...metric.RecordOption) {
+	h.meter.record(h.name, 1, metric.NewRecordConfig(options).Attributes())
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	provider := newTestMeterProvider()
+	meter := provider.meter
+	connector, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;minSessions=1", server.Address),
+		ConnectorConfig{MeterProvider: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	// Abort the commit once to trigger a retry of the transaction.
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted")},
+	})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx, err = db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		want int64
+	}{
+		{metricStatementLatency, 2},
+		{metricTransactionsCommitted, 1},
+		{metricTransactionsRolledBack, 1},
+		{metricTransactionsRetried, 1},
+	} {
+		if g, w := meter.counter(test.name), test.want; g != w {
+			t.Errorf("%s mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+	if g := meter.counter(metricSessionsCreated); g < 1 {
+		t.Errorf("%s mismatch\n Got: %v\nWant: >= 1", metricSessionsCreated, g)
+	}
+	for _, name := range []string{metricStatementLatency, metricSessionsCreated, metricTransactionsCommitted} {
+		for _, attributes := range meter.attributes(name) {
+			if v, _ := attributes.Value(attrDatabase); v.AsString() != "projects/p/instances/i/databases/d" {
+				t.Errorf("%s database mismatch\n Got: %v\nWant: projects/p/instances/i/databases/d", name, v.AsString())
+			}
+		}
+	}
+	latencies := meter.attributes(metricStatementLatency)
+	if v, _ := latencies[0].Value(attrMethod); v.AsString() != "QueryContext" {
+		t.Errorf("method mismatch\n Got: %v\nWant: QueryContext", v.AsString())
+	}
+	if v, _ := latencies[1].Value(attrMethod); v.AsString() != "ExecContext" {
+		t.Errorf("method mismatch\n Got: %v\nWant: ExecContext", v.AsString())
+	}
+	if v, _ := latencies[0].Value(attrStatus); v.AsString() != codes.OK.String() {
+		t.Errorf("status mismatch\n Got: %v\nWant: %v", v.AsString(), codes.OK)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MutationGroupResult is the result of applying a single mutation group in a
+// BatchWrite call.
+type MutationGroupResult struct {
+	// Index is the index of the mutation group in the BatchWrite call.
+	Index int
+	// CommitTimestamp is the commit timestamp of the mutation group. It is
+	// only set if the mutation group was applied successfully.
+	CommitTimestamp time.Time
+	// Err is the error that was returned for the mutation group, if any.
+	Err error
+}
+
+// InsertOrUpdate writes a row to the given table using an InsertOrUpdate
+// mutation. The columns must include all primary key columns of the table,
+// and only need to include the other columns that should be set.
+//
+// If the row already exists, the given columns are updated and all other
+// columns keep their current values. If the row does not exist, it is
+// inserted, and all columns that are not given are set to NULL or to their
+// default value. This is different from:
+//   - spanner.Update, which fails if the row does not exist.
+//   - spanner.Replace, which deletes the existing row and inserts a new row.
+//     Columns that are not given are therefore cleared for existing rows.
+//
+// The mutation is buffered in the current transaction if the connection is in
+// a read/write transaction, and is written directly to the database if the
+// connection is not in a transaction.
+//
+// Example:
+//
+//	conn, err := db.Conn(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	err = spannerdriver.InsertOrUpdate(ctx, conn, "Singers",
+//		[]string{"SingerId", "LastName"}, []interface{}{int64(1), "Richards"})
+func InsertOrUpdate(ctx context.Context, conn *sql.Conn, table string, columns []string, values []interface{}) error {
+	if len(columns) != len(values) {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "number of columns (%d) does not match number of values (%d)", len(columns), len(values)))
+	}
+	for i, column := range columns {
+		if err := CheckValueSize(column, values[i]); err != nil {
+			return err
+		}
+	}
+	return writeMutations(ctx, conn, []*spanner.Mutation{spanner.InsertOrUpdate(table, columns, values)})
+}
+
+// bulkInsertMutationLimit is the maximum number of column values that
+// BulkInsert writes in one commit. This is half of the limit of Spanner, as
+// the entries of secondary indexes also count towards the limit.
+const bulkInsertMutationLimit = 40000
+
+// BulkInsert writes all rows that are received from the given channel to the
+// given table using InsertOrUpdate mutations. This is a lot faster than
+// inserting the rows one by one with DML statements. Each row must contain
+// one value for each of the given columns, which must include all primary
+// key columns of the table.
+//
+// The rows are written in batches that are each committed in a separate
+// transaction. The size of a batch is chosen so that a commit stays well
+// below the mutation limit of Spanner. The rows are therefore not written
+// atomically. BulkInsert returns when the channel is closed, and returns the
+// number of rows that have been written. It stops at the first error, and
+// then returns the number of rows that were written by the batches that had
+// already been committed. The caller should stop sending rows when
+// BulkInsert returns, for example by cancelling the context that is used to
+// produce the rows.
+//
+// Example:
+//
+//	rows := make(chan []driver.Value)
+//	go func() {
+//		defer close(rows)
+//		for _, record := range records {
+//			rows <- []driver.Value{record.ID, record.Name}
+//		}
+//	}()
+//	n, err := spannerdriver.BulkInsert(ctx, db, "Singers", []string{"SingerId", "Name"}, rows)
+func BulkInsert(ctx context.Context, db *sql.DB, table string, columns []string, rows <-chan []driver.Value) (int64, error) {
+	if len(columns) == 0 {
+		return 0, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "columns must not be empty"))
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	batchSize := bulkInsertMutationLimit / len(columns)
+	if batchSize == 0 {
+		batchSize = 1
+	}
+	var written int64
+	var batch []*spanner.Mutation
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := writeMutations(ctx, conn, batch); err != nil {
+			return err
+		}
+		written += int64(len(batch))
+		batch = nil
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return written, spanner.ToSpannerError(ctx.Err())
+		case row, ok := <-rows:
+			if !ok {
+				return written, flush()
+			}
+			if len(row) != len(columns) {
+				return written, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "number of columns (%d) does not match number of values (%d)", len(columns), len(row)))
+			}
+			values := make([]interface{}, len(row))
+			for i, value := range row {
+				if err := CheckValueSize(columns[i], value); err != nil {
+					return written, err
+				}
+				values[i] = value
+			}
+			batch = append(batch, spanner.InsertOrUpdate(table, columns, values))
+			if len(batch) == batchSize {
+				if err := flush(); err != nil {
+					return written, err
+				}
+			}
+		}
+	}
+}
+
+// maxMutationsPerCommit is the maximum number of mutations that Spanner
+// accepts in one commit.
+const maxMutationsPerCommit = 80000
+
+// applyMutations applies the given mutations in one commit. If Spanner rejects
+// the commit because it contains too many mutations, and the connector has
+// enabled AutoSplitMutationBatches, the mutations are split in two halves that
+// are applied separately. The halves are split again if they are still too
+// large. applyMutations returns the commit timestamp of the last commit.
+func (c *conn) applyMutations(ctx context.Context, ms []*spanner.Mutation, opts ...spanner.ApplyOption) (time.Time, error) {
+	commitTimestamp, err := c.client.Apply(ctx, ms, opts...)
+	if err == nil || !isTooManyMutationsError(err) {
+		return commitTimestamp, err
+	}
+	if c.connector == nil || !c.connector.config.AutoSplitMutationBatches || len(ms) < 2 {
+		return time.Time{}, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument,
+			"the mutations exceed the limit of %d mutations per commit, set ConnectorConfig.AutoSplitMutationBatches to split them into multiple commits: %v",
+			maxMutationsPerCommit, spanner.ErrDesc(err)))
+	}
+	half := len(ms) / 2
+	if _, err := c.applyMutations(ctx, ms[:half], opts...); err != nil {
+		return time.Time{}, err
+	}
+	return c.applyMutations(ctx, ms[half:], opts...)
+}
+
+// tooManyMutationsInTransactionError returns an error that explains the
+// mutation limit if the given commit error was returned because the
+// transaction contains too many mutations, and returns the error unchanged
+// otherwise.
+func tooManyMutationsInTransactionError(err error) error {
+	if !isTooManyMutationsError(err) {
+		return err
+	}
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument,
+		"the transaction exceeds the limit of %d mutations per commit, where inserts and updates count once for each column and each affected secondary index; split the changes over multiple transactions: %v",
+		maxMutationsPerCommit, spanner.ErrDesc(err)))
+}
+
+// tooManyMutationsMessage is the start of the message of the InvalidArgument
+// error that Spanner returns for a commit that contains more mutations than
+// allowed.
+const tooManyMutationsMessage = "The transaction contains too many mutations"
+
+// isTooManyMutationsError returns true if the given error is returned by
+// Spanner for a commit that contains more mutations than allowed.
+func isTooManyMutationsError(err error) bool {
+	if spanner.ErrCode(err) != codes.InvalidArgument {
+		return false
+	}
+	desc := spanner.ErrDesc(err)
+	if strings.HasPrefix(desc, tooManyMutationsMessage) {
+		return true
+	}
+	// Spanner does not add error details that identify this error, so the
+	// message is the only way to recognize it. Fall back to a case-insensitive
+	// substring match in case the message is prefixed or reworded.
+	return strings.Contains(strings.ToLower(desc), "too many mutations")
+}
+
+// writeMutations buffers the given mutations in the current read/write
+// transaction of the connection, or applies them directly if the connection
+// is not in a transaction.
+func writeMutations(ctx context.Context, sqlConn *sql.Conn, ms []*spanner.Mutation) error {
+	return sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unexpected driver connection %v, expected a Spanner connection", driverConn))
+		}
+		if c.inTransaction() {
+			return c.BufferWriteContext(ctx, ms)
+		}
+		_, err := c.Apply(ctx, ms)
+		return err
+	})
+}
@@ -0,0 +1,484 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestInsertOrUpdate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, inTransaction := range []bool{false, true} {
+		var tx *sql.Tx
+		if inTransaction {
+			if tx, err = conn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := InsertOrUpdate(ctx, conn, "Singers", []string{"SingerId", "LastName"}, []interface{}{int64(1), "Richards"}); err != nil {
+			t.Fatalf("%v: insert or update failed: %v", inTransaction, err)
+		}
+		if tx != nil {
+			// The mutation is buffered until the transaction is committed.
+			if g, w := len(requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.CommitRequest{}))), 0; g != w {
+				t.Fatalf("%v: commit requests count mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		commitRequests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.CommitRequest{}))
+		if g, w := len(commitRequests), 1; g != w {
+			t.Fatalf("%v: commit requests count mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+		}
+		mutations := commitRequests[0].(*sppb.CommitRequest).Mutations
+		if g, w := len(mutations), 1; g != w {
+			t.Fatalf("%v: mutation count mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+		}
+		write := mutations[0].GetInsertOrUpdate()
+		if write == nil {
+			t.Fatalf("%v: unexpected mutation type: %v", inTransaction, mutations[0])
+		}
+		if g, w := write.Table, "Singers"; g != w {
+			t.Fatalf("%v: table mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+		}
+		if g, w := write.Columns, []string{"SingerId", "LastName"}; !cmp.Equal(g, w) {
+			t.Fatalf("%v: columns mismatch\n Got: %v\nWant: %v", inTransaction, g, w)
+		}
+	}
+}
+
+func TestInsertOrUpdate_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = InsertOrUpdate(ctx, conn, "Singers", []string{"SingerId", "LastName"}, []interface{}{int64(1)})
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Mutations cannot be written in a read-only transaction.
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	err = InsertOrUpdate(ctx, conn, "Singers", []string{"SingerId", "LastName"}, []interface{}{int64(1), "Richards"})
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBulkInsert(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+
+	// Each row contains 5 column values, so each commit contains at most
+	// 8000 rows.
+	columns := []string{"SingerId", "FirstName", "LastName", "Active", "Rating"}
+	batchSize := bulkInsertMutationLimit / len(columns)
+	rows := make(chan []driver.Value)
+	go func() {
+		defer close(rows)
+		for i := 0; i <= batchSize; i++ {
+			rows <- []driver.Value{int64(i), "Keith", nil, true, 3.14}
+		}
+	}()
+	n, err := BulkInsert(ctx, db, "Singers", columns, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := n, int64(batchSize+1); g != w {
+		t.Fatalf("row count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	commitRequests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.CommitRequest{}))
+	if g, w := len(commitRequests), 2; g != w {
+		t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, want := range []int{batchSize, 1} {
+		mutations := commitRequests[i].(*sppb.CommitRequest).Mutations
+		if g, w := len(mutations), want; g != w {
+			t.Fatalf("%d: mutation count mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+		if mutations[0].GetInsertOrUpdate() == nil {
+			t.Fatalf("%d: unexpected mutation type: %v", i, mutations[0])
+		}
+	}
+}
+
+func TestBulkInsert_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	columns := []string{"SingerId", "LastName"}
+	batchSize := bulkInsertMutationLimit / len(columns)
+
+	// Rows that are not complete are rejected.
+	rows := make(chan []driver.Value, 2)
+	rows <- []driver.Value{int64(1), "Richards"}
+	rows <- []driver.Value{int64(2)}
+	close(rows)
+	n, err := BulkInsert(ctx, db, "Singers", columns, rows)
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := n, int64(0); g != w {
+		t.Fatalf("row count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if _, err := BulkInsert(ctx, db, "Singers", nil, rows); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+
+	// BulkInsert returns the number of rows of the batches that were
+	// committed before the first error.
+	rows = make(chan []driver.Value)
+	type result struct {
+		n   int64
+		err error
+	}
+	results := make(chan result)
+	go func() {
+		n, err := BulkInsert(ctx, db, "Singers", columns, rows)
+		results <- result{n, err}
+	}()
+	for i := 0; i <= batchSize; i++ {
+		rows <- []driver.Value{int64(i), "Richards"}
+	}
+	// The first batch has been committed when the next row is received.
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.FailedPrecondition, "Table not found")},
+	})
+	for i := 1; i < batchSize; i++ {
+		rows <- []driver.Value{int64(batchSize + i), "Richards"}
+	}
+	close(rows)
+	res := <-results
+	if g, w := spanner.ErrCode(res.err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := res.n, int64(batchSize); g != w {
+		t.Fatalf("row count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBatchWrite(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	server.TestSpanner.PutMutationGroupError(1, status.Error(codes.AlreadyExists, "Row already exists"))
+	groups := []*spanner.MutationGroup{
+		{Mutations: []*spanner.Mutation{spanner.Insert("Singers", []string{"SingerId"}, []interface{}{int64(1)})}},
+		{Mutations: []*spanner.Mutation{spanner.Insert("Singers", []string{"SingerId"}, []interface{}{int64(2)})}},
+		{Mutations: []*spanner.Mutation{spanner.Insert("Singers", []string{"SingerId"}, []interface{}{int64(3)})}},
+	}
+	var results []MutationGroupResult
+	if err := conn.Raw(func(driverConn interface{}) error {
+		results, err = driverConn.(SpannerConn).BatchWrite(ctx, groups)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(results), len(groups); g != w {
+		t.Fatalf("result count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, result := range results {
+		if g, w := result.Index, i; g != w {
+			t.Fatalf("%d: index mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+		if i == 1 {
+			if g, w := spanner.ErrCode(result.Err), codes.AlreadyExists; g != w {
+				t.Fatalf("%d: error code mismatch\n Got: %v\nWant: %v", i, g, w)
+			}
+			if !result.CommitTimestamp.IsZero() {
+				t.Fatalf("%d: unexpected commit timestamp: %v", i, result.CommitTimestamp)
+			}
+		} else {
+			if result.Err != nil {
+				t.Fatalf("%d: unexpected error: %v", i, result.Err)
+			}
+			if result.CommitTimestamp.IsZero() {
+				t.Fatalf("%d: missing commit timestamp", i)
+			}
+		}
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.BatchWriteRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("batch write requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requests[0].(*sppb.BatchWriteRequest).MutationGroups), len(groups); g != w {
+		t.Fatalf("mutation groups count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// BatchWrite is not allowed in a transaction.
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	err = conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).BatchWrite(ctx, groups)
+		return err
+	})
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBatchWrite_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET READONLY = TRUE"); err != nil {
+		t.Fatal(err)
+	}
+	groups := []*spanner.MutationGroup{
+		{Mutations: []*spanner.Mutation{spanner.Insert("Singers", []string{"SingerId"}, []interface{}{int64(1)})}},
+	}
+	err = conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).BatchWrite(ctx, groups)
+		return err
+	})
+	if g, w := spanner.ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.BatchWriteRequest{}))
+	if g, w := len(requests), 0; g != w {
+		t.Fatalf("batch write requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBufferedMutations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	bufferedMutations := func() (ms []*spanner.Mutation) {
+		if err := conn.Raw(func(driverConn interface{}) error {
+			ms = driverConn.(SpannerConn).BufferedMutations()
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return ms
+	}
+	if g, w := len(bufferedMutations()), 0; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DML statements are not included.
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(bufferedMutations()), 0; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	m1 := spanner.Insert("Singers", []string{"SingerId"}, []interface{}{int64(1)})
+	m2 := spanner.Delete("Singers", spanner.Key{int64(2)})
+	if err := conn.Raw(func(driverConn interface{}) error {
+		if err := driverConn.(SpannerConn).BufferWrite([]*spanner.Mutation{m1}); err != nil {
+			return err
+		}
+		return driverConn.(SpannerConn).BufferWrite([]*spanner.Mutation{m2})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ms := bufferedMutations()
+	if g, w := len(ms), 2; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if ms[0] != m1 || ms[1] != m2 {
+		t.Fatalf("mutations mismatch\n Got: %v\nWant: %v", ms, []*spanner.Mutation{m1, m2})
+	}
+	// Modifying the returned slice does not change the buffered mutations.
+	ms[0] = nil
+	if g, w := bufferedMutations()[0], m1; g != w {
+		t.Fatalf("mutation mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(bufferedMutations()), 0; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAutoSplitMutationBatches(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	tooManyMutations := status.Error(codes.InvalidArgument, "The transaction contains too many mutations.")
+	ms := []*spanner.Mutation{
+		spanner.InsertOrUpdate("Singers", []string{"SingerId"}, []interface{}{int64(1)}),
+		spanner.InsertOrUpdate("Singers", []string{"SingerId"}, []interface{}{int64(2)}),
+		spanner.InsertOrUpdate("Singers", []string{"SingerId"}, []interface{}{int64(3)}),
+		spanner.InsertOrUpdate("Singers", []string{"SingerId"}, []interface{}{int64(4)}),
+	}
+
+	for _, autoSplit := range []bool{false, true} {
+		connector, err := CreateConnector(
+			fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+			ConnectorConfig{AutoSplitMutationBatches: autoSplit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainRequestsFromServer(server.TestSpanner)
+		server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+			Errors: []error{tooManyMutations},
+		})
+		err = conn.Raw(func(driverConn interface{}) error {
+			_, err := driverConn.(SpannerConn).Apply(ctx, ms)
+			return err
+		})
+		commitRequests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.CommitRequest{}))
+		if autoSplit {
+			if err != nil {
+				t.Fatalf("apply failed: %v", err)
+			}
+			// The first commit fails, and the mutations are then split over
+			// two commits.
+			if g, w := len(commitRequests), 3; g != w {
+				t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+			}
+			for i, want := range []int{4, 2, 2} {
+				if g, w := len(commitRequests[i].(*sppb.CommitRequest).Mutations), want; g != w {
+					t.Fatalf("%d: mutation count mismatch\n Got: %v\nWant: %v", i, g, w)
+				}
+			}
+		} else {
+			if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+				t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+			}
+			if !strings.Contains(err.Error(), "AutoSplitMutationBatches") {
+				t.Fatalf("error message does not mention AutoSplitMutationBatches: %v", err)
+			}
+			if g, w := len(commitRequests), 1; g != w {
+				t.Fatalf("commit requests count mismatch\n Got: %v\nWant: %v", g, w)
+			}
+		}
+
+		// Mutations in a transaction are never split.
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.Raw(func(driverConn interface{}) error {
+			return driverConn.(SpannerConn).BufferWrite(ms)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+			Errors: []error{tooManyMutations},
+		})
+		err = tx.Commit()
+		if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+			t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if !strings.Contains(err.Error(), "limit of 80000 mutations") {
+			t.Fatalf("error message does not mention the mutation limit: %v", err)
+		}
+		_ = conn.Close()
+		_ = db.Close()
+	}
+}
+
+func TestIsTooManyMutationsError(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{spanner.ToSpannerError(status.Error(codes.InvalidArgument, tooManyMutationsMessage+". Insert and update operations count with the multiplicity of the number of columns they affect.")), true},
+		{spanner.ToSpannerError(status.Error(codes.InvalidArgument, "commit failed: too many mutations")), true},
+		{spanner.ToSpannerError(status.Error(codes.FailedPrecondition, tooManyMutationsMessage)), false},
+		{spanner.ToSpannerError(status.Error(codes.InvalidArgument, "Table not found: Singers")), false},
+		{nil, false},
+	} {
+		if g, w := isTooManyMutationsError(test.err), test.want; g != w {
+			t.Errorf("isTooManyMutationsError(%v) mismatch\n Got: %v\nWant: %v", test.err, g, w)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NullBytes represents a BYTES value that may be NULL. Valid is false if the
+// value is NULL, and an empty BYTES value is a NullBytes with Valid set to
+// true and an empty or nil Bytes slice. This is an alternative to using
+// []byte for nullable BYTES columns, where nil is used both for NULL and for
+// an empty value in some cases.
+//
+// A []NullBytes can be used as a query parameter for an ARRAY<BYTES>, and an
+// ARRAY<BYTES> column can be scanned into a []NullBytes with NativeArray.
+//
+// Example:
+//
+//	var picture spannerdriver.NullBytes
+//	err := db.QueryRowContext(ctx, "SELECT Picture FROM Singers WHERE SingerId=1").Scan(&picture)
+type NullBytes struct {
+	Bytes []byte
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface. The bytes are copied, so the
+// value remains valid after the next call to Scan.
+func (n *NullBytes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		n.Bytes, n.Valid = nil, false
+	case []byte:
+		if v == nil {
+			n.Bytes, n.Valid = nil, false
+			return nil
+		}
+		n.Bytes, n.Valid = append([]byte{}, v...), true
+	case string:
+		n.Bytes, n.Valid = []byte(v), true
+	default:
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid type for NullBytes: %T", src))
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface. A valid value with nil Bytes
+// is sent to Spanner as an empty BYTES value, and an invalid value as NULL.
+func (n NullBytes) Value() (driver.Value, error) {
+	if !n.Valid {
+		return []byte(nil), nil
+	}
+	if n.Bytes == nil {
+		return []byte{}, nil
+	}
+	return n.Bytes, nil
+}
+
+// nullBytesToSlice converts a slice of NullBytes to a slice of byte slices,
+// with a nil element for each invalid element.
+func nullBytesToSlice(values []NullBytes) [][]byte {
+	if values == nil {
+		return nil
+	}
+	res := make([][]byte, len(values))
+	for i, v := range values {
+		b, _ := v.Value()
+		res[i] = b.([]byte)
+	}
+	return res
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNullBytes_Scan(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		src  interface{}
+		want NullBytes
+	}{
+		{nil, NullBytes{}},
+		{[]byte(nil), NullBytes{}},
+		{[]byte{}, NullBytes{Bytes: []byte{}, Valid: true}},
+		{[]byte("test"), NullBytes{Bytes: []byte("test"), Valid: true}},
+		{"test", NullBytes{Bytes: []byte("test"), Valid: true}},
+	} {
+		v := NullBytes{Bytes: []byte("previous"), Valid: true}
+		if err := v.Scan(test.src); err != nil {
+			t.Fatal(err)
+		}
+		if g, w := v, test.want; !reflect.DeepEqual(g, w) {
+			t.Errorf("%#v: value mismatch\n Got: %#v\nWant: %#v", test.src, g, w)
+		}
+	}
+	// The scanned bytes are a copy of the source.
+	src := []byte("test")
+	var v NullBytes
+	if err := v.Scan(src); err != nil {
+		t.Fatal(err)
+	}
+	src[0] = 'T'
+	if g, w := string(v.Bytes), "test"; g != w {
+		t.Fatalf("value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := v.Scan(int64(1)); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestNullBytes(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM AllTypes WHERE ColBytes=@p1 AND ColEmptyBytes=@p2 AND ColBytesArray=@p3"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateResultSetWithAllTypes(false),
+	})
+	rows, err := db.QueryContext(ctx, "SELECT * FROM AllTypes WHERE ColBytes=? AND ColEmptyBytes=? AND ColBytesArray=?",
+		NullBytes{}, NullBytes{Valid: true}, []NullBytes{{Bytes: []byte("a"), Valid: true}, {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("missing row: %v", rows.Err())
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var (
+		b      NullBytes
+		bArray []NullBytes
+	)
+	dest[2] = &b
+	dest[12] = NativeArray(&bArray)
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := b, (NullBytes{Bytes: []byte("testbytes"), Valid: true}); !reflect.DeepEqual(g, w) {
+		t.Fatalf("bytes mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := bArray, []NullBytes{{Bytes: []byte("testbytes1"), Valid: true}, {}, {Bytes: []byte("testbytes2"), Valid: true}}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("bytes array mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	for _, p := range []string{"p1", "p2"} {
+		if g, w := req.ParamTypes[p].GetCode(), sppb.TypeCode_BYTES; g != w {
+			t.Fatalf("%s: param type mismatch\n Got: %v\nWant: %v", p, g, w)
+		}
+	}
+	if _, ok := req.Params.Fields["p1"].GetKind().(*structpb.Value_NullValue); !ok {
+		t.Fatalf("p1 mismatch\n Got: %v\nWant: NULL", req.Params.Fields["p1"])
+	}
+	if _, ok := req.Params.Fields["p2"].GetKind().(*structpb.Value_StringValue); !ok || req.Params.Fields["p2"].GetStringValue() != "" {
+		t.Fatalf("p2 mismatch\n Got: %v\nWant: \"\"", req.Params.Fields["p2"])
+	}
+	if g, w := req.ParamTypes["p3"].GetArrayElementType().GetCode(), sppb.TypeCode_BYTES; g != w {
+		t.Fatalf("p3 type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	elements := req.Params.Fields["p3"].GetListValue().GetValues()
+	if g, w := len(elements), 2; g != w {
+		t.Fatalf("p3 length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if _, ok := elements[1].GetKind().(*structpb.Value_NullValue); !ok {
+		t.Fatalf("p3 element mismatch\n Got: %v\nWant: NULL", elements[1])
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql"
+
+	"cloud.google.com/go/spanner"
+)
+
+// The functions in this file convert between the spanner.Null* types and the
+// sql.Null* types for the types that exist in both packages.
+
+// ToSQLNullString converts a spanner.NullString to a sql.NullString.
+func ToSQLNullString(v spanner.NullString) sql.NullString {
+	return sql.NullString{String: v.StringVal, Valid: v.Valid}
+}
+
+// ToSpannerNullString converts a sql.NullString to a spanner.NullString.
+func ToSpannerNullString(v sql.NullString) spanner.NullString {
+	return spanner.NullString{StringVal: v.String, Valid: v.Valid}
+}
+
+// ToSQLNullInt64 converts a spanner.NullInt64 to a sql.NullInt64.
+func ToSQLNullInt64(v spanner.NullInt64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v.Int64, Valid: v.Valid}
+}
+
+// ToSpannerNullInt64 converts a sql.NullInt64 to a spanner.NullInt64.
+func ToSpannerNullInt64(v sql.NullInt64) spanner.NullInt64 {
+	return spanner.NullInt64{Int64: v.Int64, Valid: v.Valid}
+}
+
+// ToSQLNullFloat64 converts a spanner.NullFloat64 to a sql.NullFloat64.
+func ToSQLNullFloat64(v spanner.NullFloat64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v.Float64, Valid: v.Valid}
+}
+
+// ToSpannerNullFloat64 converts a sql.NullFloat64 to a spanner.NullFloat64.
+func ToSpannerNullFloat64(v sql.NullFloat64) spanner.NullFloat64 {
+	return spanner.NullFloat64{Float64: v.Float64, Valid: v.Valid}
+}
+
+// ToSQLNullBool converts a spanner.NullBool to a sql.NullBool.
+func ToSQLNullBool(v spanner.NullBool) sql.NullBool {
+	return sql.NullBool{Bool: v.Bool, Valid: v.Valid}
+}
+
+// ToSpannerNullBool converts a sql.NullBool to a spanner.NullBool.
+func ToSpannerNullBool(v sql.NullBool) spanner.NullBool {
+	return spanner.NullBool{Bool: v.Bool, Valid: v.Valid}
+}
+
+// ToSQLNullTime converts a spanner.NullTime to a sql.NullTime.
+func ToSQLNullTime(v spanner.NullTime) sql.NullTime {
+	return sql.NullTime{Time: v.Time, Valid: v.Valid}
+}
+
+// ToSpannerNullTime converts a sql.NullTime to a spanner.NullTime.
+func ToSpannerNullTime(v sql.NullTime) spanner.NullTime {
+	return spanner.NullTime{Time: v.Time, Valid: v.Valid}
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestNullTypeConversions(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, valid := range []bool{true, false} {
+		s := spanner.NullString{StringVal: "test", Valid: valid}
+		if g, w := ToSQLNullString(s), (sql.NullString{String: "test", Valid: valid}); g != w {
+			t.Errorf("ToSQLNullString mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g := ToSpannerNullString(ToSQLNullString(s)); g != s {
+			t.Errorf("ToSpannerNullString mismatch\n Got: %v\nWant: %v", g, s)
+		}
+		i := spanner.NullInt64{Int64: 1, Valid: valid}
+		if g, w := ToSQLNullInt64(i), (sql.NullInt64{Int64: 1, Valid: valid}); g != w {
+			t.Errorf("ToSQLNullInt64 mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g := ToSpannerNullInt64(ToSQLNullInt64(i)); g != i {
+			t.Errorf("ToSpannerNullInt64 mismatch\n Got: %v\nWant: %v", g, i)
+		}
+		f := spanner.NullFloat64{Float64: 3.14, Valid: valid}
+		if g, w := ToSQLNullFloat64(f), (sql.NullFloat64{Float64: 3.14, Valid: valid}); g != w {
+			t.Errorf("ToSQLNullFloat64 mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g := ToSpannerNullFloat64(ToSQLNullFloat64(f)); g != f {
+			t.Errorf("ToSpannerNullFloat64 mismatch\n Got: %v\nWant: %v", g, f)
+		}
+		b := spanner.NullBool{Bool: true, Valid: valid}
+		if g, w := ToSQLNullBool(b), (sql.NullBool{Bool: true, Valid: valid}); g != w {
+			t.Errorf("ToSQLNullBool mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g := ToSpannerNullBool(ToSQLNullBool(b)); g != b {
+			t.Errorf("ToSpannerNullBool mismatch\n Got: %v\nWant: %v", g, b)
+		}
+		tm := spanner.NullTime{Time: ts, Valid: valid}
+		if g, w := ToSQLNullTime(tm), (sql.NullTime{Time: ts, Valid: valid}); g != w {
+			t.Errorf("ToSQLNullTime mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g := ToSpannerNullTime(ToSQLNullTime(tm)); g != tm {
+			t.Errorf("ToSpannerNullTime mismatch\n Got: %v\nWant: %v", g, tm)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/gob"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PartitionQueryOptions contains the options for PartitionQuery.
+type PartitionQueryOptions struct {
+	// PartitionOptions determines the number and the size of the partitions.
+	PartitionOptions spanner.PartitionOptions
+	// TimestampBound is the timestamp bound of the batch read-only
+	// transaction that is used for the query. The default is a strong read.
+	TimestampBound *spanner.TimestampBound
+
+	// Priority is the RPC priority that is used when the partitions are
+	// executed.
+	Priority spannerpb.RequestOptions_Priority
+	// RequestTag is the request tag that is added to the execution of each
+	// partition.
+	RequestTag string
+	// DataBoostEnabled indicates that the partitions should be executed using
+	// Spanner Data Boost. Data Boost executes the partitions on independent
+	// compute resources, so large exports do not affect the provisioned
+	// Spanner instance. Data Boost can only be used for partitioned queries.
+	DataBoostEnabled bool
+}
+
+// validate returns an InvalidArgument error if the options are not valid.
+func (o *PartitionQueryOptions) validate() error {
+	if o.PartitionOptions.MaxPartitions < 0 {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "MaxPartitions must not be negative, got %d", o.PartitionOptions.MaxPartitions))
+	}
+	if o.PartitionOptions.PartitionBytes < 0 {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "PartitionBytes must not be negative, got %d", o.PartitionOptions.PartitionBytes))
+	}
+	if _, ok := spannerpb.RequestOptions_Priority_name[int32(o.Priority)]; !ok {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid priority: %d", o.Priority))
+	}
+	return nil
+}
+
+// PartitionedQuery is a query or a read that has been split into partitions
+// that can be executed in parallel. All partitions are executed in the same
+// batch read-only transaction, and together return the same result as the
+// query or the read.
+// The caller must call Close when all partitions have been executed.
+//
+// The partitions can also be executed by other processes. Use
+// SerializablePartitions to get partitions that can be sent to other
+// processes, and ExecutePartition to execute them there.
+type PartitionedQuery struct {
+	db         *sql.DB
+	tx         *spanner.BatchReadOnlyTransaction
+	partitions []*spanner.Partition
+}
+
+// PartitionQuery partitions the given query into partitions that can be
+// executed in parallel using PartitionedQuery.Execute. The query must be
+// root-partitionable. The priority, the request tag and Data Boost in the
+// options are applied to the execution of all partitions, so the entire
+// operation can be attributed and does not disrupt online traffic.
+//
+// Example:
+//
+//	pq, err := spannerdriver.PartitionQuery(ctx, db, "SELECT * FROM Singers",
+//		spannerdriver.PartitionQueryOptions{
+//			PartitionOptions: spanner.PartitionOptions{MaxPartitions: 100},
+//			Priority:         spannerpb.RequestOptions_PRIORITY_LOW,
+//			RequestTag:       "export",
+//			DataBoostEnabled: true,
+//		})
+//	if err != nil {
+//		return err
+//	}
+//	defer pq.Close()
+//	for _, p := range pq.Partitions() {
+//		rows, err := pq.Execute(ctx, p)
+//		...
+//	}
+func PartitionQuery(ctx context.Context, db *sql.DB, query string, options PartitionQueryOptions, args ...interface{}) (*PartitionedQuery, error) {
+	return partition(ctx, db, options, func(c *conn, tx *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error) {
+		namedValues, err := c.toNamedValues(args)
+		if err != nil {
+			return nil, err
+		}
+		stmt, err := c.prepareSpannerStmt(ctx, query, namedValues)
+		if err != nil {
+			return nil, err
+		}
+		queryOptions := spanner.QueryOptions{
+			Priority:         options.Priority,
+			RequestTag:       options.RequestTag,
+			DataBoostEnabled: options.DataBoostEnabled,
+		}
+		return tx.PartitionQueryWithOptions(ctx, stmt, options.PartitionOptions, queryOptions)
+	})
+}
+
+// PartitionReadOptions contains the options for PartitionRead.
+type PartitionReadOptions struct {
+	PartitionQueryOptions
+	// Index is the secondary index that is used for the read. The keys are
+	// interpreted as keys of the index if it is set. The columns must then
+	// be part of the index.
+	Index string
+}
+
+// PartitionRead partitions a read of the given columns of the rows with the
+// given keys in the given table. The partitions can be executed in parallel
+// in the same way as the partitions of PartitionQuery, and return the same
+// rows as a single read would. Reading a table or an index is always
+// root-partitionable, which makes PartitionRead suitable for exporting an
+// entire table.
+//
+// Example:
+//
+//	pq, err := spannerdriver.PartitionRead(ctx, db, "Singers", spanner.AllKeys(),
+//		[]string{"SingerId", "Name"}, spannerdriver.PartitionReadOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	defer pq.Close()
+func PartitionRead(ctx context.Context, db *sql.DB, table string, keys spanner.KeySet, columns []string, options PartitionReadOptions) (*PartitionedQuery, error) {
+	if keys == nil {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "keys must not be nil, use spanner.AllKeys() to read all rows"))
+	}
+	if len(columns) == 0 {
+		return nil, spanner.ToSpannerError(status.Error(codes.InvalidArgument, "at least one column must be specified"))
+	}
+	return partition(ctx, db, options.PartitionQueryOptions, func(c *conn, tx *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error) {
+		readOptions := spanner.ReadOptions{
+			Priority:         options.Priority,
+			RequestTag:       options.RequestTag,
+			DataBoostEnabled: options.DataBoostEnabled,
+		}
+		return tx.PartitionReadUsingIndexWithOptions(ctx, table, options.Index, keys, columns, options.PartitionOptions, readOptions)
+	})
+}
+
+// partition creates a batch read-only transaction on a connection of the
+// given database and partitions a query or a read in that transaction.
+func partition(ctx context.Context, db *sql.DB, options PartitionQueryOptions, f func(c *conn, tx *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error)) (*PartitionedQuery, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlConn.Close()
+
+	var pq *PartitionedQuery
+	if err := sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unexpected driver connection %v, expected a Spanner connection", driverConn))
+		}
+		tb := spanner.StrongRead()
+		if options.TimestampBound != nil {
+			tb = *options.TimestampBound
+		}
+		tx, err := c.client.BatchReadOnlyTransaction(ctx, tb)
+		if err != nil {
+			return err
+		}
+		partitions, err := f(c, tx)
+		if err != nil {
+			tx.Close()
+			return err
+		}
+		pq = &PartitionedQuery{db: db, tx: tx, partitions: partitions}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return pq, nil
+}
+
+// Partitions returns the partitions of the query.
+func (pq *PartitionedQuery) Partitions() []*spanner.Partition {
+	return pq.partitions
+}
+
+// Execute executes the given partition and returns the rows of the
+// partition. The partition is executed with the priority, the request tag and
+// the Data Boost setting of the PartitionQueryOptions that were used to create
+// the query.
+//
+// A stream that fails with a transient error while the rows are being read,
+// such as UNAVAILABLE or an INTERNAL error for a stream that was reset by the
+// network (RST_STREAM), is resumed from the last resume token that was
+// received. Rows that have already been returned are not returned again.
+func (pq *PartitionedQuery) Execute(ctx context.Context, partition *spanner.Partition) (*sql.Rows, error) {
+	return pq.db.QueryContext(ctx, "EXECUTE PARTITION", partitionRequest{tx: pq.tx, partition: partition})
+}
+
+// Close closes the batch read-only transaction of the query.
+func (pq *PartitionedQuery) Close() {
+	pq.tx.Close()
+}
+
+// Partition is a partition of a PartitionedQuery that can be serialized and
+// sent to a different process or machine, for example to distribute the
+// execution of the partitions over a number of workers. It contains both the
+// partition and the batch read-only transaction that it belongs to.
+//
+// All partitions of one PartitionQuery share the same snapshot of the
+// database, and must be executed in the batch read-only transaction that
+// created them. Use MarshalPartition and UnmarshalPartition to serialize a
+// Partition, and ExecutePartition to execute it. The coordinator must keep the
+// PartitionedQuery open until all partitions have been executed, as closing it
+// ends the batch read-only transaction.
+type Partition struct {
+	// TransactionID is the serialized ID of the batch read-only transaction
+	// of the partition.
+	TransactionID []byte
+	// ReadTimestamp is the read timestamp of the batch read-only
+	// transaction. All partitions of a query have the same read timestamp.
+	ReadTimestamp time.Time
+	// Partition is the serialized spanner.Partition.
+	Partition []byte
+}
+
+// SerializablePartitions returns the partitions of the query as Partition
+// values that can be serialized and executed in a different process with
+// ExecutePartition.
+func (pq *PartitionedQuery) SerializablePartitions() ([]*Partition, error) {
+	tid, err := pq.tx.ID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	ts, err := pq.tx.Timestamp()
+	if err != nil {
+		return nil, err
+	}
+	partitions := make([]*Partition, 0, len(pq.partitions))
+	for _, p := range pq.partitions {
+		data, err := p.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, &Partition{TransactionID: tid, ReadTimestamp: ts, Partition: data})
+	}
+	return partitions, nil
+}
+
+// MarshalPartition serializes the given partition using encoding/gob.
+func MarshalPartition(partition *Partition) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(partition); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalPartition deserializes a partition that was serialized with
+// MarshalPartition.
+func UnmarshalPartition(data []byte) (*Partition, error) {
+	partition := &Partition{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(partition); err != nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid partition: %v", err))
+	}
+	return partition, nil
+}
+
+// ExecutePartition executes the given serialized partition on the given
+// database and returns the rows of the partition. The database does not need
+// to be the same *sql.DB as the one that was used to create the partition, but
+// it must connect to the same Spanner database. The partition is executed in
+// the batch read-only transaction that created it, and observes the same
+// snapshot as all other partitions of the query.
+func ExecutePartition(ctx context.Context, db *sql.DB, partition *Partition) (*sql.Rows, error) {
+	var tid spanner.BatchReadOnlyTransactionID
+	if err := tid.UnmarshalBinary(partition.TransactionID); err != nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid transaction id: %v", err))
+	}
+	p := &spanner.Partition{}
+	if err := p.UnmarshalBinary(partition.Partition); err != nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid partition: %v", err))
+	}
+	return db.QueryContext(ctx, "EXECUTE PARTITION", partitionRequest{txID: &tid, partition: p})
+}
+
+// partitionRequest is a partition that is executed instead of a query. It is
+// passed in as an argument to a query in the same way as ExecOptions. The
+// transaction is re-created from txID if tx is nil.
+type partitionRequest struct {
+	tx        *spanner.BatchReadOnlyTransaction
+	txID      *spanner.BatchReadOnlyTransactionID
+	partition *spanner.Partition
+}
+
+// executePartition executes the given partition on the connection.
+func (c *conn) executePartition(ctx context.Context, query string, req partitionRequest) (driver.Rows, error) {
+	tx := req.tx
+	if tx == nil {
+		// The transaction is not closed after the partition has been
+		// executed, as it is owned by the process that created it.
+		tx = c.client.BatchReadOnlyTransactionFromID(*req.txID)
+	}
+	ctx, done := c.startStatement(ctx, spanQuery, query)
+	return c.newStreamingRows(ctx, &rows{it: &readOnlyRowIterator{tx.Execute(ctx, req.partition)}, done: done, decoders: c.columnDecoders()})
+}
+
+// toNamedValues converts the given query arguments to named values in the
+// same way as database/sql does for arguments of a query.
+func (c *conn) toNamedValues(args []interface{}) ([]driver.NamedValue, error) {
+	namedValues := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		nv := driver.NamedValue{Ordinal: i + 1, Value: arg}
+		if named, ok := arg.(sql.NamedArg); ok {
+			nv.Name = named.Name
+			nv.Value = named.Value
+		}
+		if err := c.CheckNamedValue(&nv); err == driver.ErrRemoveArgument {
+			c.execOptions = ExecOptions{}
+			return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unsupported argument: %T", arg))
+		} else if err != nil {
+			return nil, err
+		}
+		namedValues = append(namedValues, nv)
+	}
+	return namedValues, nil
+}
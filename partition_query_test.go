@@ -0,0 +1,386 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+)
+
+func TestPartitionQuery(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	pq, err := PartitionQuery(ctx, db, testutil.SelectFooFromBar, PartitionQueryOptions{
+		PartitionOptions: spanner.PartitionOptions{MaxPartitions: 2},
+		Priority:         sppb.RequestOptions_PRIORITY_LOW,
+		RequestTag:       "export",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close()
+	if g, w := len(pq.Partitions()), 2; g != w {
+		t.Fatalf("partition count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	count := 0
+	for _, p := range pq.Partitions() {
+		rows, err := pq.Execute(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	// The mock server returns the entire result for each partition.
+	if g, w := count, 4; g != w {
+		t.Fatalf("row count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	partitionRequests := requestsOfType(requests, reflect.TypeOf(&sppb.PartitionQueryRequest{}))
+	if g, w := len(partitionRequests), 1; g != w {
+		t.Fatalf("partition requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for _, r := range sqlRequests {
+		req := r.(*sppb.ExecuteSqlRequest)
+		if req.PartitionToken == nil {
+			t.Fatal("missing partition token")
+		}
+		if g, w := req.GetRequestOptions().GetPriority(), sppb.RequestOptions_PRIORITY_LOW; g != w {
+			t.Fatalf("priority mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g, w := req.GetRequestOptions().GetRequestTag(), "export"; g != w {
+			t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+		}
+	}
+
+	// ExecOptions cannot be used for a partitioned query.
+	_, err = PartitionQuery(ctx, db, testutil.SelectFooFromBar, PartitionQueryOptions{}, ExecOptions{})
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestPartitionQuery_DataBoost(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	pq, err := PartitionQuery(ctx, db, testutil.SelectFooFromBar, PartitionQueryOptions{
+		PartitionOptions: spanner.PartitionOptions{MaxPartitions: 2},
+		Priority:         sppb.RequestOptions_PRIORITY_LOW,
+		RequestTag:       "export",
+		DataBoostEnabled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close()
+	for _, p := range pq.Partitions() {
+		rows, err := pq.Execute(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	partitionRequests := requestsOfType(requests, reflect.TypeOf(&sppb.PartitionQueryRequest{}))
+	if g, w := len(partitionRequests), 1; g != w {
+		t.Fatalf("partition requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := partitionRequests[0].(*sppb.PartitionQueryRequest).GetPartitionOptions().GetMaxPartitions(), int64(2); g != w {
+		t.Fatalf("max partitions mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for _, r := range sqlRequests {
+		req := r.(*sppb.ExecuteSqlRequest)
+		if !req.DataBoostEnabled {
+			t.Fatal("data boost not enabled")
+		}
+		if g, w := req.GetRequestOptions().GetPriority(), sppb.RequestOptions_PRIORITY_LOW; g != w {
+			t.Fatalf("priority mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g, w := req.GetRequestOptions().GetRequestTag(), "export"; g != w {
+			t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+		}
+	}
+
+	// The options are validated before the query is partitioned.
+	for _, options := range []PartitionQueryOptions{
+		{PartitionOptions: spanner.PartitionOptions{MaxPartitions: -1}},
+		{PartitionOptions: spanner.PartitionOptions{PartitionBytes: -1}},
+		{Priority: sppb.RequestOptions_Priority(100)},
+	} {
+		if _, err := PartitionQuery(ctx, db, testutil.SelectFooFromBar, options); spanner.ErrCode(err) != codes.InvalidArgument {
+			t.Fatalf("%+v: error code mismatch\n Got: %v\nWant: %v", options, spanner.ErrCode(err), codes.InvalidArgument)
+		}
+	}
+	// Data Boost cannot be used for queries that are not partitioned.
+	_, err = db.QueryContext(ctx, testutil.SelectFooFromBar, ExecOptions{QueryOptions: spanner.QueryOptions{DataBoostEnabled: true}})
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.PartitionQueryRequest{}))), 0; g != w {
+		t.Fatalf("partition requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestPartitionQuery_ResumeAfterStreamError(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	pq, err := PartitionQuery(ctx, db, testutil.SelectFooFromBar, PartitionQueryOptions{
+		PartitionOptions: spanner.PartitionOptions{MaxPartitions: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close()
+	// Both streams fail after the first row. The Spanner client resumes the
+	// stream from the last resume token that it received.
+	for _, streamErr := range []error{
+		gstatus.Error(codes.Unavailable, "transient error"),
+		gstatus.Error(codes.Internal, "stream terminated by RST_STREAM with error code: INTERNAL_ERROR"),
+	} {
+		server.TestSpanner.AddPartialResultSetError(testutil.SelectFooFromBar, testutil.PartialResultSetExecutionTime{
+			ResumeToken: testutil.EncodeResumeToken(2),
+			Err:         streamErr,
+		})
+	}
+	var values []int64
+	for _, p := range pq.Partitions() {
+		rows, err := pq.Execute(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, v)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	if g, w := values, []int64{1, 2}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("values mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 3; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, r := range sqlRequests[1:] {
+		if g, w := r.(*sppb.ExecuteSqlRequest).ResumeToken, testutil.EncodeResumeToken(1); !reflect.DeepEqual(g, w) {
+			t.Fatalf("%d: resume token mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+	}
+}
+
+func TestPartitionQuery_SerializablePartitions(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	pq, err := PartitionQuery(ctx, db, testutil.SelectFooFromBar, PartitionQueryOptions{
+		PartitionOptions: spanner.PartitionOptions{MaxPartitions: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close()
+	partitions, err := pq.SerializablePartitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(partitions), 2; g != w {
+		t.Fatalf("partition count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+
+	count := 0
+	for _, p := range partitions {
+		data, err := MarshalPartition(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unmarshalled, err := UnmarshalPartition(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(unmarshalled.TransactionID, p.TransactionID) || !reflect.DeepEqual(unmarshalled.Partition, p.Partition) {
+			t.Fatalf("partition mismatch\n Got: %v\nWant: %v", unmarshalled, p)
+		}
+		if !unmarshalled.ReadTimestamp.Equal(p.ReadTimestamp) {
+			t.Fatalf("read timestamp mismatch\n Got: %v\nWant: %v", unmarshalled.ReadTimestamp, p.ReadTimestamp)
+		}
+		rows, err := ExecutePartition(ctx, db, unmarshalled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	if g, w := count, 4; g != w {
+		t.Fatalf("row count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// The partitions are executed in the batch transaction that created them.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	if g, w := len(requestsOfType(requests, reflect.TypeOf(&sppb.BeginTransactionRequest{}))), 0; g != w {
+		t.Fatalf("begin requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("execute requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for _, r := range sqlRequests {
+		if r.(*sppb.ExecuteSqlRequest).GetTransaction().GetId() == nil {
+			t.Fatal("missing transaction id")
+		}
+	}
+
+	if _, err := UnmarshalPartition([]byte("invalid")); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestPartitionRead(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_ = server.TestSpanner.PutStatementResult("SELECT FOO FROM BAR", &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateSingleColumnResultSet([]int64{1, 2}, "FOO"),
+	})
+	pq, err := PartitionRead(ctx, db, "BAR", spanner.AllKeys(), []string{"FOO"}, PartitionReadOptions{
+		PartitionQueryOptions: PartitionQueryOptions{
+			PartitionOptions: spanner.PartitionOptions{MaxPartitions: 3},
+			RequestTag:       "export",
+			DataBoostEnabled: true,
+		},
+		Index: "IDX_BAR_FOO",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close()
+	partitions, err := pq.SerializablePartitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(partitions), 3; g != w {
+		t.Fatalf("partition count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	count := 0
+	for _, p := range partitions {
+		rows, err := ExecutePartition(ctx, db, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	// The mock server returns the entire result for each partition.
+	if g, w := count, 6; g != w {
+		t.Fatalf("row count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	requests := drainRequestsFromServer(server.TestSpanner)
+	partitionRequests := requestsOfType(requests, reflect.TypeOf(&sppb.PartitionQueryRequest{}))
+	if g, w := len(partitionRequests), 1; g != w {
+		t.Fatalf("partition requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	readRequests := requestsOfType(requests, reflect.TypeOf(&sppb.ReadRequest{}))
+	if g, w := len(readRequests), 3; g != w {
+		t.Fatalf("read requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for _, r := range readRequests {
+		req := r.(*sppb.ReadRequest)
+		if req.PartitionToken == nil {
+			t.Fatal("missing partition token")
+		}
+		if g, w := req.Index, "IDX_BAR_FOO"; g != w {
+			t.Fatalf("index mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if g, w := req.RequestOptions.RequestTag, "export"; g != w {
+			t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		if !req.DataBoostEnabled {
+			t.Fatal("missing data boost")
+		}
+	}
+
+	if _, err := PartitionRead(ctx, db, "BAR", nil, []string{"FOO"}, PartitionReadOptions{}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if _, err := PartitionRead(ctx, db, "BAR", spanner.AllKeys(), nil, PartitionReadOptions{}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
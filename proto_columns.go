@@ -0,0 +1,268 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// protoTypes contains the proto message and enum types that have been
+// registered with RegisterProtoMessage and RegisterProtoEnum.
+var protoTypes = struct {
+	mu       sync.RWMutex
+	messages map[string]protoreflect.MessageType
+	enums    map[string]protoreflect.EnumType
+}{
+	messages: make(map[string]protoreflect.MessageType),
+	enums:    make(map[string]protoreflect.EnumType),
+}
+
+// RegisterProtoMessage registers the Go type of the given message for PROTO
+// columns with the given fully qualified proto type name, for example
+// examples.music.SingerInfo. The full name of the message descriptor is used
+// if fullName is empty. Registering a type for a name replaces any type that
+// was registered earlier for the same name.
+//
+// The driver returns the values of PROTO columns as messages of the
+// registered type, or of the type in the global protobuf registry with the
+// same name if no type has been registered. Generated Go protobuf types are
+// automatically added to the global registry, so registration is only needed
+// if the name of the proto type in the database differs from the name in the
+// generated code. The value of a PROTO column with an unknown type is returned
+// as []byte, and can be scanned into a message with ProtoMessage.
+//
+// A message value can be scanned into a pointer to a message pointer of the
+// same type, and an ARRAY<PROTO> value into a pointer to a slice of message
+// pointers:
+//
+//	var info *pb.SingerInfo
+//	var infos []*pb.SingerInfo
+//	err := db.QueryRowContext(ctx, "SELECT Info, PastInfos FROM Singers WHERE SingerId=1").
+//		Scan(&info, &infos)
+func RegisterProtoMessage(fullName string, msg proto.Message) error {
+	if msg == nil {
+		return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "msg must not be nil"))
+	}
+	messageType := msg.ProtoReflect().Type()
+	if fullName == "" {
+		fullName = string(messageType.Descriptor().FullName())
+	}
+	protoTypes.mu.Lock()
+	defer protoTypes.mu.Unlock()
+	protoTypes.messages[fullName] = messageType
+	return nil
+}
+
+// RegisterProtoEnum registers the Go type of the given enum value for ENUM
+// columns with the given fully qualified proto type name. The full name of
+// the enum descriptor is used if fullName is empty.
+//
+// The driver returns the values of ENUM columns as values of the registered
+// type, or of the type in the global protobuf registry with the same name if
+// no type has been registered. An ARRAY<ENUM> value is returned as a slice of
+// pointers to the enum type, where a NULL element is a nil pointer. The value
+// of an ENUM column with an unknown type is returned as an int64.
+//
+// Example:
+//
+//	var genre pb.Genre
+//	err := db.QueryRowContext(ctx, "SELECT Genre FROM Singers WHERE SingerId=1").Scan(&genre)
+func RegisterProtoEnum(fullName string, enum protoreflect.Enum) error {
+	if enum == nil {
+		return spanner.ToSpannerError(status.Error(codes.InvalidArgument, "enum must not be nil"))
+	}
+	enumType := enum.Type()
+	if fullName == "" {
+		fullName = string(enumType.Descriptor().FullName())
+	}
+	protoTypes.mu.Lock()
+	defer protoTypes.mu.Unlock()
+	protoTypes.enums[fullName] = enumType
+	return nil
+}
+
+// findProtoMessageType returns the registered message type with the given
+// name, or the type with that name in the global protobuf registry.
+func findProtoMessageType(fullName string) (protoreflect.MessageType, bool) {
+	protoTypes.mu.RLock()
+	messageType, ok := protoTypes.messages[fullName]
+	protoTypes.mu.RUnlock()
+	if ok {
+		return messageType, true
+	}
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(fullName))
+	return messageType, err == nil
+}
+
+// findProtoEnumType returns the registered enum type with the given name, or
+// the type with that name in the global protobuf registry.
+func findProtoEnumType(fullName string) (protoreflect.EnumType, bool) {
+	protoTypes.mu.RLock()
+	enumType, ok := protoTypes.enums[fullName]
+	protoTypes.mu.RUnlock()
+	if ok {
+		return enumType, true
+	}
+	enumType, err := protoregistry.GlobalTypes.FindEnumByName(protoreflect.FullName(fullName))
+	return enumType, err == nil
+}
+
+// decodeProto decodes a PROTO column value into a message of the type of the
+// column, or into a byte slice if the type is unknown.
+func decodeProto(col spanner.GenericColumnValue) (driver.Value, error) {
+	var b []byte
+	if err := col.Decode(&b); err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, nil
+	}
+	messageType, ok := findProtoMessageType(col.Type.ProtoTypeFqn)
+	if !ok {
+		return b, nil
+	}
+	msg := messageType.New().Interface()
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid value for %s: %v", col.Type.ProtoTypeFqn, err))
+	}
+	return msg, nil
+}
+
+// decodeProtoArray decodes an ARRAY<PROTO> column value into a slice of
+// messages of the type of the elements, or into a slice of byte slices if the
+// type is unknown.
+func decodeProtoArray(col spanner.GenericColumnValue) (driver.Value, error) {
+	var v [][]byte
+	if err := col.Decode(&v); err != nil {
+		return nil, err
+	}
+	fullName := col.Type.ArrayElementType.ProtoTypeFqn
+	messageType, ok := findProtoMessageType(fullName)
+	if !ok {
+		return v, nil
+	}
+	sliceType := reflect.SliceOf(reflect.TypeOf(messageType.Zero().Interface()))
+	if v == nil {
+		return reflect.Zero(sliceType).Interface(), nil
+	}
+	res := reflect.MakeSlice(sliceType, len(v), len(v))
+	for i, b := range v {
+		if b == nil {
+			continue
+		}
+		msg := messageType.New().Interface()
+		if err := proto.Unmarshal(b, msg); err != nil {
+			return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid value for %s: %v", fullName, err))
+		}
+		res.Index(i).Set(reflect.ValueOf(msg))
+	}
+	return res.Interface(), nil
+}
+
+// decodeEnum decodes an ENUM column value into a value of the type of the
+// column, or into an int64 if the type is unknown.
+func decodeEnum(col spanner.GenericColumnValue) (driver.Value, error) {
+	var v spanner.NullInt64
+	if err := col.Decode(&v); err != nil {
+		return nil, err
+	}
+	if !v.Valid {
+		return nil, nil
+	}
+	enumType, ok := findProtoEnumType(col.Type.ProtoTypeFqn)
+	if !ok {
+		return v.Int64, nil
+	}
+	return enumType.New(protoreflect.EnumNumber(v.Int64)), nil
+}
+
+// decodeEnumArray decodes an ARRAY<ENUM> column value into a slice of
+// pointers to the enum type of the elements, or into a slice of NullInt64
+// values if the type is unknown.
+func decodeEnumArray(col spanner.GenericColumnValue) (driver.Value, error) {
+	var v []spanner.NullInt64
+	if err := col.Decode(&v); err != nil {
+		return nil, err
+	}
+	enumType, ok := findProtoEnumType(col.Type.ArrayElementType.ProtoTypeFqn)
+	if !ok {
+		return v, nil
+	}
+	elemType := reflect.TypeOf(enumType.New(0))
+	sliceType := reflect.SliceOf(reflect.PointerTo(elemType))
+	if v == nil {
+		return reflect.Zero(sliceType).Interface(), nil
+	}
+	res := reflect.MakeSlice(sliceType, len(v), len(v))
+	for i, e := range v {
+		if !e.Valid {
+			continue
+		}
+		p := reflect.New(elemType)
+		p.Elem().Set(reflect.ValueOf(enumType.New(protoreflect.EnumNumber(e.Int64))))
+		res.Index(i).Set(p)
+	}
+	return res.Interface(), nil
+}
+
+// ProtoMessage returns a sql.Scanner that scans a PROTO column into the given
+// message. This can be used for PROTO columns of any type, including types
+// that have not been registered with RegisterProtoMessage, as long as the
+// message has the same type as the column. Scanning a NULL value returns an
+// error. Scan the column into a pointer to a message pointer instead if the
+// column can contain NULL.
+//
+// Example:
+//
+//	info := &pb.SingerInfo{}
+//	err := db.QueryRowContext(ctx, "SELECT Info FROM Singers WHERE SingerId=1").
+//		Scan(spannerdriver.ProtoMessage(info))
+func ProtoMessage(dest proto.Message) sql.Scanner {
+	return &protoMessageScanner{dest: dest}
+}
+
+type protoMessageScanner struct {
+	dest proto.Message
+}
+
+func (s *protoMessageScanner) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan NULL into %T", s.dest))
+	case []byte:
+		if err := proto.Unmarshal(v, s.dest); err != nil {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan value into %T: %v", s.dest, err))
+		}
+		return nil
+	case proto.Message:
+		if v.ProtoReflect().Descriptor().FullName() != s.dest.ProtoReflect().Descriptor().FullName() {
+			return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan %T into %T", src, s.dest))
+		}
+		proto.Reset(s.dest)
+		proto.Merge(s.dest, v)
+		return nil
+	}
+	return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "cannot scan %T into %T", src, s.dest))
+}
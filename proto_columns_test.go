@@ -0,0 +1,217 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func createProtoResultSet(t *testing.T, messageFqn, enumFqn string) *sppb.ResultSet {
+	b, err := proto.Marshal(durationpb.New(2 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	protoValue := structpb.NewStringValue(base64.StdEncoding.EncodeToString(b))
+	protoType := &sppb.Type{Code: sppb.TypeCode_PROTO, ProtoTypeFqn: messageFqn}
+	enumType := &sppb.Type{Code: sppb.TypeCode_ENUM, ProtoTypeFqn: enumFqn}
+	return &sppb.ResultSet{
+		Metadata: &sppb.ResultSetMetadata{
+			RowType: &sppb.StructType{
+				Fields: []*sppb.StructType_Field{
+					{Name: "Proto", Type: protoType},
+					{Name: "NullProto", Type: protoType},
+					{Name: "Enum", Type: enumType},
+					{Name: "NullEnum", Type: enumType},
+					{Name: "ProtoArray", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: protoType}},
+					{Name: "EnumArray", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: enumType}},
+				},
+			},
+		},
+		Rows: []*structpb.ListValue{
+			{Values: []*structpb.Value{
+				protoValue,
+				structpb.NewNullValue(),
+				structpb.NewStringValue("3"),
+				structpb.NewNullValue(),
+				structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{protoValue, structpb.NewNullValue()}}),
+				structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("2"), structpb.NewNullValue()}}),
+			}},
+		},
+	}
+}
+
+func TestProtoColumns(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// The types of these columns are in the global protobuf registry.
+	query := "SELECT * FROM ProtoTypes"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: createProtoResultSet(t, "google.protobuf.Duration", "google.spanner.v1.TypeCode"),
+	})
+	var (
+		duration, nullDuration *durationpb.Duration
+		typeCode               sppb.TypeCode
+		nullTypeCode           *sppb.TypeCode
+		durations              []*durationpb.Duration
+		typeCodes              []*sppb.TypeCode
+	)
+	if err := db.QueryRowContext(ctx, query).Scan(&duration, &nullDuration, &typeCode, &nullTypeCode, &durations, &typeCodes); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := duration.AsDuration(), 2*time.Second; g != w {
+		t.Fatalf("proto mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if nullDuration != nil {
+		t.Fatalf("null proto mismatch\n Got: %v\nWant: nil", nullDuration)
+	}
+	if g, w := typeCode, sppb.TypeCode_FLOAT64; g != w {
+		t.Fatalf("enum mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if nullTypeCode != nil {
+		t.Fatalf("null enum mismatch\n Got: %v\nWant: nil", nullTypeCode)
+	}
+	if g, w := len(durations), 2; g != w {
+		t.Fatalf("proto array length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := durations[0].AsDuration(), 2*time.Second; g != w || durations[1] != nil {
+		t.Fatalf("proto array mismatch\n Got: %v\nWant: [%v <nil>]", durations, w)
+	}
+	if g, w := len(typeCodes), 2; g != w {
+		t.Fatalf("enum array length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := *typeCodes[0], sppb.TypeCode_INT64; g != w || typeCodes[1] != nil {
+		t.Fatalf("enum array mismatch\n Got: %v\nWant: [%v <nil>]", typeCodes, w)
+	}
+	// An enum can also be scanned into an integer.
+	var typeCodeNumber int64
+	if err := db.QueryRowContext(ctx, query).Scan(ProtoMessage(&durationpb.Duration{}), &nullDuration, &typeCodeNumber, &nullTypeCode, &durations, &typeCodes); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := typeCodeNumber, int64(3); g != w {
+		t.Fatalf("enum number mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestProtoColumns_Registered(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM RegisteredProtoTypes"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: createProtoResultSet(t, "test.registered.Timeout", "test.registered.Kind"),
+	})
+	if err := RegisterProtoMessage("test.registered.Timeout", &durationpb.Duration{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterProtoEnum("test.registered.Kind", sppb.TypeCode_TYPE_CODE_UNSPECIFIED); err != nil {
+		t.Fatal(err)
+	}
+	var values [6]interface{}
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := db.QueryRowContext(ctx, query).Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := values[0].(*durationpb.Duration); !ok || d.AsDuration() != 2*time.Second {
+		t.Fatalf("proto mismatch\n Got: %v (%T)\nWant: 2s", values[0], values[0])
+	}
+	if g, w := values[2], sppb.TypeCode_FLOAT64; g != w {
+		t.Fatalf("enum mismatch\n Got: %v (%T)\nWant: %v", g, g, w)
+	}
+	if _, ok := values[4].([]*durationpb.Duration); !ok {
+		t.Fatalf("proto array type mismatch\n Got: %T", values[4])
+	}
+	if _, ok := values[5].([]*sppb.TypeCode); !ok {
+		t.Fatalf("enum array type mismatch\n Got: %T", values[5])
+	}
+
+	if err := RegisterProtoMessage("", nil); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestProtoColumns_Unknown(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM UnknownProtoTypes"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: createProtoResultSet(t, "test.unknown.Timeout", "test.unknown.Kind"),
+	})
+	// Values of unknown types are returned as bytes and integers.
+	var (
+		duration     durationpb.Duration
+		nullDuration []byte
+		enum         int64
+		nullEnum     spanner.NullInt64
+		durations    [][]byte
+		enums        []spanner.NullInt64
+	)
+	if err := db.QueryRowContext(ctx, query).Scan(ProtoMessage(&duration), &nullDuration, &enum, &nullEnum, &durations, &enums); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := duration.AsDuration(), 2*time.Second; g != w {
+		t.Fatalf("proto mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if nullDuration != nil {
+		t.Fatalf("null proto mismatch\n Got: %v\nWant: nil", nullDuration)
+	}
+	if g, w := enum, int64(3); g != w {
+		t.Fatalf("enum mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if nullEnum.Valid {
+		t.Fatalf("null enum mismatch\n Got: %v\nWant: NULL", nullEnum)
+	}
+	if g, w := len(durations), 2; g != w || durations[1] != nil {
+		t.Fatalf("proto array mismatch\n Got: %v", durations)
+	}
+	if g, w := len(enums), 2; g != w || enums[0].Int64 != 2 || enums[1].Valid {
+		t.Fatalf("enum array mismatch\n Got: %v", enums)
+	}
+
+	// NULL cannot be scanned into a message with ProtoMessage.
+	if err := ProtoMessage(&duration).Scan(nil); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if err := ProtoMessage(&duration).Scan(&structpb.Struct{}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
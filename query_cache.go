@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// queryCache is a bounded cache of query results that is shared by all
+// connections of a connector. Entries are evicted when they are older than the
+// TTL of the cache, or when the cache is full and the entry is the least
+// recently used entry. Entries are never invalidated by writes to the
+// database.
+type queryCache struct {
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type queryCacheEntry struct {
+	key      string
+	rows     []*spanner.Row
+	metadata *sppb.ResultSetMetadata
+	expires  time.Time
+}
+
+func newQueryCache(maxEntries int, ttl time.Duration) *queryCache {
+	return &queryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// queryCacheKey returns the cache key for the given statement, timestamp
+// bound and query options. Parameters are included with their encoded Spanner
+// value and type, so pointer parameters are keyed by the value that they point
+// to. An error is returned if a parameter cannot be encoded.
+func queryCacheKey(stmt spanner.Statement, tb spanner.TimestampBound, options spanner.QueryOptions) (string, error) {
+	names := make([]string, 0, len(stmt.Params))
+	for name := range stmt.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(stmt.SQL)
+	marshal := proto.MarshalOptions{Deterministic: true}
+	for _, name := range names {
+		row, err := spanner.NewRow([]string{name}, []interface{}{stmt.Params[name]})
+		if err != nil {
+			return "", err
+		}
+		var col spanner.GenericColumnValue
+		if err := row.Column(0, &col); err != nil {
+			return "", err
+		}
+		tp, err := marshal.Marshal(col.Type)
+		if err != nil {
+			return "", err
+		}
+		value, err := marshal.Marshal(col.Value)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\x00%s=%x:%x", name, tp, value)
+	}
+	fmt.Fprintf(&b, "\x00%v", tb)
+	fmt.Fprintf(&b, "\x00%s\x00%s\x00%s",
+		options.Options.GetOptimizerVersion(), options.Options.GetOptimizerStatisticsPackage(), options.RequestTag)
+	if options.DirectedReadOptions != nil {
+		directedRead, err := marshal.Marshal(options.DirectedReadOptions)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\x00%x", directedRead)
+	}
+	return b.String(), nil
+}
+
+func (qc *queryCache) get(key string) (*queryCacheEntry, bool) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	elem, ok := qc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*queryCacheEntry)
+	if !qc.now().Before(entry.expires) {
+		qc.lru.Remove(elem)
+		delete(qc.entries, key)
+		return nil, false
+	}
+	qc.lru.MoveToFront(elem)
+	return entry, true
+}
+
+func (qc *queryCache) put(key string, rows []*spanner.Row, metadata *sppb.ResultSetMetadata) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	entry := &queryCacheEntry{key: key, rows: rows, metadata: metadata, expires: qc.now().Add(qc.ttl)}
+	if elem, ok := qc.entries[key]; ok {
+		elem.Value = entry
+		qc.lru.MoveToFront(elem)
+		return
+	}
+	qc.entries[key] = qc.lru.PushFront(entry)
+	for qc.lru.Len() > qc.maxEntries {
+		oldest := qc.lru.Back()
+		qc.lru.Remove(oldest)
+		delete(qc.entries, oldest.Value.(*queryCacheEntry).key)
+	}
+}
+
+// cachedRowIterator returns the rows of a cached query result.
+type cachedRowIterator struct {
+	entry *queryCacheEntry
+	pos   int
+}
+
+func (it *cachedRowIterator) Next() (*spanner.Row, error) {
+	if it.pos >= len(it.entry.rows) {
+		return nil, iterator.Done
+	}
+	row := it.entry.rows[it.pos]
+	it.pos++
+	return row, nil
+}
+
+func (it *cachedRowIterator) Stop() {}
+
+func (it *cachedRowIterator) Metadata() *sppb.ResultSetMetadata {
+	return it.entry.metadata
+}
+
+// cachingRowIterator records the rows that are returned by a query and adds
+// these to the query cache when all rows have been consumed. Results that are
+// not consumed completely, or that return an error, are not cached.
+type cachingRowIterator struct {
+	rowIterator
+	cache *queryCache
+	key   string
+	rows  []*spanner.Row
+	done  bool
+}
+
+func (it *cachingRowIterator) Next() (*spanner.Row, error) {
+	row, err := it.rowIterator.Next()
+	if err == iterator.Done {
+		if !it.done {
+			it.done = true
+			it.cache.put(it.key, it.rows, it.rowIterator.Metadata())
+		}
+	} else if err == nil {
+		it.rows = append(it.rows, row)
+	}
+	return row, err
+}
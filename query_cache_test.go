@@ -0,0 +1,212 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestQueryCache_Eviction(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	cache := newQueryCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", nil, nil)
+	cache.put("b", nil, nil)
+	// Using "a" makes "b" the least recently used entry.
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("missing entry a")
+	}
+	cache.put("c", nil, nil)
+	if _, ok := cache.get("b"); ok {
+		t.Fatal("entry b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.get(key); !ok {
+			t.Fatalf("missing entry %s", key)
+		}
+	}
+
+	// Entries expire after the TTL.
+	now = now.Add(time.Minute)
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.get(key); ok {
+			t.Fatalf("entry %s should have expired", key)
+		}
+	}
+	if g, w := cache.lru.Len(), 0; g != w {
+		t.Fatalf("cache size mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryCacheKey(t *testing.T) {
+	t.Parallel()
+
+	stmt := func(id interface{}) spanner.Statement {
+		return spanner.Statement{SQL: "SELECT * FROM Foo WHERE Id=@id", Params: map[string]interface{}{"id": id}}
+	}
+	key := func(stmt spanner.Statement, tb spanner.TimestampBound, options spanner.QueryOptions) string {
+		key, err := queryCacheKey(stmt, tb, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	strong := spanner.StrongRead()
+	noOptions := spanner.QueryOptions{}
+	if key(stmt(int64(1)), strong, noOptions) != key(stmt(int64(1)), strong, noOptions) {
+		t.Fatal("keys for equal statements should be equal")
+	}
+	if key(stmt(int64(1)), strong, noOptions) == key(stmt(int64(2)), strong, noOptions) {
+		t.Fatal("keys for different parameter values should be different")
+	}
+	if key(stmt(int64(1)), strong, noOptions) == key(stmt(int64(1)), spanner.ExactStaleness(time.Second), noOptions) {
+		t.Fatal("keys for different timestamp bounds should be different")
+	}
+
+	// Pointer parameters are keyed by the value that they point to.
+	one, otherOne := int64(1), int64(1)
+	if key(stmt(&one), strong, noOptions) != key(stmt(&otherOne), strong, noOptions) {
+		t.Fatal("keys for pointers to equal values should be equal")
+	}
+	if key(stmt(&one), strong, noOptions) != key(stmt(int64(1)), strong, noOptions) {
+		t.Fatal("keys for a pointer and a value should be equal")
+	}
+	name := "foo"
+	before := key(stmt(&name), strong, noOptions)
+	name = "bar"
+	if before == key(stmt(&name), strong, noOptions) {
+		t.Fatal("keys for a pointer with a changed value should be different")
+	}
+	if key(stmt(spanner.NullString{StringVal: "foo", Valid: true}), strong, noOptions) ==
+		key(stmt(&spanner.NullString{StringVal: "bar", Valid: true}), strong, noOptions) {
+		t.Fatal("keys for different NullString values should be different")
+	}
+
+	// The query options are included in the key.
+	for _, options := range []spanner.QueryOptions{
+		{Options: &sppb.ExecuteSqlRequest_QueryOptions{OptimizerVersion: "1"}},
+		{Options: &sppb.ExecuteSqlRequest_QueryOptions{OptimizerStatisticsPackage: "auto_20240101"}},
+		{RequestTag: "dashboard"},
+		{DirectedReadOptions: &sppb.DirectedReadOptions{Replicas: &sppb.DirectedReadOptions_IncludeReplicas_{
+			IncludeReplicas: &sppb.DirectedReadOptions_IncludeReplicas{ReplicaSelections: []*sppb.DirectedReadOptions_ReplicaSelection{{Location: "us-east1"}}},
+		}}},
+	} {
+		if key(stmt(int64(1)), strong, noOptions) == key(stmt(int64(1)), strong, options) {
+			t.Fatalf("keys for different query options should be different: %v", options)
+		}
+		if key(stmt(int64(1)), strong, options) != key(stmt(int64(1)), strong, options) {
+			t.Fatalf("keys for equal query options should be equal: %v", options)
+		}
+	}
+
+	if _, err := queryCacheKey(stmt(make(chan int)), strong, noOptions); err == nil {
+		t.Fatal("missing error for unsupported parameter type")
+	}
+}
+
+func TestCacheableQuery(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	c, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+		ConnectorConfig{QueryCacheSize: 10, QueryCacheTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c.(*connector).queryCache.now = func() time.Time { return now }
+	db := sql.OpenDB(c)
+	defer db.Close()
+	ctx := context.Background()
+
+	query := func(q Queryer, options ExecOptions) {
+		rows, err := q.QueryContext(ctx, testutil.SelectFooFromBar, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		count := 0
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if g, w := count, 2; g != w {
+			t.Fatalf("row count mismatch\n Got: %v\nWant: %v", g, w)
+		}
+	}
+	executeRequestCount := func() int {
+		return len(requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{})))
+	}
+
+	// The second query is served from the cache.
+	query(db, ExecOptions{Cacheable: true})
+	query(db, ExecOptions{Cacheable: true})
+	if g, w := executeRequestCount(), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// Queries that are not marked as cacheable are always executed.
+	query(db, ExecOptions{})
+	if g, w := executeRequestCount(), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// Queries in a transaction are never cached.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query(tx, ExecOptions{Cacheable: true})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := executeRequestCount(), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// The query is executed again after the TTL.
+	now = now.Add(time.Minute)
+	query(db, ExecOptions{Cacheable: true})
+	if g, w := executeRequestCount(), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// Queries with different options do not share a cached result.
+	query(db, ExecOptions{Cacheable: true, RequestTag: "dashboard"})
+	query(db, ExecOptions{Cacheable: true, OptimizerVersion: "1"})
+	if g, w := executeRequestCount(), 2; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	query(db, ExecOptions{Cacheable: true, RequestTag: "dashboard"})
+	if g, w := executeRequestCount(), 0; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package spannerdriver
+
+import (
+	"context"
+	"iter"
+	"reflect"
+)
+
+// Query executes the given query and returns an iterator over the rows in the
+// result. Each row is scanned into a value of type T. If T is a plain struct,
+// each column is scanned into the field with a `spanner:"ColumnName"` struct tag,
+// or into the field with the same name as the column if no field has a
+// matching tag. If no field matches exactly, the column is scanned into the
+// field with the same name ignoring case, or else into the field with the same
+// name ignoring case and underscores, so a column `album_title` is scanned into
+// a field AlbumTitle. Pass in ExecOptions{CaseSensitiveFieldNames: true} as an
+// argument to only allow exact matches. Otherwise, including if T is a struct
+// that implements sql.Scanner or that represents a single value, such as
+// time.Time, big.Rat, sql.NullString or spanner.NullInt64, the query must
+// return exactly one column, and that column is scanned into T.
+//
+// Rows are fetched lazily while the iterator is being consumed. The underlying
+// rows are closed when the iteration finishes, or when the caller stops the
+// iteration early, for example by breaking out of a range loop.
+//
+// If the query fails, or if a row cannot be scanned into T, the iterator
+// yields the zero value of T together with the error and then stops.
+//
+// Example:
+//
+//	type singer struct {
+//		ID   int64  `spanner:"SingerId"`
+//		Name string `spanner:"Name"`
+//	}
+//	for s, err := range spannerdriver.Query[singer](ctx, db, "SELECT SingerId, Name FROM Singers") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(s.ID, s.Name)
+//	}
+func Query[T any](ctx context.Context, db Queryer, query string, args ...interface{}) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		t := reflect.TypeOf(zero)
+		var indexes []int
+		if isRowStruct(t) {
+			if indexes, err = structFieldIndexes(t, columns, caseSensitiveFieldNames(args)); err != nil {
+				yield(zero, err)
+				return
+			}
+		}
+		for rows.Next() {
+			var v T
+			var dest []interface{}
+			if indexes != nil {
+				dest = scanStructFields(reflect.ValueOf(&v).Elem(), indexes)
+			} else {
+				dest = []interface{}{&v}
+			}
+			if err := rows.Scan(dest...); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// caseSensitiveFieldNames returns true if the given query arguments contain
+// ExecOptions with CaseSensitiveFieldNames set.
+func caseSensitiveFieldNames(args []interface{}) bool {
+	for _, arg := range args {
+		if options, ok := arg.(ExecOptions); ok && options.CaseSensitiveFieldNames {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type testAlbum struct {
+	SingerID int64 `spanner:"SingerId"`
+	AlbumId  int64
+	Title    string `spanner:"AlbumTitle"`
+	Ignored  string `spanner:"-"`
+}
+
+func TestQueryIter(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	var got []testAlbum
+	Query[testAlbum](ctx, db, testutil.SelectSingerIDAlbumIDAlbumTitleFromAlbums)(func(album testAlbum, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, album)
+		return true
+	})
+	var want []testAlbum
+	for i := int64(0); i < testutil.SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; i++ {
+		want = append(want, testAlbum{SingerID: i + 1, AlbumId: i*10 + i, Title: fmt.Sprintf("Album title %d", i)})
+	}
+	if !cmp.Equal(got, want) {
+		t.Fatalf("albums mismatch\n Got: %v\nWant: %v", got, want)
+	}
+
+	// Non-struct types are scanned from the single column in the result.
+	var values []int64
+	Query[int64](ctx, db, testutil.SelectFooFromBar)(func(v int64, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+		return true
+	})
+	if g, w := values, []int64{1, 2}; !cmp.Equal(g, w) {
+		t.Fatalf("values mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryIter_StopEarly(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestDBConnection(t)
+	defer teardown()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	count := 0
+	Query[testAlbum](ctx, db, testutil.SelectSingerIDAlbumIDAlbumTitleFromAlbums)(func(album testAlbum, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		return false
+	})
+	if g, w := count, 1; g != w {
+		t.Fatalf("count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// The rows should have been closed, which means that the only connection
+	// in the pool is available for the next query.
+	if stats := db.Stats(); stats.InUse != 0 {
+		t.Fatalf("connections in use mismatch\n Got: %v\nWant: %v", stats.InUse, 0)
+	}
+}
+
+func TestQueryIter_Errors(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// A column that cannot be mapped to a field yields an error.
+	type unknownColumn struct {
+		SingerId int64
+	}
+	var errs []error
+	Query[unknownColumn](ctx, db, testutil.SelectSingerIDAlbumIDAlbumTitleFromAlbums)(func(_ unknownColumn, err error) bool {
+		errs = append(errs, err)
+		return true
+	})
+	if g, w := len(errs), 1; g != w {
+		t.Fatalf("num errors mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := spanner.ErrCode(errs[0]), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// A query error is yielded once and then the iteration stops.
+	query := "SELECT * FROM NonExisting"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Err: gstatus.Error(codes.NotFound, "Table not found"),
+	})
+	errs = nil
+	Query[int64](ctx, db, query)(func(_ int64, err error) bool {
+		errs = append(errs, err)
+		return true
+	})
+	if g, w := len(errs), 1; g != w {
+		t.Fatalf("num errors mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := spanner.ErrCode(errs[0]), codes.NotFound; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryIter_ValueStructs(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	ts := time.Date(2024, 2, 29, 10, 30, 0, 0, time.UTC)
+	query := "SELECT LastUpdated FROM Singers"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{Fields: []*sppb.StructType_Field{
+				{Name: "LastUpdated", Type: &sppb.Type{Code: sppb.TypeCode_TIMESTAMP}},
+			}}},
+			Rows: []*structpb.ListValue{
+				{Values: []*structpb.Value{structpb.NewStringValue(ts.Format(time.RFC3339Nano))}},
+				{Values: []*structpb.Value{structpb.NewNullValue()}},
+			},
+		},
+	})
+
+	// Structs that represent a single value are scanned from the column.
+	var times []time.Time
+	var scanErr error
+	for v, err := range Query[time.Time](ctx, db, query) {
+		if err != nil {
+			scanErr = err
+			break
+		}
+		times = append(times, v)
+	}
+	// The second row contains a NULL value that cannot be scanned into a
+	// time.Time.
+	if scanErr == nil {
+		t.Fatal("missing error for NULL value")
+	}
+	if g, w := times, []time.Time{ts}; !cmp.Equal(g, w) {
+		t.Fatalf("times mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Structs that implement sql.Scanner are scanned from the column.
+	var nullTimes []sql.NullTime
+	for v, err := range Query[sql.NullTime](ctx, db, query) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		nullTimes = append(nullTimes, v)
+	}
+	if g, w := nullTimes, []sql.NullTime{{Time: ts, Valid: true}, {}}; !cmp.Equal(g, w) {
+		t.Fatalf("null times mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var spannerNullTimes []spanner.NullTime
+	for v, err := range Query[spanner.NullTime](ctx, db, query) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		spannerNullTimes = append(spannerNullTimes, v)
+	}
+	if g, w := spannerNullTimes, []spanner.NullTime{{Time: ts, Valid: true}, {}}; !cmp.Equal(g, w) {
+		t.Fatalf("spanner null times mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	var names []sql.NullString
+	for v, err := range Query[sql.NullString](ctx, db, testutil.SelectFooFromBar) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, v)
+	}
+	if g, w := names, []sql.NullString{{String: "1", Valid: true}, {String: "2", Valid: true}}; !cmp.Equal(g, w) {
+		t.Fatalf("null strings mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	var ids []spanner.NullInt64
+	for v, err := range Query[spanner.NullInt64](ctx, db, testutil.SelectFooFromBar) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, v)
+	}
+	if g, w := ids, []spanner.NullInt64{{Int64: 1, Valid: true}, {Int64: 2, Valid: true}}; !cmp.Equal(g, w) {
+		t.Fatalf("null ints mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RowQueryer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type RowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// ReadRow reads a single row by primary key from the given table and scans
+// the given columns into dest. It returns sql.ErrNoRows if the row does not
+// exist.
+//
+// ReadRow uses the Read API of Spanner instead of a SQL query. This is the
+// cheapest way to read a single row, as the statement does not need to be
+// parsed and planned by Spanner. The row is read in the transaction of the
+// given queryer if it is a *sql.Tx, and with a single-use read-only
+// transaction if it is a *sql.DB or a *sql.Conn that is not in a transaction.
+//
+// Example:
+//
+//	var name string
+//	err := spannerdriver.ReadRow(ctx, db, "Singers", spanner.Key{int64(1)},
+//		[]string{"Name"}, &name)
+func ReadRow(ctx context.Context, queryer RowQueryer, table string, key spanner.Key, columns []string, dest ...interface{}) error {
+	if len(columns) != len(dest) {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "number of columns (%d) does not match number of destinations (%d)", len(columns), len(dest)))
+	}
+	req := readRequest{table: table, keys: key, columns: columns}
+	return queryer.QueryRowContext(ctx, "READ "+table, req).Scan(dest...)
+}
+
+// ReadOptions are the options for a read that is executed with
+// SpannerConn.Read or SpannerConn.ReadRow.
+type ReadOptions struct {
+	// Index is the name of a secondary index that should be used for the
+	// read. The keys of the read are then interpreted as keys of the index,
+	// and only columns that are stored in the index can be read. The primary
+	// key of the table is used if this is empty.
+	Index string
+	// Limit is the maximum number of rows that are returned. All rows are
+	// returned if it is zero.
+	Limit int
+	// Priority is the RPC priority of the read. This overrides the default
+	// priority of the connection.
+	Priority spannerpb.RequestOptions_Priority
+	// RequestTag is the request tag that should be added to the read.
+	RequestTag string
+}
+
+// readRequest is a read that is executed instead of a query. It is passed in
+// as an argument to a query in the same way as ExecOptions.
+type readRequest struct {
+	table   string
+	keys    spanner.KeySet
+	columns []string
+	// options are the options of the read. The default options of the
+	// client are used if they are empty.
+	options ReadOptions
+}
+
+type reader interface {
+	Read(ctx context.Context, table string, keys spanner.KeySet, columns []string) *spanner.RowIterator
+	ReadWithOptions(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts *spanner.ReadOptions) *spanner.RowIterator
+}
+
+func (req *readRequest) execute(ctx context.Context, r reader) *spanner.RowIterator {
+	if req.options == (ReadOptions{}) {
+		// ReadOptions replace all read options of the client, so these are
+		// only used if they change anything.
+		return r.Read(ctx, req.table, req.keys, req.columns)
+	}
+	return r.ReadWithOptions(ctx, req.table, req.keys, req.columns, &spanner.ReadOptions{
+		Index:      req.options.Index,
+		Limit:      req.options.Limit,
+		Priority:   req.options.Priority,
+		RequestTag: req.options.RequestTag,
+	})
+}
+
+func (c *conn) Read(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts ReadOptions) (driver.Rows, error) {
+	if err := c.enter(); err != nil {
+		return nil, err
+	}
+	defer c.leave()
+	if opts.Limit < 0 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid limit: %d", opts.Limit))
+	}
+	if err := c.beginImplicitTransaction(); err != nil {
+		return nil, err
+	}
+	if opts.Priority == spannerpb.RequestOptions_PRIORITY_UNSPECIFIED {
+		execOptions := c.options()
+		opts.Priority = execOptions.queryOptions().Priority
+	}
+	return c.read(ctx, "READ "+table, readRequest{table: table, keys: keys, columns: columns, options: opts})
+}
+
+func (c *conn) ReadRow(ctx context.Context, table string, key spanner.Key, columns []string, opts ReadOptions) (driver.Rows, error) {
+	return c.Read(ctx, table, key, columns, opts)
+}
+
+// read executes the given read on the connection. The read uses the current
+// transaction of the connection, or a single-use read-only transaction if the
+// connection is not in a transaction.
+func (c *conn) read(ctx context.Context, query string, req readRequest) (driver.Rows, error) {
+	c.commitTs = nil
+	c.queryPlan = nil
+
+	ctx, done := c.startStatement(ctx, spanQuery, query)
+	var iter rowIterator
+	if c.tx == nil {
+		iter = &readOnlyRowIterator{req.execute(ctx, c.client.Single().WithTimestampBound(c.readOnlyStaleness))}
+	} else {
+		if err := c.checkSingleUseReadOnlyTransaction(); err != nil {
+			done(err)
+			return nil, err
+		}
+		iter = c.tx.Read(ctx, req)
+	}
+	return c.newStreamingRows(ctx, &rows{it: iter, done: done, decoders: c.columnDecoders()})
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadRow(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// The mock server returns the result of 'SELECT <columns> FROM <table>'
+	// for a read.
+	_ = server.TestSpanner.PutStatementResult("SELECT SingerId, Rating FROM Singers", &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1, 100}}, [2]string{"SingerId", "Rating"}),
+	})
+	_ = server.TestSpanner.PutStatementResult("SELECT SingerId, Rating FROM Albums", &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{}, [2]string{"SingerId", "Rating"}),
+	})
+
+	// Autocommit uses a single-use read-only transaction.
+	var id, rating int64
+	if err := ReadRow(ctx, db, "Singers", spanner.Key{int64(1)}, []string{"SingerId", "Rating"}, &id, &rating); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := rating, int64(100); g != w {
+		t.Fatalf("rating mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ReadRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("read requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ReadRequest)
+	if req.GetTransaction().GetSingleUse() == nil {
+		t.Fatalf("missing single-use transaction: %v", req.GetTransaction())
+	}
+	if g, w := req.Table, "Singers"; g != w {
+		t.Fatalf("table mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(req.GetKeySet().GetKeys()), 1; g != w {
+		t.Fatalf("key count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// A missing row returns sql.ErrNoRows.
+	if err := ReadRow(ctx, db, "Albums", spanner.Key{int64(1)}, []string{"SingerId", "Rating"}, &id, &rating); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, sql.ErrNoRows)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+
+	// The row is read in the transaction.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadRow(ctx, tx, "Singers", spanner.Key{int64(1)}, []string{"SingerId", "Rating"}, &id, &rating); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	requests = requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ReadRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("read requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if requests[0].(*sppb.ReadRequest).GetTransaction().GetId() == nil {
+		t.Fatalf("missing transaction id: %v", requests[0].(*sppb.ReadRequest).GetTransaction())
+	}
+
+	if err := ReadRow(ctx, db, "Singers", spanner.Key{int64(1)}, []string{"SingerId", "Rating"}, &id); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestReadRow_RetryAborted(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_ = server.TestSpanner.PutStatementResult("SELECT SingerId, Rating FROM Singers", &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1, 100}}, [2]string{"SingerId", "Rating"}),
+	})
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id, rating int64
+	if err := ReadRow(ctx, tx, "Singers", spanner.Key{int64(1)}, []string{"SingerId", "Rating"}, &id, &rating); err != nil {
+		t.Fatal(err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted")},
+	})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// The read is executed twice: once in the initial transaction, and once
+	// during the retry.
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ReadRequest{}))
+	if g, w := len(requests), 2; g != w {
+		t.Fatalf("read requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestSpannerConnRead(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_ = server.TestSpanner.PutStatementResult("SELECT SingerId, Rating FROM Singers", &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1, 100}, {2, 200}}, [2]string{"SingerId", "Rating"}),
+	})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var ratings []int64
+	if err := conn.Raw(func(driverConn interface{}) error {
+		keys := spanner.KeySets(spanner.Key{int64(1)}, spanner.Key{int64(2)})
+		rows, err := driverConn.(SpannerConn).Read(ctx, "Singers", keys, []string{"SingerId", "Rating"}, ReadOptions{
+			Index:      "SingersByRating",
+			Limit:      10,
+			RequestTag: "lookup",
+		})
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if g, w := rows.Columns(), []string{"SingerId", "Rating"}; !reflect.DeepEqual(g, w) {
+			t.Fatalf("columns mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		dest := make([]driver.Value, 2)
+		for {
+			if err := rows.Next(dest); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			ratings = append(ratings, dest[1].(int64))
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := ratings, []int64{100, 200}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("ratings mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ReadRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("read requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ReadRequest)
+	if req.GetTransaction().GetSingleUse() == nil {
+		t.Fatalf("missing single-use transaction: %v", req.GetTransaction())
+	}
+	if g, w := req.Index, "SingersByRating"; g != w {
+		t.Fatalf("index mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Limit, int64(10); g != w {
+		t.Fatalf("limit mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.GetRequestOptions().GetRequestTag(), "lookup"; g != w {
+		t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(req.GetKeySet().GetKeys()), 2; g != w {
+		t.Fatalf("key count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// ReadRow uses the implicit transaction of the connection if autocommit
+	// is disabled.
+	if _, err := conn.ExecContext(ctx, "SET AUTOCOMMIT = FALSE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Raw(func(driverConn interface{}) error {
+		rows, err := driverConn.(SpannerConn).ReadRow(ctx, "Singers", spanner.Key{int64(1)}, []string{"SingerId", "Rating"}, ReadOptions{})
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		return rows.Next(make([]driver.Value, 2))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+	requests = requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ReadRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("read requests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req = requests[0].(*sppb.ReadRequest)
+	if req.GetTransaction().GetId() == nil && req.GetTransaction().GetBegin() == nil {
+		t.Fatalf("missing transaction: %v", req.GetTransaction())
+	}
+	if g, w := len(req.GetKeySet().GetKeys()), 1; g != w {
+		t.Fatalf("key count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if err := conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(SpannerConn).Read(ctx, "Singers", spanner.AllKeys(), []string{"SingerId"}, ReadOptions{Limit: -1})
+		return err
+	}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
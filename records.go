@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+)
+
+// RecordColumn contains the metadata of a column in a query result.
+type RecordColumn struct {
+	// Name is the name of the column.
+	Name string
+	// DatabaseTypeName is the Spanner type name of the column, for example
+	// INT64 or ARRAY<STRING>.
+	DatabaseTypeName string
+}
+
+// RecordReader reads the rows of a query result as records without requiring
+// the caller to know the number or the types of the columns in advance. This
+// can be used to build generic result serializers.
+//
+// Each value in a record has one of the following types:
+//   - Scalar columns: spanner.NullBool, spanner.NullString, spanner.NullInt64,
+//     spanner.NullFloat32, spanner.NullFloat64, spanner.NullNumeric,
+//     spanner.NullDate, spanner.NullTime, spanner.NullJSON or spanner.PGJsonB
+//     for PostgreSQL JSONB columns. Spanner does not return whether a column
+//     is nullable, and the Null* types are therefore used for all scalar
+//     columns.
+//   - BYTES columns: []byte, which is nil for NULL values.
+//   - ARRAY columns: a slice of the Null* type of the element type, for
+//     example []spanner.NullInt64 for ARRAY<INT64>, or [][]byte for
+//     ARRAY<BYTES>. The slice is nil for NULL values.
+type RecordReader struct {
+	rows    *sql.Rows
+	columns []RecordColumn
+	record  []interface{}
+	err     error
+}
+
+// NewRecordReader returns a RecordReader for the given rows. The rows are
+// read one at a time while the reader is being consumed. The caller must close
+// the reader, or the rows, when done.
+func NewRecordReader(rows *sql.Rows) (*RecordReader, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]RecordColumn, len(types))
+	for i, t := range types {
+		columns[i] = RecordColumn{Name: t.Name(), DatabaseTypeName: t.DatabaseTypeName()}
+	}
+	return &RecordReader{rows: rows, columns: columns}, nil
+}
+
+// Columns returns the metadata of the columns in the result.
+func (r *RecordReader) Columns() []RecordColumn {
+	return r.columns
+}
+
+// Next reads the next record. It returns false when there are no more records,
+// or when an error occurred. Err should be checked after Next returns false.
+func (r *RecordReader) Next() bool {
+	r.record = nil
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+	values := make([]interface{}, len(r.columns))
+	dest := make([]interface{}, len(r.columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		r.err = err
+		return false
+	}
+	for i, c := range r.columns {
+		values[i] = toRecordValue(c.DatabaseTypeName, values[i])
+	}
+	r.record = values
+	return true
+}
+
+// Record returns the record that was read by the last call to Next.
+func (r *RecordReader) Record() []interface{} {
+	return r.record
+}
+
+// Err returns the error that occurred while reading the records, if any.
+func (r *RecordReader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.rows.Err()
+}
+
+// Close closes the underlying rows.
+func (r *RecordReader) Close() error {
+	return r.rows.Close()
+}
+
+// RowsToRecords reads all remaining rows into records and returns these
+// together with the metadata of the columns. The rows are closed when this
+// function returns. Use a RecordReader to stream large results instead of
+// reading them into memory.
+func RowsToRecords(rows *sql.Rows) ([]RecordColumn, [][]interface{}, error) {
+	defer rows.Close()
+	reader, err := NewRecordReader(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	var records [][]interface{}
+	for reader.Next() {
+		records = append(records, reader.Record())
+	}
+	if err := reader.Err(); err != nil {
+		return nil, nil, err
+	}
+	return reader.Columns(), records, nil
+}
+
+// toRecordValue converts a value that was returned by the driver to the value
+// that is used for the given type in a record.
+func toRecordValue(typeName string, v interface{}) interface{} {
+	switch typeName {
+	case "BOOL":
+		b, ok := v.(bool)
+		return spanner.NullBool{Bool: b, Valid: ok}
+	case "STRING":
+		s, ok := v.(string)
+		return spanner.NullString{StringVal: s, Valid: ok}
+	case "INT64":
+		i, ok := v.(int64)
+		return spanner.NullInt64{Int64: i, Valid: ok}
+	case "FLOAT32":
+		f, ok := v.(float32)
+		return spanner.NullFloat32{Float32: f, Valid: ok}
+	case "FLOAT64":
+		f, ok := v.(float64)
+		return spanner.NullFloat64{Float64: f, Valid: ok}
+	case "NUMERIC":
+		n, ok := v.(big.Rat)
+		return spanner.NullNumeric{Numeric: n, Valid: ok}
+	case "DATE":
+		d, ok := v.(civil.Date)
+		return spanner.NullDate{Date: d, Valid: ok}
+	case "TIMESTAMP":
+		t, ok := v.(time.Time)
+		return spanner.NullTime{Time: t, Valid: ok}
+	case "BYTES":
+		b, _ := v.([]byte)
+		return b
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestRowsToRecords(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM AllTypes"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateResultSetWithAllTypes(false),
+	})
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, records, err := RowsToRecords(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(columns), 20; g != w {
+		t.Fatalf("column count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	wantColumns := []RecordColumn{
+		{Name: "ColBool", DatabaseTypeName: "BOOL"},
+		{Name: "ColString", DatabaseTypeName: "STRING"},
+		{Name: "ColBytes", DatabaseTypeName: "BYTES"},
+		{Name: "ColInt", DatabaseTypeName: "INT64"},
+		{Name: "ColFloat32", DatabaseTypeName: "FLOAT32"},
+		{Name: "ColFloat64", DatabaseTypeName: "FLOAT64"},
+		{Name: "ColNumeric", DatabaseTypeName: "NUMERIC"},
+		{Name: "ColDate", DatabaseTypeName: "DATE"},
+		{Name: "ColTimestamp", DatabaseTypeName: "TIMESTAMP"},
+		{Name: "ColJson", DatabaseTypeName: "JSON"},
+		{Name: "ColBoolArray", DatabaseTypeName: "ARRAY<BOOL>"},
+	}
+	if g, w := columns[:len(wantColumns)], wantColumns; !cmp.Equal(g, w) {
+		t.Fatalf("columns mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(records), 1; g != w {
+		t.Fatalf("record count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	ts, _ := time.Parse(time.RFC3339Nano, "2021-07-21T21:07:59.339911800Z")
+	wantValues := []interface{}{
+		spanner.NullBool{Bool: true, Valid: true},
+		spanner.NullString{StringVal: "test", Valid: true},
+		[]byte("testbytes"),
+		spanner.NullInt64{Int64: 5, Valid: true},
+		spanner.NullFloat32{Float32: 3.14, Valid: true},
+		spanner.NullFloat64{Float64: 3.14, Valid: true},
+		spanner.NullNumeric{Numeric: *big.NewRat(6626, 1000), Valid: true},
+		spanner.NullDate{Date: civil.Date{Year: 2021, Month: 7, Day: 21}, Valid: true},
+		spanner.NullTime{Time: ts, Valid: true},
+	}
+	for i, want := range wantValues {
+		if g, w := records[0][i], want; !cmp.Equal(g, w, cmp.AllowUnexported(big.Rat{}, big.Int{})) {
+			t.Errorf("%d: value mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+	}
+	if g, w := records[0][10], []spanner.NullBool{{Bool: true, Valid: true}, {}, {Bool: false, Valid: true}}; !cmp.Equal(g, w) {
+		t.Errorf("array value mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRecordReader_NullValues(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM AllTypes"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateResultSetWithAllTypes(true),
+	})
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewRecordReader(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	count := 0
+	for reader.Next() {
+		count++
+		record := reader.Record()
+		wantValues := []interface{}{
+			spanner.NullBool{},
+			spanner.NullString{},
+			[]byte(nil),
+			spanner.NullInt64{},
+			spanner.NullFloat32{},
+			spanner.NullFloat64{},
+			spanner.NullNumeric{},
+			spanner.NullDate{},
+			spanner.NullTime{},
+			spanner.NullJSON{},
+		}
+		for i, want := range wantValues {
+			if g, w := record[i], want; !cmp.Equal(g, w, cmp.AllowUnexported(big.Rat{}, big.Int{})) {
+				t.Errorf("%d: value mismatch\n Got: %v\nWant: %v", i, g, w)
+			}
+		}
+	}
+	if err := reader.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := count, 1; g != w {
+		t.Fatalf("record count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
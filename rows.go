@@ -16,12 +16,21 @@ package spannerdriver
 
 import (
 	"database/sql/driver"
+	"fmt"
 	"io"
+	"math/big"
+	"strconv"
 	"sync"
+	"time"
 
+	"cloud.google.com/go/civil"
 	"cloud.google.com/go/spanner"
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type rows struct {
@@ -30,8 +39,44 @@ type rows struct {
 	colsOnce sync.Once
 	dirtyErr error
 	cols     []string
+	colTypes []*sppb.Type
 
 	dirtyRow *spanner.Row
+
+	// done is called when all rows have been consumed, when an error occurs,
+	// or when the rows are closed. It may be nil.
+	done func(err error)
+	// nullAsZeroValue indicates that NULL values of scalar columns should be
+	// returned as the zero value of the column type instead of nil.
+	nullAsZeroValue bool
+	// decodeToNativeArrays indicates that arrays should be returned as slices
+	// of native Go types instead of slices of nullable Spanner types.
+	decodeToNativeArrays bool
+	// dateLocation is the location that is used to return DATE values as
+	// time.Time. DATE values are returned as civil.Date if it is nil.
+	dateLocation *time.Location
+	// numericAsString indicates that NUMERIC values should be returned as
+	// strings instead of big.Rat values.
+	numericAsString bool
+	// numericAsFloat64 indicates that NUMERIC values should be returned as
+	// float64 values instead of big.Rat values. This is lossy.
+	numericAsFloat64 bool
+	// jsonAsBytes indicates that JSON and JSONB values should be returned as
+	// byte slices that contain the JSON text.
+	jsonAsBytes bool
+	// decoders are the column decoders that are used instead of the default
+	// decoding for columns of a specific type.
+	decoders map[sppb.TypeCode]ColumnDecoder
+	// stats is called with the statistics of the query when all rows have
+	// been consumed. It may be nil.
+	stats func(stats *sppb.ResultSetStats)
+	// span is the span of the statement. The number of rows that have been
+	// returned is added to the span when the rows are finished. It may be nil.
+	span     trace.Span
+	rowCount int64
+	// lastStatement records the number of rows that have been returned when
+	// the rows are finished. It may be nil.
+	lastStatement *lastStatement
 }
 
 // Columns returns the names of the columns. The number of
@@ -43,12 +88,62 @@ func (r *rows) Columns() []string {
 	return r.cols
 }
 
+// ColumnTypeDatabaseTypeName returns the Spanner type name of the column with
+// the given index, for example INT64 or ARRAY<STRING>.
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	r.getColumns()
+	if index < 0 || index >= len(r.colTypes) {
+		return ""
+	}
+	return typeName(r.colTypes[index])
+}
+
+// typeName returns the name of the given Spanner type.
+func typeName(t *sppb.Type) string {
+	switch t.GetCode() {
+	case sppb.TypeCode_ARRAY:
+		return "ARRAY<" + typeName(t.ArrayElementType) + ">"
+	default:
+		return t.GetCode().String()
+	}
+}
+
 // Close closes the rows iterator.
 func (r *rows) Close() error {
-	r.it.Stop()
+	r.finish(nil)
 	return nil
 }
 
+// finish stops the iterator and calls the done function of the rows, if any.
+// finish is called as soon as all rows have been consumed or an error occurs,
+// so the stream is closed and the session is returned to the session pool
+// without waiting for the rows to be closed. finish may be called more than
+// once.
+func (r *rows) finish(err error) {
+	r.it.Stop()
+	if r.span != nil {
+		r.span.SetAttributes(attrRowCount.Int64(r.rowCount))
+	}
+	if r.lastStatement != nil {
+		r.lastStatement.rowsAffected.Store(r.rowCount)
+	}
+	if r.done != nil {
+		r.done(err)
+	}
+}
+
+// recordStats passes the statistics of the query to the stats function of the
+// rows, if any. The statistics are only complete after all rows have been
+// consumed.
+func (r *rows) recordStats() {
+	if r.stats == nil {
+		return
+	}
+	if stats := resultSetStats(r.it); stats != nil {
+		r.stats(stats)
+	}
+}
+
 func (r *rows) getColumns() {
 	r.colsOnce.Do(func() {
 		row, err := r.it.Next()
@@ -62,8 +157,10 @@ func (r *rows) getColumns() {
 		}
 		rowType := r.it.Metadata().RowType
 		r.cols = make([]string, len(rowType.Fields))
+		r.colTypes = make([]*sppb.Type, len(rowType.Fields))
 		for i, c := range rowType.Fields {
 			r.cols[i] = c.Name
+			r.colTypes[i] = c.Type
 		}
 	})
 }
@@ -87,25 +184,40 @@ func (r *rows) Next(dest []driver.Value) error {
 		err := r.dirtyErr
 		r.dirtyErr = nil
 		if err == iterator.Done {
+			r.recordStats()
+			r.finish(nil)
 			return io.EOF
 		}
+		r.finish(err)
 		return err
 	} else {
 		var err error
 		row, err = r.it.Next() // returns io.EOF when there is no next
 		if err == iterator.Done {
+			r.recordStats()
+			r.finish(nil)
 			return io.EOF
 		}
 		if err != nil {
+			r.finish(err)
 			return err
 		}
 	}
+	r.rowCount++
 
 	for i := 0; i < row.Size(); i++ {
 		var col spanner.GenericColumnValue
 		if err := row.Column(i, &col); err != nil {
 			return err
 		}
+		if decoder, ok := r.decoders[col.Type.Code]; ok {
+			v, err := decoder(col)
+			if err != nil {
+				return err
+			}
+			dest[i] = v
+			continue
+		}
 		switch col.Type.Code {
 		case sppb.TypeCode_INT64:
 			var v spanner.NullInt64
@@ -138,6 +250,28 @@ func (r *rows) Next(dest []driver.Value) error {
 				dest[i] = nil
 			}
 		case sppb.TypeCode_NUMERIC:
+			if r.numericAsString {
+				// NUMERIC values are encoded as strings. Returning the encoded
+				// value directly preserves the exact representation.
+				if _, ok := col.Value.GetKind().(*structpb.Value_NullValue); ok {
+					dest[i] = nil
+				} else {
+					dest[i] = col.Value.GetStringValue()
+				}
+				break
+			}
+			if r.numericAsFloat64 {
+				v, err := numericToFloat64(col.Value)
+				if err != nil {
+					return err
+				}
+				if v.Valid {
+					dest[i] = v.Float64
+				} else {
+					dest[i] = nil
+				}
+				break
+			}
 			var v spanner.NullNumeric
 			if err := col.Decode(&v); err != nil {
 				return err
@@ -158,6 +292,20 @@ func (r *rows) Next(dest []driver.Value) error {
 				dest[i] = nil
 			}
 		case sppb.TypeCode_JSON:
+			if r.jsonAsBytes {
+				dest[i] = decodeJSONBytes(col)
+				break
+			}
+			if col.Type.TypeAnnotation == sppb.TypeAnnotationCode_PG_JSONB {
+				var v spanner.PGJsonB
+				if err := col.Decode(&v); err != nil {
+					return err
+				}
+				// PostgreSQL JSONB values are always returned as PGJsonB for the
+				// same reason as JSON values are always returned as NullJSON.
+				dest[i] = v
+				break
+			}
 			var v spanner.NullJSON
 			if err := col.Decode(&v); err != nil {
 				return err
@@ -167,7 +315,9 @@ func (r *rows) Next(dest []driver.Value) error {
 			// nil we should return a NullJSON with valid=false.
 			dest[i] = v
 		case sppb.TypeCode_BYTES:
-			// The column value is a base64 encoded string.
+			// The column value is a base64 encoded string. The decoded slice is
+			// not reused for other rows, which allows database/sql to assign it
+			// directly to a sql.RawBytes destination without copying it.
 			var v []byte
 			if err := col.Decode(&v); err != nil {
 				return err
@@ -188,10 +338,12 @@ func (r *rows) Next(dest []driver.Value) error {
 			if err := col.Decode(&v); err != nil {
 				return err
 			}
-			if v.Valid {
-				dest[i] = v.Date
-			} else {
+			if !v.Valid {
 				dest[i] = nil
+			} else if r.dateLocation != nil {
+				dest[i] = v.Date.In(r.dateLocation)
+			} else {
+				dest[i] = v.Date
 			}
 		case sppb.TypeCode_TIMESTAMP:
 			var v spanner.NullTime
@@ -199,12 +351,40 @@ func (r *rows) Next(dest []driver.Value) error {
 				return err
 			}
 			if v.Valid {
-				dest[i] = v.Time
+				dest[i] = normalizeTime(v.Time)
 			} else {
 				dest[i] = nil
 			}
+		case sppb.TypeCode_PROTO:
+			v, err := decodeProto(col)
+			if err != nil {
+				return err
+			}
+			dest[i] = v
+		case sppb.TypeCode_ENUM:
+			v, err := decodeEnum(col)
+			if err != nil {
+				return err
+			}
+			dest[i] = v
 		case sppb.TypeCode_ARRAY:
+			// A NULL array is always returned as a nil slice, and an empty
+			// array as a non-nil slice with length zero, so the two can be
+			// distinguished after scanning. This also applies to the
+			// conversions below.
 			switch col.Type.ArrayElementType.Code {
+			case sppb.TypeCode_PROTO:
+				v, err := decodeProtoArray(col)
+				if err != nil {
+					return err
+				}
+				dest[i] = v
+			case sppb.TypeCode_ENUM:
+				v, err := decodeEnumArray(col)
+				if err != nil {
+					return err
+				}
+				dest[i] = v
 			case sppb.TypeCode_INT64:
 				var v []spanner.NullInt64
 				if err := col.Decode(&v); err != nil {
@@ -224,6 +404,18 @@ func (r *rows) Next(dest []driver.Value) error {
 				}
 				dest[i] = v
 			case sppb.TypeCode_NUMERIC:
+				if r.numericAsString {
+					dest[i] = toNumericStringArray(col.Value)
+					break
+				}
+				if r.numericAsFloat64 {
+					v, err := toNumericFloat64Array(col.Value)
+					if err != nil {
+						return err
+					}
+					dest[i] = v
+					break
+				}
 				var v []spanner.NullNumeric
 				if err := col.Decode(&v); err != nil {
 					return err
@@ -236,11 +428,23 @@ func (r *rows) Next(dest []driver.Value) error {
 				}
 				dest[i] = v
 			case sppb.TypeCode_JSON:
+				if r.decodeToNativeArrays {
+					v, err := decodeRawMessageArray(col)
+					if err != nil {
+						return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "column %s: %v", r.cols[i], err))
+					}
+					dest[i] = v
+					break
+				}
 				var v []spanner.NullJSON
 				if err := col.Decode(&v); err != nil {
 					return err
 				}
-				dest[i] = v
+				if col.Type.ArrayElementType.TypeAnnotation == sppb.TypeAnnotationCode_PG_JSONB {
+					dest[i] = toPGJsonBArray(v)
+				} else {
+					dest[i] = v
+				}
 			case sppb.TypeCode_BYTES:
 				var v [][]byte
 				if err := col.Decode(&v); err != nil {
@@ -258,16 +462,187 @@ func (r *rows) Next(dest []driver.Value) error {
 				if err := col.Decode(&v); err != nil {
 					return err
 				}
-				dest[i] = v
+				if r.dateLocation != nil {
+					dest[i] = toTimeArray(v, r.dateLocation)
+				} else {
+					dest[i] = v
+				}
 			case sppb.TypeCode_TIMESTAMP:
 				var v []spanner.NullTime
 				if err := col.Decode(&v); err != nil {
 					return err
 				}
+				for j := range v {
+					if v[j].Valid {
+						v[j].Time = normalizeTime(v[j].Time)
+					}
+				}
 				dest[i] = v
 			}
 		}
 		// TODO: Implement struct
+		if col.Type.Code == sppb.TypeCode_ARRAY && r.decodeToNativeArrays {
+			v, err := toNativeArray(dest[i])
+			if err != nil {
+				return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "column %s: %v", r.cols[i], err))
+			}
+			dest[i] = v
+		}
+		if dest[i] == nil && r.nullAsZeroValue {
+			if col.Type.Code == sppb.TypeCode_DATE && r.dateLocation != nil {
+				dest[i] = time.Time{}
+			} else if col.Type.Code == sppb.TypeCode_NUMERIC && r.numericAsString {
+				dest[i] = ""
+			} else if col.Type.Code == sppb.TypeCode_NUMERIC && r.numericAsFloat64 {
+				dest[i] = float64(0)
+			} else {
+				dest[i] = zeroValue(col.Type)
+			}
+		}
 	}
 	return nil
 }
+
+// zeroValue returns the zero value of the Go type that is returned for
+// non-NULL values of the given scalar type.
+func zeroValue(t *sppb.Type) driver.Value {
+	switch t.Code {
+	case sppb.TypeCode_INT64:
+		return int64(0)
+	case sppb.TypeCode_FLOAT32:
+		return float32(0)
+	case sppb.TypeCode_FLOAT64:
+		return float64(0)
+	case sppb.TypeCode_NUMERIC:
+		return big.Rat{}
+	case sppb.TypeCode_STRING:
+		return ""
+	case sppb.TypeCode_BYTES:
+		return []byte{}
+	case sppb.TypeCode_BOOL:
+		return false
+	case sppb.TypeCode_DATE:
+		return civil.Date{}
+	case sppb.TypeCode_TIMESTAMP:
+		return time.Time{}
+	}
+	return nil
+}
+
+// toPGJsonBArray converts an array of JSON values to an array of PostgreSQL
+// JSONB values. PostgreSQL JSONB arrays are decoded as NullJSON arrays, as the
+// Spanner client library does not support decoding them directly into a
+// []spanner.PGJsonB.
+func toPGJsonBArray(values []spanner.NullJSON) []spanner.PGJsonB {
+	if values == nil {
+		return nil
+	}
+	res := make([]spanner.PGJsonB, len(values))
+	for i, v := range values {
+		res[i] = spanner.PGJsonB{Value: v.Value, Valid: v.Valid}
+	}
+	return res
+}
+
+// toNumericStringArray converts an encoded ARRAY<NUMERIC> value to an array
+// of strings with the exact encoded values of the elements.
+func toNumericStringArray(value *structpb.Value) []spanner.NullString {
+	list := value.GetListValue()
+	if list == nil {
+		return nil
+	}
+	res := make([]spanner.NullString, len(list.Values))
+	for i, v := range list.Values {
+		if _, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			res[i] = spanner.NullString{StringVal: v.GetStringValue(), Valid: true}
+		}
+	}
+	return res
+}
+
+// numericToFloat64 converts an encoded NUMERIC value to the nearest float64
+// value.
+func numericToFloat64(value *structpb.Value) (spanner.NullFloat64, error) {
+	if _, ok := value.GetKind().(*structpb.Value_NullValue); ok {
+		return spanner.NullFloat64{}, nil
+	}
+	f, err := strconv.ParseFloat(value.GetStringValue(), 64)
+	if err != nil {
+		return spanner.NullFloat64{}, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid NUMERIC value %q: %v", value.GetStringValue(), err))
+	}
+	return spanner.NullFloat64{Float64: f, Valid: true}, nil
+}
+
+// toNumericFloat64Array converts an encoded ARRAY<NUMERIC> value to an array
+// of float64 values.
+func toNumericFloat64Array(value *structpb.Value) ([]spanner.NullFloat64, error) {
+	list := value.GetListValue()
+	if list == nil {
+		return nil, nil
+	}
+	res := make([]spanner.NullFloat64, len(list.Values))
+	for i, v := range list.Values {
+		f, err := numericToFloat64(v)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = f
+	}
+	return res, nil
+}
+
+// toTimeArray converts an array of DATE values to an array of time.Time
+// values at midnight in the given location.
+func toTimeArray(values []spanner.NullDate, loc *time.Location) []spanner.NullTime {
+	if values == nil {
+		return nil
+	}
+	res := make([]spanner.NullTime, len(values))
+	for i, v := range values {
+		if v.Valid {
+			res[i] = spanner.NullTime{Time: v.Date.In(loc), Valid: true}
+		}
+	}
+	return res
+}
+
+// toNativeArray converts an array of nullable Spanner values to a slice of
+// the corresponding native Go type. An error is returned if the array
+// contains a NULL element. Arrays that do not have a native Go type are
+// returned unchanged.
+func toNativeArray(value driver.Value) (driver.Value, error) {
+	switch v := value.(type) {
+	case []spanner.NullInt64:
+		return nativeArray(v, func(e spanner.NullInt64) (int64, bool) { return e.Int64, e.Valid })
+	case []spanner.NullFloat32:
+		return nativeArray(v, func(e spanner.NullFloat32) (float32, bool) { return e.Float32, e.Valid })
+	case []spanner.NullFloat64:
+		return nativeArray(v, func(e spanner.NullFloat64) (float64, bool) { return e.Float64, e.Valid })
+	case []spanner.NullNumeric:
+		return nativeArray(v, func(e spanner.NullNumeric) (big.Rat, bool) { return e.Numeric, e.Valid })
+	case []spanner.NullString:
+		return nativeArray(v, func(e spanner.NullString) (string, bool) { return e.StringVal, e.Valid })
+	case []spanner.NullBool:
+		return nativeArray(v, func(e spanner.NullBool) (bool, bool) { return e.Bool, e.Valid })
+	case []spanner.NullDate:
+		return nativeArray(v, func(e spanner.NullDate) (civil.Date, bool) { return e.Date, e.Valid })
+	case []spanner.NullTime:
+		return nativeArray(v, func(e spanner.NullTime) (time.Time, bool) { return e.Time, e.Valid })
+	}
+	return value, nil
+}
+
+func nativeArray[N any, T any](values []N, get func(N) (T, bool)) (driver.Value, error) {
+	if values == nil {
+		return []T(nil), nil
+	}
+	res := make([]T, len(values))
+	for i, v := range values {
+		e, valid := get(v)
+		if !valid {
+			return nil, fmt.Errorf("array element %d is NULL and cannot be decoded to %T", i, res)
+		}
+		res[i] = e
+	}
+	return res, nil
+}
@@ -15,13 +15,22 @@
 package spannerdriver
 
 import (
+	"context"
+	"database/sql"
 	"database/sql/driver"
 	"fmt"
 	"io"
+	"math/big"
+	"reflect"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/civil"
 	"cloud.google.com/go/spanner"
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type testIterator struct {
@@ -109,3 +118,437 @@ func TestRows_Next(t *testing.T) {
 		}
 	}
 }
+
+func TestNullAsZeroValue(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM AllTypes"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateResultSetWithAllTypes(true),
+	})
+	var b bool
+	var s string
+	var bytes []byte
+	var i int64
+	var f32 float32
+	var f64 float64
+	var n big.Rat
+	var d civil.Date
+	var ts time.Time
+	var j spanner.NullJSON
+	dest := []interface{}{&b, &s, &bytes, &i, &f32, &f64, &n, &d, &ts, &j}
+
+	scan := func(options ExecOptions) error {
+		rows, err := db.QueryContext(ctx, query, options)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			return fmt.Errorf("no rows: %v", rows.Err())
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		// Scan the remaining columns into interface{} values.
+		all := append([]interface{}{}, dest...)
+		values := make([]interface{}, len(cols)-len(dest))
+		for i := range values {
+			all = append(all, &values[i])
+		}
+		return rows.Scan(all...)
+	}
+
+	// The default returns an error for NULL values in non-nullable types.
+	if err := scan(ExecOptions{}); err == nil {
+		t.Fatal("missing error for NULL value")
+	}
+	if err := scan(ExecOptions{NullAsZeroValue: true}); err != nil {
+		t.Fatal(err)
+	}
+	if b || s != "" || len(bytes) != 0 || i != 0 || f32 != 0 || f64 != 0 || n.Sign() != 0 || !d.IsZero() || !ts.IsZero() || j.Valid {
+		t.Fatalf("unexpected non-zero value: %v %v %v %v %v %v %v %v %v %v", b, s, bytes, i, f32, f64, n.String(), d, ts, j)
+	}
+}
+
+func TestRows_DateLocationAndNativeArrays(t *testing.T) {
+	t.Parallel()
+
+	cols := []string{"D", "DA", "IA", "NA"}
+	newIterator := func() *testIterator {
+		return &testIterator{
+			metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "D", Type: &sppb.Type{Code: sppb.TypeCode_DATE}},
+						{Name: "DA", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_DATE}}},
+						{Name: "IA", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_INT64}}},
+						{Name: "NA", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: sppb.TypeCode_INT64}}},
+					},
+				},
+			},
+			rows: []*spanner.Row{
+				newRow(t, cols, []interface{}{
+					civil.Date{Year: 2024, Month: 2, Day: 29},
+					[]civil.Date{{Year: 2024, Month: 2, Day: 29}, {Year: 2024, Month: 3, Day: 1}},
+					[]int64{1, 2},
+					[]spanner.NullInt64(nil),
+				}),
+				newRow(t, cols, []interface{}{
+					spanner.NullDate{},
+					[]spanner.NullDate{{Date: civil.Date{Year: 2024, Month: 2, Day: 29}, Valid: true}, {}},
+					[]int64{},
+					[]spanner.NullInt64(nil),
+				}),
+			},
+		}
+	}
+	loc, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without any options, arrays are returned as nullable Spanner types.
+	dest := make([]driver.Value, len(cols))
+	r := &rows{it: newIterator()}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dest[1].([]spanner.NullDate); !ok {
+		t.Fatalf("unexpected type for DA: %T", dest[1])
+	}
+
+	// DateLocation returns DATE values as time.Time at midnight.
+	r = &rows{it: newIterator(), dateLocation: loc}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 2, 29, 0, 0, 0, 0, loc)
+	if g, ok := dest[0].(time.Time); !ok || !g.Equal(want) || g.Location() != loc {
+		t.Fatalf("D mismatch\n Got: %v\nWant: %v", dest[0], want)
+	}
+	if g, ok := dest[1].([]spanner.NullTime); !ok || len(g) != 2 || !g[0].Time.Equal(want) {
+		t.Fatalf("DA mismatch\n Got: %v\nWant: %v", dest[1], want)
+	}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if dest[0] != nil {
+		t.Fatalf("D mismatch\n Got: %v\nWant: nil", dest[0])
+	}
+	if g, ok := dest[1].([]spanner.NullTime); !ok || len(g) != 2 || g[1].Valid {
+		t.Fatalf("DA mismatch\n Got: %v", dest[1])
+	}
+
+	// DecodeToNativeArrays returns native slices, and fails for NULL elements.
+	r = &rows{it: newIterator(), dateLocation: loc, decodeToNativeArrays: true}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := dest[1].([]time.Time); !ok || len(g) != 2 || !g[0].Equal(want) || !g[1].Equal(want.AddDate(0, 0, 1)) {
+		t.Fatalf("DA mismatch\n Got: %v", dest[1])
+	}
+	if g, ok := dest[2].([]int64); !ok || len(g) != 2 || g[0] != 1 || g[1] != 2 {
+		t.Fatalf("IA mismatch\n Got: %v", dest[2])
+	}
+	if g, ok := dest[3].([]int64); !ok || g != nil {
+		t.Fatalf("NA mismatch\n Got: %#v", dest[3])
+	}
+	if err := r.Next(dest); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestRows_NumericAsString(t *testing.T) {
+	t.Parallel()
+
+	numeric := &sppb.Type{Code: sppb.TypeCode_NUMERIC}
+	numericArray := &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: numeric}
+	cols := []string{"N", "NA"}
+	newIterator := func() *testIterator {
+		return &testIterator{
+			metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "N", Type: numeric},
+						{Name: "NA", Type: numericArray},
+					},
+				},
+			},
+			rows: []*spanner.Row{
+				newRow(t, cols, []interface{}{
+					spanner.GenericColumnValue{Type: numeric, Value: structpb.NewStringValue("12345678901234567890123456789.123456789")},
+					spanner.GenericColumnValue{Type: numericArray, Value: structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+						structpb.NewStringValue("0.000000001"),
+						structpb.NewNullValue(),
+					}})},
+				}),
+				newRow(t, cols, []interface{}{
+					spanner.GenericColumnValue{Type: numeric, Value: structpb.NewNullValue()},
+					spanner.GenericColumnValue{Type: numericArray, Value: structpb.NewNullValue()},
+				}),
+			},
+		}
+	}
+
+	dest := make([]driver.Value, len(cols))
+	r := &rows{it: newIterator(), numericAsString: true}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := dest[0], "12345678901234567890123456789.123456789"; g != w {
+		t.Fatalf("N mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := dest[1], []spanner.NullString{{StringVal: "0.000000001", Valid: true}, {}}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("NA mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if dest[0] != nil {
+		t.Fatalf("N mismatch\n Got: %v\nWant: nil", dest[0])
+	}
+	if g, ok := dest[1].([]spanner.NullString); !ok || g != nil {
+		t.Fatalf("NA mismatch\n Got: %#v", dest[1])
+	}
+
+	// DecodeToNativeArrays returns a []string, and fails for NULL elements.
+	r = &rows{it: newIterator(), numericAsString: true, decodeToNativeArrays: true, nullAsZeroValue: true}
+	if err := r.Next(dest); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := dest[0], ""; g != w {
+		t.Fatalf("N mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, ok := dest[1].([]string); !ok || g != nil {
+		t.Fatalf("NA mismatch\n Got: %#v", dest[1])
+	}
+}
+
+func TestRows_NumericAsFloat64(t *testing.T) {
+	t.Parallel()
+
+	numeric := &sppb.Type{Code: sppb.TypeCode_NUMERIC}
+	numericArray := &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: numeric}
+	cols := []string{"N", "NA"}
+	newIterator := func() *testIterator {
+		return &testIterator{
+			metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "N", Type: numeric},
+						{Name: "NA", Type: numericArray},
+					},
+				},
+			},
+			rows: []*spanner.Row{
+				newRow(t, cols, []interface{}{
+					spanner.GenericColumnValue{Type: numeric, Value: structpb.NewStringValue("12345678901234567890123456789.123456789")},
+					spanner.GenericColumnValue{Type: numericArray, Value: structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+						structpb.NewStringValue("3.14"),
+						structpb.NewNullValue(),
+					}})},
+				}),
+				newRow(t, cols, []interface{}{
+					spanner.GenericColumnValue{Type: numeric, Value: structpb.NewNullValue()},
+					spanner.GenericColumnValue{Type: numericArray, Value: structpb.NewNullValue()},
+				}),
+			},
+		}
+	}
+
+	dest := make([]driver.Value, len(cols))
+	r := &rows{it: newIterator(), numericAsFloat64: true}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	// The value is rounded to the nearest float64.
+	if g, w := dest[0], 1.2345678901234568e28; g != w {
+		t.Fatalf("N mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := dest[1], []spanner.NullFloat64{{Float64: 3.14, Valid: true}, {}}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("NA mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if dest[0] != nil {
+		t.Fatalf("N mismatch\n Got: %v\nWant: nil", dest[0])
+	}
+	if g, ok := dest[1].([]spanner.NullFloat64); !ok || g != nil {
+		t.Fatalf("NA mismatch\n Got: %#v", dest[1])
+	}
+
+	// DecodeToNativeArrays returns a []float64, and fails for NULL elements.
+	r = &rows{it: newIterator(), numericAsFloat64: true, decodeToNativeArrays: true, nullAsZeroValue: true}
+	if err := r.Next(dest); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := dest[0], float64(0); g != w {
+		t.Fatalf("N mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, ok := dest[1].([]float64); !ok || g != nil {
+		t.Fatalf("NA mismatch\n Got: %#v", dest[1])
+	}
+
+	// DecodeNumericAsString takes precedence.
+	r = &rows{it: newIterator(), numericAsString: true, numericAsFloat64: true}
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dest[0].(string); !ok {
+		t.Fatalf("N mismatch\n Got: %T\nWant: string", dest[0])
+	}
+}
+
+func TestScanRawBytes(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for _, nullValues := range []bool{false, true} {
+		query := fmt.Sprintf("SELECT ColString, ColBytes FROM AllTypes WHERE Null=%v", nullValues)
+		resultSet := testutil.CreateResultSetWithAllTypes(nullValues)
+		resultSet.Metadata.RowType.Fields = resultSet.Metadata.RowType.Fields[1:3]
+		resultSet.Rows[0].Values = resultSet.Rows[0].Values[1:3]
+		_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+			Type:      testutil.StatementResultResultSet,
+			ResultSet: resultSet,
+		})
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rows.Next() {
+			t.Fatalf("no rows: %v", rows.Err())
+		}
+		var s, b sql.RawBytes
+		if err := rows.Scan(&s, &b); err != nil {
+			t.Fatal(err)
+		}
+		if nullValues {
+			if s != nil || b != nil {
+				t.Fatalf("values mismatch\n Got: %v, %v\nWant: nil, nil", s, b)
+			}
+		} else {
+			if g, w := string(s), "test"; g != w {
+				t.Fatalf("string mismatch\n Got: %v\nWant: %v", g, w)
+			}
+			if g, w := string(b), "testbytes"; g != w {
+				t.Fatalf("bytes mismatch\n Got: %v\nWant: %v", g, w)
+			}
+		}
+		_ = rows.Close()
+	}
+}
+
+func TestScanNullAndEmptyArrays(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	elementTypes := []*sppb.Type{
+		{Code: sppb.TypeCode_BOOL},
+		{Code: sppb.TypeCode_STRING},
+		{Code: sppb.TypeCode_BYTES},
+		{Code: sppb.TypeCode_INT64},
+		{Code: sppb.TypeCode_FLOAT32},
+		{Code: sppb.TypeCode_FLOAT64},
+		{Code: sppb.TypeCode_NUMERIC},
+		{Code: sppb.TypeCode_DATE},
+		{Code: sppb.TypeCode_TIMESTAMP},
+		{Code: sppb.TypeCode_JSON},
+		{Code: sppb.TypeCode_JSON, TypeAnnotation: sppb.TypeAnnotationCode_PG_JSONB},
+	}
+	// Each element type is returned both as a NULL array and as an empty array.
+	resultSet := &sppb.ResultSet{
+		Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{}},
+		Rows:     []*structpb.ListValue{{}},
+	}
+	for _, elementType := range elementTypes {
+		arrayType := &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: elementType}
+		resultSet.Metadata.RowType.Fields = append(resultSet.Metadata.RowType.Fields,
+			&sppb.StructType_Field{Name: "Null" + elementType.Code.String(), Type: arrayType},
+			&sppb.StructType_Field{Name: "Empty" + elementType.Code.String(), Type: arrayType})
+		resultSet.Rows[0].Values = append(resultSet.Rows[0].Values,
+			structpb.NewNullValue(),
+			structpb.NewListValue(&structpb.ListValue{}))
+	}
+	query := "SELECT * FROM NullAndEmptyArrays"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: resultSet,
+	})
+
+	for _, options := range []ExecOptions{
+		{},
+		{DecodeToNativeArrays: true},
+		{NullAsZeroValue: true},
+		{DecodeNumericAsString: true},
+		{AllowNumericToFloat64: true},
+		{DateLocation: time.UTC},
+		{DecodeToNativeArrays: true, NullAsZeroValue: true, DateLocation: time.UTC},
+	} {
+		values := make([]interface{}, len(resultSet.Metadata.RowType.Fields))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := db.QueryRowContext(ctx, query, options).Scan(dest...); err != nil {
+			t.Fatalf("%+v: %v", options, err)
+		}
+		for i, elementType := range elementTypes {
+			null, empty := reflect.ValueOf(values[2*i]), reflect.ValueOf(values[2*i+1])
+			if null.Kind() != reflect.Slice || !null.IsNil() {
+				t.Errorf("%+v: %v: NULL array mismatch\n Got: %#v\nWant: nil slice", options, elementType, values[2*i])
+			}
+			if empty.Kind() != reflect.Slice || empty.IsNil() || empty.Len() != 0 {
+				t.Errorf("%+v: %v: empty array mismatch\n Got: %#v\nWant: empty slice", options, elementType, values[2*i+1])
+			}
+			if null.Type() != empty.Type() {
+				t.Errorf("%+v: %v: type mismatch\n Got: %v and %v", options, elementType, null.Type(), empty.Type())
+			}
+		}
+	}
+
+	// Native slices can also be scanned directly.
+	stringsQuery := "SELECT NullSTRING, EmptySTRING FROM NullAndEmptyArrays"
+	stringsResultSet := &sppb.ResultSet{
+		Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{Fields: resultSet.Metadata.RowType.Fields[2:4]}},
+		Rows:     []*structpb.ListValue{{Values: resultSet.Rows[0].Values[2:4]}},
+	}
+	_ = server.TestSpanner.PutStatementResult(stringsQuery, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: stringsResultSet,
+	})
+	var nullStrings, emptyStrings []string
+	if err := db.QueryRowContext(ctx, stringsQuery, ExecOptions{DecodeToNativeArrays: true}).Scan(&nullStrings, &emptyStrings); err != nil {
+		t.Fatal(err)
+	}
+	if nullStrings != nil || emptyStrings == nil || len(emptyStrings) != 0 {
+		t.Fatalf("strings mismatch\n Got: %#v, %#v\nWant: nil, []string{}", nullStrings, emptyStrings)
+	}
+	var nullNullStrings, emptyNullStrings []spanner.NullString
+	if err := db.QueryRowContext(ctx, stringsQuery).Scan(&nullNullStrings, &emptyNullStrings); err != nil {
+		t.Fatal(err)
+	}
+	if nullNullStrings != nil || emptyNullStrings == nil || len(emptyNullStrings) != 0 {
+		t.Fatalf("strings mismatch\n Got: %#v, %#v\nWant: nil, []spanner.NullString{}", nullNullStrings, emptyNullStrings)
+	}
+}
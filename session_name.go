@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sessionRecorder records the name of the Spanner session that was used by
+// the RPCs of a single statement. The session is recorded by the gRPC
+// interceptors of the connector, which find the recorder in the context of
+// the RPC.
+type sessionRecorder struct {
+	mu      sync.Mutex
+	session string
+}
+
+type sessionRecorderKey struct{}
+
+// sessionRequest is implemented by all Spanner requests that are executed on
+// a session.
+type sessionRequest interface {
+	GetSession() string
+}
+
+func (r *sessionRecorder) record(req interface{}) {
+	sr, ok := req.(sessionRequest)
+	if !ok || sr.GetSession() == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.session = sr.GetSession()
+}
+
+func (r *sessionRecorder) name() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session
+}
+
+// recordSessions returns a context that records the session that is used by
+// the statement of the context. The session can be retrieved with
+// LastSessionName until the next statement is executed on the connection.
+func (c *conn) recordSessions(ctx context.Context) context.Context {
+	r := &sessionRecorder{}
+	c.lastSession = r
+	return context.WithValue(ctx, sessionRecorderKey{}, r)
+}
+
+func (c *conn) LastSessionName() (string, error) {
+	if c.lastSession == nil {
+		return "", spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "this connection has not executed a statement"))
+	}
+	name := c.lastSession.name()
+	if name == "" {
+		return "", spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "the last statement on this connection did not use a session"))
+	}
+	return name, nil
+}
+
+// unarySessionInterceptor records the session of unary RPCs that are executed
+// for a statement.
+func unarySessionInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if r, ok := ctx.Value(sessionRecorderKey{}).(*sessionRecorder); ok {
+		r.record(req)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// streamSessionInterceptor records the session of streaming RPCs that are
+// executed for a statement.
+func streamSessionInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	r, ok := ctx.Value(sessionRecorderKey{}).(*sessionRecorder)
+	if !ok {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionClientStream{ClientStream: stream, recorder: r}, nil
+}
+
+// sessionClientStream records the session of the requests that are sent on a
+// stream.
+type sessionClientStream struct {
+	grpc.ClientStream
+	recorder *sessionRecorder
+}
+
+func (s *sessionClientStream) SendMsg(msg interface{}) error {
+	s.recorder.record(msg)
+	return s.ClientStream.SendMsg(msg)
+}
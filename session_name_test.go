@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestLastSessionName(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	lastSessionName := func() (name string, err error) {
+		err = conn.Raw(func(driverConn interface{}) error {
+			name, err = driverConn.(SpannerConn).LastSessionName()
+			return err
+		})
+		return name, err
+	}
+
+	if _, err := lastSessionName(); spanner.ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.FailedPrecondition)
+	}
+
+	for _, test := range []struct {
+		name string
+		exec func() error
+	}{
+		{"query", func() error {
+			var v int64
+			return conn.QueryRowContext(ctx, testutil.SelectFooFromBar).Scan(&v)
+		}},
+		{"dml", func() error {
+			_, err := conn.ExecContext(ctx, testutil.UpdateBarSetFoo)
+			return err
+		}},
+	} {
+		drainRequestsFromServer(server.TestSpanner)
+		if err := test.exec(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		name, err := lastSessionName()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !strings.HasPrefix(name, "projects/p/instances/i/databases/d/sessions/") {
+			t.Fatalf("%s: session name mismatch\n Got: %v", test.name, name)
+		}
+		requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+		if g, w := len(requests), 1; g != w {
+			t.Fatalf("%s: requests count mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+		if g, w := name, requests[0].(*sppb.ExecuteSqlRequest).Session; g != w {
+			t.Fatalf("%s: session name mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sessionCounter counts the sessions that have been created by the session
+// pool of a Spanner client, so that a connection can wait until the session
+// pool contains the minimum number of sessions.
+type sessionCounter struct {
+	mu      sync.Mutex
+	created uint64
+	// changed is closed and replaced each time that sessions are created.
+	changed chan struct{}
+}
+
+func newSessionCounter() *sessionCounter {
+	return &sessionCounter{changed: make(chan struct{})}
+}
+
+func (s *sessionCounter) add(n uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created += n
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *sessionCounter) count() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.created
+}
+
+// wait waits until at least n sessions have been created, or until the given
+// context is done.
+func (s *sessionCounter) wait(ctx context.Context, n uint64) error {
+	for {
+		s.mu.Lock()
+		created, changed := s.created, s.changed
+		s.mu.Unlock()
+		if created >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// unaryInterceptor counts the sessions that are created by the session pool
+// of the Spanner client.
+func (s *sessionCounter) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		return err
+	}
+	if n := createdSessions(req, reply); n > 0 {
+		s.add(uint64(n))
+	}
+	return nil
+}
+
+// waitForMinSessions waits until the session pool of the connector has
+// created the minimum number of sessions, or until WaitForMinSessions has
+// passed. It only waits if WaitForMinSessions has been set and no other
+// connection has waited successfully.
+func (c *connector) waitForMinSessions(ctx context.Context, databaseName string) error {
+	timeout := c.config.WaitForMinSessions
+	if timeout <= 0 || atomic.LoadInt32(&c.warmedUp) == 1 {
+		return nil
+	}
+	minSessions := c.spannerClientConfig.MinOpened
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := c.sessions.wait(waitCtx, minSessions); err != nil {
+		if ctx.Err() == nil && waitCtx.Err() == context.DeadlineExceeded {
+			return spanner.ToSpannerError(status.Errorf(codes.DeadlineExceeded, "the session pool for %s did not create %d sessions within %v, only %d sessions were created", databaseName, minSessions, timeout, c.sessions.count()))
+		}
+		return spanner.ToSpannerError(err)
+	}
+	atomic.StoreInt32(&c.warmedUp, 1)
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+func TestPrewarmSessions(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address)
+	connector, err := CreateConnector(dsn, ConnectorConfig{
+		MinSessions:        25,
+		PrewarmSessions:    true,
+		WaitForMinSessions: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	// The sessions are created before the first connection is opened.
+	deadline := time.Now().Add(10 * time.Second)
+	for server.TestSpanner.TotalSessionsCreated() < 25 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions were not created\n Got: %v\nWant: 25", server.TestSpanner.TotalSessionsCreated())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForMinSessions(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;minSessions=25;waitForMinSessions=10s", server.Address)
+	connector, err := CreateConnector(dsn, ConnectorConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	// Opening the first connection waits until all sessions have been created.
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := server.TestSpanner.TotalSessionsCreated(), uint(25); g < w {
+		t.Fatalf("sessions created mismatch\n Got: %v\nWant: >= %v", g, w)
+	}
+}
+
+func TestWaitForMinSessions_Timeout(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	// The server only creates 10 sessions, so the pool never reaches its minimum.
+	server.TestSpanner.SetMaxSessionsReturnedByServerInTotal(10)
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true;minSessions=25", server.Address)
+	connector, err := CreateConnector(dsn, ConnectorConfig{WaitForMinSessions: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	if g, w := spanner.ErrCode(db.PingContext(context.Background())), codes.DeadlineExceeded; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if _, err := CreateConnector(dsn, ConnectorConfig{WaitForMinSessions: -time.Second}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestPrewarmSessions_CloseWithoutConnection(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	dsn := fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address)
+	c, err := CreateConnector(dsn, ConnectorConfig{
+		MinSessions:       25,
+		PrewarmSessions:   true,
+		KeepAliveInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(c)
+	deadline := time.Now().Add(10 * time.Second)
+	for server.TestSpanner.TotalSessionsCreated() < 25 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions were not created\n Got: %v\nWant: 25", server.TestSpanner.TotalSessionsCreated())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Closing the database closes the prewarmed client and releases the
+	// sessions in its pool, even though no connection was opened.
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	pc := c.(*connector)
+	if pc.keepAliveStop != nil {
+		t.Fatal("keep-alive queries were not stopped")
+	}
+	if !pc.clientsClosed {
+		t.Fatal("clients were not closed")
+	}
+	it := pc.client.Single().Query(context.Background(), spanner.NewStatement("SELECT 1"))
+	defer it.Stop()
+	if _, err := it.Next(); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch for closed session pool\n Got: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+}
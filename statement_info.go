@@ -0,0 +1,249 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// StatementInfo contains information about a statement that has been executed
+// on a connection. It is passed to the OnStatementComplete callback of a
+// ConnectorConfig.
+type StatementInfo struct {
+	// SQL is the SQL string of the statement as it was passed in to the
+	// driver.
+	SQL string
+	// ExecutedSQL is the SQL string that was sent to Spanner after the driver
+	// rewrote the statement, for example after replacing positional
+	// parameters with named parameters. This is empty if the statement was
+	// not sent to Spanner, for example if the statement could not be parsed.
+	ExecutedSQL string
+	// Duration is the time between the start of the statement and the moment
+	// that it finished. For queries, the statement finishes when all rows have
+	// been consumed, or when the rows are closed.
+	Duration time.Duration
+	// Err is the error that was returned by the statement, if any.
+	Err error
+
+	// RPCAttempts is the number of RPC attempts that were sent to Spanner for
+	// the statement, including any attempts that were retried by the Spanner
+	// client. This is zero if no RPC was needed, for example for statements
+	// that are buffered in a batch.
+	RPCAttempts int
+	// ServerLatency is the total latency that was reported by Spanner in the
+	// server-timing response header of the RPCs for the statement. This is
+	// zero if Spanner did not return any server timing information.
+	ServerLatency time.Duration
+	// InRetriedTransaction indicates whether the statement was executed in a
+	// read/write transaction that had been retried internally by the driver
+	// because it was aborted by Spanner.
+	InRetriedTransaction bool
+	// RequestTag is the request tag that was sent to Spanner with the
+	// statement, if any.
+	RequestTag string
+	// TransactionTag is the transaction tag of the read/write transaction
+	// that the statement was executed in, if any. Spanner adds the
+	// transaction tag to the statement in addition to the request tag.
+	TransactionTag string
+}
+
+// statementMetrics collects the RPC metrics of a single statement. The
+// metrics are collected by the gRPC interceptors of the connector, which find
+// the metrics in the context of the RPC.
+type statementMetrics struct {
+	mu            sync.Mutex
+	executedSQL   string
+	requestTag    string
+	attempts      int
+	serverLatency time.Duration
+}
+
+type statementMetricsKey struct{}
+
+// recordExecutedSQL records the SQL string that is sent to Spanner for the
+// statement of the given context. This is a no-op if the context does not
+// collect statement metrics.
+func recordExecutedSQL(ctx context.Context, sql string) {
+	if m, ok := statementMetricsFromContext(ctx); ok {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.executedSQL = sql
+	}
+}
+
+// recordRequestTag records the request tag that is sent to Spanner for the
+// statement of the given context. This is a no-op if the context does not
+// collect statement metrics.
+func recordRequestTag(ctx context.Context, tag string) {
+	if m, ok := statementMetricsFromContext(ctx); ok {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.requestTag = tag
+	}
+}
+
+func (m *statementMetrics) addAttempt() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts++
+}
+
+func (m *statementMetrics) addServerTiming(md metadata.MD) {
+	latency, ok := parseServerTiming(md)
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.serverLatency += latency
+}
+
+// parseServerTiming returns the total duration in the server-timing header
+// of the given metadata. The header has the format `name; dur=123`, where
+// the duration is in milliseconds. Multiple entries are separated by commas.
+func parseServerTiming(md metadata.MD) (time.Duration, bool) {
+	var total time.Duration
+	found := false
+	for _, header := range md.Get("server-timing") {
+		for _, entry := range strings.Split(header, ",") {
+			for _, part := range strings.Split(entry, ";") {
+				part = strings.TrimSpace(part)
+				if !strings.HasPrefix(part, "dur=") {
+					continue
+				}
+				ms, err := strconv.ParseFloat(strings.TrimPrefix(part, "dur="), 64)
+				if err != nil {
+					continue
+				}
+				total += time.Duration(ms * float64(time.Millisecond))
+				found = true
+			}
+		}
+	}
+	return total, found
+}
+
+func statementMetricsFromContext(ctx context.Context) (*statementMetrics, bool) {
+	m, ok := ctx.Value(statementMetricsKey{}).(*statementMetrics)
+	return m, ok
+}
+
+// unaryMetricsInterceptor records the RPC attempt and the server timing of
+// unary RPCs that are executed for a statement.
+func unaryMetricsInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	m, ok := statementMetricsFromContext(ctx)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	m.addAttempt()
+	var md metadata.MD
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&md))...)
+	m.addServerTiming(md)
+	return err
+}
+
+// streamMetricsInterceptor records the RPC attempt and the server timing of
+// streaming RPCs that are executed for a statement.
+func streamMetricsInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	m, ok := statementMetricsFromContext(ctx)
+	if !ok {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	m.addAttempt()
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &metricsClientStream{ClientStream: stream, metrics: m}, nil
+}
+
+// metricsClientStream records the server timing of a stream when the stream
+// has finished.
+type metricsClientStream struct {
+	grpc.ClientStream
+	metrics *statementMetrics
+	once    sync.Once
+}
+
+func (s *metricsClientStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err != nil {
+		s.once.Do(func() {
+			if md, err := s.ClientStream.Header(); err == nil {
+				s.metrics.addServerTiming(md)
+			}
+		})
+	}
+	return err
+}
+
+// startStatement starts a span and, if a statement timeout has been set, a
+// timeout for a statement. The returned function ends both, records the
+// latency of the statement and, if OnStatementComplete has been set, calls it
+// with the RPC metrics that were collected in the returned context.
+func (c *conn) startStatement(ctx context.Context, spanName, query string) (context.Context, func(err error)) {
+	ctx = c.recordSessions(ctx)
+	cancel := func() {}
+	if c.statementTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.statementTimeout)
+	}
+	ctx, span := c.startStatementSpan(ctx, spanName, query)
+	method := strings.TrimPrefix(spanName, "go-sql-spanner.")
+	start := time.Now()
+	if c.connector == nil || c.connector.config.OnStatementComplete == nil {
+		var once sync.Once
+		return ctx, func(err error) {
+			once.Do(func() {
+				c.metrics().recordStatementLatency(ctx, method, start, err)
+				endSpan(span, err)
+				cancel()
+			})
+		}
+	}
+	m := &statementMetrics{}
+	ctx = context.WithValue(ctx, statementMetricsKey{}, m)
+	tx, _ := c.tx.(*readWriteTransaction)
+	var once sync.Once
+	return ctx, func(err error) {
+		once.Do(func() {
+			info := StatementInfo{
+				SQL:      query,
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			if tx != nil {
+				info.InRetriedTransaction = tx.retried
+				info.TransactionTag = tx.options.TransactionTag
+			}
+			m.mu.Lock()
+			info.ExecutedSQL = m.executedSQL
+			info.RequestTag = m.requestTag
+			info.RPCAttempts = m.attempts
+			info.ServerLatency = m.serverLatency
+			m.mu.Unlock()
+			c.metrics().recordStatementLatency(ctx, method, start, err)
+			endSpan(span, err)
+			cancel()
+			c.connector.config.OnStatementComplete(info)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseServerTiming(t *testing.T) {
+	for _, test := range []struct {
+		header    []string
+		want      time.Duration
+		wantFound bool
+	}{
+		{},
+		{header: []string{"gfet4t7; dur=123"}, want: 123 * time.Millisecond, wantFound: true},
+		{header: []string{"gfet4t7; dur=1.5"}, want: 1500 * time.Microsecond, wantFound: true},
+		{header: []string{"gfet4t7; dur=10, other; dur=5"}, want: 15 * time.Millisecond, wantFound: true},
+		{header: []string{"gfet4t7"}},
+		{header: []string{"gfet4t7; dur=abc"}},
+	} {
+		md := metadata.MD{}
+		if test.header != nil {
+			md.Set("server-timing", test.header...)
+		}
+		got, found := parseServerTiming(md)
+		if g, w := got, test.want; g != w {
+			t.Errorf("%v: duration mismatch\n Got: %v\nWant: %v", test.header, g, w)
+		}
+		if g, w := found, test.wantFound; g != w {
+			t.Errorf("%v: found mismatch\n Got: %v\nWant: %v", test.header, g, w)
+		}
+	}
+}
+
+func TestOnStatementComplete(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	var mu sync.Mutex
+	var infos []StatementInfo
+	connector, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+		ConnectorConfig{
+			OnStatementComplete: func(info StatementInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				infos = append(infos, info)
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+	lastInfo := func() StatementInfo {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(infos) == 0 {
+			t.Fatal("no statement info received")
+		}
+		info := infos[len(infos)-1]
+		infos = nil
+		return info
+	}
+
+	// Queries are reported when the rows are closed.
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	_ = rows.Close()
+	info := lastInfo()
+	if g, w := info.SQL, testutil.SelectFooFromBar; g != w {
+		t.Fatalf("sql mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := info.RPCAttempts, 1; g != w {
+		t.Fatalf("rpc attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if info.Err != nil || info.InRetriedTransaction {
+		t.Fatalf("unexpected statement info: %+v", info)
+	}
+
+	// The executed SQL contains the statement after it has been rewritten by
+	// the driver.
+	_ = server.TestSpanner.PutStatementResult("SELECT * FROM Foo WHERE Id=@p1", &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateSelect1ResultSet(),
+	})
+	rows, err = db.QueryContext(ctx, "SELECT * FROM Foo WHERE Id=?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	_ = rows.Close()
+	info = lastInfo()
+	if g, w := info.SQL, "SELECT * FROM Foo WHERE Id=?"; g != w {
+		t.Fatalf("sql mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := info.ExecutedSQL, "SELECT * FROM Foo WHERE Id=@p1"; g != w {
+		t.Fatalf("executed sql mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// A DML statement in autocommit mode uses one RPC for the statement and
+	// one RPC for the commit.
+	if _, err := db.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := lastInfo().RPCAttempts, 2; g != w {
+		t.Fatalf("rpc attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Retries by the Spanner client are included in the number of attempts.
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteStreamingSql, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Unavailable, "unavailable")},
+	})
+	rows, err = db.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	_ = rows.Close()
+	if g, w := lastInfo().RPCAttempts, 2; g != w {
+		t.Fatalf("rpc attempts mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Statements in a transaction that was retried internally are marked.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestSpanner.PutExecutionTime(testutil.MethodExecuteSql, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted")},
+	})
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	info = lastInfo()
+	if !info.InRetriedTransaction {
+		t.Fatalf("statement not marked as executed in a retried transaction")
+	}
+	if info.RPCAttempts < 2 {
+		t.Fatalf("rpc attempts mismatch\n Got: %v\nWant: >= 2", info.RPCAttempts)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both the request tag and the transaction tag are included for a
+	// statement in a tagged transaction.
+	if err := RunTransaction(ctx, db, ReadWriteTransactionOptions{TransactionTag: "tx-tag"}, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo, ExecOptions{RequestTag: "update-tag"})
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	info = infos[0]
+	infos = nil
+	mu.Unlock()
+	if g, w := info.RequestTag, "update-tag"; g != w {
+		t.Fatalf("request tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := info.TransactionTag, "tx-tag"; g != w {
+		t.Fatalf("transaction tag mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// Errors are included in the statement info.
+	query := "SELECT * FROM NonExisting"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Err: status.Error(codes.NotFound, "Table not found"),
+	})
+	rows, err = db.QueryContext(ctx, query)
+	if err == nil {
+		for rows.Next() {
+		}
+		_ = rows.Close()
+	}
+	if g, w := status.Code(lastInfo().Err), codes.NotFound; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
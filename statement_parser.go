@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -61,6 +62,147 @@ func parseParameters(sql string) (string, []string, error) {
 	return findParams('?', sql)
 }
 
+// parseParametersForDialect returns the parameters in the given sql string
+// using the parameter syntax of the given dialect.
+func parseParametersForDialect(dialect Dialect, sql string) (string, []string, error) {
+	if dialect == PostgreSQL {
+		return parsePostgreSQLParameters(sql)
+	}
+	return parseParameters(sql)
+}
+
+// parsePostgreSQLParameters returns the parameters in the given PostgreSQL
+// sql string. PostgreSQL statements use positional parameters in the form $1,
+// $2, ..., which are sent to Spanner as the parameters p1, p2, .... The
+// returned names contain one name for each parameter up to the highest
+// parameter number in the statement, so the n-th positional argument is
+// always used as the value of $n, regardless of the order in which the
+// parameters appear in the statement. The sql string is returned unmodified,
+// except that surrounding spaces and a trailing semicolon are removed.
+//
+// String literals, quoted identifiers, dollar-quoted strings and comments
+// are skipped, so for example '$1' is not recognized as a parameter.
+func parsePostgreSQLParameters(sql string) (string, []string, error) {
+	runes := []rune(sql)
+	maxParam := 0
+	index := 0
+	for index < len(runes) {
+		c := runes[index]
+		switch {
+		case c == '\'' || c == '"':
+			// Literals that are prefixed with an E support backslash escapes.
+			escapes := c == '\'' && index > 0 && (runes[index-1] == 'e' || runes[index-1] == 'E') &&
+				(index == 1 || !(unicode.IsLetter(runes[index-2]) || unicode.IsDigit(runes[index-2]) || runes[index-2] == '_'))
+			end, err := skipPostgreSQLQuoted(runes, index, escapes)
+			if err != nil {
+				return sql, nil, err
+			}
+			index = end
+		case c == '-' && len(runes) > index+1 && runes[index+1] == '-':
+			for index < len(runes) && runes[index] != '\n' {
+				index++
+			}
+		case c == '/' && len(runes) > index+1 && runes[index+1] == '*':
+			// PostgreSQL supports nested multi-line comments.
+			level := 0
+			for ; index < len(runes); index++ {
+				if runes[index] == '/' && len(runes) > index+1 && runes[index+1] == '*' {
+					level++
+					index++
+				} else if runes[index] == '*' && len(runes) > index+1 && runes[index+1] == '/' {
+					level--
+					index++
+					if level == 0 {
+						break
+					}
+				}
+			}
+			if level > 0 {
+				return sql, nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "statement contains an unclosed comment: %s", sql))
+			}
+		case c == '$' && len(runes) > index+1 && unicode.IsDigit(runes[index+1]):
+			start := index + 1
+			index = start
+			for index < len(runes) && unicode.IsDigit(runes[index]) {
+				index++
+			}
+			n, err := strconv.Atoi(string(runes[start:index]))
+			if err != nil || n == 0 {
+				return sql, nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid query parameter $%s: %s", string(runes[start:index]), sql))
+			}
+			if n > maxParam {
+				maxParam = n
+			}
+			continue
+		case c == '$':
+			if end, ok := postgreSQLDollarQuoteTag(runes, index); ok {
+				tag := string(runes[index:end])
+				tagLen := end - index
+				closing := -1
+				for i := end; i+tagLen <= len(runes); i++ {
+					if string(runes[i:i+tagLen]) == tag {
+						closing = i
+						break
+					}
+				}
+				if closing == -1 {
+					return sql, nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "statement contains an unclosed literal: %s", sql))
+				}
+				index = closing + tagLen - 1
+			}
+		case unicode.IsLetter(c) || c == '_':
+			// Skip identifiers, as a dollar sign inside an identifier does not
+			// start a parameter or a dollar-quoted string.
+			for index+1 < len(runes) && (unicode.IsLetter(runes[index+1]) || unicode.IsDigit(runes[index+1]) || runes[index+1] == '_' || runes[index+1] == '$') {
+				index++
+			}
+		}
+		index++
+	}
+	names := make([]string, maxParam)
+	for i := range names {
+		names[i] = "p" + strconv.Itoa(i+1)
+	}
+	sql = strings.TrimSpace(sql)
+	if len(sql) > 0 && sql[len(sql)-1] == ';' {
+		sql = strings.TrimSpace(sql[:len(sql)-1])
+	}
+	return sql, names, nil
+}
+
+// skipPostgreSQLQuoted returns the index of the closing quote of the quoted
+// string or identifier that starts at the given index. A quote is escaped by
+// doubling it, and by a backslash if escapes is true.
+func skipPostgreSQLQuoted(runes []rune, index int, escapes bool) (int, error) {
+	quote := runes[index]
+	for index++; index < len(runes); index++ {
+		if escapes && runes[index] == '\\' {
+			index++
+		} else if runes[index] == quote {
+			if len(runes) > index+1 && runes[index+1] == quote {
+				index++
+			} else {
+				return index, nil
+			}
+		}
+	}
+	return index, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "statement contains an unclosed literal: %s", string(runes)))
+}
+
+// postgreSQLDollarQuoteTag returns the end index (exclusive) of the
+// dollar-quote tag, such as $$ or $tag$, that starts at the given index.
+func postgreSQLDollarQuoteTag(runes []rune, index int) (int, bool) {
+	for i := index + 1; i < len(runes); i++ {
+		if runes[i] == '$' {
+			return i + 1, true
+		}
+		if !(unicode.IsLetter(runes[i]) || runes[i] == '_' || (i > index+1 && unicode.IsDigit(runes[i]))) {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
 // RemoveCommentsAndTrim removes any comments in the query string and trims any
 // spaces at the beginning and end of the query. This makes checking what type
 // of query a string is a lot easier, as only the first word(s) need to be
@@ -322,6 +464,173 @@ func isDDL(query string) (bool, error) {
 	return false, nil
 }
 
+// StatementType is the type of a SQL statement, as determined by the driver.
+type StatementType int
+
+const (
+	// StatementTypeUnknown is a statement that the driver does not recognize.
+	// The driver sends these statements to Spanner as DML statements or
+	// queries, depending on whether they are executed with ExecContext or
+	// QueryContext.
+	StatementTypeUnknown StatementType = iota
+	// StatementTypeQuery is a query, for example a SELECT statement.
+	StatementTypeQuery
+	// StatementTypeDML is a DML statement, for example an INSERT statement.
+	StatementTypeDML
+	// StatementTypeDDL is a DDL statement, for example a CREATE TABLE
+	// statement.
+	StatementTypeDDL
+	// StatementTypeShow is a client-side SHOW VARIABLE statement.
+	StatementTypeShow
+	// StatementTypeSet is a client-side SET statement.
+	StatementTypeSet
+	// StatementTypeStartBatch is a client-side START BATCH DDL or START BATCH
+	// DML statement.
+	StatementTypeStartBatch
+	// StatementTypeRunBatch is a client-side RUN BATCH statement.
+	StatementTypeRunBatch
+	// StatementTypeAbortBatch is a client-side ABORT BATCH statement.
+	StatementTypeAbortBatch
+	// StatementTypeCommit is a client-side COMMIT statement.
+	StatementTypeCommit
+	// StatementTypeRollback is a client-side ROLLBACK statement.
+	StatementTypeRollback
+	// StatementTypeSavepoint is a client-side SAVEPOINT, RELEASE SAVEPOINT or
+	// ROLLBACK TO SAVEPOINT statement.
+	StatementTypeSavepoint
+)
+
+func (t StatementType) String() string {
+	switch t {
+	case StatementTypeUnknown:
+		return "UNKNOWN"
+	case StatementTypeQuery:
+		return "QUERY"
+	case StatementTypeDML:
+		return "DML"
+	case StatementTypeDDL:
+		return "DDL"
+	case StatementTypeShow:
+		return "SHOW"
+	case StatementTypeSet:
+		return "SET"
+	case StatementTypeStartBatch:
+		return "START_BATCH"
+	case StatementTypeRunBatch:
+		return "RUN_BATCH"
+	case StatementTypeAbortBatch:
+		return "ABORT_BATCH"
+	case StatementTypeCommit:
+		return "COMMIT"
+	case StatementTypeRollback:
+		return "ROLLBACK"
+	case StatementTypeSavepoint:
+		return "SAVEPOINT"
+	}
+	return fmt.Sprintf("StatementType(%d)", int(t))
+}
+
+// IsClientSide returns true if the statement type is a client-side statement
+// that is handled by the driver and not sent to Spanner.
+func (t StatementType) IsClientSide() bool {
+	return t >= StatementTypeShow
+}
+
+// ClassifyStatement returns the type of the given SQL statement. It uses the
+// same logic as the driver uses to determine how a statement should be
+// executed, and can be used by tools that need to know the type of a
+// statement before it is executed. Comments and statement hints are ignored.
+//
+// An error is returned if the statement contains an unclosed comment or
+// literal. Statements that are not recognized return StatementTypeUnknown.
+func ClassifyStatement(sql string) (StatementType, error) {
+	stmt, err := parseClientSideStatement(nil, sql)
+	if err != nil {
+		return StatementTypeUnknown, err
+	}
+	if stmt != nil {
+		return clientSideStatementType(stmt.clientSideStatement), nil
+	}
+	return classifySQLStatement(sql)
+}
+
+// classifySQLStatement returns the StatementType of the given statement,
+// which must not be a client-side statement.
+func classifySQLStatement(sql string) (StatementType, error) {
+	query, err := removeCommentsAndTrim(sql)
+	if err != nil {
+		return StatementTypeUnknown, err
+	}
+	if strings.HasPrefix(query, "@") {
+		query = removeStatementHint(query)
+	}
+	keyword := strings.ToUpper(firstKeyword(strings.TrimLeft(query, "( \t\n\r")))
+	switch {
+	case ddlStatements[keyword]:
+		return StatementTypeDDL, nil
+	case selectStatements[keyword]:
+		return StatementTypeQuery, nil
+	case dmlStatements[keyword]:
+		return StatementTypeDML, nil
+	}
+	return StatementTypeUnknown, nil
+}
+
+var returningClauseRegexp = regexp.MustCompile(`(?i)\b(THEN\s+RETURN|RETURNING)\b`)
+
+// isDMLWithReturning returns true if the given statement is a DML statement
+// with a THEN RETURN clause, or a RETURNING clause for PostgreSQL databases.
+// These statements return rows, and can be executed with QueryContext.
+func isDMLWithReturning(sql string) (bool, error) {
+	query, err := removeCommentsAndTrim(sql)
+	if err != nil {
+		return false, err
+	}
+	if strings.HasPrefix(query, "@") {
+		query = removeStatementHint(query)
+	}
+	keyword := strings.ToUpper(firstKeyword(strings.TrimLeft(query, "( \t\n\r")))
+	if !dmlStatements[keyword] {
+		return false, nil
+	}
+	return returningClauseRegexp.MatchString(query), nil
+}
+
+// clientSideStatementType returns the StatementType of the given client-side
+// statement based on the name of the method that executes it.
+func clientSideStatementType(stmt *clientSideStatement) StatementType {
+	method := strings.TrimPrefix(stmt.MethodName, "statement")
+	switch {
+	case strings.HasPrefix(method, "Show"):
+		return StatementTypeShow
+	case strings.HasPrefix(method, "Set"):
+		return StatementTypeSet
+	case strings.HasPrefix(method, "StartBatch"):
+		return StatementTypeStartBatch
+	case method == "RunBatch":
+		return StatementTypeRunBatch
+	case method == "AbortBatch":
+		return StatementTypeAbortBatch
+	case method == "Commit":
+		return StatementTypeCommit
+	case method == "Rollback":
+		return StatementTypeRollback
+	case strings.HasSuffix(method, "Savepoint"):
+		return StatementTypeSavepoint
+	}
+	return StatementTypeUnknown
+}
+
+// firstKeyword returns the leading letters of the given string.
+func firstKeyword(sql string) string {
+	for i, r := range sql {
+		if !unicode.IsLetter(r) {
+			return sql[:i]
+		}
+	}
+	return sql
+}
+
 // clientSideStatements are loaded from the client_side_statements.json file.
 type clientSideStatements struct {
 	Statements []*clientSideStatement `json:"statements"`
@@ -428,6 +737,10 @@ func parseClientSideStatement(c *conn, query string) (*executableClientSideState
 				if len(p) == 2 {
 					params = strings.TrimSpace(p[1])
 				}
+			} else if stmt.regexp.NumSubexp() > 0 {
+				// Statements that are not SET statements use the first
+				// capturing group of the regular expression as parameter.
+				params = stmt.regexp.FindStringSubmatch(query)[1]
 			}
 			return &executableClientSideStatement{stmt, c, query, params}, nil
 		}
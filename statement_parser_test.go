@@ -682,6 +682,49 @@ func TestFindParams(t *testing.T) {
 	}
 }
 
+func TestParsePostgreSQLParameters(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		want    []string
+		wantSQL string
+		wantErr bool
+	}{
+		{input: "SELECT * FROM Singers", want: []string{}, wantSQL: "SELECT * FROM Singers"},
+		{input: "SELECT * FROM Singers WHERE SingerId=$1;", want: []string{"p1"}, wantSQL: "SELECT * FROM Singers WHERE SingerId=$1"},
+		{input: "SELECT $2, $1, $2", want: []string{"p1", "p2"}, wantSQL: "SELECT $2, $1, $2"},
+		{input: "SELECT $3", want: []string{"p1", "p2", "p3"}, wantSQL: "SELECT $3"},
+		{input: "SELECT '$1', \"$2\", $3", want: []string{"p1", "p2", "p3"}, wantSQL: "SELECT '$1', \"$2\", $3"},
+		{input: "SELECT 'it''s $1', $1", want: []string{"p1"}, wantSQL: "SELECT 'it''s $1', $1"},
+		{input: "SELECT E'it\\'s $2', $1", want: []string{"p1"}, wantSQL: "SELECT E'it\\'s $2', $1"},
+		{input: "SELECT $$it's $2$$, $tag$ $3 $$ $tag$, $1", want: []string{"p1"}, wantSQL: "SELECT $$it's $2$$, $tag$ $3 $$ $tag$, $1"},
+		{input: "SELECT $1 -- $2\nFROM Singers /* $3 /* $4 */ $5 */", want: []string{"p1"}, wantSQL: "SELECT $1 -- $2\nFROM Singers /* $3 /* $4 */ $5 */"},
+		{input: "SELECT foo$1 FROM Singers WHERE id=$1", want: []string{"p1"}, wantSQL: "SELECT foo$1 FROM Singers WHERE id=$1"},
+		{input: "SELECT @name, ?", want: []string{}, wantSQL: "SELECT @name, ?"},
+		{input: "SELECT 'unclosed", wantErr: true},
+		{input: "SELECT $$unclosed", wantErr: true},
+		{input: "SELECT 1 /* unclosed", wantErr: true},
+		{input: "SELECT $0", wantErr: true},
+	} {
+		gotSQL, got, err := parsePostgreSQLParameters(tc.input)
+		if tc.wantErr {
+			if spanner.ErrCode(err) != codes.InvalidArgument {
+				t.Errorf("%q: error code mismatch\nGot: %v\nWant: %v", tc.input, spanner.ErrCode(err), codes.InvalidArgument)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !cmp.Equal(got, tc.want) {
+			t.Errorf("%q: parameters mismatch\nGot: %v\nWant: %v", tc.input, got, tc.want)
+		}
+		if gotSQL != tc.wantSQL {
+			t.Errorf("%q: sql mismatch\nGot: %s\nWant: %s", tc.input, gotSQL, tc.wantSQL)
+		}
+	}
+}
+
 func FuzzFindParams(f *testing.F) {
 	for _, sample := range fuzzQuerySamples {
 		f.Add(sample)
@@ -916,16 +959,17 @@ func TestParseClientSideStatement(t *testing.T) {
 			exec:  true,
 		},
 		{
-			name:  "Show variable Retry_Aborts_Internally",
-			input: "show variable retry_aborts_internally",
-			want:  "SHOW VARIABLE RETRY_ABORTS_INTERNALLY",
-			query: true,
+			name:       "Show variable Retry_Aborts_Internally",
+			input:      "show variable retry_aborts_internally",
+			want:       "SHOW VARIABLE <name>",
+			wantParams: "retry_aborts_internally",
+			query:      true,
 		},
 		{
 			name:       "SET Retry_Aborts_Internally",
 			input:      "set retry_aborts_internally = false",
-			want:       "SET RETRY_ABORTS_INTERNALLY = TRUE|FALSE",
-			wantParams: "false",
+			want:       "SET <name> = <value>",
+			wantParams: "retry_aborts_internally = false",
 			exec:       true,
 		},
 	}
@@ -1002,3 +1046,84 @@ func init() {
 		fuzzQuerySamples = append(fuzzQuerySamples, ddl)
 	}
 }
+
+func TestClassifyStatement(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		sql  string
+		want StatementType
+	}{
+		{"SELECT * FROM Singers", StatementTypeQuery},
+		{"  with t as (select 1) select * from t", StatementTypeQuery},
+		{"(SELECT 1) UNION ALL (SELECT 2)", StatementTypeQuery},
+		{"/* comment */ SELECT 1", StatementTypeQuery},
+		{"@{OPTIMIZER_VERSION=1} SELECT 1", StatementTypeQuery},
+		{"INSERT INTO Singers (SingerId) VALUES (1)", StatementTypeDML},
+		{"-- comment\nupdate Singers set Active=true where true", StatementTypeDML},
+		{"@{LOCK_SCANNED_RANGES=exclusive} DELETE FROM Singers WHERE true", StatementTypeDML},
+		{"CREATE TABLE Singers (SingerId INT64) PRIMARY KEY (SingerId)", StatementTypeDDL},
+		{"drop index SingersByName", StatementTypeDDL},
+		{"ALTER TABLE Singers ADD COLUMN Name STRING(MAX)", StatementTypeDDL},
+		{"SHOW VARIABLE COMMIT_TIMESTAMP", StatementTypeShow},
+		{"show variable read_timestamp", StatementTypeShow},
+		{"show variable retry_aborts_internally", StatementTypeShow},
+		{"SET AUTOCOMMIT_DML_MODE = 'TRANSACTIONAL'", StatementTypeSet},
+		{"SET READ_ONLY_STALENESS = 'STRONG'", StatementTypeSet},
+		{"START BATCH DDL", StatementTypeStartBatch},
+		{"start batch dml", StatementTypeStartBatch},
+		{"RUN BATCH", StatementTypeRunBatch},
+		{"ABORT BATCH", StatementTypeAbortBatch},
+		{"COMMIT", StatementTypeCommit},
+		{"rollback transaction", StatementTypeRollback},
+		{"SAVEPOINT s1", StatementTypeSavepoint},
+		{"release savepoint s1", StatementTypeSavepoint},
+		{"ROLLBACK TO SAVEPOINT s1", StatementTypeSavepoint},
+		{"SET AUTOCOMMIT = FALSE", StatementTypeSet},
+		{"GRANT SELECT ON TABLE Singers TO ROLE reader", StatementTypeUnknown},
+		{"", StatementTypeUnknown},
+	} {
+		got, err := ClassifyStatement(test.sql)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.sql, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: statement type mismatch\n Got: %v\nWant: %v", test.sql, got, test.want)
+		}
+		if g, w := got.IsClientSide(), test.want >= StatementTypeShow; g != w {
+			t.Errorf("%q: client-side mismatch\n Got: %v\nWant: %v", test.sql, g, w)
+		}
+	}
+
+	if _, err := ClassifyStatement("SELECT 'unclosed"); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
+
+func TestIsDMLWithReturning(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		sql  string
+		want bool
+	}{
+		{"INSERT INTO Singers (SingerId) VALUES (1) THEN RETURN *", true},
+		{"update Singers set Active=true where true then\nreturn SingerId", true},
+		{"@{LOCK_SCANNED_RANGES=exclusive} DELETE FROM Singers WHERE true THEN RETURN SingerId", true},
+		{"/* comment */ insert into singers (name) values ('foo') returning id", true},
+		{"INSERT INTO Singers (SingerId) VALUES (1)", false},
+		{"SELECT ReturnValue FROM Singers", false},
+		{"SELECT 1 -- THEN RETURN", false},
+		{"UPDATE Singers SET Active=true WHERE true -- THEN RETURN", false},
+	} {
+		got, err := isDMLWithReturning(test.sql)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.sql, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: returning mismatch\n Got: %v\nWant: %v", test.sql, got, test.want)
+		}
+	}
+}
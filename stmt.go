@@ -16,7 +16,10 @@ package spannerdriver
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"math"
 
 	"cloud.google.com/go/spanner"
 	"google.golang.org/grpc/codes"
@@ -50,22 +53,21 @@ func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	ss, err := prepareSpannerStmt(s.query, args)
-	if err != nil {
-		return nil, err
-	}
+	return s.conn.QueryContext(ctx, s.query, args)
+}
 
-	var it rowIterator
-	if s.conn.tx != nil {
-		it = s.conn.tx.Query(ctx, ss)
-	} else {
-		it = &readOnlyRowIterator{s.conn.client.Single().WithTimestampBound(s.conn.readOnlyStaleness).Query(ctx, ss)}
+// prepareSpannerStmt creates a Spanner statement for the given query and
+// arguments, using the parameter syntax of the dialect of the database.
+func (c *conn) prepareSpannerStmt(ctx context.Context, q string, args []driver.NamedValue) (spanner.Statement, error) {
+	dialect, err := c.statementDialect(ctx, q)
+	if err != nil {
+		return spanner.Statement{}, err
 	}
-	return &rows{it: it}, nil
+	return prepareSpannerStmt(dialect, q, args)
 }
 
-func prepareSpannerStmt(q string, args []driver.NamedValue) (spanner.Statement, error) {
-	q, names, err := parseParameters(q)
+func prepareSpannerStmt(dialect Dialect, q string, args []driver.NamedValue) (spanner.Statement, error) {
+	q, names, err := parseParametersForDialect(dialect, q)
 	if err != nil {
 		return spanner.Statement{}, err
 	}
@@ -76,7 +78,11 @@ func prepareSpannerStmt(q string, args []driver.NamedValue) (spanner.Statement,
 			name = names[i]
 		}
 		if name != "" {
-			ss.Params[name] = convertParam(v.Value)
+			value := convertParam(v.Value)
+			if err := CheckValueSize(name, value); err != nil {
+				return spanner.Statement{}, err
+			}
+			ss.Params[name] = value
 		}
 	}
 	// Verify that all parameters have a value.
@@ -88,6 +94,110 @@ func prepareSpannerStmt(q string, args []driver.NamedValue) (spanner.Statement,
 	return ss, nil
 }
 
+// MaxValueSize is the maximum size in bytes of a single STRING or BYTES value
+// in Spanner. Requests that contain larger values are rejected by Spanner.
+const MaxValueSize = 10 << 20
+
+// CheckValueSize returns an InvalidArgument error if the given value is larger
+// than MaxValueSize. The name is included in the error message, and should be
+// the name of the parameter or column that the value is intended for. For
+// arrays, the size of each element is checked.
+//
+// The driver calls this function for all query parameters before a statement
+// is sent to Spanner. Mutations are not checked by the driver, and this
+// function can be used to check the values of a mutation before it is
+// buffered or applied. This prevents large values from causing an opaque
+// transport error when the request is sent to Spanner.
+func CheckValueSize(name string, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return checkSize(name, len(v))
+	case *string:
+		if v != nil {
+			return checkSize(name, len(*v))
+		}
+	case spanner.NullString:
+		return checkSize(name, len(v.StringVal))
+	case *spanner.NullString:
+		if v != nil {
+			return checkSize(name, len(v.StringVal))
+		}
+	case sql.NullString:
+		return checkSize(name, len(v.String))
+	case []byte:
+		return checkSize(name, len(v))
+	case *[]byte:
+		if v != nil {
+			return checkSize(name, len(*v))
+		}
+	case json.RawMessage:
+		return checkSize(name, len(v))
+	case NullBytes:
+		return checkSize(name, len(v.Bytes))
+	case *NullBytes:
+		if v != nil {
+			return checkSize(name, len(v.Bytes))
+		}
+	case []string:
+		for _, e := range v {
+			if err := checkSize(name, len(e)); err != nil {
+				return err
+			}
+		}
+	case *[]string:
+		if v != nil {
+			return CheckValueSize(name, *v)
+		}
+	case []*string:
+		for _, e := range v {
+			if err := CheckValueSize(name, e); err != nil {
+				return err
+			}
+		}
+	case []spanner.NullString:
+		for _, e := range v {
+			if err := checkSize(name, len(e.StringVal)); err != nil {
+				return err
+			}
+		}
+	case *[]spanner.NullString:
+		if v != nil {
+			return CheckValueSize(name, *v)
+		}
+	case [][]byte:
+		// Nil elements are NULL values, and have length zero.
+		for _, e := range v {
+			if err := checkSize(name, len(e)); err != nil {
+				return err
+			}
+		}
+	case *[][]byte:
+		if v != nil {
+			return CheckValueSize(name, *v)
+		}
+	case []json.RawMessage:
+		for _, e := range v {
+			if err := checkSize(name, len(e)); err != nil {
+				return err
+			}
+		}
+	case []NullBytes:
+		for _, e := range v {
+			if err := checkSize(name, len(e.Bytes)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkSize(name string, size int) error {
+	if size > MaxValueSize {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "value for %s is %d bytes, which exceeds the maximum value size of %d bytes", name, size, MaxValueSize))
+	}
+	return nil
+}
+
 func convertParam(v driver.Value) driver.Value {
 	switch v := v.(type) {
 	default:
@@ -172,7 +282,84 @@ func convertParam(v driver.Value) driver.Value {
 			res[i] = int64(val)
 		}
 		return res
+	case []int8:
+		return toInt64Slice(v)
+	case []int16:
+		return toInt64Slice(v)
+	case []int32:
+		return toInt64Slice(v)
+	case []uint16:
+		return toInt64Slice(v)
+	case []uint32:
+		return toInt64Slice(v)
+	case []uint64:
+		return toInt64Slice(v)
+	case []NullBytes:
+		return nullBytesToSlice(v)
+	case json.RawMessage:
+		return rawMessageToNullJSON(v)
+	case []json.RawMessage:
+		return rawMessagesToNullJSON(v)
+	}
+}
+
+// checkInt64Range returns an InvalidArgument error if the given unsigned
+// integer value, or an element of the given slice of unsigned integers, does
+// not fit in an INT64.
+func checkInt64Range(v driver.Value) error {
+	switch v := v.(type) {
+	case uint:
+		return checkUint64InRange(uint64(v))
+	case *uint:
+		if v != nil {
+			return checkUint64InRange(uint64(*v))
+		}
+	case []uint:
+		for _, val := range v {
+			if err := checkUint64InRange(uint64(val)); err != nil {
+				return err
+			}
+		}
+	case *[]uint:
+		if v != nil {
+			return checkInt64Range(*v)
+		}
+	case []*uint:
+		for _, val := range v {
+			if val != nil {
+				if err := checkUint64InRange(uint64(*val)); err != nil {
+					return err
+				}
+			}
+		}
+	case []uint64:
+		for _, val := range v {
+			if err := checkUint64InRange(val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkUint64InRange(v uint64) error {
+	if v > math.MaxInt64 {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "value %d is out of range for INT64", v))
+	}
+	return nil
+}
+
+// toInt64Slice converts a slice of integers to a slice of int64, which is
+// sent to Spanner as an ARRAY<INT64>.
+func toInt64Slice[T int8 | int16 | int32 | uint16 | uint32 | uint64](v []T) []int64 {
+	if v == nil {
+		return nil
+	}
+	res := make([]int64, len(v))
+	for i, val := range v {
+		res[i] = int64(val)
 	}
+	return res
 }
 
 type result struct {
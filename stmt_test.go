@@ -15,9 +15,15 @@
 package spannerdriver
 
 import (
+	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
 )
 
 func TestConvertParam(t *testing.T) {
@@ -46,4 +52,62 @@ func TestConvertParam(t *testing.T) {
 	check((*[]int)(nil), ([]int64)(nil))
 }
 
+func TestCheckValueSize(t *testing.T) {
+	large := make([]byte, MaxValueSize+1)
+	for _, test := range []struct {
+		value   interface{}
+		wantErr bool
+	}{
+		{value: int64(1)},
+		{value: make([]byte, MaxValueSize)},
+		{value: large, wantErr: true},
+		{value: &large, wantErr: true},
+		{value: (*[]byte)(nil)},
+		{value: json.RawMessage(large), wantErr: true},
+		{value: NullBytes{Bytes: large, Valid: true}, wantErr: true},
+		{value: &NullBytes{Bytes: large, Valid: true}, wantErr: true},
+		{value: NullBytes{}},
+		{value: string(large), wantErr: true},
+		{value: pointerTo(string(large)), wantErr: true},
+		{value: (*string)(nil)},
+		{value: spanner.NullString{StringVal: string(large), Valid: true}, wantErr: true},
+		{value: &spanner.NullString{StringVal: string(large), Valid: true}, wantErr: true},
+		{value: sql.NullString{String: string(large), Valid: true}, wantErr: true},
+		{value: [][]byte{{1}, large}, wantErr: true},
+		{value: [][]byte{nil, {1}, nil}},
+		{value: [][]byte{nil, large}, wantErr: true},
+		{value: &[][]byte{nil, large}, wantErr: true},
+		{value: []json.RawMessage{nil, json.RawMessage(large)}, wantErr: true},
+		{value: []NullBytes{{}, {Bytes: large, Valid: true}}, wantErr: true},
+		{value: []string{"test", string(large)}, wantErr: true},
+		{value: &[]string{"test", string(large)}, wantErr: true},
+		{value: []*string{nil, pointerTo(string(large))}, wantErr: true},
+		{value: []*string{nil, pointerTo("test")}},
+		{value: []spanner.NullString{{}, {StringVal: string(large), Valid: true}}, wantErr: true},
+		{value: &[]spanner.NullString{{}, {StringVal: string(large), Valid: true}}, wantErr: true},
+	} {
+		err := CheckValueSize("p1", test.value)
+		if test.wantErr {
+			if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+				t.Errorf("%T: error code mismatch\n Got: %v\nWant: %v", test.value, g, w)
+			}
+			if err != nil && !strings.Contains(err.Error(), "p1") {
+				t.Errorf("%T: error does not contain name: %v", test.value, err)
+			}
+		} else if err != nil {
+			t.Errorf("%T: unexpected error: %v", test.value, err)
+		}
+	}
+}
+
+func TestPrepareSpannerStmt_ValueTooLarge(t *testing.T) {
+	_, err := prepareSpannerStmt(GoogleSQL, "INSERT INTO Singers (SingerId, Picture) VALUES (@id, @picture)", []driver.NamedValue{
+		{Name: "id", Ordinal: 1, Value: int64(1)},
+		{Name: "picture", Ordinal: 2, Value: make([]byte, MaxValueSize+1)},
+	})
+	if g, w := spanner.ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
 func pointerTo[T any](v T) *T { return &v }
@@ -0,0 +1,60 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+)
+
+// StreamRows iterates over the given rows and calls encode for each row. This
+// can be used to stream the result of a query directly to a writer or a gRPC
+// stream without reading the entire result into memory.
+//
+// The values of each row are decoded into a buffer that is reused for all
+// rows. The values have the same types as the values that are returned by
+// rows.Scan for a destination of type *interface{}. The encoder must not
+// retain the slice or its values after it returns, as these are overwritten by
+// the next row.
+//
+// StreamRows stops and returns the error if encode returns an error or if the
+// context is cancelled. The rows are closed when this function returns.
+func StreamRows(ctx context.Context, rows *sql.Rows, encode func([]interface{}) error) error {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if err := encode(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestStreamRows(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT * FROM Numbers"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1, 10}, {2, 20}, {3, 30}}, [2]string{"A", "B"}),
+	})
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]interface{}
+	if err := StreamRows(ctx, rows, func(values []interface{}) error {
+		// The buffer is reused, so the values must be copied.
+		got = append(got, append([]interface{}(nil), values...))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{{int64(1), int64(10)}, {int64(2), int64(20)}, {int64(3), int64(30)}}
+	if !cmp.Equal(got, want) {
+		t.Fatalf("rows mismatch\n Got: %v\nWant: %v", got, want)
+	}
+
+	// An error from the encoder stops the iteration.
+	rows, err = db.QueryContext(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodeErr := errors.New("encode failed")
+	count := 0
+	err = StreamRows(ctx, rows, func(values []interface{}) error {
+		count++
+		return encodeErr
+	})
+	if !errors.Is(err, encodeErr) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, encodeErr)
+	}
+	if g, w := count, 1; g != w {
+		t.Fatalf("encode count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func setupStreamRowsBenchmark(b *testing.B) (*sql.DB, string, func()) {
+	db, server, teardown := setupTestDBConnection(b)
+	values := make([][2]int64, 1000)
+	for i := range values {
+		values[i] = [2]int64{int64(i), int64(i * 10)}
+	}
+	query := "SELECT * FROM Numbers"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet(values, [2]string{"A", "B"}),
+	})
+	return db, query, teardown
+}
+
+func BenchmarkStreamRows(b *testing.B) {
+	db, query, teardown := setupStreamRowsBenchmark(b)
+	defer teardown()
+	ctx := context.Background()
+	encode := func([]interface{}) error { return nil }
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := StreamRows(ctx, rows, encode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamRowsNaive(b *testing.B) {
+	db, query, teardown := setupStreamRowsBenchmark(b)
+	defer teardown()
+	ctx := context.Background()
+	encode := func([]interface{}) error { return nil }
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				b.Fatal(err)
+			}
+			if err := encode(values); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			b.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+}
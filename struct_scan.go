@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ScanStruct scans the current row of rows into the fields of the struct
+// that dest points to. Each column is scanned into the field with a
+// `spanner:"ColumnName"` struct tag, or into the field with the same name as
+// the column if no field has a matching tag. If no field matches exactly, the
+// column is scanned into the field with the same name ignoring case, or else
+// into the field with the same name ignoring case and underscores, in the
+// same way as for Query. Fields can have any type that the column can be
+// scanned into with rows.Scan, including the spanner.Null* types and native
+// Go types. An error is returned if a column cannot be mapped to a field.
+// Fields that are not mapped to a column are left unchanged.
+//
+// Example:
+//
+//	type singer struct {
+//		ID       int64 `spanner:"SingerId"`
+//		Name     string
+//		Birthday spanner.NullDate
+//	}
+//	rows, err := db.QueryContext(ctx, "SELECT SingerId, Name, Birthday FROM Singers")
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		var s singer
+//		if err := spannerdriver.ScanStruct(rows, &s); err != nil {
+//			return err
+//		}
+//	}
+func ScanStruct(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "destination must be a non-nil pointer to a struct, got %T", dest))
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	indexes, err := structFieldIndexes(v.Elem().Type(), columns, false)
+	if err != nil {
+		return err
+	}
+	return rows.Scan(scanStructFields(v.Elem(), indexes)...)
+}
+
+// isRowStruct returns true if a value of the given type is a plain struct
+// that a row is scanned into by mapping each column to a field. Structs that
+// implement sql.Scanner, such as sql.NullString and spanner.NullInt64, and
+// structs that represent a single value, such as time.Time and big.Rat, are
+// scanned from a single column instead.
+func isRowStruct(t reflect.Type) bool {
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+	if reflect.PointerTo(t).Implements(scannerType) {
+		return false
+	}
+	switch t.PkgPath() {
+	case "time", "math/big", "database/sql", "cloud.google.com/go/civil", "cloud.google.com/go/spanner":
+		return false
+	}
+	return true
+}
+
+// structTagName is the name of the struct tag that can be used to map a
+// column in a query result to a field in a struct. A field with the tag
+// `spanner:"-"` is never mapped to a column.
+const structTagName = "spanner"
+
+// structFieldIndexes returns the index of the field in the given struct type
+// for each of the given columns. A field is mapped to a column if the spanner
+// struct tag of the field is equal to the column name, or if the field does
+// not have a spanner struct tag and the name of the field is equal to the
+// column name.
+//
+// If caseSensitive is false and no field has exactly the same name as a
+// column, then the column is mapped to the field with the same name ignoring
+// case, and otherwise to the field with the same name as the column without
+// underscores ignoring case. This maps a snake_case column such as `album_id`
+// to a field named AlbumId or AlbumID. An error is returned if a column cannot
+// be mapped to a field, or if it can be mapped to more than one field.
+func structFieldIndexes(t reflect.Type, columns []string, caseSensitive bool) ([]int, error) {
+	fields := make(map[string]int, t.NumField())
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup(structTagName); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields[name] = i
+		names = append(names, name)
+	}
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		if index, ok := fields[column]; ok {
+			indexes[i] = index
+			continue
+		}
+		name := ""
+		if !caseSensitive {
+			var err error
+			if name, err = findFieldNameFold(t, names, column); err != nil {
+				return nil, err
+			}
+		}
+		if name == "" {
+			return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "no field found in %v for column %q", t, column))
+		}
+		indexes[i] = fields[name]
+	}
+	return indexes, nil
+}
+
+// findFieldNameFold returns the field name that is equal to the given column
+// name ignoring case, or the field name that is equal to the column name
+// without underscores ignoring case if there is no such field. An empty
+// string is returned if there is no matching field.
+func findFieldNameFold(t reflect.Type, names []string, column string) (string, error) {
+	for _, candidate := range []string{column, strings.ReplaceAll(column, "_", "")} {
+		var matches []string
+		for _, name := range names {
+			if strings.EqualFold(name, candidate) {
+				matches = append(matches, name)
+			}
+		}
+		if len(matches) > 1 {
+			return "", spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "column %q matches more than one field in %v: %v", column, t, matches))
+		}
+		if len(matches) == 1 {
+			return matches[0], nil
+		}
+	}
+	return "", nil
+}
+
+// scanStructFields returns the scan destinations for the given field indexes
+// of a struct value.
+func scanStructFields(v reflect.Value, indexes []int) []interface{} {
+	dest := make([]interface{}, len(indexes))
+	for i, index := range indexes {
+		dest[i] = v.Field(index).Addr().Interface()
+	}
+	return dest
+}
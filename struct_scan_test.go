@@ -0,0 +1,182 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestStructFieldIndexes(t *testing.T) {
+	t.Parallel()
+
+	type singer struct {
+		SingerID  int64
+		FirstName string
+		Last      string `spanner:"LastName"`
+		Ignored   string `spanner:"-"`
+	}
+	type ambiguous struct {
+		Name string
+		NAME string
+	}
+	for _, test := range []struct {
+		name          string
+		typ           reflect.Type
+		columns       []string
+		caseSensitive bool
+		want          []int
+		code          codes.Code
+	}{
+		{
+			name:    "exact",
+			typ:     reflect.TypeOf(singer{}),
+			columns: []string{"SingerID", "FirstName", "LastName"},
+			want:    []int{0, 1, 2},
+		},
+		{
+			name:    "alias with different case",
+			typ:     reflect.TypeOf(singer{}),
+			columns: []string{"singerid", "FIRSTNAME", "lastname"},
+			want:    []int{0, 1, 2},
+		},
+		{
+			name:    "snake_case",
+			typ:     reflect.TypeOf(singer{}),
+			columns: []string{"singer_id", "first_name", "last_name"},
+			want:    []int{0, 1, 2},
+		},
+		{
+			name:          "case sensitive",
+			typ:           reflect.TypeOf(singer{}),
+			columns:       []string{"singerid"},
+			caseSensitive: true,
+			code:          codes.InvalidArgument,
+		},
+		{
+			name:    "ignored field",
+			typ:     reflect.TypeOf(singer{}),
+			columns: []string{"Ignored"},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "exact match takes precedence",
+			typ:     reflect.TypeOf(ambiguous{}),
+			columns: []string{"NAME"},
+			want:    []int{1},
+		},
+		{
+			name:    "ambiguous",
+			typ:     reflect.TypeOf(ambiguous{}),
+			columns: []string{"name"},
+			code:    codes.InvalidArgument,
+		},
+	} {
+		got, err := structFieldIndexes(test.typ, test.columns, test.caseSensitive)
+		if g, w := spanner.ErrCode(err), test.code; g != w {
+			t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, g, w)
+			continue
+		}
+		if !cmp.Equal(got, test.want) {
+			t.Errorf("%s: indexes mismatch\n Got: %v\nWant: %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestScanStruct(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	type allTypes struct {
+		Bool              bool `spanner:"ColBool"`
+		ColString         string
+		ColBytes          []byte
+		Int               spanner.NullInt64 `spanner:"ColInt"`
+		ColFloat32        float32
+		ColFloat64        spanner.NullFloat64
+		ColNumeric        big.Rat
+		Date              civil.Date `spanner:"ColDate"`
+		ColTimestamp      time.Time
+		ColJson           spanner.NullJSON
+		ColBoolArray      []spanner.NullBool
+		ColStringArray    []spanner.NullString
+		ColBytesArray     [][]byte
+		ColIntArray       []spanner.NullInt64
+		ColFloat32Array   []spanner.NullFloat32
+		ColFloat64Array   []spanner.NullFloat64
+		ColNumericArray   []spanner.NullNumeric
+		ColDateArray      []spanner.NullDate
+		ColTimestampArray []spanner.NullTime
+		ColJsonArray      []spanner.NullJSON
+		Ignored           string `spanner:"-"`
+	}
+	query := "SELECT * FROM AllTypes"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateResultSetWithAllTypes(false),
+	})
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("missing row: %v", rows.Err())
+	}
+	v := allTypes{Ignored: "unchanged"}
+	if err := ScanStruct(rows, &v); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := v.Bool, true; g != w {
+		t.Errorf("bool mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := v.ColString, "test"; g != w {
+		t.Errorf("string mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := v.Int, (spanner.NullInt64{Int64: 5, Valid: true}); g != w {
+		t.Errorf("int mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := v.Date, (civil.Date{Year: 2021, Month: 7, Day: 21}); g != w {
+		t.Errorf("date mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(v.ColStringArray), 3; g != w {
+		t.Errorf("string array length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := v.Ignored, "unchanged"; g != w {
+		t.Errorf("ignored field mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	type missingField struct {
+		ColBool bool
+	}
+	if err := ScanStruct(rows, &missingField{}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+	if err := ScanStruct(rows, v); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}
@@ -17,6 +17,7 @@ package testutil
 import (
 	"context"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	instancepb "cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
 	"google.golang.org/protobuf/proto"
 )
@@ -61,6 +62,15 @@ func (s *inMemInstanceAdminServer) GetInstance(ctx context.Context, req *instanc
 	return s.resps[0].(*instancepb.Instance), nil
 }
 
+// CreateInstance returns the first response as the operation for creating the instance.
+func (s *inMemInstanceAdminServer) CreateInstance(ctx context.Context, req *instancepb.CreateInstanceRequest) (*longrunningpb.Operation, error) {
+	s.reqs = append(s.reqs, req)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.resps[0].(*longrunningpb.Operation), nil
+}
+
 func (s *inMemInstanceAdminServer) Stop() {
 	// do nothing
 }
@@ -87,6 +87,7 @@ const (
 	MethodExecuteStreamingSql string = "EXECUTE_STREAMING_SQL"
 	MethodExecuteBatchDml     string = "EXECUTE_BATCH_DML"
 	MethodStreamingRead       string = "EXECUTE_STREAMING_READ"
+	MethodBatchWrite          string = "BATCH_WRITE"
 )
 
 // StatementResult represents a mocked result on the test server. The result is
@@ -155,6 +156,7 @@ func (s *StatementResult) ToPartialResultSets(resumeToken []byte) (result []*spa
 			Metadata: s.ResultSet.Metadata,
 		})
 	}
+	result[len(result)-1].Stats = s.ResultSet.Stats
 	return result, nil
 }
 
@@ -274,6 +276,11 @@ type InMemSpannerServer interface {
 	// transaction retry logic.
 	AbortTransaction(id []byte)
 
+	// Puts an error on the server that will be returned for the mutation group
+	// with the given index in BatchWrite requests. All other mutation groups
+	// are applied successfully.
+	PutMutationGroupError(index int, err error)
+
 	// Puts a simulated execution time for one of the Spanner methods.
 	PutExecutionTime(method string, executionTime SimulatedExecutionTime)
 	// Freeze stalls all requests.
@@ -327,6 +334,8 @@ type inMemSpannerServer struct {
 	executionTimes map[string]*SimulatedExecutionTime
 	// The simulated errors for partial result sets
 	partialResultSetErrors map[string][]*PartialResultSetExecutionTime
+	// The simulated errors for mutation groups in BatchWrite requests.
+	mutationGroupErrors map[int]error
 
 	totalSessionsCreated uint
 	totalSessionsDeleted uint
@@ -349,6 +358,7 @@ func NewInMemSpannerServer() InMemSpannerServer {
 	res.partitionResults = make(map[string]*StatementResult)
 	res.executionTimes = make(map[string]*SimulatedExecutionTime)
 	res.partialResultSetErrors = make(map[string][]*PartialResultSetExecutionTime)
+	res.mutationGroupErrors = make(map[int]error)
 	res.receivedRequests = make(chan interface{}, 1000000)
 	// Produce a closed channel, so the default action of ready is to not block.
 	res.Freeze()
@@ -409,6 +419,12 @@ func (s *inMemSpannerServer) AbortTransaction(id []byte) {
 	s.abortedTransactions[string(id)] = true
 }
 
+func (s *inMemSpannerServer) PutMutationGroupError(index int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutationGroupErrors[index] = err
+}
+
 func (s *inMemSpannerServer) PutExecutionTime(method string, executionTime SimulatedExecutionTime) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -618,11 +634,17 @@ func (s *inMemSpannerServer) removeTransaction(tx *spannerpb.Transaction) {
 	delete(s.partitionedDmlTransactions, string(tx.Id))
 }
 
-func (s *inMemSpannerServer) getPartitionResult(partitionToken []byte) (*StatementResult, error) {
+// getPartitionResult returns the result that has been registered for the
+// given partition token. If no result has been registered for the token, the
+// result that has been registered for the SQL string is returned.
+func (s *inMemSpannerServer) getPartitionResult(partitionToken []byte, sql string) (*StatementResult, error) {
 	tokenString := string(partitionToken)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	result, ok := s.partitionResults[tokenString]
+	if !ok {
+		result, ok = s.statementResults[sql]
+	}
 	if !ok {
 		return nil, gstatus.Error(codes.Internal, fmt.Sprintf("No result found for partition token %v", tokenString))
 	}
@@ -827,7 +849,7 @@ func (s *inMemSpannerServer) ExecuteSql(ctx context.Context, req *spannerpb.Exec
 	}
 	var statementResult *StatementResult
 	if req.PartitionToken != nil {
-		statementResult, err = s.getPartitionResult(req.PartitionToken)
+		statementResult, err = s.getPartitionResult(req.PartitionToken, req.Sql)
 	} else {
 		statementResult, err = s.getStatementResult(req.Sql)
 	}
@@ -874,7 +896,7 @@ func (s *inMemSpannerServer) executeStreamingSQL(req *spannerpb.ExecuteSqlReques
 	}
 	var statementResult *StatementResult
 	if req.PartitionToken != nil {
-		statementResult, err = s.getPartitionResult(req.PartitionToken)
+		statementResult, err = s.getPartitionResult(req.PartitionToken, req.Sql)
 	} else {
 		statementResult, err = s.getStatementResult(req.Sql)
 	}
@@ -1022,6 +1044,52 @@ func (s *inMemSpannerServer) BeginTransaction(ctx context.Context, req *spannerp
 	return tx, nil
 }
 
+func (s *inMemSpannerServer) BatchWrite(req *spannerpb.BatchWriteRequest, stream spannerpb.Spanner_BatchWriteServer) error {
+	if err := s.simulateExecutionTime(MethodBatchWrite, req); err != nil {
+		return err
+	}
+	if req.Session == "" {
+		return gstatus.Error(codes.InvalidArgument, "Missing session name")
+	}
+	session, err := s.findSession(req.Session)
+	if err != nil {
+		return err
+	}
+	s.updateSessionLastUseTime(session.Name)
+	if len(req.GetMutationGroups()) == 0 {
+		return gstatus.Error(codes.InvalidArgument, "No mutations in Batch Write")
+	}
+	// All successful mutation groups are returned in one response with the
+	// same commit timestamp. Each failed mutation group is returned in a
+	// separate response.
+	success := &spannerpb.BatchWriteResponse{
+		CommitTimestamp: getCurrentTimestamp(),
+		Status:          &status.Status{},
+	}
+	var responses []*spannerpb.BatchWriteResponse
+	s.mu.Lock()
+	for idx := range req.GetMutationGroups() {
+		if err, ok := s.mutationGroupErrors[idx]; ok {
+			responses = append(responses, &spannerpb.BatchWriteResponse{
+				Indexes: []int32{int32(idx)},
+				Status:  gstatus.Convert(err).Proto(),
+			})
+		} else {
+			success.Indexes = append(success.Indexes, int32(idx))
+		}
+	}
+	s.mu.Unlock()
+	if len(success.Indexes) > 0 {
+		responses = append([]*spannerpb.BatchWriteResponse{success}, responses...)
+	}
+	for _, res := range responses {
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *inMemSpannerServer) Commit(ctx context.Context, req *spannerpb.CommitRequest) (*spannerpb.CommitResponse, error) {
 	if err := s.simulateExecutionTime(MethodCommitTransaction, req); err != nil {
 		return nil, err
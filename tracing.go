@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation name of the spans of the driver.
+const tracerName = "github.com/googleapis/go-sql-spanner"
+
+// The names of the spans of the driver.
+const (
+	spanQuery            = "go-sql-spanner.QueryContext"
+	spanExec             = "go-sql-spanner.ExecContext"
+	spanCommit           = "go-sql-spanner.Commit"
+	spanRollback         = "go-sql-spanner.Rollback"
+	spanRetryTransaction = "go-sql-spanner.RetryTransaction"
+)
+
+// The attributes of the spans of the driver.
+const (
+	attrStatementType  = attribute.Key("db.spanner.statement_type")
+	attrRowCount       = attribute.Key("db.spanner.row_count")
+	attrRowsAffected   = attribute.Key("db.spanner.rows_affected")
+	attrTransactionTag = attribute.Key("db.spanner.transaction_tag")
+	attrRetried        = attribute.Key("db.spanner.retried")
+)
+
+var dbSystemAttribute = attribute.String("db.system", "spanner")
+
+// newTracer returns the tracer of the given tracer provider, or of the global
+// tracer provider if it is nil.
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// tracer returns the tracer of the connector of the connection, or a no-op
+// tracer if the connection has no connector.
+func (c *conn) tracer() trace.Tracer {
+	if c.connector == nil || c.connector.tracer == nil {
+		return noop.NewTracerProvider().Tracer(tracerName)
+	}
+	return c.connector.tracer
+}
+
+// startStatementSpan starts a span for a statement. The statement type is
+// only determined if the span is recorded, as this requires parsing the
+// statement.
+func (c *conn) startStatementSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	ctx, span := c.tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dbSystemAttribute, attribute.String("db.statement", query)))
+	if !span.IsRecording() {
+		return ctx, span
+	}
+	if statementType, err := ClassifyStatement(query); err == nil {
+		span.SetAttributes(attrStatementType.String(statementType.String()))
+	}
+	if tx, ok := c.tx.(*readWriteTransaction); ok {
+		span.SetAttributes(attrRetried.Bool(tx.retried))
+		if tx.options.TransactionTag != "" {
+			span.SetAttributes(attrTransactionTag.String(tx.options.TransactionTag))
+		}
+	}
+	return ctx, span
+}
+
+// startSpan starts a span for a commit, a rollback or a retry of
+// the given read/write transaction.
+func (tx *readWriteTransaction) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	tracer := tx.tracer
+	if tracer == nil {
+		tracer = noop.NewTracerProvider().Tracer(tracerName)
+	}
+	attributes := []attribute.KeyValue{dbSystemAttribute}
+	if tx.options.TransactionTag != "" {
+		attributes = append(attributes, attrTransactionTag.String(tx.options.TransactionTag))
+	}
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// endSpan adds whether the transaction has been retried to the span, and
+// ends the span.
+func (tx *readWriteTransaction) endSpan(span trace.Span, err error) {
+	span.SetAttributes(attrRetried.Bool(tx.retried))
+	endSpan(span, err)
+}
+
+// recordRowsAffected adds the number of rows that were affected by a
+// statement to the span in the given context.
+func recordRowsAffected(ctx context.Context, res driver.Result) {
+	if res == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	if rowsAffected, err := res.RowsAffected(); err == nil {
+		span.SetAttributes(attrRowsAffected.Int64(rowsAffected))
+	}
+}
+
+// endSpan records the given error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
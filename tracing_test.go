@@ -0,0 +1,135 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	recorder := tracetest.NewSpanRecorder()
+	connector, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+		ConnectorConfig{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	rows, err := db.QueryContext(ctx, testutil.SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, testutil.UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	// Abort the commit once to trigger a retry of the transaction.
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted")},
+	})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	// The retry is ended before the commit that it is part of.
+	wantNames := []string{spanQuery, spanExec, spanRetryTransaction, spanCommit}
+	if fmt.Sprint(names) != fmt.Sprint(wantNames) {
+		t.Fatalf("span names mismatch\n Got: %v\nWant: %v", names, wantNames)
+	}
+	if v, _ := spanAttribute(spans[0], attrRowCount); v.AsInt64() != 2 {
+		t.Fatalf("row count mismatch\n Got: %v\nWant: 2", v.AsInt64())
+	}
+	if v, _ := spanAttribute(spans[0], attrStatementType); v.AsString() != "QUERY" {
+		t.Fatalf("statement type mismatch\n Got: %v\nWant: QUERY", v.AsString())
+	}
+	if v, _ := spanAttribute(spans[1], attrRowsAffected); v.AsInt64() != testutil.UpdateBarSetFooRowCount {
+		t.Fatalf("rows affected mismatch\n Got: %v\nWant: %v", v.AsInt64(), testutil.UpdateBarSetFooRowCount)
+	}
+	if g, w := spans[2].Parent().SpanID(), spans[3].SpanContext().SpanID(); g != w {
+		t.Fatalf("retry span parent mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if v, _ := spanAttribute(spans[3], attrRetried); !v.AsBool() {
+		t.Fatal("commit span retried mismatch\n Got: false\nWant: true")
+	}
+
+	// Failed statements are recorded as errors.
+	if _, err := db.ExecContext(ctx, "UPDATE Unknown SET Foo=1 WHERE TRUE"); err == nil {
+		t.Fatal("missing expected error")
+	}
+	spans = recorder.Ended()
+	if g, w := spans[len(spans)-1].Status().Code, otelcodes.Error; g != w {
+		t.Fatalf("span status mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestTracing_Disabled(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	connector, err := CreateConnector(
+		fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+		ConnectorConfig{TracerProvider: noop.NewTracerProvider()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	if err := db.QueryRowContext(context.Background(), testutil.SelectFooFromBar).Scan(new(int64)); err != nil {
+		t.Fatal(err)
+	}
+}
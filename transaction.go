@@ -23,8 +23,11 @@ import (
 
 	"cloud.google.com/go/spanner"
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/gax-go/v2"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // contextTransaction is the combination of both read/write and read-only
@@ -32,11 +35,12 @@ import (
 type contextTransaction interface {
 	Commit() error
 	Rollback() error
-	Query(ctx context.Context, stmt spanner.Statement) rowIterator
-	ExecContext(ctx context.Context, stmt spanner.Statement) (int64, error)
+	Query(ctx context.Context, stmt spanner.Statement, options spanner.QueryOptions) rowIterator
+	Read(ctx context.Context, req readRequest) rowIterator
+	ExecContext(ctx context.Context, stmt spanner.Statement, options spanner.QueryOptions) (int64, error)
 
 	StartBatchDML() (driver.Result, error)
-	RunBatch(ctx context.Context) (driver.Result, error)
+	RunBatch(ctx context.Context, options spanner.QueryOptions) (driver.Result, error)
 	AbortBatch() (driver.Result, error)
 
 	BufferWrite(ms []*spanner.Mutation) error
@@ -48,6 +52,31 @@ type rowIterator interface {
 	Metadata() *sppb.ResultSetMetadata
 }
 
+// resultSetStats returns the query plan and the query statistics of the given
+// iterator, or nil if the iterator does not have any statistics. The
+// statistics are only available after all rows have been consumed.
+func resultSetStats(it rowIterator) *sppb.ResultSetStats {
+	var spannerIt *spanner.RowIterator
+	switch it := it.(type) {
+	case *checksumRowIterator:
+		spannerIt = it.RowIterator
+	case *readOnlyRowIterator:
+		spannerIt = it.RowIterator
+	case *noRetryRowIterator:
+		spannerIt = it.RowIterator
+	}
+	if spannerIt == nil || (spannerIt.QueryPlan == nil && spannerIt.QueryStats == nil) {
+		return nil
+	}
+	stats := &sppb.ResultSetStats{QueryPlan: spannerIt.QueryPlan}
+	if spannerIt.QueryStats != nil {
+		// The query statistics are converted from a protobuf Struct by the
+		// Spanner client, so converting them back cannot fail.
+		stats.QueryStats, _ = structpb.NewStruct(spannerIt.QueryStats)
+	}
+	return stats
+}
+
 type readOnlyRowIterator struct {
 	*spanner.RowIterator
 }
@@ -65,8 +94,12 @@ func (ri *readOnlyRowIterator) Metadata() *sppb.ResultSetMetadata {
 }
 
 type readOnlyTransaction struct {
-	roTx  *spanner.ReadOnlyTransaction
-	close func()
+	roTx *spanner.ReadOnlyTransaction
+	// singleUse indicates that roTx is a single-use read-only transaction
+	// that can only execute one query.
+	singleUse bool
+	queried   bool
+	close     func()
 }
 
 func (tx *readOnlyTransaction) Commit() error {
@@ -89,11 +122,17 @@ func (tx *readOnlyTransaction) Rollback() error {
 	return nil
 }
 
-func (tx *readOnlyTransaction) Query(ctx context.Context, stmt spanner.Statement) rowIterator {
-	return &readOnlyRowIterator{tx.roTx.Query(ctx, stmt)}
+func (tx *readOnlyTransaction) Query(ctx context.Context, stmt spanner.Statement, options spanner.QueryOptions) rowIterator {
+	tx.queried = true
+	return &readOnlyRowIterator{tx.roTx.QueryWithOptions(ctx, stmt, options)}
+}
+
+func (tx *readOnlyTransaction) Read(ctx context.Context, req readRequest) rowIterator {
+	tx.queried = true
+	return &readOnlyRowIterator{req.execute(ctx, tx.roTx)}
 }
 
-func (tx *readOnlyTransaction) ExecContext(_ context.Context, stmt spanner.Statement) (int64, error) {
+func (tx *readOnlyTransaction) ExecContext(_ context.Context, stmt spanner.Statement, _ spanner.QueryOptions) (int64, error) {
 	return 0, spanner.ToSpannerError(status.Errorf(codes.FailedPrecondition, "read-only transactions cannot write"))
 }
 
@@ -101,7 +140,7 @@ func (tx *readOnlyTransaction) StartBatchDML() (driver.Result, error) {
 	return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "read-only transactions cannot write"))
 }
 
-func (tx *readOnlyTransaction) RunBatch(_ context.Context) (driver.Result, error) {
+func (tx *readOnlyTransaction) RunBatch(_ context.Context, _ spanner.QueryOptions) (driver.Result, error) {
 	return nil, spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "read-only transactions cannot write"))
 }
 
@@ -140,11 +179,50 @@ type readWriteTransaction struct {
 	// retryAborts indicates whether this transaction will automatically retry
 	// the transaction if it is aborted by Spanner. The default is true.
 	retryAborts bool
+	// retried indicates whether this transaction has been retried at least
+	// once because it was aborted by Spanner.
+	retried bool
+	// retries is the number of times that this transaction has been retried
+	// internally because it was aborted by Spanner.
+	retries int
+	// maxRetries is the maximum number of internal retries of this
+	// transaction. The number of retries is not limited if this is zero.
+	maxRetries int
+	// abortedErr is the Aborted error that was returned by Spanner for a
+	// transaction that is not retried internally. All following statements
+	// and the commit of the transaction return an Aborted error, and the
+	// transaction must be rolled back.
+	abortedErr error
+	// retryBackoff determines the delay between internal retries of this
+	// transaction if Spanner does not return a retry delay. The transaction
+	// is retried without delay if this is nil.
+	retryBackoff *gax.Backoff
+	// priority is the default RPC priority of the statements in this
+	// transaction.
+	priority sppb.RequestOptions_Priority
+	// options are the options that were used to start this transaction. The
+	// same options are used when the transaction is retried.
+	options spanner.TransactionOptions
+	// tracer creates the spans for the commit, rollback and retries of this
+	// transaction.
+	tracer trace.Tracer
+	// metrics counts the commits, rollbacks and retries of this transaction.
+	metrics *driverMetrics
+	// checkpoints contains the checkpoints of this transaction that can still
+	// be rolled back to, in the order in which they were created.
+	checkpoints []*TransactionCheckpoint
+	// savepoints contains the savepoints of this transaction that were
+	// created with SAVEPOINT statements, in the order in which they were
+	// created.
+	savepoints []namedCheckpoint
 
 	// statements contains the list of statements that has been executed on this
 	// transaction so far. These statements will be replayed on a new read write
 	// transaction if the initial attempt is aborted.
 	statements []retriableStatement
+	// mutations contains the mutations that have been buffered in this
+	// transaction so far.
+	mutations []*spanner.Mutation
 }
 
 // retriableStatement is the interface that is used to keep track of statements
@@ -167,6 +245,8 @@ type retriableStatement interface {
 type retriableUpdate struct {
 	// stmt is the statement that was executed on Spanner.
 	stmt spanner.Statement
+	// options are the query options that were used for the statement.
+	options spanner.QueryOptions
 	// c is the record count that was returned by Spanner.
 	c int64
 	// err is the error that was returned by Spanner.
@@ -177,7 +257,7 @@ type retriableUpdate struct {
 // of the statement during the retry is equal to the result during the initial
 // attempt.
 func (ru *retriableUpdate) retry(ctx context.Context, tx *spanner.ReadWriteStmtBasedTransaction) error {
-	c, err := tx.Update(ctx, ru.stmt)
+	c, err := tx.UpdateWithOptions(ctx, ru.stmt, ru.options)
 	if err != nil && spanner.ErrCode(err) == codes.Aborted {
 		return err
 	}
@@ -194,6 +274,8 @@ func (ru *retriableUpdate) retry(ctx context.Context, tx *spanner.ReadWriteStmtB
 type retriableBatchUpdate struct {
 	// statements are the statement that were executed on Spanner.
 	statements []spanner.Statement
+	// options are the query options that were used for the statements.
+	options spanner.QueryOptions
 	// c is the record counts that were returned by Spanner.
 	c []int64
 	// err is the error that was returned by Spanner.
@@ -204,7 +286,7 @@ type retriableBatchUpdate struct {
 // of the statement during the retry is equal to the result during the initial
 // attempt.
 func (ru *retriableBatchUpdate) retry(ctx context.Context, tx *spanner.ReadWriteStmtBasedTransaction) error {
-	c, err := tx.BatchUpdate(ctx, ru.statements)
+	c, err := tx.BatchUpdateWithOptions(ctx, ru.statements, ru.options)
 	if err != nil && spanner.ErrCode(err) == codes.Aborted {
 		return err
 	}
@@ -236,6 +318,9 @@ func (tx *readWriteTransaction) runWithRetry(ctx context.Context, f func(ctx con
 			return
 		}
 		if spanner.ErrCode(err) == codes.Aborted {
+			if err = tx.waitBeforeRetry(ctx, err); err != nil {
+				return
+			}
 			err = tx.retry(ctx)
 			continue
 		}
@@ -243,10 +328,39 @@ func (tx *readWriteTransaction) runWithRetry(ctx context.Context, f func(ctx con
 	}
 }
 
+// waitBeforeRetry waits before the transaction is retried after the given
+// Aborted error. It waits for the retry delay that was returned by Spanner, or
+// for the next pause of the retry backoff of the transaction. It returns a
+// *RetryBudgetExceededError if the transaction may not be retried again, or if
+// the context is done before the transaction can be retried.
+func (tx *readWriteTransaction) waitBeforeRetry(ctx context.Context, abortedErr error) error {
+	attempts := tx.retries + 1
+	if tx.maxRetries > 0 && tx.retries >= tx.maxRetries {
+		return &RetryBudgetExceededError{Attempts: attempts, Err: abortedErr}
+	}
+	delay, ok := spanner.ExtractRetryDelay(abortedErr)
+	if !ok && tx.retryBackoff != nil {
+		delay = tx.retryBackoff.Pause()
+	}
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return &RetryBudgetExceededError{Attempts: attempts, Err: abortedErr}
+		case <-time.After(delay):
+		}
+	}
+	tx.retries++
+	return nil
+}
+
 // retry retries the entire read/write transaction on a new Spanner transaction.
 // It will return ErrAbortedDueToConcurrentModification if the retry fails.
 func (tx *readWriteTransaction) retry(ctx context.Context) (err error) {
-	tx.rwTx, err = spanner.NewReadWriteStmtBasedTransaction(ctx, tx.client)
+	tx.retried = true
+	tx.metrics.addTransactionsRetried(ctx)
+	ctx, span := tx.startSpan(ctx, spanRetryTransaction)
+	defer func() { tx.endSpan(span, err) }()
+	tx.rwTx, err = spanner.NewReadWriteStmtBasedTransactionWithOptions(ctx, tx.client, tx.options)
 	if err != nil {
 		return err
 	}
@@ -256,6 +370,11 @@ func (tx *readWriteTransaction) retry(ctx context.Context) (err error) {
 			return err
 		}
 	}
+	// Mutations are buffered in the Spanner transaction, and must be buffered
+	// again in the new transaction.
+	if len(tx.mutations) > 0 {
+		err = tx.rwTx.BufferWrite(tx.mutations)
+	}
 
 	return err
 }
@@ -265,18 +384,33 @@ func (tx *readWriteTransaction) retry(ctx context.Context) (err error) {
 // aborted by Spanner, the entire transaction will automatically be retried,
 // unless internal retries have been disabled.
 func (tx *readWriteTransaction) Commit() (err error) {
+	ctx, span := tx.startSpan(tx.ctx, spanCommit)
+	defer func() {
+		if err == nil {
+			tx.metrics.addTransactionsCommitted(ctx)
+		}
+		tx.endSpan(span, err)
+	}()
 	var commitTs time.Time
 	if tx.rwTx != nil {
 		if !tx.retryAborts {
-			ts, err := tx.rwTx.Commit(tx.ctx)
+			if err := tx.checkNotAborted(); err != nil {
+				// The Spanner transaction must still be ended to return
+				// the session to the pool.
+				tx.rwTx.Rollback(ctx)
+				tx.close(nil, err)
+				return err
+			}
+			ts, err := tx.rwTx.Commit(ctx)
 			tx.close(&ts, err)
-			return err
+			return tooManyMutationsInTransactionError(err)
 		}
 
-		err = tx.runWithRetry(tx.ctx, func(ctx context.Context) (err error) {
+		err = tx.runWithRetry(ctx, func(ctx context.Context) (err error) {
 			commitTs, err = tx.rwTx.Commit(ctx)
 			return err
 		})
+		err = tooManyMutationsInTransactionError(err)
 	}
 	tx.close(&commitTs, err)
 	return err
@@ -285,8 +419,11 @@ func (tx *readWriteTransaction) Commit() (err error) {
 // Rollback implements driver.Tx#Rollback(). The underlying Spanner transaction
 // will be rolled back and the session will be returned to the session pool.
 func (tx *readWriteTransaction) Rollback() error {
+	ctx, span := tx.startSpan(tx.ctx, spanRollback)
+	defer tx.endSpan(span, nil)
+	tx.metrics.addTransactionsRolledBack(ctx)
 	if tx.rwTx != nil {
-		tx.rwTx.Rollback(tx.ctx)
+		tx.rwTx.Rollback(ctx)
 	}
 	tx.close(nil, nil)
 	return nil
@@ -295,21 +432,48 @@ func (tx *readWriteTransaction) Rollback() error {
 // Query executes a query using the read/write transaction and returns a
 // rowIterator that will automatically retry the read/write transaction if the
 // transaction is aborted during the query or while iterating the returned rows.
-func (tx *readWriteTransaction) Query(ctx context.Context, stmt spanner.Statement) rowIterator {
+func (tx *readWriteTransaction) Query(ctx context.Context, stmt spanner.Statement, options spanner.QueryOptions) rowIterator {
 	// If internal retries have been disabled, we don't need to keep track of a
 	// running checksum for all results that we have seen.
 	if !tx.retryAborts {
-		return &readOnlyRowIterator{tx.rwTx.Query(ctx, stmt)}
+		if err := tx.checkNotAborted(); err != nil {
+			return &noRetryRowIterator{tx: tx, err: err}
+		}
+		return &noRetryRowIterator{RowIterator: tx.rwTx.QueryWithOptions(ctx, stmt, options), tx: tx}
 	}
 
 	// If retries are enabled, we need to use a row iterator that will keep
 	// track of a running checksum of all the results that we see.
 	buffer := &bytes.Buffer{}
 	it := &checksumRowIterator{
-		RowIterator: tx.rwTx.Query(ctx, stmt),
+		RowIterator: tx.rwTx.QueryWithOptions(ctx, stmt, options),
 		ctx:         ctx,
 		tx:          tx,
 		stmt:        stmt,
+		options:     options,
+		buffer:      buffer,
+		enc:         gob.NewEncoder(buffer),
+	}
+	tx.statements = append(tx.statements, it)
+	return it
+}
+
+// Read executes a read using the read/write transaction. The returned
+// rowIterator will automatically retry the read/write transaction if the
+// transaction is aborted during the read or while iterating the returned rows.
+func (tx *readWriteTransaction) Read(ctx context.Context, req readRequest) rowIterator {
+	if !tx.retryAborts {
+		if err := tx.checkNotAborted(); err != nil {
+			return &noRetryRowIterator{tx: tx, err: err}
+		}
+		return &noRetryRowIterator{RowIterator: req.execute(ctx, tx.rwTx), tx: tx}
+	}
+	buffer := &bytes.Buffer{}
+	it := &checksumRowIterator{
+		RowIterator: req.execute(ctx, tx.rwTx),
+		ctx:         ctx,
+		tx:          tx,
+		read:        &req,
 		buffer:      buffer,
 		enc:         gob.NewEncoder(buffer),
 	}
@@ -317,24 +481,29 @@ func (tx *readWriteTransaction) Query(ctx context.Context, stmt spanner.Statemen
 	return it
 }
 
-func (tx *readWriteTransaction) ExecContext(ctx context.Context, stmt spanner.Statement) (res int64, err error) {
+func (tx *readWriteTransaction) ExecContext(ctx context.Context, stmt spanner.Statement, options spanner.QueryOptions) (res int64, err error) {
 	if tx.batch != nil {
 		tx.batch.statements = append(tx.batch.statements, stmt)
 		return 0, nil
 	}
 
 	if !tx.retryAborts {
-		return tx.rwTx.Update(ctx, stmt)
+		if err := tx.checkNotAborted(); err != nil {
+			return 0, err
+		}
+		res, err = tx.rwTx.UpdateWithOptions(ctx, stmt, options)
+		return res, tx.markAborted(err)
 	}
 
 	err = tx.runWithRetry(ctx, func(ctx context.Context) error {
-		res, err = tx.rwTx.Update(ctx, stmt)
+		res, err = tx.rwTx.UpdateWithOptions(ctx, stmt, options)
 		return err
 	})
 	tx.statements = append(tx.statements, &retriableUpdate{
-		stmt: stmt,
-		c:    res,
-		err:  err,
+		stmt:    stmt,
+		options: options,
+		c:       res,
+		err:     err,
 	})
 	return res, err
 }
@@ -347,13 +516,13 @@ func (tx *readWriteTransaction) StartBatchDML() (driver.Result, error) {
 	return driver.ResultNoRows, nil
 }
 
-func (tx *readWriteTransaction) RunBatch(ctx context.Context) (driver.Result, error) {
+func (tx *readWriteTransaction) RunBatch(ctx context.Context, options spanner.QueryOptions) (driver.Result, error) {
 	if tx.batch == nil {
 		return nil, spanner.ToSpannerError(status.Errorf(codes.FailedPrecondition, "This transaction does not have an active batch"))
 	}
 	switch tx.batch.tp {
 	case dml:
-		return tx.runDmlBatch(ctx)
+		return tx.runDmlBatch(ctx, options)
 	case ddl:
 		fallthrough
 	default:
@@ -366,31 +535,99 @@ func (tx *readWriteTransaction) AbortBatch() (driver.Result, error) {
 	return driver.ResultNoRows, nil
 }
 
-func (tx *readWriteTransaction) runDmlBatch(ctx context.Context) (driver.Result, error) {
+func (tx *readWriteTransaction) runDmlBatch(ctx context.Context, options spanner.QueryOptions) (driver.Result, error) {
 	statements := tx.batch.statements
 	tx.batch = nil
 
+	affected, err := tx.batchUpdate(ctx, statements, options)
+	return &result{rowsAffected: sum(affected)}, err
+}
+
+// batchUpdate executes the given statements as one Batch DML request on the
+// transaction.
+func (tx *readWriteTransaction) batchUpdate(ctx context.Context, statements []spanner.Statement, options spanner.QueryOptions) ([]int64, error) {
 	if !tx.retryAborts {
-		affected, err := tx.rwTx.BatchUpdate(ctx, statements)
-		return &result{rowsAffected: sum(affected)}, err
+		if err := tx.checkNotAborted(); err != nil {
+			return nil, err
+		}
+		affected, err := tx.rwTx.BatchUpdateWithOptions(ctx, statements, options)
+		return affected, tx.markAborted(err)
 	}
 
 	var affected []int64
 	var err error
 	err = tx.runWithRetry(ctx, func(ctx context.Context) error {
-		affected, err = tx.rwTx.BatchUpdate(ctx, statements)
+		affected, err = tx.rwTx.BatchUpdateWithOptions(ctx, statements, options)
 		return err
 	})
 	tx.statements = append(tx.statements, &retriableBatchUpdate{
 		statements: statements,
+		options:    options,
 		c:          affected,
 		err:        err,
 	})
-	return &result{rowsAffected: sum(affected)}, err
+	return affected, err
 }
 
 func (tx *readWriteTransaction) BufferWrite(ms []*spanner.Mutation) error {
-	return tx.rwTx.BufferWrite(ms)
+	if err := tx.checkNotAborted(); err != nil {
+		return err
+	}
+	if err := tx.rwTx.BufferWrite(ms); err != nil {
+		return err
+	}
+	tx.mutations = append(tx.mutations, ms...)
+	return nil
+}
+
+// markAborted marks the transaction as aborted if the given error is an
+// Aborted error. This is only used for transactions that are not retried
+// internally. The given error is returned unchanged.
+func (tx *readWriteTransaction) markAborted(err error) error {
+	if err != nil && tx.abortedErr == nil && spanner.ErrCode(err) == codes.Aborted {
+		tx.abortedErr = err
+	}
+	return err
+}
+
+// checkNotAborted returns an Aborted error if an earlier statement in the
+// transaction was aborted by Spanner.
+func (tx *readWriteTransaction) checkNotAborted() error {
+	if tx.abortedErr == nil {
+		return nil
+	}
+	return spanner.ToSpannerError(status.Errorf(codes.Aborted, "the transaction was aborted by Spanner and must be rolled back: %v", spanner.ErrDesc(tx.abortedErr)))
+}
+
+// noRetryRowIterator is the row iterator of a query in a read/write
+// transaction that is not retried internally. It marks the transaction as
+// aborted if the query is aborted by Spanner. The iterator only returns err
+// if err is set.
+type noRetryRowIterator struct {
+	*spanner.RowIterator
+	tx  *readWriteTransaction
+	err error
+}
+
+func (it *noRetryRowIterator) Next() (*spanner.Row, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+	row, err := it.RowIterator.Next()
+	return row, it.tx.markAborted(err)
+}
+
+func (it *noRetryRowIterator) Stop() {
+	if it.RowIterator != nil {
+		it.RowIterator.Stop()
+	}
+}
+
+func (it *noRetryRowIterator) Metadata() *sppb.ResultSetMetadata {
+	if it.RowIterator == nil {
+		return nil
+	}
+	return it.RowIterator.Metadata
 }
 
 // errorsEqualForRetry returns true if the two errors should be considered equal
@@ -0,0 +1,178 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VectorDistanceFunction is the function that is used to calculate the
+// distance between two vectors in a vector search.
+type VectorDistanceFunction int
+
+const (
+	// CosineDistance uses the COSINE_DISTANCE function. Smaller distances are
+	// more similar. This is the default.
+	CosineDistance VectorDistanceFunction = iota
+	// EuclideanDistance uses the EUCLIDEAN_DISTANCE function. Smaller
+	// distances are more similar.
+	EuclideanDistance
+	// DotProduct uses the DOT_PRODUCT function. Larger values are more
+	// similar.
+	DotProduct
+)
+
+func (f VectorDistanceFunction) String() string {
+	switch f {
+	case CosineDistance:
+		return "COSINE_DISTANCE"
+	case EuclideanDistance:
+		return "EUCLIDEAN_DISTANCE"
+	case DotProduct:
+		return "DOT_PRODUCT"
+	}
+	return fmt.Sprintf("VectorDistanceFunction(%d)", int(f))
+}
+
+// VectorSearchOptions contains the options for VectorSearch.
+type VectorSearchOptions struct {
+	// DistanceFunction is the function that is used to calculate the
+	// distance between the vectors. The default is CosineDistance.
+	DistanceFunction VectorDistanceFunction
+	// Columns are the columns that should be returned. All columns are
+	// returned if this is empty.
+	Columns []string
+
+	// Approximate indicates that the search should use an approximate
+	// nearest neighbor (ANN) search with the APPROX_ variant of the distance
+	// function. This requires a vector index on the column. Rows where the
+	// vector column is NULL are not returned by an approximate search.
+	Approximate bool
+	// Index is the name of the vector index that should be used for an
+	// approximate search. The index is selected by Spanner if this is empty.
+	Index string
+	// NumLeavesToSearch is the number of leaves of the vector index that
+	// should be searched in an approximate search. The default of Spanner is
+	// used if this is zero.
+	NumLeavesToSearch int
+
+	// Where is an additional SQL filter for the rows, for example
+	// `Category=@category`. The filter may only use named query parameters.
+	Where string
+	// Args are the arguments for the query parameters in Where. Use
+	// sql.Named to specify the name of each argument.
+	Args []interface{}
+}
+
+// Names of the query parameters that are used by VectorSearch.
+const (
+	vectorSearchVectorParam = "vectorSearchVector"
+	vectorSearchLimitParam  = "vectorSearchLimit"
+)
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// VectorSearch returns the k rows in the given table with the vectors in the
+// given column that are the nearest to the given vector. The rows are ordered
+// by distance, with the most similar row first. The returned rows contain the
+// selected columns, followed by a column named `distance` with the distance
+// between the vector of the row and the given vector.
+//
+// Example:
+//
+//	rows, err := spannerdriver.VectorSearch(ctx, db, "Documents", "Embedding",
+//		embedding, 10, spannerdriver.VectorSearchOptions{
+//			Columns: []string{"Id", "Title"},
+//			Where:   "Category=@category",
+//			Args:    []interface{}{sql.Named("category", "news")},
+//		})
+func VectorSearch(ctx context.Context, queryer Queryer, table, column string, vector []float32, k int, options VectorSearchOptions) (*sql.Rows, error) {
+	query, err := buildVectorSearchQuery(table, column, options)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "k must be positive, got %d", k))
+	}
+	args := append([]interface{}{
+		sql.Named(vectorSearchVectorParam, vector),
+		sql.Named(vectorSearchLimitParam, int64(k)),
+	}, options.Args...)
+	return queryer.QueryContext(ctx, query, args...)
+}
+
+// buildVectorSearchQuery returns the SQL string for a vector search.
+func buildVectorSearchQuery(table, column string, options VectorSearchOptions) (string, error) {
+	for _, identifier := range append([]string{table, column}, options.Columns...) {
+		if !identifierRegexp.MatchString(identifier) {
+			return "", spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid identifier: %q", identifier))
+		}
+	}
+	if options.Index != "" && !identifierRegexp.MatchString(options.Index) {
+		return "", spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "invalid index name: %q", options.Index))
+	}
+	distanceFunction := options.DistanceFunction.String()
+	switch options.DistanceFunction {
+	case CosineDistance, EuclideanDistance, DotProduct:
+	default:
+		return "", spanner.ToSpannerError(status.Errorf(codes.InvalidArgument, "unknown distance function: %v", options.DistanceFunction))
+	}
+
+	var distance string
+	if options.Approximate {
+		distance = fmt.Sprintf("APPROX_%s(%s, @%s", distanceFunction, column, vectorSearchVectorParam)
+		if options.NumLeavesToSearch > 0 {
+			distance += fmt.Sprintf(`, options => JSON '{"num_leaves_to_search": %d}'`, options.NumLeavesToSearch)
+		}
+		distance += ")"
+	} else {
+		distance = fmt.Sprintf("%s(%s, @%s)", distanceFunction, column, vectorSearchVectorParam)
+	}
+	columns := "*"
+	if len(options.Columns) > 0 {
+		columns = strings.Join(options.Columns, ", ")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s, %s AS distance FROM %s", columns, distance, table)
+	if options.Approximate && options.Index != "" {
+		fmt.Fprintf(&b, "@{FORCE_INDEX=%s}", options.Index)
+	}
+	var filters []string
+	if options.Approximate {
+		// Approximate searches require the vector column to be non-null.
+		filters = append(filters, column+" IS NOT NULL")
+	}
+	if options.Where != "" {
+		filters = append(filters, "("+options.Where+")")
+	}
+	if len(filters) > 0 {
+		fmt.Fprintf(&b, " WHERE %s", strings.Join(filters, " AND "))
+	}
+	order := ""
+	if options.DistanceFunction == DotProduct {
+		order = " DESC"
+	}
+	fmt.Fprintf(&b, " ORDER BY distance%s LIMIT @%s", order, vectorSearchLimitParam)
+	return b.String(), nil
+}
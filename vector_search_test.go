@@ -0,0 +1,149 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestBuildVectorSearchQuery(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name    string
+		options VectorSearchOptions
+		want    string
+		code    codes.Code
+	}{
+		{
+			name: "default",
+			want: "SELECT *, COSINE_DISTANCE(Embedding, @vectorSearchVector) AS distance FROM Documents ORDER BY distance LIMIT @vectorSearchLimit",
+		},
+		{
+			name: "columns and filter",
+			options: VectorSearchOptions{
+				DistanceFunction: EuclideanDistance,
+				Columns:          []string{"Id", "Title"},
+				Where:            "Category=@category",
+			},
+			want: "SELECT Id, Title, EUCLIDEAN_DISTANCE(Embedding, @vectorSearchVector) AS distance FROM Documents WHERE (Category=@category) ORDER BY distance LIMIT @vectorSearchLimit",
+		},
+		{
+			name:    "dot product",
+			options: VectorSearchOptions{DistanceFunction: DotProduct},
+			want:    "SELECT *, DOT_PRODUCT(Embedding, @vectorSearchVector) AS distance FROM Documents ORDER BY distance DESC LIMIT @vectorSearchLimit",
+		},
+		{
+			name: "approximate",
+			options: VectorSearchOptions{
+				Approximate:       true,
+				Index:             "DocumentsByEmbedding",
+				NumLeavesToSearch: 10,
+				Where:             "Category=@category",
+			},
+			want: `SELECT *, APPROX_COSINE_DISTANCE(Embedding, @vectorSearchVector, options => JSON '{"num_leaves_to_search": 10}') AS distance FROM Documents@{FORCE_INDEX=DocumentsByEmbedding} WHERE Embedding IS NOT NULL AND (Category=@category) ORDER BY distance LIMIT @vectorSearchLimit`,
+		},
+		{
+			name:    "invalid column",
+			options: VectorSearchOptions{Columns: []string{"Id; DROP TABLE Documents"}},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "invalid distance function",
+			options: VectorSearchOptions{DistanceFunction: VectorDistanceFunction(100)},
+			code:    codes.InvalidArgument,
+		},
+	} {
+		query, err := buildVectorSearchQuery("Documents", "Embedding", test.options)
+		if g, w := spanner.ErrCode(err), test.code; g != w {
+			t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, g, w)
+			continue
+		}
+		if g, w := query, test.want; g != w {
+			t.Errorf("%s: query mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
+
+func TestVectorSearch(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestDBConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	query := "SELECT Id, COSINE_DISTANCE(Embedding, @vectorSearchVector) AS distance FROM Documents WHERE (Category=@category) ORDER BY distance LIMIT @vectorSearchLimit"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1, 0}, {2, 1}}, [2]string{"Id", "distance"}),
+	})
+
+	rows, err := VectorSearch(ctx, db, "Documents", "Embedding", []float32{0.1, 0.2}, 2, VectorSearchOptions{
+		Columns: []string{"Id"},
+		Where:   "Category=@category",
+		Args:    []interface{}{sql.Named("category", "news")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id, distance int64
+		if err := rows.Scan(&id, &distance); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if g, w := ids, []int64{1, 2}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("ids mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	requests := requestsOfType(drainRequestsFromServer(server.TestSpanner), reflect.TypeOf(&sppb.ExecuteSqlRequest{}))
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := requests[0].(*sppb.ExecuteSqlRequest)
+	if g, w := len(req.Params.Fields), 3; g != w {
+		t.Fatalf("param count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(req.Params.Fields[vectorSearchVectorParam].GetListValue().GetValues()), 2; g != w {
+		t.Fatalf("vector length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.ParamTypes[vectorSearchVectorParam].GetArrayElementType().GetCode(), sppb.TypeCode_FLOAT32; g != w {
+		t.Fatalf("vector element type mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields[vectorSearchLimitParam].GetStringValue(), "2"; g != w {
+		t.Fatalf("limit mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := req.Params.Fields["category"].GetStringValue(), "news"; g != w {
+		t.Fatalf("category mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if _, err := VectorSearch(ctx, db, "Documents", "Embedding", []float32{0.1}, 0, VectorSearchOptions{}); spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", spanner.ErrCode(err), codes.InvalidArgument)
+	}
+}